@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestRunUsageErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"unknown command", []string{"bogus"}},
+		{"migrate without action", []string{"migrate"}},
+		{"unknown migrate action", []string{"migrate", "sideways"}},
+		{"migrate up with arguments", []string{"migrate", "up", "3"}},
+		{"migrate down with bad count", []string{"migrate", "down", "zero"}},
+		{"migrate down with negative count", []string{"migrate", "down", "-1"}},
+		{"seed with unknown flag", []string{"seed", "--bogus"}},
+		{"fake-receiver with positional argument", []string{"fake-receiver", "now"}},
+		{"serve with unknown flag", []string{"serve", "--bogus"}},
+		{"serve with positional argument", []string{"serve", "now"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := run(tt.args); code != exitUsage {
+				t.Errorf("Expected exit code %d, got %d", exitUsage, code)
+			}
+		})
+	}
+}
+
+func TestParseSteps(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    int
+		wantErr bool
+	}{
+		{"default", nil, 1, false},
+		{"explicit", []string{"3"}, 3, false},
+		{"zero", []string{"0"}, 0, true},
+		{"not a number", []string{"all"}, 0, true},
+		{"too many", []string{"1", "2"}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSteps(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %d steps, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseServeFlags(t *testing.T) {
+	t.Setenv("AUTO_MIGRATE", "false")
+	t.Setenv("AUTO_SEED", "not-a-bool")
+	t.Setenv("REQUIRE_CURRENT_SCHEMA", "")
+	t.Setenv("CHALLENGE_JOB", "0")
+
+	opts, err := parseServeFlags(nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opts.migrate {
+		t.Error("Expected AUTO_MIGRATE=false to disable migrations")
+	}
+	if !opts.seed {
+		t.Error("Expected an invalid AUTO_SEED to fall back to the default")
+	}
+	if opts.requireCurrentSchema {
+		t.Error("Expected require-current-schema to default to false")
+	}
+	if !opts.nudges || !opts.attribution || !opts.notifications {
+		t.Errorf("Expected background jobs to default to on, got %+v", *opts)
+	}
+	if opts.challenges {
+		t.Error("Expected CHALLENGE_JOB=0 to disable the challenge job")
+	}
+
+	opts, err = parseServeFlags([]string{"-migrate", "-require-current-schema"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !opts.migrate || !opts.requireCurrentSchema {
+		t.Errorf("Expected flags to override the environment, got %+v", *opts)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/migrate"
+)
+
+// migrateCommand runs "migrate up|down [n]|status|redo"
+func migrateCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return exitUsage
+	}
+
+	action, rest := args[0], args[1:]
+	steps := 1
+	switch action {
+	case "up", "status", "redo":
+		if len(rest) > 0 {
+			fmt.Fprintf(os.Stderr, "migrate %s takes no arguments\n", action)
+			return exitUsage
+		}
+	case "down":
+		n, err := parseSteps(rest)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitUsage
+		}
+		steps = n
+	default:
+		fmt.Fprintf(os.Stderr, "unknown migrate action %q\n\n%s", action, usage)
+		return exitUsage
+	}
+
+	closeDB, err := connect()
+	if err != nil {
+		slog.Error("Failed to connect to database", "error", err)
+		return exitError
+	}
+	defer closeDB()
+
+	ctx := context.Background()
+	switch action {
+	case "up":
+		err = migrateUp(ctx)
+	case "down":
+		err = migrateDown(ctx, steps)
+	case "status":
+		err = migrateStatus(ctx)
+	case "redo":
+		err = migrateRedo(ctx)
+	}
+	if err != nil {
+		slog.Error("Migration failed", "action", action, "error", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// parseSteps parses the optional step count for migrate down
+func parseSteps(args []string) (int, error) {
+	switch len(args) {
+	case 0:
+		return 1, nil
+	case 1:
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 {
+			return 0, fmt.Errorf("invalid step count %q: must be a positive integer", args[0])
+		}
+		return n, nil
+	default:
+		return 0, errors.New("migrate down takes at most one argument")
+	}
+}
+
+// newMigrator returns a migrator for the connected database
+func newMigrator() (*migrate.Migrator, error) {
+	sqlDB, err := database.DB.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get underlying sql.DB: %w", err)
+	}
+	return database.NewMigrator(sqlDB)
+}
+
+func migrateUp(ctx context.Context) error {
+	applied, err := database.RunMigrations(ctx)
+	for _, m := range applied {
+		fmt.Printf("applied  %04d_%s\n", m.Version, m.Name)
+	}
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		fmt.Println("schema is up to date")
+	}
+	return nil
+}
+
+func migrateDown(ctx context.Context, steps int) error {
+	migrator, err := newMigrator()
+	if err != nil {
+		return err
+	}
+
+	rolledBack, err := migrator.Down(ctx, steps)
+	for _, m := range rolledBack {
+		fmt.Printf("reverted %04d_%s\n", m.Version, m.Name)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to roll back: %w", err)
+	}
+	if len(rolledBack) == 0 {
+		fmt.Println("no migrations to roll back")
+	}
+	return nil
+}
+
+func migrateRedo(ctx context.Context) error {
+	migrator, err := newMigrator()
+	if err != nil {
+		return err
+	}
+
+	m, err := migrator.Redo(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to redo: %w", err)
+	}
+	fmt.Printf("redone   %04d_%s\n", m.Version, m.Name)
+	return nil
+}
+
+func migrateStatus(ctx context.Context) error {
+	migrator, err := newMigrator()
+	if err != nil {
+		return err
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read migration status: %w", err)
+	}
+	return writeStatus(os.Stdout, statuses)
+}
+
+// writeStatus prints a table of migration statuses
+func writeStatus(out io.Writer, statuses []migrate.Status) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, s := range statuses {
+		appliedAt := "-"
+		if s.AppliedAt != nil {
+			appliedAt = s.AppliedAt.Local().Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", s.Version, s.Name, statusLabel(s), appliedAt)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write status: %w", err)
+	}
+	return nil
+}
+
+// statusLabel summarizes a migration status
+func statusLabel(s migrate.Status) string {
+	switch {
+	case s.Unknown:
+		return "unknown (not in this binary)"
+	case s.Modified:
+		return "applied, MODIFIED since"
+	case s.Applied:
+		return "applied"
+	default:
+		return "pending"
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/notify/notifytest"
+)
+
+// receiverReadTimeout bounds how long the fake receiver waits for headers
+const receiverReadTimeout = 10 * time.Second
+
+// fakeReceiverCommand runs "fake-receiver [-addr host:port]", a local stand-in
+// for the Expo push API and notification webhooks. Point EXPO_PUSH_URL at
+// its push path and NOTIFY_WEBHOOK_URL at any other path.
+func fakeReceiverCommand(args []string) int {
+	fs := flag.NewFlagSet("fake-receiver", flag.ContinueOnError)
+	addr := fs.String("addr", "localhost:8090", "address to listen on")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "unexpected arguments: %v\n", fs.Args())
+		return exitUsage
+	}
+
+	slog.Info("Fake notification receiver listening",
+		"push_url", "http://"+*addr+notifytest.PushPath,
+		"webhook_url", "http://"+*addr+"/webhook")
+	server := &http.Server{Addr: *addr, Handler: notifytest.NewReceiver(), ReadHeaderTimeout: receiverReadTimeout}
+	if err := server.ListenAndServe(); err != nil {
+		slog.Error("Fake receiver stopped", "error", err)
+		return exitError
+	}
+	return exitOK
+}
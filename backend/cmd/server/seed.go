@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/seed"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+)
+
+// seedCommand runs "seed [-builtin=false] [-v] [pack ...]", upserting the
+// built-in seed data followed by any packs given as files or directories
+func seedCommand(args []string) int {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	builtin := fs.Bool("builtin", true, "apply the built-in seed data before the given packs")
+	verbose := fs.Bool("v", false, "list unchanged entries too")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	packs, err := loadPacks(*builtin, fs.Args())
+	if err != nil {
+		slog.Error("Failed to load seed data", "error", err)
+		return exitError
+	}
+
+	closeDB, err := connect()
+	if err != nil {
+		slog.Error("Failed to connect to database", "error", err)
+		return exitError
+	}
+	defer closeDB()
+
+	report, err := seed.Apply(context.Background(), store.NewPostgres(database.DB), packs...)
+	if err != nil {
+		slog.Error("Failed to seed data", "error", err)
+		return exitError
+	}
+	if err := writeSeedReport(os.Stdout, report, *verbose); err != nil {
+		slog.Error("Failed to write seed report", "error", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// loadPacks returns the built-in packs, when requested, followed by the packs at paths
+func loadPacks(builtin bool, paths []string) ([]*seed.Pack, error) {
+	var packs []*seed.Pack
+	if builtin {
+		builtinPacks, err := seed.Builtin()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load built-in seed data: %w", err)
+		}
+		packs = append(packs, builtinPacks...)
+	}
+
+	custom, err := seed.Load(paths...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load seed packs: %w", err)
+	}
+	return append(packs, custom...), nil
+}
+
+// writeSeedReport prints the entries seeding created or updated, and
+// unchanged ones when verbose, followed by a summary
+func writeSeedReport(out io.Writer, report *seed.Report, verbose bool) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, c := range report.Changes {
+		if c.Outcome != seed.Unchanged || verbose {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", c.Outcome, c.Kind, c.Name)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write seed report: %w", err)
+	}
+
+	_, err := fmt.Fprintf(out, "%d created, %d updated, %d unchanged\n",
+		report.Count(seed.Created), report.Count(seed.Updated), report.Count(seed.Unchanged))
+	if err != nil {
+		return fmt.Errorf("failed to write seed report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,549 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"github.com/lucas-albers-lz4/ferrovis/internal/achievements"
+	"github.com/lucas-albers-lz4/ferrovis/internal/attribution"
+	"github.com/lucas-albers-lz4/ferrovis/internal/auth"
+	"github.com/lucas-albers-lz4/ferrovis/internal/challenges"
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/env"
+	"github.com/lucas-albers-lz4/ferrovis/internal/handlers"
+	"github.com/lucas-albers-lz4/ferrovis/internal/mail"
+	"github.com/lucas-albers-lz4/ferrovis/internal/middleware"
+	"github.com/lucas-albers-lz4/ferrovis/internal/notify"
+	"github.com/lucas-albers-lz4/ferrovis/internal/nudge"
+	"github.com/lucas-albers-lz4/ferrovis/internal/realtime"
+	"github.com/lucas-albers-lz4/ferrovis/internal/seed"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+	"github.com/lucas-albers-lz4/ferrovis/internal/streaks"
+	"github.com/lucas-albers-lz4/ferrovis/internal/weasel"
+)
+
+// serveOptions control what the server does before accepting requests
+type serveOptions struct {
+	migrate              bool
+	seed                 bool
+	requireCurrentSchema bool
+	nudges               bool
+	attribution          bool
+	notifications        bool
+	challenges           bool
+}
+
+// parseServeFlags parses serve's flags; defaults come from the environment
+// so containers can be configured without changing their command
+func parseServeFlags(args []string) (*serveOptions, error) {
+	opts := &serveOptions{}
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.BoolVar(&opts.migrate, "migrate", env.Bool("AUTO_MIGRATE", true),
+		"apply pending migrations before serving (env AUTO_MIGRATE)")
+	fs.BoolVar(&opts.seed, "seed", env.Bool("AUTO_SEED", true),
+		"upsert the built-in seed data (env AUTO_SEED)")
+	fs.BoolVar(&opts.requireCurrentSchema, "require-current-schema", env.Bool("REQUIRE_CURRENT_SCHEMA", false),
+		"refuse to start while migrations are pending (env REQUIRE_CURRENT_SCHEMA)")
+	fs.BoolVar(&opts.nudges, "nudges", env.Bool("NUDGE_SCHEDULER", true),
+		"send scheduled Weasel nudges; enable on one instance only (env NUDGE_SCHEDULER)")
+	fs.BoolVar(&opts.attribution, "attribution", env.Bool("ATTRIBUTION_JOB", true),
+		"credit Weasel messages with the workouts they triggered; enable on one instance only (env ATTRIBUTION_JOB)")
+	fs.BoolVar(&opts.notifications, "notifications", env.Bool("NOTIFY_WORKER", true),
+		"deliver queued notifications (env NOTIFY_WORKER)")
+	fs.BoolVar(&opts.challenges, "challenges", env.Bool("CHALLENGE_JOB", true),
+		"complete ended group challenges; enable on one instance only (env CHALLENGE_JOB)")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, fmt.Errorf("invalid serve arguments: %w", err)
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+	return opts, nil
+}
+
+// serve runs the API server
+func serve(args []string) int {
+	opts, err := parseServeFlags(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+
+	// Log version information
+	slog.Info("Starting Ferrovis API server",
+		"version", version,
+		"commit", commit,
+		"build_date", date)
+
+	closeDB, err := connect()
+	if err != nil {
+		slog.Error("Failed to connect to database", "error", err)
+		return exitError
+	}
+	defer closeDB()
+
+	// Background jobs and open requests stop when the server is told to shut down
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if opts.migrate {
+		if _, err := database.RunMigrations(ctx); err != nil {
+			slog.Error("Failed to run migrations", "error", err)
+			return exitError
+		}
+	}
+	if opts.requireCurrentSchema {
+		if err := checkSchemaCurrent(ctx); err != nil {
+			slog.Error("Refusing to start", "error", err)
+			return exitError
+		}
+	}
+	// Set Gin mode
+	if os.Getenv("GIN_MODE") == "release" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	// Outgoing mail
+	mailer, err := mail.New(mail.LoadConfig())
+	if err != nil {
+		slog.Error("Failed to configure mailer", "error", err)
+		return exitError
+	}
+
+	app, err := newApplication(mailer, env.String("APP_URL", defaultAppURL))
+	if err != nil {
+		slog.Error("Failed to initialize application", "error", err)
+		return exitError
+	}
+	if opts.seed {
+		// Seed data is a convenience; failing to apply it must not keep the API down
+		if err := seedBuiltin(ctx, app.store); err != nil {
+			slog.Warn("Failed to seed initial data", "error", err)
+		}
+	}
+
+	var jobs sync.WaitGroup
+	run := func(job func(context.Context)) {
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			job(ctx)
+		}()
+	}
+	if opts.nudges {
+		run(nudge.NewScheduler(app.store, app.weasel, nudge.LoadConfig()).Run)
+	}
+	if opts.attribution {
+		run(attribution.NewJob(app.store, app.attribution).Run)
+	}
+	if opts.notifications {
+		run(notify.NewWorker(app.store, app.notifiers, app.notify).Run)
+	}
+	if opts.challenges {
+		run(challenges.NewJob(app.store, app.achievements, app.broker, challenges.LoadConfig()).Run)
+	}
+	// Every instance listens so its own subscribers receive shared events
+	if pg, ok := app.broker.(*realtime.PGBroker); ok {
+		run(pg.Run)
+	}
+	// Jobs may be mid-transaction; let them finish before the database closes
+	defer jobs.Wait()
+
+	// Start server
+	port := env.String("PORT", defaultPort)
+	srv := &http.Server{
+		Addr:              ":" + port,
+		Handler:           app.routes(),
+		ReadHeaderTimeout: readHeaderTimeout,
+		// Cancelling request contexts on shutdown ends long-lived event streams
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	shutdown := make(chan struct{})
+	go func() {
+		defer close(shutdown)
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			slog.Warn("Server did not shut down cleanly", "error", err)
+		}
+	}()
+
+	slog.Info("Starting Ferrovis API server", "port", port)
+	code := exitOK
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("Failed to start server", "error", err)
+		code = exitError
+		stop()
+	}
+	<-shutdown
+	slog.Info("Server stopped")
+	return code
+}
+
+// seedBuiltin applies the built-in seed data and logs what changed
+func seedBuiltin(ctx context.Context, s store.Store) error {
+	packs, err := seed.Builtin()
+	if err != nil {
+		return fmt.Errorf("failed to load built-in seed data: %w", err)
+	}
+	report, err := seed.Apply(ctx, s, packs...)
+	if err != nil {
+		return fmt.Errorf("failed to apply built-in seed data: %w", err)
+	}
+	slog.Info("Seed data applied",
+		"created", report.Count(seed.Created),
+		"updated", report.Count(seed.Updated),
+		"unchanged", report.Count(seed.Unchanged))
+	return nil
+}
+
+// checkSchemaCurrent returns an error when migrations are pending
+func checkSchemaCurrent(ctx context.Context) error {
+	migrator, err := newMigrator()
+	if err != nil {
+		return err
+	}
+
+	pending, err := migrator.Pending(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check migrations: %w", err)
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("database schema is behind: %d pending migration(s), first %d_%s; run \"ferrovis migrate up\"",
+			len(pending), pending[0].Version, pending[0].Name)
+	}
+	return nil
+}
+
+// application holds the dependencies shared by the HTTP handlers
+type application struct {
+	store        store.Store
+	sqlDB        *sql.DB
+	tokens       *auth.TokenManager
+	mailer       mail.Mailer
+	appURL       string
+	streaks      *streaks.Service
+	achievements *achievements.Engine
+	weasel       *weasel.Engine
+	experiments  []weasel.Experiment
+	attribution  *attribution.Config
+	notify       *notify.Config
+	notifiers    map[string]notify.Notifier
+	realtime     *realtime.Config
+	broker       realtime.Broker
+}
+
+// newApplication wires the application to the connected database
+func newApplication(mailer mail.Mailer, appURL string) (*application, error) {
+	sqlDB, err := database.DB.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get underlying sql.DB: %w", err)
+	}
+	templates, err := weasel.Builtin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load weasel templates: %w", err)
+	}
+	experiments, err := weasel.LoadExperiments(os.Getenv("WEASEL_EXPERIMENTS"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load weasel experiments: %w", err)
+	}
+	notifyConfig := notify.LoadConfig()
+	notifiers, err := notify.Notifiers(notifyConfig, mailer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure notifications: %w", err)
+	}
+	realtimeConfig := realtime.LoadConfig()
+	broker, err := newBroker(realtimeConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	s := store.NewPostgres(database.DB)
+	return &application{
+		store:        s,
+		sqlDB:        sqlDB,
+		tokens:       auth.NewTokenManager(auth.LoadConfig()),
+		mailer:       mailer,
+		appURL:       appURL,
+		streaks:      streaks.NewService(s, streaks.LoadConfig()),
+		achievements: achievements.NewEngine(s),
+		weasel:       weasel.NewEngine(s, templates, experiments, notify.NewOutbox(notifyConfig.Channels), broker, rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))), //nolint:gosec // message variety, not security
+		experiments:  experiments,
+		attribution:  attribution.LoadConfig(),
+		notify:       notifyConfig,
+		notifiers:    notifiers,
+		realtime:     realtimeConfig,
+		broker:       broker,
+	}, nil
+}
+
+// newBroker creates the event broker selected by cfg.Broker
+func newBroker(cfg *realtime.Config) (realtime.Broker, error) {
+	switch cfg.Broker {
+	case realtime.BrokerMemory:
+		return realtime.NewHub(), nil
+	case realtime.BrokerPostgres:
+		return realtime.NewPGBroker(database.DB, database.LoadConfig().DSN()), nil
+	default:
+		return nil, fmt.Errorf("unknown realtime broker %q", cfg.Broker)
+	}
+}
+
+// routes builds the HTTP router with every route registered
+func (app *application) routes() *gin.Engine {
+	authHandler := handlers.NewAuthHandler(app.store, app.tokens, app.mailer, app.appURL)
+	userHandler := handlers.NewUserHandler(app.store)
+	workoutHandler := handlers.NewWorkoutHandler(app.store, app.streaks, app.achievements, app.broker)
+	streakHandler := handlers.NewStreakHandler(app.streaks, app.achievements)
+	weaselHandler := handlers.NewWeaselHandler(app.store, app.weasel, app.attribution.Window)
+	experimentHandler := handlers.NewExperimentHandler(app.store, app.experiments, app.attribution.Window)
+	eventsHandler := handlers.NewEventsHandler(app.broker, app.realtime.Heartbeat)
+	programHandler := handlers.NewProgramHandler(app.store)
+	enrollmentHandler := handlers.NewEnrollmentHandler(app.store, weekDuration)
+	buddyHandler := handlers.NewBuddyHandler(app.store, app.mailer, app.appURL)
+	coachHandler := handlers.NewCoachHandler(app.store, app.weasel, weekDuration)
+	challengeHandler := handlers.NewChallengeHandler(app.store)
+
+	// Initialize router
+	r := gin.Default()
+
+	// CORS middleware
+	r.Use(cors.New(cors.Config{
+		AllowOrigins:     []string{"*"}, // Configure appropriately for production
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"*"},
+		AllowCredentials: true,
+	}))
+
+	// Health check endpoint
+	r.GET("/health", func(c *gin.Context) {
+		c.JSON(statusOK, gin.H{
+			"status":     "ok",
+			"message":    "Ferrovis API is running",
+			"version":    version,
+			"commit":     commit,
+			"build_date": date,
+		})
+	})
+
+	// Version endpoint
+	r.GET("/version", func(c *gin.Context) {
+		c.JSON(statusOK, gin.H{
+			"version":    version,
+			"commit":     commit,
+			"build_date": date,
+			"service":    "ferrovis-api",
+		})
+	})
+
+	// Database test endpoint
+	r.GET("/db-test", func(c *gin.Context) {
+		// Test database connection
+		sqlDB := app.sqlDB
+		if err := sqlDB.PingContext(c.Request.Context()); err != nil {
+			c.JSON(statusInternalServerError, gin.H{
+				"status":  "error",
+				"message": "Database ping failed",
+				"error":   err.Error(),
+			})
+			return
+		}
+
+		// Get database stats
+		stats := sqlDB.Stats()
+		c.JSON(statusOK, gin.H{
+			"status":  "ok",
+			"message": "Database connection is healthy",
+			"database": gin.H{
+				"open_connections": stats.OpenConnections,
+				"in_use":           stats.InUse,
+				"idle":             stats.Idle,
+			},
+		})
+	})
+
+	// Weasel mode schema showcase endpoint
+	r.GET("/schema-test", func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		// The showcase is best effort: missing data is reported as zero values
+		programCount, _ := app.store.Programs().Count(ctx)         //nolint:errcheck // best-effort showcase
+		exerciseCount, _ := app.store.Exercises().Count(ctx)       //nolint:errcheck // best-effort showcase
+		achievementCount, _ := app.store.Achievements().Count(ctx) //nolint:errcheck // best-effort showcase
+		fakeActivityCount, _ := app.store.Activities().Count(ctx)  //nolint:errcheck // best-effort showcase
+
+		// Get a sample achievement with weasel features
+		var sampleAchievement database.Achievement
+		achievements, _ := app.store.Achievements().List(ctx) //nolint:errcheck // best-effort showcase
+		for _, a := range achievements {
+			if a.IsFakeAchievement {
+				sampleAchievement = a
+				break
+			}
+		}
+
+		// Get sample fake social activity
+		var sampleFakeActivity database.FakeSocialActivity
+		if activities, _ := app.store.Activities().Recent(ctx, 1); len(activities) > 0 { //nolint:errcheck // best-effort showcase
+			sampleFakeActivity = activities[0]
+		}
+
+		c.JSON(statusOK, gin.H{
+			"status":  "ok",
+			"message": "Ferrovis Weasel Mode™ Database Schema",
+			"weasel_features": gin.H{
+				"schema": gin.H{
+					"total_tables":  defaultTableCount,
+					"core_entities": []string{"users", "workouts", "achievements", "weasel_messages", "buddy_relationships"},
+					"weasel_tables": []string{"fake_social_activities", "streaks", "user_achievements"},
+				},
+				"seed_data": gin.H{
+					"workout_programs":       programCount,
+					"exercises":              exerciseCount,
+					"achievements":           achievementCount,
+					"fake_social_activities": fakeActivityCount,
+				},
+				"weasel_mode_examples": gin.H{
+					"fake_achievement": gin.H{
+						"name":           sampleAchievement.Name,
+						"description":    sampleAchievement.Description,
+						"weasel_message": sampleAchievement.WeaselMessage,
+						"rarity_percent": sampleAchievement.RarityPercent,
+					},
+					"fake_social_activity": gin.H{
+						"fake_user": sampleFakeActivity.FakeUserName,
+						"activity":  sampleFakeActivity.Details,
+						"type":      sampleFakeActivity.ActivityType,
+					},
+				},
+				"psychological_features": []string{
+					"Progress inflation algorithms",
+					"Variable reward messaging",
+					"Fake social pressure generation",
+					"Achievement rarity manipulation",
+					"Streak anxiety timers",
+					"Guilt trip message classification",
+				},
+			},
+		})
+	})
+
+	// API routes group
+	api := r.Group("/api")
+
+	// Auth routes
+	authRoutes := api.Group("/auth")
+	authRoutes.POST("/register", authHandler.Register)
+	authRoutes.POST("/login", authHandler.Login)
+	authRoutes.POST("/refresh", authHandler.Refresh)
+	authRoutes.POST("/logout", authHandler.Logout)
+	authRoutes.POST("/verify-email", authHandler.VerifyEmail)
+	authRoutes.POST("/password-reset/request", authHandler.RequestPasswordReset)
+	authRoutes.POST("/password-reset", authHandler.ResetPassword)
+
+	// Public program catalog
+	api.GET("/programs", programHandler.List)
+	api.GET("/programs/:id", programHandler.Get)
+
+	// Protected routes require a valid bearer token
+	protected := api.Group("", middleware.RequireAuth(app.tokens, middleware.StoreUserLoader(app.store)))
+
+	// Session routes
+	protected.POST("/auth/logout-all", authHandler.LogoutAll)
+	protected.POST("/auth/verify-email/request", authHandler.RequestEmailVerification)
+
+	// User routes
+	protected.GET("/user/profile", userHandler.GetProfile)
+	protected.PUT("/user/profile", userHandler.UpdateProfile)
+	protected.PATCH("/user/profile", userHandler.UpdateProfile)
+	protected.PUT("/user/push-token", userHandler.SetPushToken)
+	protected.DELETE("/user/push-token", userHandler.DeletePushToken)
+
+	// Workout routes
+	protected.POST("/workouts", workoutHandler.Create)
+	protected.GET("/workouts", workoutHandler.List)
+	protected.GET("/workouts/:id", workoutHandler.Get)
+	protected.PUT("/workouts/:id", workoutHandler.Update)
+	protected.DELETE("/workouts/:id", workoutHandler.Delete)
+
+	// Program routes that depend on the current user
+	protected.POST("/programs", programHandler.Create)
+	protected.GET("/programs/:id/next-workout", programHandler.NextWorkout)
+
+	// Enrollment routes
+	protected.POST("/enrollments", enrollmentHandler.Enroll)
+	protected.GET("/enrollments", enrollmentHandler.List)
+	protected.GET("/enrollments/current", enrollmentHandler.Current)
+	protected.POST("/enrollments/current/switch", enrollmentHandler.Switch)
+	protected.POST("/enrollments/current/pause", enrollmentHandler.Pause)
+	protected.POST("/enrollments/current/resume", enrollmentHandler.Resume)
+	protected.POST("/enrollments/current/abandon", enrollmentHandler.Abandon)
+
+	// Streak routes
+	protected.GET("/streaks", streakHandler.List)
+	protected.POST("/streaks/recompute", streakHandler.Recompute)
+
+	// Weasel Mode routes
+	protected.GET("/weasel/messages", weaselHandler.ListMessages)
+	protected.POST("/weasel/messages", weaselHandler.GenerateMessage)
+	protected.PATCH("/weasel/messages/:id", weaselHandler.UpdateMessage)
+
+	// Real-time events
+	protected.GET("/events", eventsHandler.Stream)
+	protected.GET("/events/ws", eventsHandler.WebSocket)
+
+	// Admin reports
+	admin := protected.Group("/admin", middleware.RequireAdmin())
+	admin.GET("/weasel/conversion", weaselHandler.Conversion)
+	admin.GET("/experiments", experimentHandler.List)
+	admin.GET("/experiments/:name/results", experimentHandler.Results)
+
+	// Buddy routes
+	protected.GET("/buddies", buddyHandler.List)
+	protected.GET("/buddies/compare", buddyHandler.Compare)
+	protected.POST("/buddies/invite", buddyHandler.Invite)
+	protected.POST("/buddies/invites/accept", buddyHandler.AcceptInvite)
+	protected.POST("/buddies/invites/decline", buddyHandler.DeclineInvite)
+	protected.DELETE("/buddies/invites/:id", buddyHandler.CancelInvite)
+	protected.POST("/buddies/:id/accept", buddyHandler.Accept)
+	protected.POST("/buddies/:id/decline", buddyHandler.Decline)
+	protected.POST("/buddies/:id/pause", buddyHandler.Pause)
+	protected.POST("/buddies/:id/resume", buddyHandler.Resume)
+	protected.DELETE("/buddies/:id", buddyHandler.Remove)
+
+	// Coach routes: a coach's clients and a client's coaches
+	protected.GET("/clients", coachHandler.Clients)
+	protected.GET("/clients/:id/workouts", coachHandler.ClientWorkouts)
+	protected.GET("/clients/:id/records", coachHandler.ClientRecords)
+	protected.POST("/clients/:id/program", coachHandler.AssignProgram)
+	protected.POST("/clients/:id/messages", coachHandler.SendMessage)
+	protected.GET("/coaches", coachHandler.Coaches)
+	protected.PUT("/coaches/:id/permissions/:permission", coachHandler.GrantPermission)
+	protected.DELETE("/coaches/:id/permissions/:permission", coachHandler.RevokePermission)
+
+	// Challenge routes: time-boxed group challenges between buddies
+	protected.GET("/challenges", challengeHandler.List)
+	protected.POST("/challenges", challengeHandler.Create)
+	protected.GET("/challenges/:id", challengeHandler.Get)
+	protected.POST("/challenges/:id/join", challengeHandler.Join)
+	protected.POST("/challenges/:id/decline", challengeHandler.Decline)
+
+	return r
+}
@@ -0,0 +1,285 @@
+package achievements
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+)
+
+var start = time.Date(2025, time.March, 3, 7, 0, 0, 0, time.UTC) // A Monday morning
+
+// workout returns a workout completed days after start, at the given hour,
+// with one completed set per weight
+func workout(days, hour int, exerciseID uint, name string, weights ...float64) database.Workout {
+	w := database.Workout{CompletedAt: start.AddDate(0, 0, days).Add(time.Duration(hour-start.Hour()) * time.Hour)}
+	for i, weight := range weights {
+		w.Sets = append(w.Sets, database.WorkoutSet{
+			ExerciseID: exerciseID,
+			Exercise:   database.Exercise{ID: exerciseID, Name: name},
+			SetIndex:   i,
+			Reps:       5,
+			Weight:     weight,
+			Unit:       database.UnitPounds,
+			Completed:  true,
+		})
+	}
+	return w
+}
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		name          string
+		raw           string
+		expectedError string
+	}{
+		{"total workouts", `{"metric": "total_workouts"}`, ""},
+		{"max weight", `{"metric": "max_weight", "exercise": "Deadlift", "unit": "kg"}`, ""},
+		{"streak", `{"metric": "streak", "streak_type": "weekly"}`, ""},
+		{"challenges won", `{"metric": "challenges_won"}`, ""},
+		{"missing metric", `{}`, "metric: required"},
+		{"unknown metric", `{"metric": "vibes"}`, `unknown metric "vibes"`},
+		{"unknown field", `{"metric": "total_workouts", "colour": "red"}`, "unknown field"},
+		{"max weight without exercise", `{"metric": "max_weight"}`, "exercise: required"},
+		{"bad unit", `{"metric": "max_weight", "exercise": "Squat", "unit": "stone"}`, "unit: must be lb or kg"},
+		{"negative gap", `{"metric": "consecutive_workouts", "max_gap_days": -1}`, "must not be negative"},
+		{"bad streak type", `{"metric": "streak", "streak_type": "daily"}`, "streak_type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseRule(tt.raw)
+			if tt.expectedError == "" {
+				if err != nil {
+					t.Errorf("Expected rule to parse, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+				t.Errorf("Expected error containing %q, got %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+// number gives workouts the IDs the store would, which tell records apart
+func number(workouts []database.Workout) {
+	for i := range workouts {
+		workouts[i].ID = uint(i + 1)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	history := []database.Workout{
+		workout(0, 7, 1, "Deadlift", 90),
+		workout(2, 13, 1, "Deadlift", 95, 90), // PR
+		workout(9, 18, 1, "Deadlift", 95),     // Week-long gap
+		workout(11, 7, 1, "Deadlift", 100),    // PR
+		workout(14, 23, 2, "Squat", 135),      // First squat sets the baseline
+	}
+	history[4].Sets = append(history[4].Sets, database.WorkoutSet{
+		ExerciseID: 1, Exercise: database.Exercise{ID: 1, Name: "Deadlift"}, Weight: 60, Unit: database.UnitKilograms,
+	}) // Heavier than any lb set, but not completed
+	number(history)
+
+	tests := []struct {
+		name     string
+		got      int
+		expected int
+	}{
+		{"consecutive with default gap", consecutiveWorkouts(history, 0, time.UTC), 3},
+		{"consecutive with long gap", consecutiveWorkouts(history, 7, time.UTC), 5},
+		{"max weight in lb", maxWeight(history, "deadlift", database.UnitPounds), 100},
+		{"max weight in kg", maxWeight(history, "Deadlift", database.UnitKilograms), 45},
+		{"personal records", personalRecords(history, ""), 2},
+		{"squat records", personalRecords(history, "Squat"), 0},
+		{"times of day", timeOfDayBuckets(history, time.UTC), 4},
+		{"times of day shifted", timeOfDayBuckets(history, time.FixedZone("UTC-8", -8*60*60)), 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.expected {
+				t.Errorf("Expected %d, got %d", tt.expected, tt.got)
+			}
+		})
+	}
+}
+
+func TestPersonalRecordsCountWorkouts(t *testing.T) {
+	both := func(days int, deadlift, squat float64) database.Workout {
+		w := workout(days, 7, 1, "Deadlift", deadlift)
+		w.Sets = append(w.Sets, workout(days, 7, 2, "Squat", squat).Sets...)
+		return w
+	}
+	history := []database.Workout{
+		both(0, 100, 100),
+		both(2, 105, 105), // Beats both exercises, one record
+		both(4, 110, 100), // Beats the deadlift only
+	}
+	number(history)
+
+	if got := personalRecords(history, ""); got != 2 {
+		t.Errorf("Expected each workout that beat a best to count once, got %d", got)
+	}
+	if got := personalRecords(history, "squat"); got != 1 {
+		t.Errorf("Expected one squat record, got %d", got)
+	}
+}
+
+// newTestStore returns a memory store with users 1 to 3, a Deadlift
+// exercise and the given achievements
+func newTestStore(t *testing.T, achievements ...database.Achievement) store.Store {
+	t.Helper()
+	ctx := context.Background()
+	s := store.NewMemory()
+	for i := 1; i <= 3; i++ {
+		if err := s.Users().Create(ctx, &database.User{Email: fmt.Sprintf("user%d@example.com", i)}); err != nil {
+			t.Fatalf("Failed to add user: %v", err)
+		}
+	}
+	if err := s.Exercises().Save(ctx, &database.Exercise{Name: "Deadlift"}); err != nil {
+		t.Fatalf("Failed to add exercise: %v", err)
+	}
+	for i := range achievements {
+		if err := s.Achievements().Save(ctx, &achievements[i]); err != nil {
+			t.Fatalf("Failed to add achievement: %v", err)
+		}
+	}
+	return s
+}
+
+func rule(raw string) *string {
+	return &raw
+}
+
+func logWorkout(t *testing.T, s store.Store, userID uint, w database.Workout) {
+	t.Helper()
+	w.UserID = userID
+	if err := s.Workouts().Create(context.Background(), &w); err != nil {
+		t.Fatalf("Failed to log workout: %v", err)
+	}
+}
+
+func progress(t *testing.T, s store.Store, userID uint) map[uint]database.UserAchievement {
+	t.Helper()
+	rows, err := s.Achievements().Progress(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("Failed to load progress: %v", err)
+	}
+	out := make(map[uint]database.UserAchievement, len(rows))
+	for _, r := range rows {
+		out[r.AchievementID] = r
+	}
+	return out
+}
+
+func TestEngineTracksProgressAndUnlocks(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t,
+		database.Achievement{Name: "Regular", Target: 2, Rule: rule(`{"metric": "total_workouts"}`)},
+		database.Achievement{Name: "Century Club", Target: 100, Rule: rule(`{"metric": "max_weight", "exercise": "Deadlift"}`)},
+		database.Achievement{Name: "Iron Will", Target: 30, Rule: rule(`{"metric": "streak"}`)},
+		database.Achievement{Name: "Manual", Target: 1},
+	)
+	engine := NewEngine(s)
+
+	logWorkout(t, s, 1, workout(0, 7, 1, "Deadlift", 80))
+	unlocked, err := engine.WorkoutLogged(ctx, 1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(unlocked) != 0 {
+		t.Errorf("Expected nothing unlocked after one workout, got %+v", unlocked)
+	}
+	rows := progress(t, s, 1)
+	if len(rows) != 2 || rows[1].Progress != 1 || rows[2].Progress != 80 {
+		t.Errorf("Expected progress for the two workout rules, got %+v", rows)
+	}
+
+	logWorkout(t, s, 1, workout(1, 7, 1, "Deadlift", 225))
+	unlocked, err = engine.WorkoutLogged(ctx, 1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(unlocked) != 2 {
+		t.Fatalf("Expected both workout achievements to unlock, got %+v", unlocked)
+	}
+	rows = progress(t, s, 1)
+	if rows[2].Progress != 100 || rows[2].UnlockedAt == nil {
+		t.Errorf("Expected progress capped at the target and unlocked, got %+v", rows[2])
+	}
+
+	// Deleting the heavy workout does not revoke the achievement
+	history, err := s.Workouts().History(ctx, store.HistoryQuery{UserID: 1})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := s.Workouts().Delete(ctx, 1, history[1].ID); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	unlocked, err = engine.WorkoutLogged(ctx, 1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(unlocked) != 0 || progress(t, s, 1)[2].UnlockedAt == nil {
+		t.Errorf("Expected earned achievements to stay earned, got %+v", unlocked)
+	}
+
+	// Streak rules only run on streak events
+	if err := s.Streaks().Save(ctx, &database.Streak{UserID: 1, StreakType: "workout", Current: 30, Longest: 30}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	unlocked, err = engine.Evaluate(ctx, 1, StreakChanged)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(unlocked) != 1 || unlocked[0].Name != "Iron Will" {
+		t.Errorf("Expected Iron Will to unlock, got %+v", unlocked)
+	}
+}
+
+func TestEngineCountsBuddyWorkouts(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t,
+		database.Achievement{Name: "Team Player", Target: 2, Rule: rule(`{"metric": "buddy_workouts", "window_hours": 24}`)},
+	)
+	rels := []database.BuddyRelationship{
+		{UserID: 1, BuddyID: 2, RelationshipType: database.RelationshipPeer, Status: database.BuddyActive},
+		{UserID: 3, BuddyID: 1, RelationshipType: database.RelationshipPeer, Status: database.BuddyPending},
+		{UserID: 1, BuddyID: 4, RelationshipType: database.RelationshipCoach, Status: database.BuddyActive},
+	}
+	if err := s.Users().Create(ctx, &database.User{Email: "coach@example.com"}); err != nil {
+		t.Fatalf("Failed to add user: %v", err)
+	}
+	for i := range rels {
+		if err := s.Buddies().Create(ctx, &rels[i]); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	engine := NewEngine(s)
+
+	logWorkout(t, s, 1, workout(0, 7, 1, "Deadlift", 100))
+	logWorkout(t, s, 3, workout(0, 8, 1, "Deadlift", 100)) // Pending buddies do not count
+	logWorkout(t, s, 4, workout(0, 9, 1, "Deadlift", 100)) // Nor do coaches
+	logWorkout(t, s, 2, workout(3, 7, 1, "Deadlift", 100)) // Outside the window
+	logWorkout(t, s, 2, workout(0, 20, 1, "Deadlift", 100))
+	if _, err := engine.WorkoutLogged(ctx, 2); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := progress(t, s, 1)[1]; got.Progress != 1 || got.UnlockedAt != nil {
+		t.Errorf("Expected one buddy workout counted for user 1, got %+v", got)
+	}
+
+	logWorkout(t, s, 2, workout(1, 6, 1, "Deadlift", 100))
+	if _, err := engine.WorkoutLogged(ctx, 2); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := progress(t, s, 1)[1]; got.UnlockedAt == nil {
+		t.Errorf("Expected Team Player to unlock for user 1, got %+v", got)
+	}
+}
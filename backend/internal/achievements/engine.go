@@ -0,0 +1,121 @@
+package achievements
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+)
+
+// Engine evaluates achievement rules against the store
+type Engine struct {
+	store store.Store
+	now   func() time.Time
+}
+
+// NewEngine creates an engine backed by s
+func NewEngine(s store.Store) *Engine {
+	return &Engine{store: s, now: time.Now}
+}
+
+// Evaluate recomputes the progress of every rule the event can affect and
+// returns the achievements it unlocked. Earned achievements are never
+// revoked, even if the metric later drops (a deleted workout, a broken streak).
+func (e *Engine) Evaluate(ctx context.Context, userID uint, event Event) ([]database.Achievement, error) {
+	var unlocked []database.Achievement
+	err := e.store.Transaction(ctx, func(tx store.Store) error {
+		var err error
+		unlocked, err = e.evaluate(ctx, tx, userID, event)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate achievements: %w", err)
+	}
+	return unlocked, nil
+}
+
+func (e *Engine) evaluate(ctx context.Context, tx store.Store, userID uint, event Event) ([]database.Achievement, error) {
+	all, err := tx.Achievements().List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load achievements: %w", err)
+	}
+	rows, err := tx.Achievements().Progress(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load achievement progress: %w", err)
+	}
+	progress := make(map[uint]database.UserAchievement, len(rows))
+	for _, p := range rows {
+		progress[p.AchievementID] = p
+	}
+
+	user, err := tx.Users().Get(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user: %w", err)
+	}
+	f := &facts{tx: tx, userID: userID, loc: user.Location()}
+	var unlocked []database.Achievement
+	for _, a := range all {
+		if a.Rule == nil {
+			continue
+		}
+		row, ok := progress[a.ID]
+		if ok && row.UnlockedAt != nil {
+			continue
+		}
+		rule, err := ParseRule(*a.Rule)
+		if err != nil {
+			// A bad rule is a data problem; skip it rather than block workout logging
+			slog.Warn("Skipping achievement with invalid rule", "achievement", a.Name, "error", err)
+			continue
+		}
+		if !rule.triggeredBy(event) {
+			continue
+		}
+
+		value, err := f.measure(ctx, rule)
+		if err != nil {
+			return nil, fmt.Errorf("achievement %q: %w", a.Name, err)
+		}
+		value = min(value, a.Target)
+		if ok && row.Progress == value {
+			continue
+		}
+
+		row.UserID = userID
+		row.AchievementID = a.ID
+		row.Progress = value
+		if value >= a.Target {
+			now := e.now()
+			row.UnlockedAt = &now
+			unlocked = append(unlocked, a)
+		}
+		if err := tx.Achievements().SaveProgress(ctx, &row); err != nil {
+			return nil, fmt.Errorf("failed to save achievement progress: %w", err)
+		}
+	}
+	return unlocked, nil
+}
+
+// WorkoutLogged evaluates the user's rules after they log, edit or delete a
+// workout, then the buddy rules of their active peer buddies. It returns the
+// user's own unlocks.
+func (e *Engine) WorkoutLogged(ctx context.Context, userID uint) ([]database.Achievement, error) {
+	unlocked, err := e.Evaluate(ctx, userID, WorkoutLogged)
+	if err != nil {
+		return nil, err
+	}
+
+	buddies, err := activeBuddies(ctx, e.store, userID)
+	if err != nil {
+		return unlocked, err
+	}
+	for _, buddyID := range buddies {
+		if _, err := e.Evaluate(ctx, buddyID, BuddyWorkoutLogged); err != nil {
+			return unlocked, err
+		}
+	}
+	return unlocked, nil
+}
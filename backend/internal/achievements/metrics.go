@@ -0,0 +1,241 @@
+package achievements
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/records"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+)
+
+const hoursPerDay = 24
+
+// Time of day buckets by local hour
+const (
+	morningStart   = 5
+	afternoonStart = 12
+	eveningStart   = 17
+	nightStart     = 22
+)
+
+// facts loads what rules measure for one user, once per evaluation
+type facts struct {
+	tx     store.Store
+	userID uint
+	loc    *time.Location // The user's time zone, for calendar days and time of day
+
+	workouts []database.Workout // Oldest first; nil until loaded
+}
+
+func (f *facts) userWorkouts(ctx context.Context) ([]database.Workout, error) {
+	if f.workouts != nil {
+		return f.workouts, nil
+	}
+	workouts, err := f.tx.Workouts().History(ctx, store.HistoryQuery{UserID: f.userID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load workouts: %w", err)
+	}
+	f.workouts = append([]database.Workout{}, workouts...)
+	return f.workouts, nil
+}
+
+// measure computes the rule's metric
+func (f *facts) measure(ctx context.Context, r *Rule) (int, error) {
+	switch r.Metric {
+	case MetricStreak:
+		return f.longestStreak(ctx, r)
+	case MetricChallengesCompleted, MetricChallengesWon:
+		return f.challenges(ctx, r.Metric == MetricChallengesWon)
+	}
+
+	workouts, err := f.userWorkouts(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	switch r.Metric {
+	case MetricTotalWorkouts:
+		return len(workouts), nil
+	case MetricConsecutiveWorkouts:
+		return consecutiveWorkouts(workouts, r.MaxGapDays, f.loc), nil
+	case MetricMaxWeight:
+		return maxWeight(workouts, r.Exercise, r.Unit), nil
+	case MetricPersonalRecords:
+		return personalRecords(workouts, r.Exercise), nil
+	case MetricTimeOfDay:
+		return timeOfDayBuckets(workouts, f.loc), nil
+	case MetricBuddyWorkouts:
+		return f.buddyWorkouts(ctx, workouts, r)
+	default:
+		return 0, fmt.Errorf("unknown metric %q", r.Metric)
+	}
+}
+
+// consecutiveWorkouts counts the workouts in the latest run with no gap
+// longer than maxGapDays between calendar days
+func consecutiveWorkouts(workouts []database.Workout, maxGapDays int, loc *time.Location) int {
+	if maxGapDays == 0 {
+		maxGapDays = defaultMaxGapDays
+	}
+
+	run := 0
+	var previous time.Time
+	for _, w := range workouts {
+		day := localDay(w.CompletedAt, loc)
+		if run > 0 && daysBetween(previous, day) > maxGapDays {
+			run = 0
+		}
+		run++
+		previous = day
+	}
+	return run
+}
+
+// maxWeight returns the heaviest completed set of an exercise, rounded down
+func maxWeight(workouts []database.Workout, exercise, unit string) int {
+	best := 0.0
+	for _, w := range workouts {
+		for _, s := range w.Sets {
+			if s.Completed && strings.EqualFold(s.Exercise.Name, exercise) {
+				best = math.Max(best, database.ConvertWeight(s.Weight, s.Unit, unit))
+			}
+		}
+	}
+	return int(math.Floor(best))
+}
+
+// personalRecords counts workouts in which a completed set beat the
+// previous best weight for its exercise. First attempts set the baseline
+// and do not count.
+func personalRecords(workouts []database.Workout, exercise string) int {
+	beat := make(map[uint]bool)
+	for _, r := range records.Set(workouts) {
+		if exercise == "" || strings.EqualFold(r.Exercise, exercise) {
+			beat[r.WorkoutID] = true
+		}
+	}
+	return len(beat)
+}
+
+// timeOfDayBuckets counts the distinct times of day workouts were completed in
+func timeOfDayBuckets(workouts []database.Workout, loc *time.Location) int {
+	seen := make(map[string]bool)
+	for _, w := range workouts {
+		seen[timeOfDay(w.CompletedAt.In(loc).Hour())] = true
+	}
+	return len(seen)
+}
+
+func timeOfDay(hour int) string {
+	switch {
+	case hour >= morningStart && hour < afternoonStart:
+		return database.WorkoutTimeMorning
+	case hour >= afternoonStart && hour < eveningStart:
+		return database.WorkoutTimeAfternoon
+	case hour >= eveningStart && hour < nightStart:
+		return database.WorkoutTimeEvening
+	default:
+		return "night"
+	}
+}
+
+// buddyWorkouts counts workouts by the user's active peer buddies that were
+// completed within the rule's window of one of the user's workouts
+func (f *facts) buddyWorkouts(ctx context.Context, own []database.Workout, r *Rule) (int, error) {
+	window := time.Duration(r.WindowHours) * time.Hour
+	if window == 0 {
+		window = defaultWindowHours * time.Hour
+	}
+
+	buddies, err := activeBuddies(ctx, f.tx, f.userID)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, buddyID := range buddies {
+		theirs, err := f.tx.Workouts().History(ctx, store.HistoryQuery{UserID: buddyID})
+		if err != nil {
+			return 0, fmt.Errorf("failed to load buddy workouts: %w", err)
+		}
+		for _, w := range theirs {
+			if withinWindow(own, w.CompletedAt, window) {
+				count++
+			}
+		}
+	}
+	return count, nil
+}
+
+// withinWindow reports whether any workout was completed within window of at
+func withinWindow(workouts []database.Workout, at time.Time, window time.Duration) bool {
+	for _, w := range workouts {
+		d := w.CompletedAt.Sub(at)
+		if d <= window && d >= -window {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *facts) longestStreak(ctx context.Context, r *Rule) (int, error) {
+	streakType := r.StreakType
+	if streakType == "" {
+		streakType = defaultStreakType
+	}
+	streak, err := f.tx.Streaks().Get(ctx, f.userID, streakType)
+	if errors.Is(err, store.ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to load streak: %w", err)
+	}
+	return streak.Longest, nil
+}
+
+// challenges counts the completed challenges the user has a final rank in,
+// or only those they ranked first in when won is set
+func (f *facts) challenges(ctx context.Context, won bool) (int, error) {
+	results, err := f.tx.Challenges().Results(ctx, f.userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load challenge results: %w", err)
+	}
+	count := 0
+	for _, r := range results {
+		if !won || *r.FinalRank == 1 {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// activeBuddies returns the IDs of the user's active peer buddies, on
+// either side of the relationship
+func activeBuddies(ctx context.Context, tx store.Store, userID uint) ([]uint, error) {
+	rels, err := tx.Buddies().List(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load buddies: %w", err)
+	}
+
+	var ids []uint
+	for _, buddy := range database.ActivePeers(rels, userID) {
+		ids = append(ids, buddy.ID)
+	}
+	return ids, nil
+}
+
+// localDay returns midnight of t's calendar day in loc
+func localDay(t time.Time, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
+
+// daysBetween counts calendar days from a to b, both local midnights
+func daysBetween(a, b time.Time) int {
+	return int(math.Round(b.Sub(a).Hours() / hoursPerDay))
+}
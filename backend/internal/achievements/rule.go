@@ -0,0 +1,144 @@
+// Package achievements evaluates achievement unlock rules. Each achievement
+// stores a declarative rule naming a metric, such as total workouts or the
+// heaviest deadlift, and its parameters; Achievement.Target is the value
+// the metric must reach. New achievements reuse the metrics below, so adding
+// one is a data change:
+//
+//	{"metric": "max_weight", "exercise": "Deadlift", "unit": "lb"}
+package achievements
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+)
+
+// Metrics an achievement rule can measure
+const (
+	// MetricTotalWorkouts counts every logged workout
+	MetricTotalWorkouts = "total_workouts"
+	// MetricConsecutiveWorkouts counts the workouts in the latest run where
+	// each follows the previous within MaxGapDays calendar days
+	MetricConsecutiveWorkouts = "consecutive_workouts"
+	// MetricMaxWeight is the heaviest completed set of Exercise, in Unit
+	MetricMaxWeight = "max_weight"
+	// MetricPersonalRecords counts workouts that beat a previous best
+	// weight, for Exercise or any exercise when it is empty. A workout
+	// counts once however many exercises it beat.
+	MetricPersonalRecords = "personal_records"
+	// MetricTimeOfDay counts the distinct times of day (morning, afternoon,
+	// evening, night) the user has worked out in
+	MetricTimeOfDay = "time_of_day_buckets"
+	// MetricBuddyWorkouts counts workouts by active peer buddies completed within
+	// WindowHours of one of the user's own workouts
+	MetricBuddyWorkouts = "buddy_workouts"
+	// MetricStreak is the longest streak of StreakType
+	MetricStreak = "streak"
+	// MetricChallengesCompleted counts the group challenges the user
+	// finished as a participant
+	MetricChallengesCompleted = "challenges_completed"
+	// MetricChallengesWon counts the group challenges the user finished first in
+	MetricChallengesWon = "challenges_won"
+)
+
+// Defaults for optional rule parameters
+const (
+	defaultMaxGapDays  = 3 // Covers a Friday to Monday gap in a three day program
+	defaultWindowHours = 24
+	defaultStreakType  = database.StreakWorkout
+)
+
+// Rule is a declarative unlock rule
+type Rule struct {
+	Metric      string `json:"metric" yaml:"metric"`
+	Exercise    string `json:"exercise,omitempty" yaml:"exercise"`         // max_weight, personal_records
+	Unit        string `json:"unit,omitempty" yaml:"unit"`                 // max_weight: lb (default) or kg
+	MaxGapDays  int    `json:"max_gap_days,omitempty" yaml:"max_gap_days"` // consecutive_workouts
+	WindowHours int    `json:"window_hours,omitempty" yaml:"window_hours"` // buddy_workouts
+	StreakType  string `json:"streak_type,omitempty" yaml:"streak_type"`   // streak: workout, weekly or monthly
+}
+
+// ParseRule decodes and validates a stored rule
+func ParseRule(raw string) (*Rule, error) {
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.DisallowUnknownFields()
+
+	var r Rule
+	if err := dec.Decode(&r); err != nil {
+		return nil, fmt.Errorf("invalid achievement rule: %w", err)
+	}
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Validate checks that the metric exists and its parameters make sense
+func (r *Rule) Validate() error {
+	var problems []string
+	switch r.Metric {
+	case MetricTotalWorkouts, MetricTimeOfDay, MetricChallengesCompleted, MetricChallengesWon:
+	case MetricConsecutiveWorkouts:
+		if r.MaxGapDays < 0 {
+			problems = append(problems, "max_gap_days: must not be negative")
+		}
+	case MetricMaxWeight:
+		if r.Exercise == "" {
+			problems = append(problems, "exercise: required")
+		}
+		if r.Unit != "" && r.Unit != database.UnitPounds && r.Unit != database.UnitKilograms {
+			problems = append(problems, "unit: must be lb or kg")
+		}
+	case MetricPersonalRecords:
+	case MetricBuddyWorkouts:
+		if r.WindowHours < 0 {
+			problems = append(problems, "window_hours: must not be negative")
+		}
+	case MetricStreak:
+		if r.StreakType != "" && !slices.Contains(database.StreakTypes, r.StreakType) {
+			problems = append(problems, "streak_type: must be workout, weekly or monthly")
+		}
+	case "":
+		problems = append(problems, "metric: required")
+	default:
+		problems = append(problems, fmt.Sprintf("metric: unknown metric %q", r.Metric))
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid achievement rule: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Events that change the metrics a rule measures
+type Event int
+
+const (
+	// WorkoutLogged fires when the user logs, edits or deletes a workout
+	WorkoutLogged Event = iota
+	// BuddyWorkoutLogged fires for each active peer buddy of a user who logs a workout
+	BuddyWorkoutLogged
+	// StreakChanged fires when one of the user's streaks is updated
+	StreakChanged
+	// ChallengeCompleted fires for each participant of a group challenge
+	// once its final standings are recorded
+	ChallengeCompleted
+)
+
+// triggeredBy reports whether event can change the rule's metric
+func (r *Rule) triggeredBy(event Event) bool {
+	switch r.Metric {
+	case MetricStreak:
+		return event == StreakChanged
+	case MetricBuddyWorkouts:
+		return event == WorkoutLogged || event == BuddyWorkoutLogged
+	case MetricChallengesCompleted, MetricChallengesWon:
+		return event == ChallengeCompleted
+	default:
+		return event == WorkoutLogged
+	}
+}
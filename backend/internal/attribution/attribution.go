@@ -0,0 +1,91 @@
+// Package attribution credits Weasel messages with the workouts that
+// followed them.
+//
+// A message triggered a workout when the user completed one within the
+// attribution window after it was sent. The job runs periodically over the
+// messages of the lookback period, so workouts logged after the fact are
+// still credited. Messages are never uncredited.
+package attribution
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/env"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+)
+
+const (
+	defaultWindow   = 24 * time.Hour
+	defaultInterval = 15 * time.Minute
+	defaultLookback = 7 * 24 * time.Hour
+)
+
+// Config controls which workouts count toward a message
+type Config struct {
+	// Window is how long after a message a workout still counts
+	Window time.Duration
+	// Lookback is how far back the job revisits messages
+	Lookback time.Duration
+	// Interval is how often the job runs
+	Interval time.Duration
+}
+
+// DefaultConfig returns a 24 hour window revisited for a week
+func DefaultConfig() *Config {
+	return &Config{Window: defaultWindow, Lookback: defaultLookback, Interval: defaultInterval}
+}
+
+// LoadConfig loads the defaults, overriding them from ATTRIBUTION_WINDOW,
+// ATTRIBUTION_LOOKBACK and ATTRIBUTION_INTERVAL
+func LoadConfig() *Config {
+	cfg := DefaultConfig()
+	cfg.Window = env.Duration("ATTRIBUTION_WINDOW", cfg.Window)
+	cfg.Lookback = env.Duration("ATTRIBUTION_LOOKBACK", cfg.Lookback)
+	cfg.Interval = env.Duration("ATTRIBUTION_INTERVAL", cfg.Interval)
+	return cfg
+}
+
+// Job marks messages that triggered a workout
+type Job struct {
+	store store.Store
+	cfg   *Config
+}
+
+// NewJob creates a Job
+func NewJob(s store.Store, cfg *Config) *Job {
+	return &Job{store: s, cfg: cfg}
+}
+
+// Run calls Attribute every configured interval until ctx is done
+func (j *Job) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			marked, err := j.Attribute(ctx, now)
+			if err != nil {
+				slog.Error("Message attribution failed", "error", err)
+			}
+			if marked > 0 {
+				slog.Info("Messages credited with a workout", "count", marked)
+			}
+		}
+	}
+}
+
+// Attribute marks the messages of the lookback period before now that were
+// followed by a workout, returning how many it marked
+func (j *Job) Attribute(ctx context.Context, now time.Time) (int64, error) {
+	marked, err := j.store.Messages().MarkTriggered(ctx, now.Add(-j.cfg.Lookback), j.cfg.Window)
+	if err != nil {
+		return 0, fmt.Errorf("failed to attribute messages: %w", err)
+	}
+	return marked, nil
+}
@@ -0,0 +1,82 @@
+package attribution
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+)
+
+func TestAttribute(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	sentAt := time.Date(2025, time.March, 10, 9, 0, 0, 0, time.UTC)
+
+	var users []*database.User
+	for _, email := range []string{"sam@example.com", "kim@example.com", "alex@example.com"} {
+		user := &database.User{Email: email, Name: email}
+		if err := s.Users().Create(ctx, user); err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		users = append(users, user)
+	}
+
+	tests := []struct {
+		name      string
+		user      *database.User
+		sentAt    time.Time
+		workout   *time.Time
+		triggered bool
+	}{
+		{"workout within the window", users[0], sentAt, ptr(sentAt.Add(3 * time.Hour)), true},
+		{"workout after the window", users[1], sentAt, ptr(sentAt.Add(25 * time.Hour)), false},
+		{"workout before the message", users[2], sentAt, nil, false},
+		{"older than the lookback", users[0], sentAt.Add(-8 * 24 * time.Hour), ptr(sentAt.Add(-8*24*time.Hour + time.Hour)), false},
+	}
+	if err := s.Workouts().Create(ctx, &database.Workout{UserID: users[2].ID, CompletedAt: sentAt.Add(-time.Hour)}); err != nil {
+		t.Fatalf("Failed to log workout: %v", err)
+	}
+
+	msgs := make([]*database.WeaselMessage, len(tests))
+	for i, tt := range tests {
+		msgs[i] = &database.WeaselMessage{UserID: tt.user.ID, MessageType: database.MessageFunny, Content: tt.name, SentAt: tt.sentAt}
+		if err := s.Messages().Create(ctx, msgs[i]); err != nil {
+			t.Fatalf("Failed to store message: %v", err)
+		}
+		if tt.workout != nil {
+			if err := s.Workouts().Create(ctx, &database.Workout{UserID: tt.user.ID, CompletedAt: *tt.workout}); err != nil {
+				t.Fatalf("Failed to log workout: %v", err)
+			}
+		}
+	}
+
+	job := NewJob(s, DefaultConfig())
+	marked, err := job.Attribute(ctx, sentAt.Add(2*24*time.Hour))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if marked != 1 {
+		t.Errorf("Expected 1 message marked, got %d", marked)
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := s.Messages().Get(ctx, tt.user.ID, msgs[i].ID)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if msg.TriggeredWorkout != tt.triggered {
+				t.Errorf("Expected triggered=%v, got %v", tt.triggered, msg.TriggeredWorkout)
+			}
+		})
+	}
+
+	// Running again finds nothing new
+	if marked, err := job.Attribute(ctx, sentAt.Add(2*24*time.Hour)); err != nil || marked != 0 {
+		t.Errorf("Expected a second run to mark nothing, got %d, %v", marked, err)
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
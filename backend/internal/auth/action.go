@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Purposes for single-use action tokens
+const (
+	PurposeVerifyEmail   = "verify_email"
+	PurposeResetPassword = "reset_password"
+
+	// actionAudiencePrefix scopes action tokens away from the API audience
+	actionAudiencePrefix = "ferrovis-action:"
+)
+
+// ActionClaims are the claims carried by an email verification or password
+// reset token. The token ID (jti) is recorded server-side so each token can
+// be redeemed only once.
+type ActionClaims struct {
+	jwt.RegisteredClaims
+	Use string `json:"use"`
+}
+
+// UserID returns the numeric user ID stored in the subject claim
+func (c *ActionClaims) UserID() (uint, error) {
+	id, err := strconv.ParseUint(c.Subject, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid subject claim: %w", err)
+	}
+	return uint(id), nil
+}
+
+// IssueActionToken creates a signed, expiring token for a single purpose.
+// It returns the token and its ID, which the caller must persist to enforce
+// single use.
+func (m *TokenManager) IssueActionToken(userID uint, purpose string, ttl time.Duration) (token, id string, err error) {
+	id, err = randomString(familyIDBytes)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate token ID: %w", err)
+	}
+
+	now := m.now()
+	claims := ActionClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        id,
+			Subject:   strconv.FormatUint(uint64(userID), 10),
+			Issuer:    m.issuer,
+			Audience:  jwt.ClaimStrings{actionAudiencePrefix + purpose},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+		Use: purpose,
+	}
+
+	token, err = m.sign(claims)
+	if err != nil {
+		return "", "", err
+	}
+	return token, id, nil
+}
+
+// ParseActionToken verifies an action token and checks it was issued for purpose
+func (m *TokenManager) ParseActionToken(raw, purpose string) (*ActionClaims, error) {
+	claims := &ActionClaims{}
+	if err := m.parse(raw, claims, actionAudiencePrefix+purpose); err != nil {
+		return nil, err
+	}
+
+	if claims.Use != purpose || claims.ID == "" {
+		return nil, fmt.Errorf("%w: wrong token purpose", ErrInvalidToken)
+	}
+	if _, err := claims.UserID(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	return claims, nil
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestActionTokenRoundTrip(t *testing.T) {
+	m := NewTokenManager(testConfig())
+
+	token, id, err := m.IssueActionToken(9, PurposeResetPassword, time.Hour)
+	if err != nil {
+		t.Fatalf("IssueActionToken returned error: %v", err)
+	}
+
+	claims, err := m.ParseActionToken(token, PurposeResetPassword)
+	if err != nil {
+		t.Fatalf("ParseActionToken returned error: %v", err)
+	}
+	if claims.ID != id {
+		t.Errorf("Expected token ID %s, got %s", id, claims.ID)
+	}
+	uid, err := claims.UserID()
+	if err != nil || uid != 9 {
+		t.Errorf("Expected user ID 9, got %d (%v)", uid, err)
+	}
+}
+
+func TestActionTokenPurposeIsolation(t *testing.T) {
+	m := NewTokenManager(testConfig())
+
+	verify, _, err := m.IssueActionToken(1, PurposeVerifyEmail, time.Hour)
+	if err != nil {
+		t.Fatalf("IssueActionToken returned error: %v", err)
+	}
+	access, err := m.IssueAccessToken(1, "a@b.co", 0)
+	if err != nil {
+		t.Fatalf("IssueAccessToken returned error: %v", err)
+	}
+
+	if _, err := m.ParseActionToken(verify, PurposeResetPassword); err == nil {
+		t.Error("Verification token must not be accepted for password reset")
+	}
+	if _, err := m.ParseActionToken(access, PurposeResetPassword); err == nil {
+		t.Error("Access token must not be accepted as an action token")
+	}
+	if _, err := m.ParseAccessToken(verify); err == nil {
+		t.Error("Action token must not be accepted as an access token")
+	}
+
+	// Even with the audience check disabled the "use" claim keeps them apart
+	cfg := testConfig()
+	cfg.Audience = ""
+	if _, err := NewTokenManager(cfg).ParseAccessToken(verify); err == nil {
+		t.Error("Action token must not be accepted as an access token without audience checks")
+	}
+}
+
+func TestActionTokenExpiry(t *testing.T) {
+	m := NewTokenManager(testConfig())
+	m.now = func() time.Time { return time.Now().Add(-2 * time.Hour) }
+
+	token, _, err := m.IssueActionToken(1, PurposeVerifyEmail, time.Hour)
+	if err != nil {
+		t.Fatalf("IssueActionToken returned error: %v", err)
+	}
+
+	m.now = time.Now
+	if _, err := m.ParseActionToken(token, PurposeVerifyEmail); err == nil {
+		t.Error("Expected expired action token to be rejected")
+	}
+}
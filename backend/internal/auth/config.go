@@ -0,0 +1,101 @@
+// Package auth provides password hashing and access token handling for the
+// Ferrovis API. It is deliberately free of HTTP and database concerns so the
+// primitives can be unit tested in isolation.
+package auth
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/env"
+)
+
+const (
+	// Default token settings
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+	defaultIssuer          = "ferrovis-api"
+	defaultAudience        = "ferrovis-mobile"
+	defaultKeyID           = "primary"
+
+	// Development fallback secret (matches docker-compose.yml)
+	devSecret = "dev_jwt_secret_change_in_production"
+)
+
+// Config holds authentication configuration
+type Config struct {
+	// Keys maps a key ID (the JWT "kid" header) to its HMAC secret.
+	// Keeping retired keys here lets tokens signed before a rotation
+	// stay valid until they expire.
+	Keys map[string]string
+	// ActiveKeyID selects the key used to sign new tokens
+	ActiveKeyID string
+
+	// Issuer and Audience are stamped on issued tokens and enforced on
+	// verification. An empty value disables the corresponding check.
+	Issuer   string
+	Audience string
+
+	AccessTokenTTL time.Duration
+	// RefreshTokenTTL is the idle lifetime of a refresh token; each
+	// rotation issues a fresh token with a full lifetime
+	RefreshTokenTTL time.Duration
+}
+
+// LoadConfig loads authentication configuration from environment variables.
+//
+// Keys are read from JWT_SIGNING_KEYS as a comma-separated list of
+// "kid:secret" pairs, with JWT_ACTIVE_KEY_ID choosing the signing key
+// (defaults to the first pair). When JWT_SIGNING_KEYS is unset, JWT_SECRET
+// is used as a single key named by JWT_KEY_ID.
+func LoadConfig() *Config {
+	keys, order := parseKeys(os.Getenv("JWT_SIGNING_KEYS"))
+	if len(keys) == 0 {
+		secret := os.Getenv("JWT_SECRET")
+		if secret == "" {
+			slog.Warn("JWT_SECRET not set, using insecure development secret")
+			secret = devSecret
+		}
+		kid := env.String("JWT_KEY_ID", defaultKeyID)
+		keys = map[string]string{kid: secret}
+		order = []string{kid}
+	}
+
+	active := env.String("JWT_ACTIVE_KEY_ID", order[0])
+	if _, ok := keys[active]; !ok {
+		slog.Warn("JWT_ACTIVE_KEY_ID does not match a configured key, using first key", "kid", active)
+		active = order[0]
+	}
+
+	return &Config{
+		Keys:            keys,
+		ActiveKeyID:     active,
+		Issuer:          env.Lookup("JWT_ISSUER", defaultIssuer),
+		Audience:        env.Lookup("JWT_AUDIENCE", defaultAudience),
+		AccessTokenTTL:  env.Duration("ACCESS_TOKEN_TTL", defaultAccessTokenTTL),
+		RefreshTokenTTL: env.Duration("REFRESH_TOKEN_TTL", defaultRefreshTokenTTL),
+	}
+}
+
+// parseKeys parses "kid:secret" pairs, returning the keys and their declaration order
+func parseKeys(raw string) (map[string]string, []string) {
+	keys := make(map[string]string)
+	var order []string
+
+	for _, pair := range strings.Split(raw, ",") {
+		kid, secret, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok || kid == "" || secret == "" {
+			if pair != "" {
+				slog.Warn("Ignoring malformed entry in JWT_SIGNING_KEYS")
+			}
+			continue
+		}
+		if _, dup := keys[kid]; !dup {
+			order = append(order, kid)
+		}
+		keys[kid] = secret
+	}
+	return keys, order
+}
@@ -0,0 +1,22 @@
+package auth
+
+import "fmt"
+
+// inviteTokenBytes is the entropy of an opaque buddy invite token
+const inviteTokenBytes = 32
+
+// NewInviteToken generates an opaque token for an emailed invitation and the
+// hash to persist. Invitees may not have an account yet, so unlike action
+// tokens the token is not bound to a user.
+func NewInviteToken() (token, hash string, err error) {
+	token, err = randomString(inviteTokenBytes)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate invite token: %w", err)
+	}
+	return token, HashInviteToken(token), nil
+}
+
+// HashInviteToken returns the storage hash of an invite token
+func HashInviteToken(token string) string {
+	return HashRefreshToken(token)
+}
@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	// Argon2id parameters (OWASP recommended minimums)
+	argonMemory      = 19 * 1024 // 19 MiB, expressed in KiB
+	argonIterations  = 2
+	argonParallelism = 1
+	argonSaltLength  = 16
+	argonKeyLength   = 32
+
+	// Number of "$"-separated fields in an encoded hash
+	encodedHashParts = 6
+)
+
+var (
+	// ErrInvalidHash is returned when a stored hash cannot be parsed
+	ErrInvalidHash = errors.New("invalid password hash format")
+	// ErrIncompatibleVersion is returned when a hash uses an unsupported argon2 version
+	ErrIncompatibleVersion = errors.New("incompatible argon2 version")
+)
+
+// Params holds the Argon2id cost parameters
+type Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultParams returns the production Argon2id parameters
+func DefaultParams() Params {
+	return Params{
+		Memory:      argonMemory,
+		Iterations:  argonIterations,
+		Parallelism: argonParallelism,
+		SaltLength:  argonSaltLength,
+		KeyLength:   argonKeyLength,
+	}
+}
+
+// HashPassword hashes a password with Argon2id using the default parameters.
+// The result is encoded in the PHC string format so parameters travel with the hash.
+func HashPassword(password string) (string, error) {
+	return HashPasswordWithParams(password, DefaultParams())
+}
+
+// HashPasswordWithParams hashes a password with Argon2id using explicit parameters
+func HashPasswordWithParams(password string, p Params) (string, error) {
+	salt := make([]byte, p.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// VerifyPassword reports whether password matches the encoded Argon2id hash
+func VerifyPassword(password, encoded string) (bool, error) {
+	p, salt, key, err := decodeHash(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	other := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+// decodeHash parses a PHC-formatted Argon2id hash
+func decodeHash(encoded string) (Params, []byte, []byte, error) {
+	var p Params
+
+	parts := strings.Split(encoded, "$")
+	if len(parts) != encodedHashParts || parts[1] != "argon2id" {
+		return p, nil, nil, ErrInvalidHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return p, nil, nil, ErrInvalidHash
+	}
+	if version != argon2.Version {
+		return p, nil, nil, ErrIncompatibleVersion
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return p, nil, nil, ErrInvalidHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, ErrInvalidHash
+	}
+	p.SaltLength = uint32(len(salt)) //nolint:gosec // salt length is bounded by the encoded string
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return p, nil, nil, ErrInvalidHash
+	}
+	p.KeyLength = uint32(len(key)) //nolint:gosec // key length is bounded by the encoded string
+
+	return p, salt, key, nil
+}
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testParams keeps hashing fast in unit tests
+func testParams() Params {
+	return Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+}
+
+func TestHashAndVerifyPassword(t *testing.T) {
+	hash, err := HashPasswordWithParams("correct horse battery staple", testParams())
+	if err != nil {
+		t.Fatalf("HashPasswordWithParams returned error: %v", err)
+	}
+
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Errorf("Unexpected hash encoding: %s", hash)
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		expected bool
+	}{
+		{"correct password", "correct horse battery staple", true},
+		{"wrong password", "Tr0ub4dor&3", false},
+		{"empty password", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := VerifyPassword(tt.password, hash)
+			if err != nil {
+				t.Fatalf("VerifyPassword returned error: %v", err)
+			}
+			if ok != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, ok)
+			}
+		})
+	}
+}
+
+func TestHashPasswordUsesUniqueSalt(t *testing.T) {
+	first, err := HashPasswordWithParams("same-password", testParams())
+	if err != nil {
+		t.Fatalf("HashPasswordWithParams returned error: %v", err)
+	}
+	second, err := HashPasswordWithParams("same-password", testParams())
+	if err != nil {
+		t.Fatalf("HashPasswordWithParams returned error: %v", err)
+	}
+
+	if first == second {
+		t.Error("Expected different hashes for the same password")
+	}
+}
+
+func TestVerifyPasswordInvalidHash(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoded  string
+		expected error
+	}{
+		{"empty", "", ErrInvalidHash},
+		{"bcrypt hash", "$2a$10$abcdefghijklmnopqrstuv", ErrInvalidHash},
+		{"wrong version", "$argon2id$v=16$m=1024,t=1,p=1$c2FsdA$a2V5", ErrIncompatibleVersion},
+		{"bad params", "$argon2id$v=19$m=x,t=1,p=1$c2FsdA$a2V5", ErrInvalidHash},
+		{"bad salt", "$argon2id$v=19$m=1024,t=1,p=1$!!!$a2V5", ErrInvalidHash},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := VerifyPassword("password", tt.encoded)
+			if !errors.Is(err, tt.expected) {
+				t.Errorf("Expected error %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+const (
+	// refreshTokenBytes is the entropy of an opaque refresh token
+	refreshTokenBytes = 32
+	// familyIDBytes is the entropy of a refresh token family identifier
+	familyIDBytes = 16
+)
+
+// NewRefreshToken generates an opaque refresh token and the hash to persist.
+// Only the hash is stored so a database leak does not expose live sessions.
+func NewRefreshToken() (token, hash string, err error) {
+	token, err = randomString(refreshTokenBytes)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	return token, HashRefreshToken(token), nil
+}
+
+// HashRefreshToken returns the storage hash of a refresh token
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewFamilyID generates an identifier shared by every token rotated from one login
+func NewFamilyID() (string, error) {
+	id, err := randomString(familyIDBytes)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate token family: %w", err)
+	}
+	return id, nil
+}
+
+// randomString returns n random bytes encoded as unpadded base64url
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to read random bytes: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"testing"
+)
+
+func TestNewRefreshToken(t *testing.T) {
+	token, hash, err := NewRefreshToken()
+	if err != nil {
+		t.Fatalf("NewRefreshToken returned error: %v", err)
+	}
+
+	if token == "" || hash == "" {
+		t.Fatal("Expected non-empty token and hash")
+	}
+	if token == hash {
+		t.Error("Stored hash must differ from the raw token")
+	}
+	if HashRefreshToken(token) != hash {
+		t.Error("HashRefreshToken should reproduce the stored hash")
+	}
+
+	other, _, err := NewRefreshToken()
+	if err != nil {
+		t.Fatalf("NewRefreshToken returned error: %v", err)
+	}
+	if other == token {
+		t.Error("Expected unique refresh tokens")
+	}
+}
+
+func TestNewFamilyID(t *testing.T) {
+	first, err := NewFamilyID()
+	if err != nil {
+		t.Fatalf("NewFamilyID returned error: %v", err)
+	}
+	second, err := NewFamilyID()
+	if err != nil {
+		t.Fatalf("NewFamilyID returned error: %v", err)
+	}
+	if first == second {
+		t.Error("Expected unique family IDs")
+	}
+}
@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// clockSkewLeeway tolerates small clock differences between replicas and clients
+	clockSkewLeeway = 30 * time.Second
+
+	// tokenUseAccess marks API access tokens so single-purpose tokens signed
+	// with the same keys can never be presented as one
+	tokenUseAccess = "access"
+)
+
+var (
+	// ErrInvalidToken is returned when a token fails signature or claim validation
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrUnknownKeyID is returned when a token references a key we do not hold
+	ErrUnknownKeyID = errors.New("unknown signing key")
+)
+
+// Claims are the JWT claims carried by a Ferrovis access token
+type Claims struct {
+	jwt.RegisteredClaims
+	Email string `json:"email"`
+	// TokenVersion must match the user's current token version; bumping the
+	// version server-side invalidates every outstanding access token
+	TokenVersion int `json:"ver"`
+	// Use distinguishes access tokens from single-purpose action tokens
+	Use string `json:"use"`
+}
+
+// UserID returns the numeric user ID stored in the subject claim
+func (c *Claims) UserID() (uint, error) {
+	id, err := strconv.ParseUint(c.Subject, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid subject claim: %w", err)
+	}
+	return uint(id), nil
+}
+
+// TokenManager issues and verifies signed access tokens
+type TokenManager struct {
+	keys        map[string][]byte
+	activeKeyID string
+	issuer      string
+	audience    string
+	ttl         time.Duration
+	refreshTTL  time.Duration
+	now         func() time.Time
+}
+
+// NewTokenManager creates a TokenManager from configuration
+func NewTokenManager(cfg *Config) *TokenManager {
+	keys := make(map[string][]byte, len(cfg.Keys))
+	for kid, secret := range cfg.Keys {
+		keys[kid] = []byte(secret)
+	}
+
+	return &TokenManager{
+		keys:        keys,
+		activeKeyID: cfg.ActiveKeyID,
+		issuer:      cfg.Issuer,
+		audience:    cfg.Audience,
+		ttl:         cfg.AccessTokenTTL,
+		refreshTTL:  cfg.RefreshTokenTTL,
+		now:         time.Now,
+	}
+}
+
+// TTL returns the lifetime of issued access tokens
+func (m *TokenManager) TTL() time.Duration {
+	return m.ttl
+}
+
+// RefreshTTL returns the lifetime of issued refresh tokens
+func (m *TokenManager) RefreshTTL() time.Duration {
+	return m.refreshTTL
+}
+
+// IssueAccessToken creates a signed HS256 access token for the given user.
+// The token's "kid" header names the active key so verifiers can pick the
+// right secret after a rotation.
+func (m *TokenManager) IssueAccessToken(userID uint, email string, version int) (string, error) {
+	now := m.now()
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.FormatUint(uint64(userID), 10),
+			Issuer:    m.issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(m.ttl)),
+		},
+		Email:        email,
+		TokenVersion: version,
+		Use:          tokenUseAccess,
+	}
+	if m.audience != "" {
+		claims.Audience = jwt.ClaimStrings{m.audience}
+	}
+
+	return m.sign(claims)
+}
+
+// ParseAccessToken verifies a token's signature, expiry, issuer and audience
+// and returns its claims
+func (m *TokenManager) ParseAccessToken(raw string) (*Claims, error) {
+	claims := &Claims{}
+	if err := m.parse(raw, claims, m.audience); err != nil {
+		return nil, err
+	}
+
+	if claims.Use != tokenUseAccess {
+		return nil, fmt.Errorf("%w: not an access token", ErrInvalidToken)
+	}
+	if _, err := claims.UserID(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	return claims, nil
+}
+
+// sign signs claims with the active key and stamps its "kid" header
+func (m *TokenManager) sign(claims jwt.Claims) (string, error) {
+	secret, ok := m.keys[m.activeKeyID]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownKeyID, m.activeKeyID)
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = m.activeKeyID
+
+	signed, err := token.SignedString(secret)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+	return signed, nil
+}
+
+// parse verifies signature, expiry, issuer and the given audience into claims
+func (m *TokenManager) parse(raw string, claims jwt.Claims, audience string) error {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(clockSkewLeeway),
+		jwt.WithTimeFunc(m.now),
+	}
+	if m.issuer != "" {
+		opts = append(opts, jwt.WithIssuer(m.issuer))
+	}
+	if audience != "" {
+		opts = append(opts, jwt.WithAudience(audience))
+	}
+
+	if _, err := jwt.ParseWithClaims(raw, claims, m.keyFunc, opts...); err != nil {
+		if errors.Is(err, ErrUnknownKeyID) {
+			return err
+		}
+		return fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	return nil
+}
+
+// keyFunc selects the verification key named by the token's "kid" header
+func (m *TokenManager) keyFunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string) //nolint:errcheck // missing kid is handled below
+	if kid == "" {
+		return nil, fmt.Errorf("%w: token has no kid header", ErrUnknownKeyID)
+	}
+
+	secret, ok := m.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKeyID, kid)
+	}
+	return secret, nil
+}
@@ -0,0 +1,207 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func testConfig() *Config {
+	return &Config{
+		Keys:           map[string]string{"k1": "secret-one"},
+		ActiveKeyID:    "k1",
+		Issuer:         "test-issuer",
+		Audience:       "test-audience",
+		AccessTokenTTL: time.Hour,
+	}
+}
+
+func TestIssueAndParseAccessToken(t *testing.T) {
+	m := NewTokenManager(testConfig())
+
+	signed, err := m.IssueAccessToken(42, "lifter@example.com", 0)
+	if err != nil {
+		t.Fatalf("IssueAccessToken returned error: %v", err)
+	}
+
+	claims, err := m.ParseAccessToken(signed)
+	if err != nil {
+		t.Fatalf("ParseAccessToken returned error: %v", err)
+	}
+
+	id, err := claims.UserID()
+	if err != nil {
+		t.Fatalf("UserID returned error: %v", err)
+	}
+	if id != 42 {
+		t.Errorf("Expected user ID 42, got %d", id)
+	}
+	if claims.Email != "lifter@example.com" {
+		t.Errorf("Expected email lifter@example.com, got %s", claims.Email)
+	}
+	if got := claims.ExpiresAt.Sub(claims.IssuedAt.Time); got != time.Hour {
+		t.Errorf("Expected token lifetime of 1h, got %v", got)
+	}
+}
+
+func TestIssueAccessTokenSetsKeyID(t *testing.T) {
+	m := NewTokenManager(testConfig())
+
+	signed, err := m.IssueAccessToken(1, "a@b.co", 0)
+	if err != nil {
+		t.Fatalf("IssueAccessToken returned error: %v", err)
+	}
+
+	token, _, err := jwt.NewParser().ParseUnverified(signed, &Claims{})
+	if err != nil {
+		t.Fatalf("ParseUnverified returned error: %v", err)
+	}
+	if token.Header["kid"] != "k1" {
+		t.Errorf("Expected kid header k1, got %v", token.Header["kid"])
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldCfg := testConfig()
+	oldToken, err := NewTokenManager(oldCfg).IssueAccessToken(7, "a@b.co", 0)
+	if err != nil {
+		t.Fatalf("IssueAccessToken returned error: %v", err)
+	}
+
+	// Rotate: k2 signs new tokens, k1 is kept for verification only
+	rotated := testConfig()
+	rotated.Keys["k2"] = "secret-two"
+	rotated.ActiveKeyID = "k2"
+	m := NewTokenManager(rotated)
+
+	if _, err := m.ParseAccessToken(oldToken); err != nil {
+		t.Errorf("Expected token signed with retired key to verify, got %v", err)
+	}
+
+	// Retire k1 completely
+	delete(rotated.Keys, "k1")
+	m = NewTokenManager(rotated)
+	if _, err := m.ParseAccessToken(oldToken); !errors.Is(err, ErrUnknownKeyID) {
+		t.Errorf("Expected ErrUnknownKeyID for removed key, got %v", err)
+	}
+}
+
+func TestParseAccessTokenRejections(t *testing.T) {
+	base := testConfig()
+	valid, err := NewTokenManager(base).IssueAccessToken(1, "a@b.co", 0)
+	if err != nil {
+		t.Fatalf("IssueAccessToken returned error: %v", err)
+	}
+
+	expiredManager := NewTokenManager(base)
+	expiredManager.now = func() time.Time { return time.Now().Add(-2 * time.Hour) }
+	expired, err := expiredManager.IssueAccessToken(1, "a@b.co", 0)
+	if err != nil {
+		t.Fatalf("IssueAccessToken returned error: %v", err)
+	}
+
+	noneToken, err := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.RegisteredClaims{Subject: "1"}).
+		SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatalf("Failed to build unsigned token: %v", err)
+	}
+
+	otherIssuer := testConfig()
+	otherIssuer.Issuer = "someone-else"
+
+	otherAudience := testConfig()
+	otherAudience.Audience = "web"
+
+	otherSecret := testConfig()
+	otherSecret.Keys["k1"] = "forged"
+
+	tests := []struct {
+		name  string
+		cfg   *Config
+		token string
+	}{
+		{"expired token", base, expired},
+		{"unsigned token", base, noneToken},
+		{"garbage", base, "not.a.token"},
+		{"wrong issuer", otherIssuer, valid},
+		{"wrong audience", otherAudience, valid},
+		{"wrong secret", otherSecret, valid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewTokenManager(tt.cfg).ParseAccessToken(tt.token); err == nil {
+				t.Error("Expected token to be rejected")
+			}
+		})
+	}
+}
+
+func TestAudienceCheckCanBeDisabled(t *testing.T) {
+	cfg := testConfig()
+	cfg.Audience = ""
+	m := NewTokenManager(cfg)
+
+	signed, err := m.IssueAccessToken(1, "a@b.co", 0)
+	if err != nil {
+		t.Fatalf("IssueAccessToken returned error: %v", err)
+	}
+	if _, err := m.ParseAccessToken(signed); err != nil {
+		t.Errorf("Expected token without audience to verify, got %v", err)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name           string
+		envVars        map[string]string
+		expectedActive string
+		expectedKeys   int
+	}{
+		{
+			name:           "development fallback",
+			envVars:        map[string]string{"JWT_SECRET": "", "JWT_SIGNING_KEYS": ""},
+			expectedActive: defaultKeyID,
+			expectedKeys:   1,
+		},
+		{
+			name:           "rotation keys default to first",
+			envVars:        map[string]string{"JWT_SIGNING_KEYS": "new:s2, old:s1"},
+			expectedActive: "new",
+			expectedKeys:   2,
+		},
+		{
+			name:           "explicit active key",
+			envVars:        map[string]string{"JWT_SIGNING_KEYS": "new:s2,old:s1", "JWT_ACTIVE_KEY_ID": "old"},
+			expectedActive: "old",
+			expectedKeys:   2,
+		},
+		{
+			name:           "unknown active key falls back",
+			envVars:        map[string]string{"JWT_SIGNING_KEYS": "new:s2", "JWT_ACTIVE_KEY_ID": "missing"},
+			expectedActive: "new",
+			expectedKeys:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.envVars {
+				t.Setenv(key, value)
+			}
+
+			cfg := LoadConfig()
+			if cfg.ActiveKeyID != tt.expectedActive {
+				t.Errorf("Expected active key %s, got %s", tt.expectedActive, cfg.ActiveKeyID)
+			}
+			if len(cfg.Keys) != tt.expectedKeys {
+				t.Errorf("Expected %d keys, got %d", tt.expectedKeys, len(cfg.Keys))
+			}
+			if cfg.AccessTokenTTL != defaultAccessTokenTTL {
+				t.Errorf("Expected TTL %v, got %v", defaultAccessTokenTTL, cfg.AccessTokenTTL)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+// Package challenges runs time-boxed group challenges between buddies.
+//
+// A challenge measures one metric over the workouts completed from StartsAt
+// up to, but not including, EndsAt: the number of workouts, the volume
+// (weight times reps of completed sets, in pounds, of one exercise or of
+// every exercise) or the heaviest completed set of an exercise, in pounds.
+//
+// Invited buddies can join until the challenge ends. Late joiners are not
+// backfilled: only workouts completed once a participant joined count, so
+// joining late is a handicap rather than a way to claim earlier work.
+//
+// Standings rank the joined participants by score, highest first. Equal
+// scores go to whoever reached the score first, that is whose workout
+// bringing them to it was completed earlier, then to whoever joined first,
+// then to the lower user ID, so every participant has a distinct rank.
+//
+// Once a challenge ends, the Job records each joined participant's final rank
+// and score, evaluates their achievements with achievements.ChallengeCompleted
+// and tells their clients. Final standings are kept as recorded; editing a
+// workout afterwards does not change them.
+package challenges
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/records"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+)
+
+// Standing is a participant's place on a leaderboard
+type Standing struct {
+	Rank   int     `json:"rank"`
+	UserID uint    `json:"user_id"`
+	Name   string  `json:"name"`
+	Score  float64 `json:"score"`
+	// ReachedAt is when the participant reached their score; nil for a zero
+	// score and in final standings
+	ReachedAt *time.Time `json:"reached_at,omitempty"`
+	JoinedAt  time.Time  `json:"joined_at"`
+}
+
+// Leaderboard ranks the challenge's joined participants. Completed
+// challenges return their recorded final standings; others are computed
+// from the workouts completed up to now.
+func Leaderboard(ctx context.Context, s store.Store, c *database.Challenge, now time.Time) ([]Standing, error) {
+	if c.CompletedAt != nil {
+		return final(c), nil
+	}
+
+	until := c.EndsAt
+	if now.Before(until) {
+		until = now
+	}
+	standings := []Standing{}
+	for _, p := range c.Participants {
+		if p.Status != database.ParticipantJoined || p.JoinedAt == nil {
+			continue
+		}
+		standing := Standing{UserID: p.UserID, Name: p.User.Name, JoinedAt: *p.JoinedAt}
+		since := c.StartsAt
+		if p.JoinedAt.After(since) {
+			since = *p.JoinedAt
+		}
+		if since.Before(until) {
+			workouts, err := s.Workouts().History(ctx, store.HistoryQuery{UserID: p.UserID, Since: since, Until: &until})
+			if err != nil {
+				return nil, fmt.Errorf("failed to load workouts: %w", err)
+			}
+			// The end of the challenge is exclusive
+			workouts = slices.DeleteFunc(workouts, func(w database.Workout) bool { return !w.CompletedAt.Before(c.EndsAt) })
+			standing.Score, standing.ReachedAt = score(c, workouts)
+		}
+		standings = append(standings, standing)
+	}
+
+	slices.SortFunc(standings, compare)
+	for i := range standings {
+		standings[i].Rank = i + 1
+	}
+	return standings, nil
+}
+
+// compare orders standings by score, then by who reached it or joined first
+func compare(a, b Standing) int {
+	if a.Score != b.Score {
+		return cmp.Compare(b.Score, a.Score)
+	}
+	if a.ReachedAt != nil && b.ReachedAt != nil && !a.ReachedAt.Equal(*b.ReachedAt) {
+		return a.ReachedAt.Compare(*b.ReachedAt)
+	}
+	return cmp.Or(a.JoinedAt.Compare(b.JoinedAt), cmp.Compare(a.UserID, b.UserID))
+}
+
+// final returns the recorded standings of a completed challenge
+func final(c *database.Challenge) []Standing {
+	standings := []Standing{}
+	for _, p := range c.Participants {
+		if p.FinalRank == nil || p.JoinedAt == nil {
+			continue
+		}
+		standing := Standing{Rank: *p.FinalRank, UserID: p.UserID, Name: p.User.Name, JoinedAt: *p.JoinedAt}
+		if p.FinalScore != nil {
+			standing.Score = *p.FinalScore
+		}
+		standings = append(standings, standing)
+	}
+	slices.SortFunc(standings, func(a, b Standing) int { return cmp.Compare(a.Rank, b.Rank) })
+	return standings
+}
+
+// score measures the challenge's metric over workouts, oldest first, and
+// returns when the score was reached
+func score(c *database.Challenge, workouts []database.Workout) (float64, *time.Time) {
+	if c.Metric == database.ChallengeMaxWeight {
+		best, reached := heaviest(workouts, c.Exercise)
+		return math.Round(best*10) / 10, reached
+	}
+
+	total := 0.0
+	var reached *time.Time
+	for i := range workouts {
+		w := &workouts[i]
+		switch c.Metric {
+		case database.ChallengeWorkouts:
+			total++
+			reached = &w.CompletedAt
+		case database.ChallengeVolume:
+			if v := w.Volume(c.Exercise); v > 0 {
+				total += v
+				reached = &w.CompletedAt
+			}
+		}
+	}
+	return math.Round(total*10) / 10, reached
+}
+
+// heaviest returns the heaviest completed set of exercise in workouts, oldest
+// first, in pounds, and when it was first lifted
+func heaviest(workouts []database.Workout, exercise string) (float64, *time.Time) {
+	for _, r := range records.Heaviest(workouts) {
+		if strings.EqualFold(r.Exercise, exercise) && r.Pounds() > 0 {
+			return r.Pounds(), &r.AchievedAt
+		}
+	}
+	return 0, nil
+}
@@ -0,0 +1,237 @@
+package challenges
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/achievements"
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/realtime"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+)
+
+var start = time.Date(2025, time.October, 1, 0, 0, 0, 0, time.UTC)
+
+// recorder is a realtime.Publisher that keeps what it is given
+type recorder []realtime.Event
+
+func (r *recorder) Publish(_ context.Context, event realtime.Event) error {
+	*r = append(*r, event)
+	return nil
+}
+
+// newChallenge stores a squat volume challenge over October between the
+// named users, who joined the given number of days after it started; a
+// negative day leaves them invited
+func newChallenge(t *testing.T, s store.Store, joined map[string]int) (*database.Challenge, map[string]uint) {
+	t.Helper()
+	ctx := context.Background()
+	if err := s.Exercises().Save(ctx, &database.Exercise{Name: "Squat"}); err != nil {
+		t.Fatalf("Failed to add exercise: %v", err)
+	}
+
+	challenge := &database.Challenge{
+		Name:     "Squat October",
+		Metric:   database.ChallengeVolume,
+		Exercise: "Squat",
+		StartsAt: start,
+		EndsAt:   start.AddDate(0, 0, 31),
+	}
+	ids := make(map[string]uint, len(joined))
+	for _, name := range []string{"Sam", "Kim", "Alex", "Jo"} {
+		day, ok := joined[name]
+		if !ok {
+			continue
+		}
+		user := &database.User{Email: name + "@example.com", Name: name}
+		if err := s.Users().Create(ctx, user); err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		ids[name] = user.ID
+		p := database.ChallengeParticipant{UserID: user.ID, Status: database.ParticipantInvited, InvitedAt: start}
+		if day >= 0 {
+			at := start.AddDate(0, 0, day)
+			p.Status, p.JoinedAt = database.ParticipantJoined, &at
+		}
+		challenge.Participants = append(challenge.Participants, p)
+	}
+	challenge.CreatorID = ids["Sam"]
+	if err := s.Challenges().Create(ctx, challenge); err != nil {
+		t.Fatalf("Failed to create challenge: %v", err)
+	}
+	challenge, err := s.Challenges().Get(ctx, challenge.ID)
+	if err != nil {
+		t.Fatalf("Failed to load challenge: %v", err)
+	}
+	return challenge, ids
+}
+
+// logSquats logs a workout of one completed set of five squats
+func logSquats(t *testing.T, s store.Store, userID uint, at time.Time, weight float64, unit string) {
+	t.Helper()
+	ctx := context.Background()
+	catalog, err := s.Exercises().List(ctx)
+	if err != nil || len(catalog) != 1 {
+		t.Fatalf("Failed to load the squat: %v", err)
+	}
+	workout := &database.Workout{
+		UserID:      userID,
+		CompletedAt: at,
+		Sets:        []database.WorkoutSet{{ExerciseID: catalog[0].ID, Reps: 5, Weight: weight, Unit: unit, Completed: true}},
+	}
+	if err := s.Workouts().Create(ctx, workout); err != nil {
+		t.Fatalf("Failed to log workout: %v", err)
+	}
+}
+
+func TestLeaderboard(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	c, ids := newChallenge(t, s, map[string]int{"Sam": 0, "Kim": 0, "Alex": 10, "Jo": -1})
+
+	day := func(d, hour int) time.Time { return start.AddDate(0, 0, d).Add(time.Duration(hour) * time.Hour) }
+	logSquats(t, s, ids["Sam"], day(-1, 18), 500, database.UnitPounds) // Before the start
+	logSquats(t, s, ids["Sam"], day(2, 18), 100, database.UnitPounds)
+	logSquats(t, s, ids["Sam"], day(12, 18), 100, database.UnitPounds)
+	logSquats(t, s, ids["Kim"], day(3, 8), 200, database.UnitPounds)  // Reaches 1000 first
+	logSquats(t, s, ids["Alex"], day(5, 8), 500, database.UnitPounds) // Before joining
+	logSquats(t, s, ids["Alex"], day(11, 8), 50, database.UnitKilograms)
+	logSquats(t, s, ids["Jo"], day(4, 8), 500, database.UnitPounds)   // Never joined
+	logSquats(t, s, ids["Kim"], day(31, 0), 500, database.UnitPounds) // At the exclusive end
+
+	standings, err := Leaderboard(ctx, s, c, day(40, 0))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []struct {
+		name  string
+		score float64
+	}{
+		{"Kim", 1000},
+		{"Sam", 1000},
+		{"Alex", 551.2},
+	}
+	if len(standings) != len(expected) {
+		t.Fatalf("Expected %d standings, got %+v", len(expected), standings)
+	}
+	for i, want := range expected {
+		got := standings[i]
+		if got.Rank != i+1 || got.Name != want.name || got.Score != want.score {
+			t.Errorf("Expected #%d to be %s with %v, got %+v", i+1, want.name, want.score, got)
+		}
+	}
+
+	// Halfway through only what was logged so far counts
+	standings, err = Leaderboard(ctx, s, c, day(5, 0))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(standings) != 3 || standings[0].Name != "Kim" || standings[1].Score != 500 || standings[2].Score != 0 {
+		t.Errorf("Expected the live standings as of day 5, got %+v", standings)
+	}
+}
+
+func TestCompareBreaksTies(t *testing.T) {
+	early, late := start.Add(time.Hour), start.Add(2*time.Hour)
+	tests := []struct {
+		name   string
+		a, b   Standing
+		aFirst bool
+	}{
+		{"higher score", Standing{UserID: 2, Score: 10, ReachedAt: &late}, Standing{UserID: 1, Score: 5, ReachedAt: &early}, true},
+		{"reached first", Standing{UserID: 2, Score: 10, ReachedAt: &late}, Standing{UserID: 1, Score: 10, ReachedAt: &early}, false},
+		{"joined first", Standing{UserID: 2, JoinedAt: early}, Standing{UserID: 1, JoinedAt: late}, true},
+		{"lower user ID", Standing{UserID: 1, JoinedAt: early}, Standing{UserID: 2, JoinedAt: early}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := compare(tt.a, tt.b) < 0; got != tt.aFirst {
+				t.Errorf("Expected a before b to be %v", tt.aFirst)
+			}
+			if got := compare(tt.b, tt.a) > 0; got != tt.aFirst {
+				t.Errorf("Expected the order to be antisymmetric")
+			}
+		})
+	}
+}
+
+func TestCompleteRecordsStandings(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	rule := `{"metric": "challenges_won"}`
+	if err := s.Achievements().Save(ctx, &database.Achievement{Name: "Winner", Target: 1, Rule: &rule}); err != nil {
+		t.Fatalf("Failed to add achievement: %v", err)
+	}
+	c, ids := newChallenge(t, s, map[string]int{"Sam": 0, "Kim": 1, "Jo": -1})
+	logSquats(t, s, ids["Kim"], start.AddDate(0, 0, 2), 100, database.UnitPounds)
+
+	var events recorder
+	job := NewJob(s, achievements.NewEngine(s), &events, DefaultConfig())
+	if n, err := job.Complete(ctx, c.EndsAt.Add(-time.Second)); err != nil || n != 0 {
+		t.Fatalf("Expected nothing to complete before the end, got %d, %v", n, err)
+	}
+	now := c.EndsAt.Add(time.Minute)
+	if n, err := job.Complete(ctx, now); err != nil || n != 1 {
+		t.Fatalf("Expected the challenge to complete, got %d, %v", n, err)
+	}
+	if n, err := job.Complete(ctx, now); err != nil || n != 0 {
+		t.Errorf("Expected a second run to complete nothing, got %d, %v", n, err)
+	}
+
+	// Workouts logged afterwards do not change the recorded standings
+	logSquats(t, s, ids["Sam"], start.AddDate(0, 0, 3), 500, database.UnitPounds)
+	completed, err := s.Challenges().Get(ctx, c.ID)
+	if err != nil {
+		t.Fatalf("Failed to load challenge: %v", err)
+	}
+	if completed.CompletedAt == nil {
+		t.Fatal("Expected the challenge to be completed")
+	}
+	standings, err := Leaderboard(ctx, s, completed, now)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(standings) != 2 || standings[0].UserID != ids["Kim"] || standings[0].Score != 500 || standings[1].Rank != 2 {
+		t.Errorf("Expected Kim to win with 500, got %+v", standings)
+	}
+
+	kinds := make(map[uint][]string)
+	for _, e := range events {
+		kinds[e.UserID] = append(kinds[e.UserID], e.Type)
+	}
+	if got := kinds[ids["Kim"]]; len(got) != 2 || got[0] != realtime.EventChallengeCompleted || got[1] != realtime.EventAchievementUnlocked {
+		t.Errorf("Expected Kim to hear of the result and the achievement, got %v", got)
+	}
+	if got := kinds[ids["Sam"]]; len(got) != 1 || got[0] != realtime.EventChallengeCompleted {
+		t.Errorf("Expected Sam to hear of the result only, got %v", got)
+	}
+	if got := kinds[ids["Jo"]]; len(got) != 0 {
+		t.Errorf("Expected Jo, who never joined, to hear nothing, got %v", got)
+	}
+}
+
+func TestScoreMaxWeight(t *testing.T) {
+	squat := database.Exercise{ID: 1, Name: "Squat"}
+	workout := func(id uint, day int, weight float64, unit string, completed bool) database.Workout {
+		return database.Workout{ID: id, CompletedAt: start.AddDate(0, 0, day), Sets: []database.WorkoutSet{
+			{ExerciseID: squat.ID, Exercise: squat, Reps: 1, Weight: weight, Unit: unit, Completed: completed},
+		}}
+	}
+	workouts := []database.Workout{
+		workout(1, 1, 100, database.UnitPounds, true),
+		workout(2, 2, 100, database.UnitKilograms, true),
+		workout(3, 3, 300, database.UnitPounds, false), // Missed
+		workout(4, 4, 220, database.UnitPounds, true),
+	}
+	c := &database.Challenge{Metric: database.ChallengeMaxWeight, Exercise: "squat"}
+
+	got, reached := score(c, workouts)
+	if got != 220.5 || reached == nil || !reached.Equal(workouts[1].CompletedAt) {
+		t.Errorf("Expected 220.5 lb reached on day 2, got %v at %v", got, reached)
+	}
+	if got, reached := score(c, nil); got != 0 || reached != nil {
+		t.Errorf("Expected no score without workouts, got %v at %v", got, reached)
+	}
+}
@@ -0,0 +1,173 @@
+package challenges
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/achievements"
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/env"
+	"github.com/lucas-albers-lz4/ferrovis/internal/realtime"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+)
+
+const defaultInterval = 5 * time.Minute
+
+// Config controls how often ended challenges are completed
+type Config struct {
+	Interval time.Duration
+}
+
+// DefaultConfig returns a five minute interval
+func DefaultConfig() *Config {
+	return &Config{Interval: defaultInterval}
+}
+
+// LoadConfig loads the defaults, overriding the interval from CHALLENGE_INTERVAL
+func LoadConfig() *Config {
+	cfg := DefaultConfig()
+	cfg.Interval = env.Duration("CHALLENGE_INTERVAL", cfg.Interval)
+	return cfg
+}
+
+// Job completes challenges once they end
+type Job struct {
+	store        store.Store
+	achievements *achievements.Engine
+	events       realtime.Publisher
+	cfg          *Config
+}
+
+// NewJob creates a Job that evaluates participants' achievements with
+// engine and publishes completions to events, which may be nil
+func NewJob(s store.Store, engine *achievements.Engine, events realtime.Publisher, cfg *Config) *Job {
+	return &Job{store: s, achievements: engine, events: events, cfg: cfg}
+}
+
+// Run calls Complete every configured interval until ctx is done
+func (j *Job) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			completed, err := j.Complete(ctx, now)
+			if err != nil {
+				slog.Error("Challenge completion failed", "error", err)
+			}
+			if completed > 0 {
+				slog.Info("Challenges completed", "count", completed)
+			}
+		}
+	}
+}
+
+// Complete records the final standings of the challenges that ended by now,
+// returning how many it completed. A challenge completed concurrently, by
+// another replica, is skipped.
+func (j *Job) Complete(ctx context.Context, now time.Time) (int, error) {
+	due, err := j.store.Challenges().Due(ctx, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load due challenges: %w", err)
+	}
+
+	completed := 0
+	for i := range due {
+		standings, err := j.complete(ctx, &due[i], now)
+		if err != nil {
+			return completed, fmt.Errorf("challenge %d: %w", due[i].ID, err)
+		}
+		if standings == nil {
+			continue
+		}
+		completed++
+		j.notify(ctx, &due[i], standings, now)
+	}
+	return completed, nil
+}
+
+// complete records a challenge's final standings, returning nil when it was
+// already completed
+func (j *Job) complete(ctx context.Context, c *database.Challenge, now time.Time) ([]Standing, error) {
+	var standings []Standing
+	err := j.store.Transaction(ctx, func(tx store.Store) error {
+		n, err := tx.Challenges().Complete(ctx, c.ID, now)
+		if err != nil || n == 0 {
+			return err //nolint:wrapcheck // wrapped by the store
+		}
+		standings, err = Leaderboard(ctx, tx, c, c.EndsAt)
+		if err != nil {
+			return err
+		}
+
+		ranks := make(map[uint]Standing, len(standings))
+		for _, s := range standings {
+			ranks[s.UserID] = s
+		}
+		for k := range c.Participants {
+			p := &c.Participants[k]
+			s, ok := ranks[p.UserID]
+			if !ok {
+				continue
+			}
+			p.FinalRank, p.FinalScore = &s.Rank, &s.Score
+			if err := tx.Challenges().UpdateParticipant(ctx, p); err != nil {
+				return err //nolint:wrapcheck // wrapped by the store
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record final standings: %w", err)
+	}
+	if standings != nil {
+		c.CompletedAt = &now
+	}
+	return standings, nil
+}
+
+// notify evaluates the participants' achievements and publishes the final
+// standings. The standings are already recorded, so failures are only logged.
+func (j *Job) notify(ctx context.Context, c *database.Challenge, standings []Standing, now time.Time) {
+	for _, s := range standings {
+		var unlocked []database.Achievement
+		if j.achievements != nil {
+			var err error
+			unlocked, err = j.achievements.Evaluate(ctx, s.UserID, achievements.ChallengeCompleted)
+			if err != nil {
+				slog.Warn("Failed to evaluate challenge achievements", "user_id", s.UserID, "challenge_id", c.ID, "error", err)
+			}
+		}
+		if j.events == nil {
+			continue
+		}
+
+		result := map[string]any{
+			"challenge_id": c.ID,
+			"name":         c.Name,
+			"rank":         s.Rank,
+			"score":        s.Score,
+			"participants": len(standings),
+		}
+		j.publish(ctx, s.UserID, realtime.EventChallengeCompleted, result, now)
+		for k := range unlocked {
+			j.publish(ctx, s.UserID, realtime.EventAchievementUnlocked, &unlocked[k], now)
+		}
+	}
+}
+
+// publish sends one event to the user's clients, logging failures
+func (j *Job) publish(ctx context.Context, userID uint, eventType string, data any, at time.Time) {
+	event, err := realtime.NewEvent(userID, eventType, data, at)
+	if err == nil {
+		err = j.events.Publish(ctx, event)
+	}
+	if err != nil {
+		slog.Warn("Failed to publish event", "user_id", userID, "type", eventType, "error", err)
+	}
+}
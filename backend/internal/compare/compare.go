@@ -0,0 +1,236 @@
+// Package compare measures a user against their buddies week over week and
+// turns the gaps into peer-pressure insights.
+//
+// Everyone is measured over the same Monday to Sunday weeks, taken in the
+// time zone of the user asking, so a buddy elsewhere is not judged on a
+// different week. Only active peer relationships are compared: coaches are
+// not training alongside their clients. Insights are only produced for users
+// who allow social pressure.
+package compare
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"text/template"
+	"time"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/records"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+)
+
+const daysPerWeek = 7
+
+// Insight kinds, most pressing first
+const (
+	KindBehindWorkouts = "behind_workouts"
+	KindBuddyRecords   = "buddy_records"
+	KindBehindVolume   = "behind_volume"
+	KindBehindStreak   = "behind_streak"
+	KindAheadWorkouts  = "ahead_workouts"
+)
+
+var kinds = []string{KindBehindWorkouts, KindBuddyRecords, KindBehindVolume, KindBehindStreak, KindAheadWorkouts}
+
+var insightTemplates = func() map[string]*template.Template {
+	funcs := template.FuncMap{"plural": func(n int, one, many string) string {
+		if n == 1 {
+			return one
+		}
+		return many
+	}}
+	texts := map[string]string{
+		KindBehindWorkouts: `You're behind {{.BuddyName}} by {{.Gap}} {{plural .Gap "workout" "workouts"}} this week.`,
+		KindBuddyRecords:   `{{.BuddyName}} set {{.Gap}} personal {{plural .Gap "record" "records"}} this week. Your move.`,
+		KindBehindVolume:   `{{.BuddyName}} has lifted {{.Gap}} lb more than you this week.`,
+		KindBehindStreak:   `{{.BuddyName}}'s streak is {{.Gap}} {{plural .Gap "day" "days"}} longer than yours.`,
+		KindAheadWorkouts:  `You're ahead of {{.BuddyName}} by {{.Gap}} {{plural .Gap "workout" "workouts"}} this week. Don't let them catch up.`,
+	}
+	out := make(map[string]*template.Template, len(texts))
+	for kind, text := range texts {
+		out[kind] = template.Must(template.New(kind).Funcs(funcs).Parse(text))
+	}
+	return out
+}()
+
+// Week sums one person's training over a week
+type Week struct {
+	Workouts int `json:"workouts"`
+	// Volume is the weight times reps of completed sets, in pounds
+	Volume float64 `json:"volume"`
+	// Records counts the exercises whose best weight was beaten, once per workout
+	Records int `json:"records"`
+}
+
+// Stats describes one person's training this week and last
+type Stats struct {
+	UserID   uint   `json:"user_id"`
+	Name     string `json:"name"`
+	ThisWeek Week   `json:"this_week"`
+	LastWeek Week   `json:"last_week"`
+	// Streak is the current workout streak in days; zero once it lapsed
+	Streak        int `json:"streak"`
+	LongestStreak int `json:"longest_streak"`
+}
+
+// Insight is a templated observation about the user and one buddy. Gap is
+// the difference it reports, in the unit of its kind.
+type Insight struct {
+	Kind      string `json:"kind"`
+	BuddyID   uint   `json:"buddy_id"`
+	BuddyName string `json:"buddy_name"`
+	Gap       int    `json:"gap"`
+	Text      string `json:"text"`
+}
+
+// Comparison measures the user against each active buddy
+type Comparison struct {
+	// WeekStart is the Monday midnight, in the user's time zone, starting this week
+	WeekStart time.Time `json:"week_start"`
+	You       Stats     `json:"you"`
+	Buddies   []Stats   `json:"buddies"`
+	// Insights are most pressing first; empty unless the user allows social pressure
+	Insights []Insight `json:"insights"`
+}
+
+// Compare measures the user and their active peers over the week containing
+// at and the week before. Workouts after at are ignored.
+func Compare(ctx context.Context, s store.Store, user *database.User, at time.Time) (*Comparison, error) {
+	weekStart := startOfWeek(at, user.Location())
+	out := &Comparison{WeekStart: weekStart, Buddies: []Stats{}, Insights: []Insight{}}
+
+	you, err := measure(ctx, s, user, weekStart, at)
+	if err != nil {
+		return nil, err
+	}
+	out.You = *you
+
+	buddies, err := peers(ctx, s, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range buddies {
+		stats, err := measure(ctx, s, &buddies[i], weekStart, at)
+		if err != nil {
+			return nil, err
+		}
+		out.Buddies = append(out.Buddies, *stats)
+	}
+
+	if user.AllowSocialPressure {
+		out.Insights, err = insights(&out.You, out.Buddies)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// peers returns the user's active peer buddies, on either side of the
+// relationship, ordered by name
+func peers(ctx context.Context, s store.Store, userID uint) ([]database.User, error) {
+	rels, err := s.Buddies().List(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load buddies: %w", err)
+	}
+
+	out := database.ActivePeers(rels, userID)
+	slices.SortFunc(out, func(a, b database.User) int {
+		return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.ID, b.ID))
+	})
+	return out, nil
+}
+
+// measure computes someone's stats for the week starting at weekStart and
+// the one before, from their history up to at
+func measure(ctx context.Context, s store.Store, user *database.User, weekStart, at time.Time) (*Stats, error) {
+	workouts, err := s.Workouts().History(ctx, store.HistoryQuery{UserID: user.ID, Until: &at})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load workouts: %w", err)
+	}
+
+	stats := &Stats{UserID: user.ID, Name: user.Name}
+	lastWeekStart := weekStart.AddDate(0, 0, -daysPerWeek)
+	bucket := func(when time.Time) *Week {
+		switch {
+		case !when.Before(weekStart):
+			return &stats.ThisWeek
+		case !when.Before(lastWeekStart):
+			return &stats.LastWeek
+		}
+		return nil
+	}
+	for i := range workouts {
+		if week := bucket(workouts[i].CompletedAt); week != nil {
+			week.Workouts++
+			week.Volume += workouts[i].Volume("")
+		}
+	}
+	for _, r := range records.Set(workouts) {
+		if week := bucket(r.AchievedAt); week != nil {
+			week.Records++
+		}
+	}
+	stats.ThisWeek.Volume = math.Round(stats.ThisWeek.Volume)
+	stats.LastWeek.Volume = math.Round(stats.LastWeek.Volume)
+
+	streak, err := s.Streaks().Get(ctx, user.ID, database.StreakWorkout)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+	case err != nil:
+		return nil, fmt.Errorf("failed to load streak: %w", err)
+	default:
+		if streak.IsActive {
+			stats.Streak = streak.Current
+		}
+		stats.LongestStreak = streak.Longest
+	}
+	return stats, nil
+}
+
+// insights compares you with each buddy, most pressing first
+func insights(you *Stats, buddies []Stats) ([]Insight, error) {
+	out := []Insight{}
+	for i := range buddies {
+		b := &buddies[i]
+		gaps := map[string]int{
+			KindBehindWorkouts: b.ThisWeek.Workouts - you.ThisWeek.Workouts,
+			KindAheadWorkouts:  you.ThisWeek.Workouts - b.ThisWeek.Workouts,
+			KindBehindVolume:   int(math.Round(b.ThisWeek.Volume - you.ThisWeek.Volume)),
+			KindBehindStreak:   b.Streak - you.Streak,
+		}
+		if b.ThisWeek.Records > you.ThisWeek.Records {
+			gaps[KindBuddyRecords] = b.ThisWeek.Records
+		}
+		for _, kind := range kinds {
+			if gaps[kind] <= 0 {
+				continue
+			}
+			insight := Insight{Kind: kind, BuddyID: b.UserID, BuddyName: b.Name, Gap: gaps[kind]}
+			var buf bytes.Buffer
+			if err := insightTemplates[kind].Execute(&buf, insight); err != nil {
+				return nil, fmt.Errorf("failed to render %s insight: %w", kind, err)
+			}
+			insight.Text = buf.String()
+			out = append(out, insight)
+		}
+	}
+	// Buddies are already ordered by name, so ties keep that order
+	slices.SortStableFunc(out, func(a, b Insight) int {
+		return cmp.Or(cmp.Compare(slices.Index(kinds, a.Kind), slices.Index(kinds, b.Kind)), cmp.Compare(b.Gap, a.Gap))
+	})
+	return out, nil
+}
+
+// startOfWeek returns midnight of the Monday starting t's week in loc
+func startOfWeek(t time.Time, loc *time.Location) time.Time {
+	local := t.In(loc)
+	y, m, d := local.Date()
+	offset := (int(local.Weekday()) + daysPerWeek - 1) % daysPerWeek
+	return time.Date(y, m, d-offset, 0, 0, 0, 0, loc)
+}
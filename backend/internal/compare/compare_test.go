@@ -0,0 +1,141 @@
+package compare
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+)
+
+func TestCompare(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	// Wednesday; the week started on Monday the 10th
+	at := time.Date(2025, time.March, 12, 12, 0, 0, 0, time.UTC)
+
+	squat := &database.Exercise{Name: "Squat"}
+	if err := s.Exercises().Save(ctx, squat); err != nil {
+		t.Fatalf("Failed to add exercise: %v", err)
+	}
+	users := make(map[string]*database.User)
+	for _, name := range []string{"Sam", "Alex", "Kim", "Jo"} {
+		user := &database.User{Email: name + "@example.com", Name: name}
+		if err := s.Users().Create(ctx, user); err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		users[name] = user
+	}
+	sam := users["Sam"]
+
+	rels := []database.BuddyRelationship{
+		{UserID: sam.ID, BuddyID: users["Alex"].ID, RelationshipType: database.RelationshipPeer, Status: database.BuddyActive},
+		{UserID: sam.ID, BuddyID: users["Kim"].ID, RelationshipType: database.RelationshipCoach, Status: database.BuddyActive},
+		{UserID: users["Jo"].ID, BuddyID: sam.ID, RelationshipType: database.RelationshipPeer, Status: database.BuddyPaused},
+	}
+	for i := range rels {
+		if err := s.Buddies().Create(ctx, &rels[i]); err != nil {
+			t.Fatalf("Failed to create relationship: %v", err)
+		}
+	}
+
+	logged := []struct {
+		user   string
+		day    int
+		hour   int
+		weight float64
+	}{
+		{"Sam", 5, 18, 100},
+		{"Sam", 11, 18, 110},
+		{"Alex", 3, 8, 100},
+		{"Alex", 10, 8, 105},
+		{"Alex", 11, 8, 100},
+		{"Alex", 12, 8, 110},
+		{"Alex", 12, 18, 200}, // Later than at
+		{"Kim", 11, 8, 300},
+		{"Jo", 11, 8, 300},
+	}
+	for _, l := range logged {
+		workout := &database.Workout{
+			UserID:      users[l.user].ID,
+			CompletedAt: time.Date(2025, time.March, l.day, l.hour, 0, 0, 0, time.UTC),
+			Sets:        []database.WorkoutSet{{ExerciseID: squat.ID, Reps: 5, Weight: l.weight, Unit: database.UnitPounds, Completed: true}},
+		}
+		if err := s.Workouts().Create(ctx, workout); err != nil {
+			t.Fatalf("Failed to log workout: %v", err)
+		}
+	}
+	for name, current := range map[string]int{"Sam": 2, "Alex": 5} {
+		streak := &database.Streak{UserID: users[name].ID, StreakType: database.StreakWorkout, Current: current, Longest: current, IsActive: true}
+		if err := s.Streaks().Save(ctx, streak); err != nil {
+			t.Fatalf("Failed to save streak: %v", err)
+		}
+	}
+
+	comparison, err := Compare(ctx, s, sam, at)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if want := time.Date(2025, time.March, 10, 0, 0, 0, 0, time.UTC); !comparison.WeekStart.Equal(want) {
+		t.Errorf("Expected the week to start on %v, got %v", want, comparison.WeekStart)
+	}
+	if want := (Week{Workouts: 1, Volume: 550, Records: 1}); comparison.You.ThisWeek != want || comparison.You.LastWeek.Workouts != 1 {
+		t.Errorf("Expected Sam's week to be %+v, got %+v", want, comparison.You)
+	}
+	if len(comparison.Buddies) != 1 || comparison.Buddies[0].Name != "Alex" {
+		t.Fatalf("Expected only Alex to be compared, got %+v", comparison.Buddies)
+	}
+	if want := (Week{Workouts: 3, Volume: 1575, Records: 2}); comparison.Buddies[0].ThisWeek != want {
+		t.Errorf("Expected Alex's week to be %+v, got %+v", want, comparison.Buddies[0].ThisWeek)
+	}
+
+	want := []string{
+		"You're behind Alex by 2 workouts this week.",
+		"Alex set 2 personal records this week. Your move.",
+		"Alex has lifted 1025 lb more than you this week.",
+		"Alex's streak is 3 days longer than yours.",
+	}
+	if len(comparison.Insights) != len(want) {
+		t.Fatalf("Expected %d insights, got %+v", len(want), comparison.Insights)
+	}
+	for i, text := range want {
+		if comparison.Insights[i].Text != text {
+			t.Errorf("Expected insight %d to be %q, got %q", i, text, comparison.Insights[i].Text)
+		}
+	}
+
+	// Alex sees the mirror image
+	comparison, err = Compare(ctx, s, users["Alex"], at)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(comparison.Insights) != 1 || comparison.Insights[0].Text != "You're ahead of Sam by 2 workouts this week. Don't let them catch up." {
+		t.Errorf("Expected Alex to be ahead, got %+v", comparison.Insights)
+	}
+
+	// Without consent to social pressure the stats remain but insights go
+	sam.AllowSocialPressure = false
+	comparison, err = Compare(ctx, s, sam, at)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(comparison.Insights) != 0 || len(comparison.Buddies) != 1 {
+		t.Errorf("Expected stats without insights, got %+v", comparison)
+	}
+}
+
+func TestStartOfWeek(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skipf("Time zone data unavailable: %v", err)
+	}
+	// Sunday evening in UTC is already Monday in Tokyo
+	at := time.Date(2025, time.March, 16, 20, 0, 0, 0, time.UTC)
+	if got, want := startOfWeek(at, time.UTC), time.Date(2025, time.March, 10, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if got, want := startOfWeek(at, tokyo), time.Date(2025, time.March, 17, 0, 0, 0, 0, tokyo); !got.Equal(want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
@@ -38,18 +38,25 @@ func LoadConfig() *Config {
 	}
 }
 
-// Connect establishes a connection to the PostgreSQL database
-func Connect() error {
-	config := LoadConfig()
-
-	dsn := fmt.Sprintf(
+// DSN returns the connection string for the configured database
+func (c *Config) DSN() string {
+	return fmt.Sprintf(
 		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		config.Host, config.Port, config.User, config.Password, config.DBName, config.SSLMode,
+		c.Host, c.Port, c.User, c.Password, c.DBName, c.SSLMode,
 	)
+}
+
+// Connect establishes a connection to the PostgreSQL database. It does not
+// migrate or seed; see RunMigrations and package seed.
+func Connect() error {
+	config := LoadConfig()
+	dsn := config.DSN()
 
 	// Configure GORM logger for development
 	gormConfig := &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info),
+		// Translate driver errors (e.g. unique violations) into gorm.ErrDuplicatedKey
+		TranslateError: true,
 	}
 
 	// Set environment-specific logging
@@ -79,11 +86,6 @@ func Connect() error {
 		"host", config.Host,
 		"database", config.DBName)
 
-	// Run migrations
-	if err := RunMigrations(); err != nil {
-		return fmt.Errorf("failed to run migrations: %w", err)
-	}
-
 	return nil
 }
 
@@ -0,0 +1,160 @@
+package database
+
+// Weasel Mode intensity levels, from polite reminders to unhinged nagging
+const (
+	IntensityGentle     = "gentle"
+	IntensityMedium     = "medium"
+	IntensityAggressive = "aggressive"
+	IntensityFullChaos  = "full_chaos"
+)
+
+// Weasel message types
+const (
+	MessageGuilt   = "guilt"
+	MessageFOMO    = "fomo"
+	MessageUrgency = "urgency"
+	MessageSocial  = "social"
+	MessageFunny   = "funny"
+)
+
+// MessageTypes lists every Weasel message type
+var MessageTypes = []string{MessageGuilt, MessageFOMO, MessageUrgency, MessageSocial, MessageFunny}
+
+// MessageCoach marks messages written by a coach. They are delivered as
+// written instead of being rendered from templates, so the type is not in
+// MessageTypes.
+const MessageCoach = "coach"
+
+// Reactions a user can record to a Weasel message
+const (
+	ReactionIgnored   = "ignored"
+	ReactionAnnoyed   = "annoyed"
+	ReactionMotivated = "motivated"
+	ReactionWorkedOut = "worked_out"
+)
+
+// Notification delivery channels and states
+const (
+	ChannelPush    = "push"
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+
+	NotificationPending   = "pending"
+	NotificationDelivered = "delivered"
+	NotificationFailed    = "failed"
+)
+
+// Channels lists every notification delivery channel
+var Channels = []string{ChannelPush, ChannelEmail, ChannelWebhook}
+
+// Preferred workout times of day
+const (
+	WorkoutTimeMorning   = "morning"
+	WorkoutTimeAfternoon = "afternoon"
+	WorkoutTimeEvening   = "evening"
+)
+
+// Fitness goals a user can pick during onboarding
+const (
+	GoalStrength   = "strength"
+	GoalEndurance  = "endurance"
+	GoalWeightLoss = "weight_loss"
+	GoalGeneral    = "general"
+)
+
+// WeaselIntensities lists the valid intensity levels, mildest first
+var WeaselIntensities = []string{IntensityGentle, IntensityMedium, IntensityAggressive, IntensityFullChaos}
+
+// Weight units for logged sets
+const (
+	UnitPounds    = "lb"
+	UnitKilograms = "kg"
+)
+
+// PoundsPerKilogram converts between the weight units
+const PoundsPerKilogram = 2.20462
+
+// ConvertWeight converts weight between units; an empty unit means pounds
+func ConvertWeight(weight float64, from, to string) float64 {
+	if from == UnitKilograms {
+		weight *= PoundsPerKilogram
+	}
+	if to == UnitKilograms {
+		weight /= PoundsPerKilogram
+	}
+	return weight
+}
+
+// Enrollment states. Active and paused enrollments are "current"; a user has
+// at most one current enrollment.
+const (
+	EnrollmentActive    = "active"
+	EnrollmentPaused    = "paused"
+	EnrollmentCompleted = "completed"
+	EnrollmentAbandoned = "abandoned"
+)
+
+// Buddy relationship types and states
+const (
+	RelationshipPeer  = "peer"
+	RelationshipCoach = "coach"
+
+	BuddyPending = "pending"
+	BuddyActive  = "active"
+	BuddyPaused  = "paused"
+)
+
+// Roles an invitee can take: a peer, the inviter's coach or the inviter's
+// client. Buddy invites move from pending to one of the other states.
+const (
+	InviteRolePeer   = "peer"
+	InviteRoleCoach  = "coach"
+	InviteRoleClient = "client"
+
+	InvitePending   = "pending"
+	InviteAccepted  = "accepted"
+	InviteDeclined  = "declined"
+	InviteCancelled = "cancelled"
+)
+
+// Permissions a client grants their coach. Every permission is granted when
+// a coaching relationship is accepted; the client can revoke each one.
+const (
+	PermissionViewWorkouts   = "view_workouts"
+	PermissionViewRecords    = "view_records"
+	PermissionManagePrograms = "manage_programs"
+	PermissionSendMessages   = "send_messages"
+)
+
+// CoachPermissions lists every coach permission
+var CoachPermissions = []string{PermissionViewWorkouts, PermissionViewRecords, PermissionManagePrograms, PermissionSendMessages}
+
+// Challenge metrics: workouts counts workouts, volume sums weight times reps
+// and max_weight is the heaviest set, both in pounds
+const (
+	ChallengeWorkouts  = "workouts"
+	ChallengeVolume    = "volume"
+	ChallengeMaxWeight = "max_weight"
+)
+
+// ChallengeMetrics lists every challenge metric
+var ChallengeMetrics = []string{ChallengeWorkouts, ChallengeVolume, ChallengeMaxWeight}
+
+// Challenge participants start out invited, except the creator who joins
+// right away
+const (
+	ParticipantInvited  = "invited"
+	ParticipantJoined   = "joined"
+	ParticipantDeclined = "declined"
+)
+
+// Streak types: consecutive days, Monday to Sunday weeks and calendar months
+// with at least one workout
+const (
+	StreakWorkout = "workout"
+	StreakWeekly  = "weekly"
+	StreakMonthly = "monthly"
+)
+
+// StreakTypes lists every streak type
+var StreakTypes = []string{StreakWorkout, StreakWeekly, StreakMonthly}
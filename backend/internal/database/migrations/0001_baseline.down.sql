@@ -0,0 +1,16 @@
+-- Drops every baseline table, dependents first
+DROP TABLE IF EXISTS "enrollment_lifts";
+DROP TABLE IF EXISTS "enrollments";
+DROP TABLE IF EXISTS "action_tokens";
+DROP TABLE IF EXISTS "refresh_tokens";
+DROP TABLE IF EXISTS "fake_social_activities";
+DROP TABLE IF EXISTS "streaks";
+DROP TABLE IF EXISTS "weasel_messages";
+DROP TABLE IF EXISTS "buddy_relationships";
+DROP TABLE IF EXISTS "user_achievements";
+DROP TABLE IF EXISTS "achievements";
+DROP TABLE IF EXISTS "workout_sets";
+DROP TABLE IF EXISTS "exercises";
+DROP TABLE IF EXISTS "workouts";
+DROP TABLE IF EXISTS "programs";
+DROP TABLE IF EXISTS "users";
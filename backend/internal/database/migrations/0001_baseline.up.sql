@@ -0,0 +1,284 @@
+-- Baseline schema: the tables previously created by GORM AutoMigrate.
+--
+-- Every statement is idempotent. Databases built by AutoMigrate are adopted
+-- by running this file over the existing schema, which fills in anything
+-- older builds never created, and recording it as applied.
+
+CREATE TABLE IF NOT EXISTS "users" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "email" text NOT NULL,
+    "name" text NOT NULL,
+    "password" text NOT NULL,
+    "token_version" bigint NOT NULL DEFAULT 0,
+    "email_verified_at" timestamptz,
+    "weasel_mode_enabled" boolean DEFAULT true,
+    "weasel_intensity" text DEFAULT 'medium',
+    "allow_guilt_trips" boolean DEFAULT true,
+    "allow_fake_stats" boolean DEFAULT true,
+    "allow_social_pressure" boolean DEFAULT true,
+    "preferred_workout_time" text,
+    "fitness_goal" text,
+    PRIMARY KEY ("id")
+);
+-- Added while the schema was managed by AutoMigrate; CREATE TABLE IF NOT EXISTS
+-- skips them on databases that already had the table
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "token_version" bigint NOT NULL DEFAULT 0;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "email_verified_at" timestamptz;
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_email" ON "users" ("email");
+CREATE INDEX IF NOT EXISTS "idx_users_deleted_at" ON "users" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "programs" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "name" text NOT NULL,
+    "description" text,
+    "difficulty" text,
+    "duration" bigint,
+    "structure" jsonb,
+    "created_by_id" bigint,
+    PRIMARY KEY ("id")
+);
+ALTER TABLE "programs" ADD COLUMN IF NOT EXISTS "created_by_id" bigint;
+CREATE INDEX IF NOT EXISTS "idx_programs_created_by_id" ON "programs" ("created_by_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_programs_name" ON "programs" ("name");
+CREATE INDEX IF NOT EXISTS "idx_programs_deleted_at" ON "programs" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "workouts" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_id" bigint NOT NULL,
+    "program_id" bigint,
+    "completed_at" timestamptz,
+    "duration" bigint,
+    "fake_progress_boost" bigint DEFAULT 0,
+    "is_personal_record" boolean DEFAULT false,
+    "notes" text,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_programs_workouts" FOREIGN KEY ("program_id") REFERENCES "programs"("id"),
+    CONSTRAINT "fk_users_workouts" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_workouts_completed_at" ON "workouts" ("completed_at");
+CREATE INDEX IF NOT EXISTS "idx_workouts_deleted_at" ON "workouts" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "exercises" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "name" text NOT NULL,
+    "category" text,
+    "muscle_groups" text,
+    "instructions" text,
+    "progress_multiplier" decimal DEFAULT 1,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_exercises_name" ON "exercises" ("name");
+CREATE INDEX IF NOT EXISTS "idx_exercises_deleted_at" ON "exercises" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "workout_sets" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "workout_id" bigint NOT NULL,
+    "exercise_id" bigint NOT NULL,
+    "exercise_order" bigint NOT NULL,
+    "set_index" bigint NOT NULL,
+    "reps" bigint NOT NULL,
+    "weight" decimal NOT NULL DEFAULT 0,
+    "unit" text NOT NULL DEFAULT 'lb',
+    "rpe" decimal,
+    "completed" boolean DEFAULT true,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_workout_sets_exercise" FOREIGN KEY ("exercise_id") REFERENCES "exercises"("id") ON DELETE RESTRICT ON UPDATE CASCADE,
+    CONSTRAINT "fk_workouts_sets" FOREIGN KEY ("workout_id") REFERENCES "workouts"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_workout_sets_exercise_id" ON "workout_sets" ("exercise_id");
+CREATE INDEX IF NOT EXISTS "idx_workout_sets_workout_id" ON "workout_sets" ("workout_id");
+CREATE INDEX IF NOT EXISTS "idx_workout_sets_deleted_at" ON "workout_sets" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "achievements" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "name" text NOT NULL,
+    "description" text,
+    "category" text,
+    "icon" text,
+    "target" bigint,
+    "is_fake_achievement" boolean DEFAULT false,
+    "rarity_percent" bigint DEFAULT 50,
+    "weasel_message" text,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_achievements_name" ON "achievements" ("name");
+CREATE INDEX IF NOT EXISTS "idx_achievements_deleted_at" ON "achievements" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "user_achievements" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_id" bigint NOT NULL,
+    "achievement_id" bigint NOT NULL,
+    "unlocked_at" timestamptz,
+    "progress" bigint,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_users_achievements" FOREIGN KEY ("user_id") REFERENCES "users"("id"),
+    CONSTRAINT "fk_achievements_user_achievements" FOREIGN KEY ("achievement_id") REFERENCES "achievements"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_user_achievements_deleted_at" ON "user_achievements" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "buddy_relationships" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_id" bigint NOT NULL,
+    "buddy_id" bigint NOT NULL,
+    "relationship_type" text NOT NULL,
+    "status" text DEFAULT 'pending',
+    "invited_at" timestamptz,
+    "accepted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_users_buddy_requests" FOREIGN KEY ("user_id") REFERENCES "users"("id"),
+    CONSTRAINT "fk_users_coaching_clients" FOREIGN KEY ("buddy_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_buddy_relationships_deleted_at" ON "buddy_relationships" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "weasel_messages" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_id" bigint NOT NULL,
+    "message_type" text NOT NULL,
+    "content" text NOT NULL,
+    "intensity" text NOT NULL,
+    "sent_at" timestamptz,
+    "read_at" timestamptz,
+    "user_reaction" text,
+    "triggered_workout" boolean DEFAULT false,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_users_weasel_messages" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_weasel_messages_deleted_at" ON "weasel_messages" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "streaks" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_id" bigint NOT NULL,
+    "streak_type" text NOT NULL,
+    "current" bigint DEFAULT 0,
+    "longest" bigint DEFAULT 0,
+    "last_workout" timestamptz,
+    "streak_start" timestamptz,
+    "is_active" boolean DEFAULT true,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_streaks_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_streaks_deleted_at" ON "streaks" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "fake_social_activities" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "activity_type" text NOT NULL,
+    "fake_user_name" text NOT NULL,
+    "details" text,
+    "timestamp" timestamptz,
+    "target_user_groups" text,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_fake_social_activities_deleted_at" ON "fake_social_activities" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "refresh_tokens" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_id" bigint NOT NULL,
+    "family_id" text NOT NULL,
+    "token_hash" text NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "used_at" timestamptz,
+    "revoked_at" timestamptz,
+    "replaced_by_id" bigint,
+    "user_agent" text,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_refresh_tokens_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_refresh_tokens_token_hash" ON "refresh_tokens" ("token_hash");
+CREATE INDEX IF NOT EXISTS "idx_refresh_tokens_family_id" ON "refresh_tokens" ("family_id");
+CREATE INDEX IF NOT EXISTS "idx_refresh_tokens_user_id" ON "refresh_tokens" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_refresh_tokens_deleted_at" ON "refresh_tokens" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "action_tokens" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_id" bigint NOT NULL,
+    "purpose" text NOT NULL,
+    "token_id" text NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "used_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_action_tokens_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_action_tokens_token_id" ON "action_tokens" ("token_id");
+CREATE INDEX IF NOT EXISTS "idx_action_tokens_purpose" ON "action_tokens" ("purpose");
+CREATE INDEX IF NOT EXISTS "idx_action_tokens_user_id" ON "action_tokens" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_action_tokens_deleted_at" ON "action_tokens" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "enrollments" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_id" bigint NOT NULL,
+    "program_id" bigint NOT NULL,
+    "status" text NOT NULL DEFAULT 'active',
+    "started_at" timestamptz NOT NULL,
+    "paused_at" timestamptz,
+    "ended_at" timestamptz,
+    "duration_weeks" bigint NOT NULL,
+    "current_week" bigint NOT NULL DEFAULT 1,
+    "current_day" bigint NOT NULL DEFAULT 0,
+    "sessions_completed" bigint NOT NULL DEFAULT 0,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_enrollments_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT "fk_enrollments_program" FOREIGN KEY ("program_id") REFERENCES "programs"("id") ON DELETE RESTRICT ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_enrollments_status" ON "enrollments" ("status");
+CREATE INDEX IF NOT EXISTS "idx_enrollments_program_id" ON "enrollments" ("program_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_enrollments_current" ON "enrollments" ("user_id") WHERE status <> 'completed' AND status <> 'abandoned' AND deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS "idx_enrollments_deleted_at" ON "enrollments" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "enrollment_lifts" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "enrollment_id" bigint NOT NULL,
+    "exercise" text NOT NULL,
+    "start_weight" decimal NOT NULL,
+    "working_weight" decimal NOT NULL,
+    "consecutive_failures" bigint NOT NULL DEFAULT 0,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_enrollments_lifts" FOREIGN KEY ("enrollment_id") REFERENCES "enrollments"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_enrollment_lift" ON "enrollment_lifts" ("enrollment_id","exercise");
+CREATE INDEX IF NOT EXISTS "idx_enrollment_lifts_deleted_at" ON "enrollment_lifts" ("deleted_at");
+
@@ -0,0 +1,2 @@
+-- Restores the column only; the data lives in workout_sets
+ALTER TABLE "workouts" ADD COLUMN IF NOT EXISTS "exercises" jsonb;
@@ -0,0 +1,3 @@
+-- Exercise data moved to workout_sets; AutoMigrate never dropped the old
+-- jsonb column from databases created before that change
+ALTER TABLE "workouts" DROP COLUMN IF EXISTS "exercises";
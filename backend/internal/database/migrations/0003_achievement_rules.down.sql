@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS "idx_user_achievements_user_achievement";
+ALTER TABLE "achievements" DROP COLUMN IF EXISTS "rule";
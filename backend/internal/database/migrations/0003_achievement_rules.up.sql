@@ -0,0 +1,6 @@
+-- Declarative unlock rules evaluated by package achievements
+ALTER TABLE "achievements" ADD COLUMN IF NOT EXISTS "rule" jsonb;
+
+-- Progress is tracked in one row per user and achievement; unlocked_at stays
+-- NULL until the achievement is earned
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_user_achievements_user_achievement" ON "user_achievements" ("user_id","achievement_id");
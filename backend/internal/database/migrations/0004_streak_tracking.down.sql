@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS "idx_streaks_user_type";
+ALTER TABLE "streaks" ALTER COLUMN "is_active" SET DEFAULT true;
+ALTER TABLE "streaks" DROP COLUMN IF EXISTS "timezone";
+ALTER TABLE "streaks" DROP COLUMN IF EXISTS "freezes_used";
+ALTER TABLE "streaks" DROP COLUMN IF EXISTS "freezes";
+ALTER TABLE "users" DROP COLUMN IF EXISTS "timezone";
@@ -0,0 +1,12 @@
+-- Streaks are computed in the user's own time zone
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "timezone" text NOT NULL DEFAULT 'UTC';
+
+ALTER TABLE "streaks" ADD COLUMN IF NOT EXISTS "freezes" bigint NOT NULL DEFAULT 0;
+ALTER TABLE "streaks" ADD COLUMN IF NOT EXISTS "freezes_used" bigint NOT NULL DEFAULT 0;
+ALTER TABLE "streaks" ADD COLUMN IF NOT EXISTS "timezone" text;
+ALTER TABLE "streaks" ALTER COLUMN "is_active" DROP DEFAULT;
+
+-- One streak per user and type; keep the most recent row of any duplicates
+DELETE FROM "streaks" s USING "streaks" newer
+WHERE s.user_id = newer.user_id AND s.streak_type = newer.streak_type AND s.id < newer.id;
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_streaks_user_type" ON "streaks" ("user_id","streak_type");
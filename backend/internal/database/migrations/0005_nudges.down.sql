@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS "idx_weasel_messages_user_sent";
+ALTER TABLE "weasel_messages" DROP COLUMN IF EXISTS "nudge_step";
@@ -0,0 +1,5 @@
+-- Messages sent by the nudge scheduler record their schedule step
+ALTER TABLE "weasel_messages" ADD COLUMN IF NOT EXISTS "nudge_step" bigint;
+
+-- The scheduler counts each user's recent messages
+CREATE INDEX IF NOT EXISTS "idx_weasel_messages_user_sent" ON "weasel_messages" ("user_id","sent_at");
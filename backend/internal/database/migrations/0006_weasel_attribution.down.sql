@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS "idx_weasel_messages_untriggered";
+ALTER TABLE "users" DROP COLUMN IF EXISTS "is_admin";
@@ -0,0 +1,5 @@
+-- Admins can read aggregate reports
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "is_admin" boolean NOT NULL DEFAULT false;
+
+-- The attribution job only looks at messages not yet credited with a workout
+CREATE INDEX IF NOT EXISTS "idx_weasel_messages_untriggered" ON "weasel_messages" ("sent_at") WHERE NOT "triggered_workout";
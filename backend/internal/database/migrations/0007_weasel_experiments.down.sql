@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS "idx_weasel_messages_experiment";
+ALTER TABLE "weasel_messages" DROP COLUMN IF EXISTS "variant";
+ALTER TABLE "weasel_messages" DROP COLUMN IF EXISTS "experiment";
@@ -0,0 +1,4 @@
+-- Messages record the copy experiment and variant they were sent under
+ALTER TABLE "weasel_messages" ADD COLUMN IF NOT EXISTS "experiment" text;
+ALTER TABLE "weasel_messages" ADD COLUMN IF NOT EXISTS "variant" text;
+CREATE INDEX IF NOT EXISTS "idx_weasel_messages_experiment" ON "weasel_messages" ("experiment","variant");
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS "notifications";
+ALTER TABLE "users" DROP COLUMN IF EXISTS "expo_push_token";
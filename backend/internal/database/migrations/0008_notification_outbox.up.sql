@@ -0,0 +1,26 @@
+-- Users register an Expo push token to receive push notifications
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "expo_push_token" text;
+
+-- Outbox of Weasel messages waiting to be delivered, written in the same
+-- transaction as the message
+CREATE TABLE IF NOT EXISTS "notifications" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_id" bigint NOT NULL,
+    "message_id" bigint NOT NULL,
+    "channel" text NOT NULL,
+    "status" text NOT NULL DEFAULT 'pending',
+    "attempts" bigint NOT NULL DEFAULT 0,
+    "next_attempt_at" timestamptz NOT NULL,
+    "last_error" text,
+    "delivered_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_notifications_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT "fk_notifications_message" FOREIGN KEY ("message_id") REFERENCES "weasel_messages"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_notifications_deleted_at" ON "notifications" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_notifications_user_id" ON "notifications" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_notifications_message_id" ON "notifications" ("message_id");
+CREATE INDEX IF NOT EXISTS "idx_notifications_due" ON "notifications" ("status","next_attempt_at");
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS "buddy_invites";
+DROP INDEX IF EXISTS "idx_buddy_relationships_buddy_id";
+DROP INDEX IF EXISTS "idx_buddy_relationships_pair";
+ALTER TABLE "buddy_relationships" DROP CONSTRAINT IF EXISTS "chk_buddy_relationships_distinct";
+ALTER TABLE "buddy_relationships" DROP CONSTRAINT IF EXISTS "fk_buddy_relationships_invited_by";
+ALTER TABLE "buddy_relationships" DROP COLUMN IF EXISTS "invited_by_id";
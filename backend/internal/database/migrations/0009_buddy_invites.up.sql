@@ -0,0 +1,46 @@
+-- Relationships record who sent the invitation; existing rows were all
+-- created by the user on the user_id side
+ALTER TABLE "buddy_relationships" ADD COLUMN IF NOT EXISTS "invited_by_id" bigint;
+UPDATE "buddy_relationships" SET "invited_by_id" = "user_id" WHERE "invited_by_id" IS NULL;
+ALTER TABLE "buddy_relationships" ALTER COLUMN "invited_by_id" SET NOT NULL;
+ALTER TABLE "buddy_relationships" DROP CONSTRAINT IF EXISTS "fk_buddy_relationships_invited_by";
+ALTER TABLE "buddy_relationships" ADD CONSTRAINT "fk_buddy_relationships_invited_by"
+    FOREIGN KEY ("invited_by_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- A pair of users shares at most one live relationship, in either
+-- direction, and nobody is their own buddy. Older duplicates are retired
+-- in favor of the first relationship.
+DELETE FROM "buddy_relationships" WHERE "user_id" = "buddy_id";
+UPDATE "buddy_relationships" AS b SET "deleted_at" = now()
+WHERE b."deleted_at" IS NULL AND EXISTS (
+    SELECT 1 FROM "buddy_relationships" AS o
+    WHERE o."deleted_at" IS NULL AND o."id" < b."id"
+      AND LEAST(o."user_id", o."buddy_id") = LEAST(b."user_id", b."buddy_id")
+      AND GREATEST(o."user_id", o."buddy_id") = GREATEST(b."user_id", b."buddy_id")
+);
+ALTER TABLE "buddy_relationships" DROP CONSTRAINT IF EXISTS "chk_buddy_relationships_distinct";
+ALTER TABLE "buddy_relationships" ADD CONSTRAINT "chk_buddy_relationships_distinct" CHECK ("user_id" <> "buddy_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_buddy_relationships_pair" ON "buddy_relationships"
+    (LEAST("user_id", "buddy_id"), GREATEST("user_id", "buddy_id")) WHERE "deleted_at" IS NULL;
+CREATE INDEX IF NOT EXISTS "idx_buddy_relationships_buddy_id" ON "buddy_relationships" ("buddy_id");
+
+-- Emailed invitations, including to people without an account
+CREATE TABLE IF NOT EXISTS "buddy_invites" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "inviter_id" bigint NOT NULL,
+    "email" text NOT NULL,
+    "role" text NOT NULL,
+    "status" text NOT NULL DEFAULT 'pending',
+    "token_hash" text NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "responded_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_buddy_invites_inviter" FOREIGN KEY ("inviter_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_buddy_invites_deleted_at" ON "buddy_invites" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_buddy_invites_inviter_id" ON "buddy_invites" ("inviter_id");
+CREATE INDEX IF NOT EXISTS "idx_buddy_invites_email" ON "buddy_invites" ("email");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_buddy_invites_token_hash" ON "buddy_invites" ("token_hash");
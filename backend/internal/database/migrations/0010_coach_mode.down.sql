@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS "idx_weasel_messages_sender_id";
+ALTER TABLE "weasel_messages" DROP CONSTRAINT IF EXISTS "fk_weasel_messages_sender";
+ALTER TABLE "weasel_messages" DROP COLUMN IF EXISTS "sender_id";
+DROP TABLE IF EXISTS "coach_permissions";
@@ -0,0 +1,29 @@
+-- Permissions clients grant their coaches, one row per permission
+CREATE TABLE IF NOT EXISTS "coach_permissions" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "relationship_id" bigint NOT NULL,
+    "permission" text NOT NULL,
+    "granted_at" timestamptz NOT NULL,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_coach_permissions_relationship" FOREIGN KEY ("relationship_id") REFERENCES "buddy_relationships"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_coach_permissions_deleted_at" ON "coach_permissions" ("deleted_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_coach_permissions_grant" ON "coach_permissions" ("relationship_id","permission") WHERE deleted_at IS NULL;
+
+-- Coaching relationships accepted before permissions existed get all of them
+INSERT INTO "coach_permissions" ("created_at", "updated_at", "relationship_id", "permission", "granted_at")
+SELECT now(), now(), b."id", p."permission", COALESCE(b."accepted_at", now())
+FROM "buddy_relationships" AS b
+CROSS JOIN (VALUES ('view_workouts'), ('view_records'), ('manage_programs'), ('send_messages')) AS p("permission")
+WHERE b."deleted_at" IS NULL AND b."relationship_type" = 'coach' AND b."status" <> 'pending'
+ON CONFLICT DO NOTHING;
+
+-- Coach messages record the coach who wrote them
+ALTER TABLE "weasel_messages" ADD COLUMN IF NOT EXISTS "sender_id" bigint;
+ALTER TABLE "weasel_messages" DROP CONSTRAINT IF EXISTS "fk_weasel_messages_sender";
+ALTER TABLE "weasel_messages" ADD CONSTRAINT "fk_weasel_messages_sender"
+    FOREIGN KEY ("sender_id") REFERENCES "users"("id") ON DELETE SET NULL ON UPDATE CASCADE;
+CREATE INDEX IF NOT EXISTS "idx_weasel_messages_sender_id" ON "weasel_messages" ("sender_id");
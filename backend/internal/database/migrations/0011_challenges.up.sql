@@ -0,0 +1,40 @@
+-- Time-boxed group challenges between buddies
+CREATE TABLE IF NOT EXISTS "challenges" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "creator_id" bigint NOT NULL,
+    "name" text NOT NULL,
+    "description" text,
+    "metric" text NOT NULL,
+    "exercise" text,
+    "starts_at" timestamptz NOT NULL,
+    "ends_at" timestamptz NOT NULL,
+    "completed_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_challenges_creator" FOREIGN KEY ("creator_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_challenges_deleted_at" ON "challenges" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_challenges_creator_id" ON "challenges" ("creator_id");
+CREATE INDEX IF NOT EXISTS "idx_challenges_ends_at" ON "challenges" ("ends_at");
+
+CREATE TABLE IF NOT EXISTS "challenge_participants" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "challenge_id" bigint NOT NULL,
+    "user_id" bigint NOT NULL,
+    "status" text NOT NULL DEFAULT 'invited',
+    "invited_at" timestamptz NOT NULL,
+    "joined_at" timestamptz,
+    "final_rank" bigint,
+    "final_score" decimal,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_challenges_participants" FOREIGN KEY ("challenge_id") REFERENCES "challenges"("id") ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT "fk_challenge_participants_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_challenge_participants_deleted_at" ON "challenge_participants" ("deleted_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_challenge_participants_user" ON "challenge_participants" ("challenge_id","user_id");
+CREATE INDEX IF NOT EXISTS "idx_challenge_participants_user_id" ON "challenge_participants" ("user_id");
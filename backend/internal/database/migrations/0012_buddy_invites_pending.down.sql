@@ -0,0 +1 @@
+DROP INDEX IF EXISTS "idx_buddy_invites_pending";
@@ -0,0 +1,11 @@
+-- An inviter has at most one pending invite to an address, so concurrent
+-- invites to someone without an account cannot both be stored and emailed.
+-- Older duplicates keep the first invite and cancel the rest.
+UPDATE "buddy_invites" AS b SET "status" = 'cancelled', "responded_at" = now()
+WHERE b."status" = 'pending' AND b."deleted_at" IS NULL AND EXISTS (
+    SELECT 1 FROM "buddy_invites" AS o
+    WHERE o."status" = 'pending' AND o."deleted_at" IS NULL AND o."id" < b."id"
+      AND o."inviter_id" = b."inviter_id" AND o."email" = b."email"
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_buddy_invites_pending" ON "buddy_invites" ("inviter_id", "email")
+    WHERE "status" = 'pending' AND "deleted_at" IS NULL;
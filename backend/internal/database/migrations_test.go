@@ -1,103 +1,62 @@
 package database
 
 import (
+	"fmt"
+	"strings"
+	"sync"
 	"testing"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/migrate"
+	"gorm.io/gorm/schema"
 )
 
-func TestConstants(t *testing.T) {
-	tests := []struct {
-		name     string
-		constant interface{}
-		expected interface{}
-	}{
-		{"standardProgramDuration", standardProgramDuration, 12},
-		{"squatMultiplier", squatMultiplier, 1.2},
-		{"deadliftMultiplier", deadliftMultiplier, 1.3},
-		{"benchMultiplier", benchMultiplier, 1.1},
-		{"ohpMultiplier", ohpMultiplier, 1.15},
-		{"rowMultiplier", rowMultiplier, 1.1},
-		{"weeklyStreakTarget", weeklyStreakTarget, 7},
-		{"monthlyStreakTarget", monthlyStreakTarget, 30},
-		{"firstPRTarget", firstPRTarget, 1},
-		{"centuryClubTarget", centuryClubTarget, 100},
-		{"teamPlayerTarget", teamPlayerTarget, 5},
-		{"sweatSommelierTarget", sweatSommelierTarget, 3},
-		{"gymWhispererTarget", gymWhispererTarget, 100},
-		{"showUpSamuraiRarity", showUpSamuraiRarity, 35},
-		{"ironWillRarity", ironWillRarity, 8},
-		{"weightWarriorRarity", weightWarriorRarity, 60},
-		{"centuryClubRarity", centuryClubRarity, 25},
-		{"teamPlayerRarity", teamPlayerRarity, 40},
-		{"sweatSommelierRarity", sweatSommelierRarity, 45},
-		{"gymWhispererRarity", gymWhispererRarity, 5},
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := migrate.Load(migrationFiles, "migrations")
+	if err != nil {
+		t.Fatalf("Failed to load embedded migrations: %v", err)
 	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if tt.constant != tt.expected {
-				t.Errorf("Expected %s to be %v, got %v", tt.name, tt.expected, tt.constant)
-			}
-		})
+	if len(migrations) == 0 || migrations[0].Version != baselineVersion {
+		t.Fatalf("Expected migrations to start at the baseline, got %+v", migrations)
 	}
-}
-
-func TestProgressMultipliers(t *testing.T) {
-	// Test that progress multipliers are reasonable values
-	multipliers := map[string]float64{
-		"squat":    squatMultiplier,
-		"deadlift": deadliftMultiplier,
-		"bench":    benchMultiplier,
-		"ohp":      ohpMultiplier,
-		"row":      rowMultiplier,
+	for _, m := range migrations {
+		if m.Down == "" {
+			t.Errorf("Migration %d_%s has no down step", m.Version, m.Name)
+		}
 	}
 
-	for exercise, multiplier := range multipliers {
-		t.Run(exercise, func(t *testing.T) {
-			if multiplier < 1.0 || multiplier > 2.0 {
-				t.Errorf("Progress multiplier for %s should be between 1.0 and 2.0, got %f", exercise, multiplier)
-			}
-		})
+	// Adoption runs the baseline over existing schemas, so it must be idempotent
+	for _, line := range strings.Split(migrations[0].Up, "\n") {
+		stmt := strings.TrimSpace(line)
+		if (strings.HasPrefix(stmt, "CREATE ") || strings.HasPrefix(stmt, "ALTER ")) && !strings.Contains(stmt, "IF NOT EXISTS") {
+			t.Errorf("Baseline statement is not idempotent: %s", stmt)
+		}
 	}
 }
 
-func TestAchievementTargets(t *testing.T) {
-	// Test that achievement targets are positive values
-	targets := map[string]int{
-		"weeklyStreak":   weeklyStreakTarget,
-		"monthlyStreak":  monthlyStreakTarget,
-		"firstPR":        firstPRTarget,
-		"centuryClub":    centuryClubTarget,
-		"teamPlayer":     teamPlayerTarget,
-		"sweatSommelier": sweatSommelierTarget,
-		"gymWhisperer":   gymWhispererTarget,
-	}
-
-	for achievement, target := range targets {
-		t.Run(achievement, func(t *testing.T) {
-			if target <= 0 {
-				t.Errorf("Achievement target for %s should be positive, got %d", achievement, target)
-			}
-		})
-	}
-}
-
-func TestAchievementRarities(t *testing.T) {
-	// Test that achievement rarities are valid percentages
-	rarities := map[string]int{
-		"showUpSamurai":  showUpSamuraiRarity,
-		"ironWill":       ironWillRarity,
-		"weightWarrior":  weightWarriorRarity,
-		"centuryClub":    centuryClubRarity,
-		"teamPlayer":     teamPlayerRarity,
-		"sweatSommelier": sweatSommelierRarity,
-		"gymWhisperer":   gymWhispererRarity,
-	}
-
-	for achievement, rarity := range rarities {
-		t.Run(achievement, func(t *testing.T) {
-			if rarity < 0 || rarity > 100 {
-				t.Errorf("Achievement rarity for %s should be between 0-100, got %d", achievement, rarity)
-			}
-		})
+func TestMigrationsCreateEveryModel(t *testing.T) {
+	migrations, err := migrate.Load(migrationFiles, "migrations")
+	if err != nil {
+		t.Fatalf("Failed to load embedded migrations: %v", err)
+	}
+	var all strings.Builder
+	for _, m := range migrations {
+		all.WriteString(m.Up)
+	}
+
+	models := []any{
+		&User{}, &Workout{}, &WorkoutSet{}, &Program{}, &Exercise{}, &Achievement{}, &UserAchievement{},
+		&BuddyRelationship{}, &WeaselMessage{}, &Streak{}, &FakeSocialActivity{}, &RefreshToken{},
+		&ActionToken{}, &Enrollment{}, &EnrollmentLift{}, &Notification{}, &BuddyInvite{},
+		&CoachPermission{}, &Challenge{}, &ChallengeParticipant{},
+	}
+	for _, model := range models {
+		s, err := schema.Parse(model, &sync.Map{}, schema.NamingStrategy{})
+		if err != nil {
+			t.Fatalf("Failed to parse %T: %v", model, err)
+		}
+		if !strings.Contains(all.String(), fmt.Sprintf("CREATE TABLE IF NOT EXISTS %q", s.Table)) &&
+			!strings.Contains(all.String(), fmt.Sprintf("CREATE TABLE %q", s.Table)) {
+			t.Errorf("No migration creates table %s for %T", s.Table, model)
+		}
 	}
 }
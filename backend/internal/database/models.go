@@ -1,6 +1,7 @@
 package database
 
 import (
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -18,6 +19,18 @@ type User struct {
 	Name     string `gorm:"not null" json:"name"`
 	Password string `gorm:"not null" json:"-"` // Never include in JSON responses
 
+	// TokenVersion is embedded in access tokens; incrementing it revokes them all
+	TokenVersion int `gorm:"default:0;not null" json:"-"`
+
+	// EmailVerifiedAt is set once the user follows their verification link
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+
+	// IsAdmin grants access to the /api/admin reports
+	IsAdmin bool `gorm:"default:false;not null" json:"-"`
+
+	// ExpoPushToken addresses the user's device for push notifications
+	ExpoPushToken string `json:"-"`
+
 	// Weasel mode configuration
 	WeaselModeEnabled   bool   `gorm:"default:true" json:"weasel_mode_enabled"`
 	WeaselIntensity     string `gorm:"default:medium" json:"weasel_intensity"` // gentle, medium, aggressive, full_chaos
@@ -28,6 +41,8 @@ type User struct {
 	// User preferences
 	PreferredWorkoutTime string `json:"preferred_workout_time"` // morning, afternoon, evening
 	FitnessGoal          string `json:"fitness_goal"`           // strength, endurance, weight_loss, general
+	// Timezone is an IANA zone name; streaks and time of day are computed in it
+	Timezone string `gorm:"not null;default:UTC" json:"timezone"`
 
 	// Relationships
 	Workouts        []Workout           `gorm:"foreignKey:UserID" json:"workouts,omitempty"`
@@ -37,6 +52,14 @@ type User struct {
 	CoachingClients []BuddyRelationship `gorm:"foreignKey:BuddyID" json:"coaching_clients,omitempty"`
 }
 
+// Location returns the user's time zone, or UTC when it is unset or unknown
+func (u *User) Location() *time.Location {
+	if loc, err := time.LoadLocation(u.Timezone); err == nil && u.Timezone != "" {
+		return loc
+	}
+	return time.UTC
+}
+
 // Workout represents a completed workout session
 type Workout struct {
 	ID        uint           `gorm:"primarykey" json:"id"`
@@ -48,13 +71,13 @@ type Workout struct {
 	User   User `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"user,omitempty"`
 
 	// Workout details
-	ProgramID   uint      `json:"program_id"`
-	Program     Program   `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"program,omitempty"`
-	CompletedAt time.Time `json:"completed_at"`
+	ProgramID   *uint     `json:"program_id,omitempty"` // Optional; nil for freestyle sessions
+	Program     *Program  `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"program,omitempty"`
+	CompletedAt time.Time `gorm:"index" json:"completed_at"`
 	Duration    int       `json:"duration"` // Duration in minutes
 
-	// Exercise data (JSON field for flexibility)
-	Exercises string `gorm:"type:jsonb" json:"exercises"` // JSON array of exercises with sets/reps/weight
+	// Set-level exercise data, one row per logged set
+	Sets []WorkoutSet `gorm:"foreignKey:WorkoutID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"sets,omitempty"`
 
 	// Weasel mode enhancements
 	FakeProgressBoost int  `gorm:"default:0" json:"fake_progress_boost"` // Artificial stat inflation percentage
@@ -64,6 +87,47 @@ type Workout struct {
 	Notes string `json:"notes"`
 }
 
+// WorkoutSet represents a single logged set of an exercise within a workout
+type WorkoutSet struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	WorkoutID  uint     `gorm:"not null;index" json:"workout_id"`
+	ExerciseID uint     `gorm:"not null;index" json:"exercise_id"`
+	Exercise   Exercise `gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;" json:"exercise,omitempty"`
+
+	// Position of the exercise within the workout and of the set within the exercise
+	ExerciseOrder int `gorm:"not null" json:"exercise_order"`
+	SetIndex      int `gorm:"not null" json:"set_index"`
+
+	Reps      int      `gorm:"not null" json:"reps"`
+	Weight    float64  `gorm:"not null;default:0" json:"weight"`
+	Unit      string   `gorm:"not null;default:lb" json:"unit"` // lb, kg
+	RPE       *float64 `json:"rpe,omitempty"`                   // Rate of perceived exertion, 1-10
+	Completed bool     `json:"completed"`                       // No default:true tag: GORM omits a false it would default, storing true
+}
+
+// Pounds returns the set's weight in pounds
+func (s *WorkoutSet) Pounds() float64 {
+	return ConvertWeight(s.Weight, s.Unit, UnitPounds)
+}
+
+// Volume sums weight times reps over the workout's completed sets, in
+// pounds. A non-empty exercise limits it to sets of that exercise, matched
+// by name regardless of case; sets need their Exercise loaded for that.
+func (w *Workout) Volume(exercise string) float64 {
+	total := 0.0
+	for i := range w.Sets {
+		s := &w.Sets[i]
+		if s.Completed && (exercise == "" || strings.EqualFold(s.Exercise.Name, exercise)) {
+			total += s.Pounds() * float64(s.Reps)
+		}
+	}
+	return total
+}
+
 // Program represents a workout program (Starting Strength, 5x5, etc.)
 type Program struct {
 	ID        uint           `gorm:"primarykey" json:"id"`
@@ -76,8 +140,11 @@ type Program struct {
 	Difficulty  string `json:"difficulty"` // beginner, intermediate, advanced
 	Duration    int    `json:"duration"`   // Program duration in weeks
 
-	// Program structure (JSON field for flexibility)
-	Structure string `gorm:"type:jsonb" json:"structure"` // JSON defining exercises, progression, etc.
+	// Structure is a versioned program definition (see package programdef)
+	Structure string `gorm:"type:jsonb" json:"structure"`
+
+	// CreatedByID is set for programs created through the API; seeded programs have none
+	CreatedByID *uint `gorm:"index" json:"created_by_id,omitempty"`
 
 	// Relationships
 	Workouts []Workout `gorm:"foreignKey:ProgramID" json:"workouts,omitempty"`
@@ -117,6 +184,10 @@ type Achievement struct {
 	RarityPercent     int    `gorm:"default:50" json:"rarity_percent"`         // "Only X% of users earn this!"
 	WeaselMessage     string `json:"weasel_message"`                           // Custom message when unlocked
 
+	// Rule is the declarative unlock rule evaluated by package achievements;
+	// achievements without one are only awarded by hand
+	Rule *string `gorm:"type:jsonb" json:"rule,omitempty"`
+
 	// Relationships
 	UserAchievements []UserAchievement `gorm:"foreignKey:AchievementID" json:"user_achievements,omitempty"`
 }
@@ -128,16 +199,18 @@ type UserAchievement struct {
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 
-	UserID        uint        `gorm:"not null" json:"user_id"`
+	UserID        uint        `gorm:"not null;uniqueIndex:idx_user_achievements_user_achievement" json:"user_id"`
 	User          User        `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"user,omitempty"`
-	AchievementID uint        `gorm:"not null" json:"achievement_id"`
+	AchievementID uint        `gorm:"not null;uniqueIndex:idx_user_achievements_user_achievement" json:"achievement_id"`
 	Achievement   Achievement `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"achievement,omitempty"`
 
-	UnlockedAt time.Time `json:"unlocked_at"`
-	Progress   int       `json:"progress"` // Current progress toward achievement
+	UnlockedAt *time.Time `json:"unlocked_at,omitempty"` // Nil until the achievement is earned
+	Progress   int        `json:"progress"`              // Current progress toward achievement
 }
 
-// BuddyRelationship represents the buddy/coach system
+// BuddyRelationship represents the buddy/coach system. In coach
+// relationships UserID is the client and BuddyID the coach. A pair of users
+// has at most one relationship, in either direction.
 type BuddyRelationship struct {
 	ID        uint           `gorm:"primarykey" json:"id"`
 	CreatedAt time.Time      `json:"created_at"`
@@ -146,15 +219,119 @@ type BuddyRelationship struct {
 
 	UserID  uint `gorm:"not null" json:"user_id"`
 	User    User `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"user,omitempty"`
-	BuddyID uint `gorm:"not null" json:"buddy_id"`
+	BuddyID uint `gorm:"not null;index" json:"buddy_id"`
 	Buddy   User `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"buddy,omitempty"`
 
 	RelationshipType string `gorm:"not null" json:"relationship_type"` // peer, coach
 	Status           string `gorm:"default:pending" json:"status"`     // pending, active, paused
 
-	// Invitation details
-	InvitedAt  time.Time  `json:"invited_at"`
-	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+	// Invitation details. Only the side that did not send the invitation
+	// may accept it.
+	InvitedByID uint       `gorm:"not null" json:"invited_by_id"`
+	InvitedBy   User       `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	InvitedAt   time.Time  `json:"invited_at"`
+	AcceptedAt  *time.Time `json:"accepted_at,omitempty"`
+}
+
+// ActivePeers returns the users on the other side of userID's active peer
+// relationships in rels. Coach relationships are left out: coaches are not
+// training alongside their clients.
+func ActivePeers(rels []BuddyRelationship, userID uint) []User {
+	var out []User
+	for i := range rels {
+		rel := &rels[i]
+		switch {
+		case rel.Status != BuddyActive || rel.RelationshipType != RelationshipPeer:
+		case rel.UserID == userID:
+			out = append(out, rel.Buddy)
+		default:
+			out = append(out, rel.User)
+		}
+	}
+	return out
+}
+
+// BuddyInvite is an emailed, expiring invitation to a buddy or coaching
+// relationship. Invites to existing users come with a pending relationship;
+// anyone else accepts with the token once they have an account.
+type BuddyInvite struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// Email is the invitee's normalized address. The partial unique index
+	// allows one pending invite per inviter and address.
+	InviterID uint   `gorm:"not null;index;uniqueIndex:idx_buddy_invites_pending,where:status = 'pending' AND deleted_at IS NULL" json:"inviter_id"`
+	Inviter   User   `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Email     string `gorm:"not null;index;uniqueIndex:idx_buddy_invites_pending,where:status = 'pending' AND deleted_at IS NULL" json:"email"`
+
+	Role        string     `gorm:"not null" json:"role"`                   // What the invitee becomes: peer, coach, client
+	Status      string     `gorm:"not null;default:pending" json:"status"` // pending, accepted, declined, cancelled
+	TokenHash   string     `gorm:"uniqueIndex;not null" json:"-"`          // SHA-256 of the emailed token
+	ExpiresAt   time.Time  `gorm:"not null" json:"expires_at"`
+	RespondedAt *time.Time `json:"responded_at,omitempty"`
+}
+
+// CoachPermission is a permission a client has granted the coach in a
+// coaching relationship. Revoking it deletes the row.
+type CoachPermission struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// The partial unique index grants each permission at most once per relationship
+	RelationshipID uint              `gorm:"not null;uniqueIndex:idx_coach_permissions_grant,where:deleted_at IS NULL" json:"relationship_id"`
+	Relationship   BuddyRelationship `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+
+	Permission string    `gorm:"not null;uniqueIndex:idx_coach_permissions_grant,where:deleted_at IS NULL" json:"permission"` // view_workouts, view_records, manage_programs, send_messages
+	GrantedAt  time.Time `gorm:"not null" json:"granted_at"`
+}
+
+// Challenge is a time-boxed competition between a user and the buddies they
+// invited. Standings are computed from the workouts completed between
+// StartsAt and EndsAt; CompletedAt is set once the final ranks are recorded.
+type Challenge struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	CreatorID uint `gorm:"not null;index" json:"creator_id"`
+	Creator   User `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+
+	Name        string `gorm:"not null" json:"name"`
+	Description string `json:"description"`
+	Metric      string `gorm:"not null" json:"metric"` // workouts, volume, max_weight
+	Exercise    string `json:"exercise,omitempty"`     // Catalog name; volume of every exercise when empty
+
+	StartsAt    time.Time  `gorm:"not null" json:"starts_at"`
+	EndsAt      time.Time  `gorm:"not null;index" json:"ends_at"` // Exclusive
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+
+	Participants []ChallengeParticipant `gorm:"foreignKey:ChallengeID" json:"participants,omitempty"`
+}
+
+// ChallengeParticipant is a user invited to a challenge. FinalRank and
+// FinalScore are recorded for joined participants when the challenge completes.
+type ChallengeParticipant struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	ChallengeID uint      `gorm:"not null;uniqueIndex:idx_challenge_participants_user" json:"challenge_id"`
+	Challenge   Challenge `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	UserID      uint      `gorm:"not null;uniqueIndex:idx_challenge_participants_user;index" json:"user_id"`
+	User        User      `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+
+	Status    string     `gorm:"not null;default:invited" json:"status"` // invited, joined, declined
+	InvitedAt time.Time  `gorm:"not null" json:"invited_at"`
+	JoinedAt  *time.Time `json:"joined_at,omitempty"`
+
+	FinalRank  *int     `json:"final_rank,omitempty"`
+	FinalScore *float64 `json:"final_score,omitempty"`
 }
 
 // WeaselMessage represents psychological manipulation messages sent to users
@@ -164,21 +341,55 @@ type WeaselMessage struct {
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 
-	UserID uint `gorm:"not null" json:"user_id"`
+	UserID uint `gorm:"not null;index:idx_weasel_messages_user_sent" json:"user_id"`
 	User   User `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"user,omitempty"`
 
 	// Message details
 	MessageType string `gorm:"not null" json:"message_type"` // guilt, fomo, urgency, social, funny
 	Content     string `gorm:"type:text;not null" json:"content"`
-	Intensity   string `gorm:"not null" json:"intensity"` // gentle, medium, aggressive
+	Intensity   string `gorm:"not null" json:"intensity"` // gentle, medium, aggressive, full_chaos
 
 	// Delivery and tracking
-	SentAt       time.Time  `json:"sent_at"`
+	SentAt       time.Time  `gorm:"index:idx_weasel_messages_user_sent" json:"sent_at"`
 	ReadAt       *time.Time `json:"read_at,omitempty"`
 	UserReaction string     `json:"user_reaction"` // ignored, annoyed, motivated, worked_out
 
 	// Effectiveness tracking
 	TriggeredWorkout bool `gorm:"default:false" json:"triggered_workout"` // Did user work out within 24h?
+
+	// NudgeStep is the step of the nudge schedule that sent the message; nil
+	// for messages not sent by the nudge scheduler
+	NudgeStep *int `json:"nudge_step,omitempty"`
+
+	// Experiment and Variant record the copy experiment the message was
+	// sent under; empty outside experiments
+	Experiment string `gorm:"index:idx_weasel_messages_experiment" json:"-"`
+	Variant    string `gorm:"index:idx_weasel_messages_experiment" json:"-"`
+
+	// SenderID is the coach who wrote a coach message; nil for generated messages
+	SenderID *uint `gorm:"index" json:"sender_id,omitempty"`
+}
+
+// Notification is an outbox entry delivering a Weasel message over one
+// channel. It is written in the same transaction as the message and
+// delivered, with retries, by the notify worker.
+type Notification struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	UserID    uint          `gorm:"not null;index" json:"user_id"`
+	User      User          `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	MessageID uint          `gorm:"not null;index" json:"message_id"`
+	Message   WeaselMessage `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+
+	Channel       string     `gorm:"not null" json:"channel"`                                            // push, email, webhook
+	Status        string     `gorm:"not null;default:pending;index:idx_notifications_due" json:"status"` // pending, delivered, failed
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`                                 // Deliveries tried so far
+	NextAttemptAt time.Time  `gorm:"not null;index:idx_notifications_due" json:"next_attempt_at"`        // When a pending entry is due
+	LastError     string     `json:"last_error,omitempty"`                                               // Why the last attempt failed
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty"`
 }
 
 // Streak represents user workout streaks
@@ -188,15 +399,22 @@ type Streak struct {
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 
-	UserID uint `gorm:"not null" json:"user_id"`
+	UserID uint `gorm:"not null;uniqueIndex:idx_streaks_user_type" json:"user_id"`
 	User   User `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"user,omitempty"`
 
-	StreakType  string    `gorm:"not null" json:"streak_type"` // workout, weekly, monthly
+	StreakType  string    `gorm:"not null;uniqueIndex:idx_streaks_user_type" json:"streak_type"` // workout, weekly, monthly
 	Current     int       `gorm:"default:0" json:"current"`
 	Longest     int       `gorm:"default:0" json:"longest"`
 	LastWorkout time.Time `json:"last_workout"`
 	StreakStart time.Time `json:"streak_start"`
-	IsActive    bool      `gorm:"default:true" json:"is_active"`
+	IsActive    bool      `json:"is_active"` // False once broken; no default tag, as with WorkoutSet.Completed
+
+	// Streak freezes bridge missed periods; they are earned as the streak grows
+	Freezes     int `gorm:"not null;default:0" json:"freezes"`      // Banked and available
+	FreezesUsed int `gorm:"not null;default:0" json:"freezes_used"` // Spent on the current streak
+
+	// Timezone the streak was computed in; a change triggers a recompute
+	Timezone string `json:"timezone"`
 }
 
 // FakeSocialActivity represents algorithmic fake social activity for pressure
@@ -212,3 +430,86 @@ type FakeSocialActivity struct {
 	Timestamp        time.Time `json:"timestamp"`
 	TargetUserGroups string    `json:"target_user_groups"` // JSON array of user groups to show this to
 }
+
+// RefreshToken represents a single-use, rotating session credential.
+// Every token rotated from the same login shares a FamilyID so the whole
+// chain can be revoked when a stolen token is replayed.
+type RefreshToken struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	UserID uint `gorm:"not null;index" json:"user_id"`
+	User   User `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+
+	FamilyID  string `gorm:"not null;index" json:"family_id"`
+	TokenHash string `gorm:"uniqueIndex;not null" json:"-"` // SHA-256 of the opaque token
+
+	ExpiresAt    time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt       *time.Time `json:"used_at,omitempty"`    // Set when rotated; a second use is a replay
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"` // Set on logout or reuse detection
+	ReplacedByID *uint      `json:"replaced_by_id,omitempty"`
+	UserAgent    string     `json:"user_agent"`
+}
+
+// ActionToken records a signed single-purpose token (email verification,
+// password reset) so that it can be redeemed only once
+type ActionToken struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	UserID uint `gorm:"not null;index" json:"user_id"`
+	User   User `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+
+	Purpose   string     `gorm:"not null;index" json:"purpose"` // verify_email, reset_password
+	TokenID   string     `gorm:"uniqueIndex;not null" json:"-"` // JWT ID (jti) of the issued token
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
+// Enrollment links a user to a program they are running. Progress is derived
+// from the workouts logged against the program since StartedAt.
+type Enrollment struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// The partial unique index allows one current (active or paused) enrollment per user
+	UserID    uint    `gorm:"not null;uniqueIndex:idx_enrollments_current,where:status <> 'completed' AND status <> 'abandoned' AND deleted_at IS NULL" json:"user_id"`
+	User      User    `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	ProgramID uint    `gorm:"not null;index" json:"program_id"`
+	Program   Program `gorm:"constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;" json:"program,omitempty"`
+
+	Status    string     `gorm:"not null;default:active;index" json:"status"` // active, paused, completed, abandoned
+	StartedAt time.Time  `gorm:"not null" json:"started_at"`
+	PausedAt  *time.Time `json:"paused_at,omitempty"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"` // Set when completed or abandoned
+
+	// Position within the program, refreshed from workout history
+	DurationWeeks     int `gorm:"not null" json:"duration_weeks"`
+	CurrentWeek       int `gorm:"not null;default:1" json:"current_week"`
+	CurrentDay        int `gorm:"not null;default:0" json:"current_day"` // Index into the program's days
+	SessionsCompleted int `gorm:"not null;default:0" json:"sessions_completed"`
+
+	Lifts []EnrollmentLift `gorm:"foreignKey:EnrollmentID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"lifts,omitempty"`
+}
+
+// EnrollmentLift tracks the starting and current working weight of one lift
+// within an enrollment
+type EnrollmentLift struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	EnrollmentID uint   `gorm:"not null;uniqueIndex:idx_enrollment_lift" json:"enrollment_id"`
+	Exercise     string `gorm:"not null;uniqueIndex:idx_enrollment_lift" json:"exercise"`
+
+	StartWeight         float64 `gorm:"not null" json:"start_weight"`   // Pounds
+	WorkingWeight       float64 `gorm:"not null" json:"working_weight"` // Weight to attempt next session, pounds
+	ConsecutiveFailures int     `gorm:"not null;default:0" json:"consecutive_failures"`
+}
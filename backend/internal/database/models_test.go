@@ -0,0 +1,118 @@
+package database
+
+import (
+	"math"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// dryRun returns a session that builds statements without a database
+func dryRun(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatalf("Failed to open dry-run session: %v", err)
+	}
+	return db
+}
+
+// stored returns the row the database stores when model is created: the
+// values GORM inserts, and column defaults for those it leaves out. GORM
+// leaves out zero values of fields with a default tag.
+func stored(t *testing.T, model any) map[string]any {
+	t.Helper()
+	stmt := dryRun(t).Create(model).Statement
+	values, ok := stmt.Clauses["VALUES"].Expression.(clause.Values)
+	if !ok || len(values.Values) != 1 {
+		t.Fatalf("Expected one inserted row, got %v", stmt.Clauses["VALUES"].Expression)
+	}
+	row := make(map[string]any)
+	for _, f := range stmt.Schema.Fields {
+		if f.DBName != "" && f.HasDefaultValue && f.DefaultValueInterface != nil {
+			row[f.DBName] = f.DefaultValueInterface
+		}
+	}
+	for i, column := range values.Columns {
+		row[column.Name] = values.Values[0][i]
+	}
+	return row
+}
+
+func TestWorkoutSetInsertKeepsIncompleteSets(t *testing.T) {
+	for _, completed := range []bool{false, true} {
+		set := WorkoutSet{WorkoutID: 1, ExerciseID: 2, Reps: 5, Weight: 135, Unit: UnitPounds, Completed: completed}
+		if got := stored(t, &set)["completed"]; got != completed {
+			t.Errorf("Expected completed=%v to be stored, got %v", completed, got)
+		}
+	}
+}
+
+func TestStreakInsertKeepsBrokenStreaks(t *testing.T) {
+	for _, active := range []bool{false, true} {
+		streak := Streak{UserID: 1, StreakType: StreakWorkout, IsActive: active}
+		if got := stored(t, &streak)["is_active"]; got != active {
+			t.Errorf("Expected is_active=%v to be stored, got %v", active, got)
+		}
+	}
+}
+
+func TestWorkoutSetPounds(t *testing.T) {
+	tests := []struct {
+		unit     string
+		expected float64
+	}{
+		{UnitPounds, 100},
+		{"", 100}, // An empty unit means pounds
+		{UnitKilograms, 100 * PoundsPerKilogram},
+	}
+	for _, tt := range tests {
+		set := WorkoutSet{Weight: 100, Unit: tt.unit}
+		if got := set.Pounds(); math.Abs(got-tt.expected) > 1e-9 {
+			t.Errorf("Expected %q to be %v lb, got %v", tt.unit, tt.expected, got)
+		}
+	}
+	if got := ConvertWeight(100, UnitKilograms, UnitKilograms); got != 100 {
+		t.Errorf("Expected kg to stay kg, got %v", got)
+	}
+}
+
+func TestWorkoutVolume(t *testing.T) {
+	squat, bench := Exercise{ID: 1, Name: "Squat"}, Exercise{ID: 2, Name: "Bench Press"}
+	w := Workout{Sets: []WorkoutSet{
+		{Exercise: squat, Reps: 5, Weight: 100, Unit: UnitPounds, Completed: true},
+		{Exercise: squat, Reps: 5, Weight: 100, Completed: true}, // An empty unit means pounds
+		{Exercise: squat, Reps: 5, Weight: 200, Unit: UnitPounds},
+		{Exercise: bench, Reps: 2, Weight: 50, Unit: UnitKilograms, Completed: true},
+	}}
+
+	tests := []struct {
+		exercise string
+		expected float64
+	}{
+		{"", 1000 + 100*PoundsPerKilogram},
+		{"squat", 1000},
+		{"Deadlift", 0},
+	}
+	for _, tt := range tests {
+		if got := w.Volume(tt.exercise); math.Abs(got-tt.expected) > 1e-9 {
+			t.Errorf("Expected %q volume %v, got %v", tt.exercise, tt.expected, got)
+		}
+	}
+}
+
+func TestActivePeers(t *testing.T) {
+	rels := []BuddyRelationship{
+		{UserID: 1, BuddyID: 2, Buddy: User{ID: 2}, RelationshipType: RelationshipPeer, Status: BuddyActive},
+		{UserID: 3, User: User{ID: 3}, BuddyID: 1, RelationshipType: RelationshipPeer, Status: BuddyActive},
+		{UserID: 1, BuddyID: 4, Buddy: User{ID: 4}, RelationshipType: RelationshipPeer, Status: BuddyPending},
+		{UserID: 1, BuddyID: 5, Buddy: User{ID: 5}, RelationshipType: RelationshipCoach, Status: BuddyActive},
+		{UserID: 6, User: User{ID: 6}, BuddyID: 1, RelationshipType: RelationshipCoach, Status: BuddyActive},
+	}
+	peers := ActivePeers(rels, 1)
+	if len(peers) != 2 || peers[0].ID != 2 || peers[1].ID != 3 {
+		t.Errorf("Expected active peers 2 and 3, got %+v", peers)
+	}
+}
@@ -0,0 +1,88 @@
+// Package env reads typed settings from environment variables. Unset or
+// empty variables fall back to the default; invalid values are logged and
+// fall back to it as well, so a typo never keeps the server from starting.
+package env
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+)
+
+// String reads a string, treating an empty variable as unset
+func String(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// Lookup is like String but keeps an explicitly empty variable, so a
+// setting such as a token audience can be switched off
+func Lookup(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return def
+}
+
+// Duration reads a positive duration such as "90s"
+func Duration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		warn(key, v)
+		return def
+	}
+	return d
+}
+
+// Int reads an integer of at least minimum
+func Int(key string, def, minimum int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < minimum {
+		warn(key, v)
+		return def
+	}
+	return n
+}
+
+// Uint64 reads an unsigned integer
+func Uint64(key string, def uint64) uint64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		warn(key, v)
+		return def
+	}
+	return n
+}
+
+// Bool reads a boolean such as "true", "0" or "F"
+func Bool(key string, def bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		warn(key, v)
+		return def
+	}
+	return b
+}
+
+func warn(key, value string) {
+	slog.Warn("Ignoring invalid setting", "key", key, "value", value)
+}
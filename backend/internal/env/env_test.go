@@ -0,0 +1,48 @@
+package env
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		read     func(key string) any
+		expected any
+	}{
+		{"string", "abc", func(k string) any { return String(k, "def") }, "abc"},
+		{"empty string", "", func(k string) any { return String(k, "def") }, "def"},
+		{"empty lookup", "", func(k string) any { return Lookup(k, "def") }, ""},
+		{"duration", "90s", func(k string) any { return Duration(k, time.Minute) }, 90 * time.Second},
+		{"unset duration", "", func(k string) any { return Duration(k, time.Minute) }, time.Minute},
+		{"negative duration", "-5m", func(k string) any { return Duration(k, time.Minute) }, time.Minute},
+		{"bad duration", "soon", func(k string) any { return Duration(k, time.Minute) }, time.Minute},
+		{"int", "0", func(k string) any { return Int(k, 3, 0) }, 0},
+		{"int below minimum", "0", func(k string) any { return Int(k, 3, 1) }, 3},
+		{"bad int", "many", func(k string) any { return Int(k, 3, 0) }, 3},
+		{"uint64", "42", func(k string) any { return Uint64(k, 7) }, uint64(42)},
+		{"negative uint64", "-1", func(k string) any { return Uint64(k, 7) }, uint64(7)},
+		{"bool", "false", func(k string) any { return Bool(k, true) }, false},
+		{"bad bool", "sure", func(k string) any { return Bool(k, true) }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("FERROVIS_TEST_SETTING", tt.value)
+			if got := tt.read("FERROVIS_TEST_SETTING"); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestLookupUnset(t *testing.T) {
+	t.Setenv("FERROVIS_TEST_SETTING", "")
+	os.Unsetenv("FERROVIS_TEST_SETTING")
+	if got := Lookup("FERROVIS_TEST_SETTING", "def"); got != "def" {
+		t.Errorf("Expected an unset variable to fall back, got %q", got)
+	}
+}
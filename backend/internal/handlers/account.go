@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lucas-albers-lz4/ferrovis/internal/auth"
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/mail"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+)
+
+const (
+	// Lifetimes of emailed action tokens
+	verifyEmailTTL   = 24 * time.Hour
+	passwordResetTTL = time.Hour
+
+	// mailSendTimeout bounds how long a request waits on the mail relay
+	mailSendTimeout = 10 * time.Second
+
+	msgInvalidActionToken = "Invalid or expired link"
+	msgResetRequested     = "If an account exists for that email, a reset link has been sent"
+)
+
+// errActionTokenRejected is returned when an action token cannot be redeemed
+var errActionTokenRejected = errors.New("action token rejected")
+
+type actionTokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type passwordResetRequest struct {
+	Email string `json:"email" binding:"required,email,max=254"`
+}
+
+func (r *passwordResetRequest) normalize() {
+	r.Email = normalizeEmail(r.Email)
+}
+
+type resetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8,max=128"`
+}
+
+// RequestEmailVerification sends a new verification link to the current user
+func (h *AuthHandler) RequestEmailVerification(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if user.EmailVerifiedAt != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  "ok",
+			"message": "Email already verified",
+		})
+		return
+	}
+
+	if err := h.sendActionEmail(c.Request.Context(), user, auth.PurposeVerifyEmail); err != nil {
+		respondInternalError(c, "Failed to send verification email", err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"status":  "ok",
+		"message": "Verification email sent",
+	})
+}
+
+// VerifyEmail redeems a verification token and marks the email as verified
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req actionTokenRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	err := h.store.Transaction(ctx, func(tx store.Store) error {
+		userID, err := h.redeemActionToken(ctx, tx, req.Token, auth.PurposeVerifyEmail)
+		if err != nil {
+			return err
+		}
+		return markEmailVerified(ctx, tx, userID)
+	})
+	switch {
+	case errors.Is(err, errActionTokenRejected):
+		respondError(c, http.StatusBadRequest, msgInvalidActionToken)
+		return
+	case err != nil:
+		respondInternalError(c, "Failed to verify email", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"message": "Email verified",
+	})
+}
+
+// RequestPasswordReset emails a reset link. The response is identical whether
+// or not the account exists so the endpoint cannot be used to probe emails.
+func (h *AuthHandler) RequestPasswordReset(c *gin.Context) {
+	var req passwordResetRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	user, err := h.store.Users().GetByEmail(ctx, req.Email)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		// Fall through to the generic response
+	case err != nil:
+		respondInternalError(c, "Failed to request password reset", err)
+		return
+	default:
+		if err := h.sendActionEmail(ctx, user, auth.PurposeResetPassword); err != nil {
+			slog.Error("Failed to send password reset email", "user_id", user.ID, "error", err)
+		}
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"status":  "ok",
+		"message": msgResetRequested,
+	})
+}
+
+// ResetPassword redeems a reset token, sets the new password and signs the
+// user out everywhere
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req resetPasswordRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		respondInternalError(c, "Failed to secure password", err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	err = h.store.Transaction(ctx, func(tx store.Store) error {
+		userID, err := h.redeemActionToken(ctx, tx, req.Token, auth.PurposeResetPassword)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if err := tx.Users().SetPassword(ctx, userID, hash); err != nil {
+			return fmt.Errorf("failed to update password: %w", err)
+		}
+
+		// Receiving the reset email proves ownership of the address
+		if err := markEmailVerified(ctx, tx, userID); err != nil {
+			return err
+		}
+
+		if err := tx.Sessions().RevokeAll(ctx, userID, now); err != nil {
+			return fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+
+		// Any other reset links still in flight are now stale
+		if err := tx.ActionTokens().ExpireAll(ctx, userID, auth.PurposeResetPassword, now); err != nil {
+			return fmt.Errorf("failed to expire reset tokens: %w", err)
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, errActionTokenRejected):
+		respondError(c, http.StatusBadRequest, msgInvalidActionToken)
+		return
+	case err != nil:
+		respondInternalError(c, "Failed to reset password", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"message": "Password has been reset. Please log in again.",
+	})
+}
+
+// sendActionEmail issues a single-use token for purpose and emails it to the user
+func (h *AuthHandler) sendActionEmail(ctx context.Context, user *database.User, purpose string) error {
+	ttl := verifyEmailTTL
+	if purpose == auth.PurposeResetPassword {
+		ttl = passwordResetTTL
+	}
+
+	token, tokenID, err := h.tokens.IssueActionToken(user.ID, purpose, ttl)
+	if err != nil {
+		return fmt.Errorf("failed to issue %s token: %w", purpose, err)
+	}
+
+	row := database.ActionToken{
+		UserID:    user.ID,
+		Purpose:   purpose,
+		TokenID:   tokenID,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := h.store.ActionTokens().Create(ctx, &row); err != nil {
+		return fmt.Errorf("failed to store %s token: %w", purpose, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, mailSendTimeout)
+	defer cancel()
+
+	if err := h.mailer.Send(ctx, h.actionMessage(user, purpose, token, ttl)); err != nil {
+		return fmt.Errorf("failed to send %s email: %w", purpose, err)
+	}
+	return nil
+}
+
+// actionMessage renders the email for an action token
+func (h *AuthHandler) actionMessage(user *database.User, purpose, token string, ttl time.Duration) mail.Message {
+	path, subject, intro := "verify-email", "Verify your Ferrovis email", "Confirm your email address so we can keep you accountable:"
+	if purpose == auth.PurposeResetPassword {
+		path, subject, intro = "reset-password", "Reset your Ferrovis password", "Someone (hopefully you) asked to reset your password:"
+	}
+
+	link := fmt.Sprintf("%s/%s?token=%s", h.appURL, path, url.QueryEscape(token))
+	body := fmt.Sprintf("Hi %s,\n\n%s\n\n%s\n\nThis link expires in %s and can only be used once.\n"+
+		"If you didn't request this, you can ignore this email.\n\n- The Ferrovis Weasel\n",
+		user.Name, intro, link, ttl)
+
+	return mail.Message{To: user.Email, Subject: subject, Body: body}
+}
+
+// redeemActionToken verifies raw and atomically marks it used, returning the user ID
+func (h *AuthHandler) redeemActionToken(ctx context.Context, tx store.Store, raw, purpose string) (uint, error) {
+	claims, err := h.tokens.ParseActionToken(raw, purpose)
+	if err != nil {
+		slog.Debug("Rejected action token", "purpose", purpose, "error", err)
+		return 0, errActionTokenRejected
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		return 0, errActionTokenRejected
+	}
+
+	redeemed, err := tx.ActionTokens().Redeem(ctx, claims.ID, userID, purpose, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to redeem token: %w", err)
+	}
+	if !redeemed {
+		return 0, errActionTokenRejected
+	}
+	return userID, nil
+}
+
+// markEmailVerified stamps EmailVerifiedAt unless it is already set
+func markEmailVerified(ctx context.Context, tx store.Store, userID uint) error {
+	if err := tx.Users().MarkEmailVerified(ctx, userID, time.Now()); err != nil {
+		return fmt.Errorf("failed to mark email verified: %w", err)
+	}
+	return nil
+}
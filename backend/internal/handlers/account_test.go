@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/auth"
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+)
+
+func TestActionMessage(t *testing.T) {
+	h := NewAuthHandler(store.NewMemory(), nil, nil, "https://ferrovis.app/")
+	user := &database.User{Name: "Sam", Email: "sam@example.com"}
+
+	tests := []struct {
+		purpose     string
+		expectedURL string
+		subject     string
+	}{
+		{auth.PurposeVerifyEmail, "https://ferrovis.app/verify-email?token=a%2Bb", "Verify your Ferrovis email"},
+		{auth.PurposeResetPassword, "https://ferrovis.app/reset-password?token=a%2Bb", "Reset your Ferrovis password"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.purpose, func(t *testing.T) {
+			msg := h.actionMessage(user, tt.purpose, "a+b", time.Hour)
+			if msg.To != user.Email {
+				t.Errorf("Expected recipient %s, got %s", user.Email, msg.To)
+			}
+			if msg.Subject != tt.subject {
+				t.Errorf("Expected subject %q, got %q", tt.subject, msg.Subject)
+			}
+			if !strings.Contains(msg.Body, tt.expectedURL) {
+				t.Errorf("Expected link %s in body %q", tt.expectedURL, msg.Body)
+			}
+		})
+	}
+}
+
+func TestResetPasswordValidation(t *testing.T) {
+	h := NewAuthHandler(store.NewMemory(), nil, nil, "")
+
+	code, resp := performRequest(t, h.ResetPassword, http.MethodPost, `{"token":"x","password":"short"}`)
+	if code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", code)
+	}
+	errs, ok := resp["errors"].(map[string]any)
+	if !ok || errs["password"] == nil {
+		t.Errorf("Expected password field error, got %v", resp)
+	}
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lucas-albers-lz4/ferrovis/internal/auth"
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/mail"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+)
+
+// Error messages that must not reveal which credential was wrong
+const (
+	msgInvalidCredentials = "Invalid email or password"
+	msgEmailTaken         = "An account with this email already exists"
+)
+
+// AuthHandler serves the /api/auth endpoints
+type AuthHandler struct {
+	store  store.Store
+	tokens *auth.TokenManager
+	mailer mail.Mailer
+	// appURL is the base of links sent by email (verification, password reset)
+	appURL string
+}
+
+// NewAuthHandler creates an AuthHandler
+func NewAuthHandler(s store.Store, tokens *auth.TokenManager, mailer mail.Mailer, appURL string) *AuthHandler {
+	return &AuthHandler{store: s, tokens: tokens, mailer: mailer, appURL: strings.TrimRight(appURL, "/")}
+}
+
+type registerRequest struct {
+	Email    string `json:"email" binding:"required,email,max=254"`
+	Password string `json:"password" binding:"required,min=8,max=128"`
+	Name     string `json:"name" binding:"max=100"`
+}
+
+func (r *registerRequest) normalize() {
+	r.Email = normalizeEmail(r.Email)
+	r.Name = strings.TrimSpace(r.Name)
+}
+
+type loginRequest struct {
+	Email    string `json:"email" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+func (r *loginRequest) normalize() {
+	r.Email = normalizeEmail(r.Email)
+}
+
+// Register creates a new account and starts a session for it
+func (h *AuthHandler) Register(c *gin.Context) {
+	var req registerRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	_, err := h.store.Users().GetByEmail(ctx, req.Email)
+	switch {
+	case err == nil:
+		respondError(c, http.StatusConflict, msgEmailTaken)
+		return
+	case !errors.Is(err, store.ErrNotFound):
+		respondInternalError(c, "Failed to check existing accounts", err)
+		return
+	}
+
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		respondInternalError(c, "Failed to secure password", err)
+		return
+	}
+
+	name := req.Name
+	if name == "" {
+		name = strings.SplitN(req.Email, "@", 2)[0]
+	}
+
+	user := database.User{
+		Email:    req.Email,
+		Name:     name,
+		Password: hash,
+	}
+	if err := h.store.Users().Create(ctx, &user); err != nil {
+		// The unique index is the source of truth when two registrations race
+		if errors.Is(err, store.ErrDuplicate) {
+			respondError(c, http.StatusConflict, msgEmailTaken)
+			return
+		}
+		respondInternalError(c, "Failed to create account", err)
+		return
+	}
+
+	slog.Info("User registered", "user_id", user.ID)
+
+	// Registration succeeds even if the mail relay is down; the user can
+	// ask for a new verification email later
+	if err := h.sendActionEmail(ctx, &user, auth.PurposeVerifyEmail); err != nil {
+		slog.Error("Failed to send verification email", "user_id", user.ID, "error", err)
+	}
+
+	h.respondWithNewSession(c, http.StatusCreated, &user)
+}
+
+// Login verifies credentials and starts a new session
+func (h *AuthHandler) Login(c *gin.Context) {
+	var req loginRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	user, err := h.store.Users().GetByEmail(c.Request.Context(), req.Email)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		// Burn the same hashing time as a real check so response timing
+		// does not reveal whether the email is registered
+		verifyDummyPassword(req.Password)
+		respondError(c, http.StatusUnauthorized, msgInvalidCredentials)
+		return
+	case err != nil:
+		respondInternalError(c, "Failed to look up account", err)
+		return
+	}
+
+	ok, err := auth.VerifyPassword(req.Password, user.Password)
+	if err != nil {
+		slog.Error("Stored password hash is unreadable", "user_id", user.ID, "error", err)
+	}
+	if !ok {
+		respondError(c, http.StatusUnauthorized, msgInvalidCredentials)
+		return
+	}
+
+	h.respondWithNewSession(c, http.StatusOK, user)
+}
+
+// normalizeEmail trims and lower-cases an email so the unique index is case-insensitive
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+// verifyDummyPassword runs a password verification against a throwaway hash
+func verifyDummyPassword(password string) {
+	dummyHashOnce.Do(func() {
+		hash, err := auth.HashPassword("ferrovis-timing-equalizer")
+		if err != nil {
+			slog.Error("Failed to create dummy password hash", "error", err)
+			return
+		}
+		dummyHash = hash
+	})
+	if dummyHash != "" {
+		_, _ = auth.VerifyPassword(password, dummyHash) //nolint:errcheck // result intentionally discarded
+	}
+}
@@ -0,0 +1,688 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lucas-albers-lz4/ferrovis/internal/auth"
+	"github.com/lucas-albers-lz4/ferrovis/internal/compare"
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/mail"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+)
+
+// buddyInviteTTL is how long an emailed buddy invite can be accepted
+const buddyInviteTTL = 7 * 24 * time.Hour
+
+// Directions of a relationship from the current user's side
+const (
+	directionOutgoing = "outgoing"
+	directionIncoming = "incoming"
+)
+
+// errInviteClosed reports an invite that expired or was already answered
+var errInviteClosed = errors.New("invite is no longer pending")
+
+// BuddyHandler manages buddy and coach relationships and the emailed
+// invitations that start them
+type BuddyHandler struct {
+	store  store.Store
+	mailer mail.Mailer
+	// appURL is the base of the invite links sent by email
+	appURL string
+}
+
+// NewBuddyHandler creates a BuddyHandler that emails invites with links under appURL
+func NewBuddyHandler(s store.Store, mailer mail.Mailer, appURL string) *BuddyHandler {
+	return &BuddyHandler{store: s, mailer: mailer, appURL: strings.TrimRight(appURL, "/")}
+}
+
+// buddyInviteRequest invites someone by email. Role is what the invitee
+// becomes and defaults to a peer.
+type buddyInviteRequest struct {
+	Email string `json:"email" binding:"required,email,max=255"`
+	Role  string `json:"role" binding:"omitempty,oneof=peer coach client"`
+}
+
+// inviteTokenRequest answers an emailed invite
+type inviteTokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// buddyView describes a relationship from the current user's side
+type buddyView struct {
+	ID               uint   `json:"id"`
+	BuddyID          uint   `json:"buddy_id"`
+	BuddyName        string `json:"buddy_name"`
+	RelationshipType string `json:"relationship_type"`
+	// Role is the buddy's role towards the user: peer, coach or client
+	Role string `json:"role"`
+	// Direction is outgoing when the user sent the invitation
+	Direction  string     `json:"direction"`
+	Status     string     `json:"status"`
+	InvitedAt  time.Time  `json:"invited_at"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+}
+
+// newBuddyView describes rel from userID's side
+func newBuddyView(rel *database.BuddyRelationship, userID uint) buddyView {
+	buddy, role := rel.Buddy, database.InviteRoleCoach
+	if rel.BuddyID == userID {
+		buddy, role = rel.User, database.InviteRoleClient
+	}
+	if rel.RelationshipType == database.RelationshipPeer {
+		role = database.InviteRolePeer
+	}
+	direction := directionIncoming
+	if rel.InvitedByID == userID {
+		direction = directionOutgoing
+	}
+	return buddyView{
+		ID:               rel.ID,
+		BuddyID:          buddy.ID,
+		BuddyName:        buddy.Name,
+		RelationshipType: rel.RelationshipType,
+		Role:             role,
+		Direction:        direction,
+		Status:           rel.Status,
+		InvitedAt:        rel.InvitedAt,
+		AcceptedAt:       rel.AcceptedAt,
+	}
+}
+
+// newRelationship builds a pending relationship in which the invitee takes
+// role. Coach relationships keep the client on the user side.
+func newRelationship(inviterID, inviteeID uint, role string, at time.Time) *database.BuddyRelationship {
+	rel := &database.BuddyRelationship{
+		UserID:           inviterID,
+		BuddyID:          inviteeID,
+		RelationshipType: database.RelationshipCoach,
+		Status:           database.BuddyPending,
+		InvitedByID:      inviterID,
+		InvitedAt:        at,
+	}
+	switch role {
+	case database.InviteRolePeer:
+		rel.RelationshipType = database.RelationshipPeer
+	case database.InviteRoleClient:
+		rel.UserID, rel.BuddyID = inviteeID, inviterID
+	}
+	return rel
+}
+
+// List returns the current user's relationships in both directions and the
+// emailed invites still waiting on someone without an account
+func (h *BuddyHandler) List(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	rels, err := h.store.Buddies().List(ctx, user.ID)
+	if err != nil {
+		respondInternalError(c, "Failed to load buddies", err)
+		return
+	}
+	invites, err := h.store.BuddyInvites().Pending(ctx, user.ID, time.Now())
+	if err != nil {
+		respondInternalError(c, "Failed to load invites", err)
+		return
+	}
+
+	buddies := make([]buddyView, 0, len(rels))
+	linked := make(map[string]bool, len(rels))
+	for i := range rels {
+		buddies = append(buddies, newBuddyView(&rels[i], user.ID))
+		linked[rels[i].User.Email], linked[rels[i].Buddy.Email] = true, true
+	}
+	// Invites to existing users already show up as pending relationships
+	waiting := make([]database.BuddyInvite, 0, len(invites))
+	for _, invite := range invites {
+		if !linked[invite.Email] {
+			waiting = append(waiting, invite)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"buddies": buddies,
+		"invites": waiting,
+	})
+}
+
+// Compare measures the current user against each active peer this week and
+// last. Insights are only included when the user allows social pressure.
+func (h *BuddyHandler) Compare(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	comparison, err := compare.Compare(c.Request.Context(), h.store, user, time.Now())
+	if err != nil {
+		respondInternalError(c, "Failed to compare with buddies", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":     "ok",
+		"comparison": comparison,
+	})
+}
+
+// Invite emails an expiring invitation. Existing users also get a pending
+// relationship they can accept in the app; anyone else accepts with the
+// emailed token once they have signed up.
+func (h *BuddyHandler) Invite(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	var req buddyInviteRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	req.Email = normalizeEmail(req.Email)
+	if req.Role == "" {
+		req.Role = database.InviteRolePeer
+	}
+	if req.Email == user.Email {
+		respondError(c, http.StatusBadRequest, "You can't invite yourself")
+		return
+	}
+
+	token, hash, err := auth.NewInviteToken()
+	if err != nil {
+		respondInternalError(c, "Failed to create invite", err)
+		return
+	}
+	now := time.Now()
+	invite := database.BuddyInvite{
+		InviterID: user.ID,
+		Email:     req.Email,
+		Role:      req.Role,
+		Status:    database.InvitePending,
+		TokenHash: hash,
+		ExpiresAt: now.Add(buddyInviteTTL),
+	}
+
+	ctx := c.Request.Context()
+	var relID uint
+	err = h.store.Transaction(ctx, func(tx store.Store) error {
+		var err error
+		if relID, err = h.linkInvitee(ctx, tx, user, &invite, now); err != nil {
+			return err
+		}
+		if err := tx.BuddyInvites().Create(ctx, &invite); err != nil {
+			return fmt.Errorf("failed to store invite: %w", err)
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, store.ErrDuplicate):
+		respondError(c, http.StatusConflict, "You have already invited or linked up with this person")
+		return
+	case err != nil:
+		respondInternalError(c, "Failed to create invite", err)
+		return
+	}
+
+	// The email goes out once the invite is stored; when it fails, the invite
+	// is withdrawn so the user can send it again
+	if err := h.sendInvite(ctx, user, &invite, token); err != nil {
+		if werr := h.withdrawInvite(ctx, &invite, relID); werr != nil {
+			err = errors.Join(err, werr)
+		}
+		respondInternalError(c, "Failed to send invite", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status": "ok",
+		"invite": invite,
+	})
+}
+
+// linkInvitee creates the pending relationship when the invitee already has
+// an account, returning its ID, or 0 for anyone else. It returns ErrDuplicate
+// when the user is already linked to them or has an unexpired invite out to
+// someone without one. Concurrent invites are settled by unique indexes: the
+// relationship's pair index, and for everyone the index allowing one pending
+// invite per address, which makes storing the second invite fail.
+func (h *BuddyHandler) linkInvitee(ctx context.Context, tx store.Store, user *database.User,
+	invite *database.BuddyInvite, now time.Time,
+) (uint, error) {
+	var relID uint
+	invitee, err := tx.Users().GetByEmail(ctx, invite.Email)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		pending, err := tx.BuddyInvites().Pending(ctx, user.ID, now)
+		if err != nil {
+			return 0, fmt.Errorf("failed to check invites: %w", err)
+		}
+		for _, p := range pending {
+			if p.Email == invite.Email {
+				return 0, fmt.Errorf("invite to %s is pending: %w", invite.Email, store.ErrDuplicate)
+			}
+		}
+	case err != nil:
+		return 0, fmt.Errorf("failed to look up invitee: %w", err)
+	default:
+		rel := newRelationship(user.ID, invitee.ID, invite.Role, now)
+		if err := tx.Buddies().Create(ctx, rel); err != nil {
+			return 0, fmt.Errorf("failed to create relationship: %w", err)
+		}
+		relID = rel.ID
+	}
+
+	// Invites still pending to the address have expired or were sent before
+	// the invitee had an account; the new invite replaces them
+	if _, err := tx.BuddyInvites().Resolve(ctx, user.ID, invite.Email, database.InviteCancelled, now); err != nil {
+		return 0, fmt.Errorf("failed to cancel stale invites: %w", err)
+	}
+	return relID, nil
+}
+
+// withdrawInvite cancels an invite whose email could not be sent, along with
+// the pending relationship linkInvitee created for it
+func (h *BuddyHandler) withdrawInvite(ctx context.Context, invite *database.BuddyInvite, relID uint) error {
+	err := h.store.Transaction(ctx, func(tx store.Store) error {
+		if err := tx.BuddyInvites().ResolveByID(ctx, invite.ID, database.InviteCancelled, time.Now()); err != nil {
+			return fmt.Errorf("failed to cancel invite: %w", err)
+		}
+		if relID == 0 {
+			return nil
+		}
+		if err := tx.Buddies().Delete(ctx, relID); err != nil {
+			return fmt.Errorf("failed to remove relationship: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to withdraw invite: %w", err)
+	}
+	return nil
+}
+
+// sendInvite emails the invite token to the invitee
+func (h *BuddyHandler) sendInvite(ctx context.Context, inviter *database.User, invite *database.BuddyInvite, token string) error {
+	ctx, cancel := context.WithTimeout(ctx, mailSendTimeout)
+	defer cancel()
+
+	ask := "wants you as a training buddy"
+	switch invite.Role {
+	case database.InviteRoleCoach:
+		ask = "wants you to be their coach"
+	case database.InviteRoleClient:
+		ask = "wants to coach you"
+	}
+	link := fmt.Sprintf("%s/buddy-invite?token=%s", h.appURL, url.QueryEscape(token))
+	body := fmt.Sprintf("Hi,\n\n%s %s on Ferrovis:\n\n%s\n\nThis invite expires in %s. Sign up with this address to accept it.\n"+
+		"If you don't know %s, you can ignore this email.\n\n- The Ferrovis Weasel\n",
+		inviter.Name, ask, link, buddyInviteTTL, inviter.Name)
+
+	msg := mail.Message{To: invite.Email, Subject: inviter.Name + " invited you to Ferrovis", Body: body}
+	if err := h.mailer.Send(ctx, msg); err != nil {
+		return fmt.Errorf("failed to send invite email: %w", err)
+	}
+	return nil
+}
+
+// AcceptInvite accepts an emailed invite, creating the relationship when
+// the invitee signed up after being invited
+func (h *BuddyHandler) AcceptInvite(c *gin.Context) {
+	user, invite, ok := h.openInvite(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	now := time.Now()
+	var rel *database.BuddyRelationship
+	err := h.store.Transaction(ctx, func(tx store.Store) error {
+		if err := resolveInvite(ctx, tx, invite.InviterID, user.Email, database.InviteAccepted, now); err != nil {
+			return err
+		}
+		existing, err := tx.Buddies().Between(ctx, invite.InviterID, user.ID)
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			rel = newRelationship(invite.InviterID, user.ID, invite.Role, now)
+			rel.Status, rel.AcceptedAt = database.BuddyActive, &now
+			if err := tx.Buddies().Create(ctx, rel); err != nil {
+				return fmt.Errorf("failed to create relationship: %w", err)
+			}
+			if err := grantCoachPermissions(ctx, tx, rel, now); err != nil {
+				return err
+			}
+			// Load both users for the response
+			if rel, err = tx.Buddies().Get(ctx, user.ID, rel.ID); err != nil {
+				return fmt.Errorf("failed to load relationship: %w", err)
+			}
+			return nil
+		case err != nil:
+			return fmt.Errorf("failed to look up relationship: %w", err)
+		case existing.Status != database.BuddyPending:
+			return fmt.Errorf("already linked: %w", store.ErrDuplicate)
+		}
+		rel = existing
+		return activate(ctx, tx, rel, now)
+	})
+	switch {
+	case errors.Is(err, errInviteClosed):
+		respondError(c, http.StatusGone, "This invite has expired or was already answered")
+		return
+	case errors.Is(err, store.ErrDuplicate):
+		respondError(c, http.StatusConflict, "You are already linked up with this person")
+		return
+	case err != nil:
+		respondInternalError(c, "Failed to accept invite", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+		"buddy":  newBuddyView(rel, user.ID),
+	})
+}
+
+// DeclineInvite declines an emailed invite along with any pending
+// relationship that came with it
+func (h *BuddyHandler) DeclineInvite(c *gin.Context) {
+	user, invite, ok := h.openInvite(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	err := h.store.Transaction(ctx, func(tx store.Store) error {
+		if err := resolveInvite(ctx, tx, invite.InviterID, user.Email, database.InviteDeclined, time.Now()); err != nil {
+			return err
+		}
+		rel, err := tx.Buddies().Between(ctx, invite.InviterID, user.ID)
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			return nil
+		case err != nil:
+			return fmt.Errorf("failed to look up relationship: %w", err)
+		case rel.Status != database.BuddyPending:
+			return nil
+		}
+		if err := tx.Buddies().Delete(ctx, rel.ID); err != nil {
+			return fmt.Errorf("failed to delete relationship: %w", err)
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, errInviteClosed):
+		respondError(c, http.StatusGone, "This invite has expired or was already answered")
+		return
+	case err != nil:
+		respondInternalError(c, "Failed to decline invite", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"message": "Invite declined",
+	})
+}
+
+// openInvite loads the pending invite named by the request's token, writing
+// an error response when it cannot be answered by the current user
+func (h *BuddyHandler) openInvite(c *gin.Context) (*database.User, *database.BuddyInvite, bool) {
+	user, ok := currentUser(c)
+	if !ok {
+		return nil, nil, false
+	}
+	var req inviteTokenRequest
+	if !bindJSON(c, &req) {
+		return nil, nil, false
+	}
+
+	invite, err := h.store.BuddyInvites().GetByHash(c.Request.Context(), auth.HashInviteToken(req.Token))
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		respondError(c, http.StatusNotFound, "Invite not found")
+		return nil, nil, false
+	case err != nil:
+		respondInternalError(c, "Failed to load invite", err)
+		return nil, nil, false
+	case invite.Email != user.Email:
+		respondError(c, http.StatusForbidden, "This invite was sent to another email address")
+		return nil, nil, false
+	case invite.Status != database.InvitePending || !invite.ExpiresAt.After(time.Now()):
+		respondError(c, http.StatusGone, "This invite has expired or was already answered")
+		return nil, nil, false
+	}
+	return user, invite, true
+}
+
+// Accept accepts a pending relationship the current user was invited to
+func (h *BuddyHandler) Accept(c *gin.Context) {
+	user, rel, ok := h.invitedRelationship(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	now := time.Now()
+	err := h.store.Transaction(ctx, func(tx store.Store) error {
+		if _, err := tx.BuddyInvites().Resolve(ctx, rel.InvitedByID, user.Email, database.InviteAccepted, now); err != nil {
+			return fmt.Errorf("failed to resolve invites: %w", err)
+		}
+		return activate(ctx, tx, rel, now)
+	})
+	if err != nil {
+		respondInternalError(c, "Failed to accept invite", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+		"buddy":  newBuddyView(rel, user.ID),
+	})
+}
+
+// Decline declines a pending relationship the current user was invited to
+func (h *BuddyHandler) Decline(c *gin.Context) {
+	user, rel, ok := h.invitedRelationship(c)
+	if !ok {
+		return
+	}
+
+	if err := h.remove(c.Request.Context(), user, rel); err != nil {
+		respondInternalError(c, "Failed to decline invite", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"message": "Invite declined",
+	})
+}
+
+// invitedRelationship loads the pending relationship named by the id
+// parameter, writing an error response unless the current user received it
+func (h *BuddyHandler) invitedRelationship(c *gin.Context) (*database.User, *database.BuddyRelationship, bool) {
+	user, rel, ok := h.relationship(c)
+	if !ok {
+		return nil, nil, false
+	}
+	switch {
+	case rel.Status != database.BuddyPending:
+		respondError(c, http.StatusConflict, "This invite was already accepted")
+		return nil, nil, false
+	case rel.InvitedByID == user.ID:
+		respondError(c, http.StatusForbidden, "Only the invited person can answer an invite")
+		return nil, nil, false
+	}
+	return user, rel, true
+}
+
+// Pause pauses an active relationship; either side may pause it
+func (h *BuddyHandler) Pause(c *gin.Context) {
+	h.transition(c, database.BuddyActive, database.BuddyPaused)
+}
+
+// Resume resumes a paused relationship; either side may resume it
+func (h *BuddyHandler) Resume(c *gin.Context) {
+	h.transition(c, database.BuddyPaused, database.BuddyActive)
+}
+
+// transition moves the relationship named by the id parameter from one status to another
+func (h *BuddyHandler) transition(c *gin.Context, from, to string) {
+	user, rel, ok := h.relationship(c)
+	if !ok {
+		return
+	}
+	if rel.Status != from {
+		respondError(c, http.StatusConflict, "Only "+from+" relationships can be "+to)
+		return
+	}
+
+	rel.Status = to
+	if err := h.store.Buddies().Update(c.Request.Context(), rel); err != nil {
+		respondInternalError(c, "Failed to update relationship", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+		"buddy":  newBuddyView(rel, user.ID),
+	})
+}
+
+// Remove ends a relationship from either side. Removing a pending
+// relationship cancels or declines its invite.
+func (h *BuddyHandler) Remove(c *gin.Context) {
+	user, rel, ok := h.relationship(c)
+	if !ok {
+		return
+	}
+
+	if err := h.remove(c.Request.Context(), user, rel); err != nil {
+		respondInternalError(c, "Failed to remove relationship", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"message": "Relationship removed",
+	})
+}
+
+// remove deletes rel and closes the invites that are still out for it
+func (h *BuddyHandler) remove(ctx context.Context, user *database.User, rel *database.BuddyRelationship) error {
+	return h.store.Transaction(ctx, func(tx store.Store) error { //nolint:wrapcheck // errors from fn are returned unchanged
+		if err := tx.Buddies().Delete(ctx, rel.ID); err != nil {
+			return fmt.Errorf("failed to delete relationship: %w", err)
+		}
+		if rel.Status != database.BuddyPending {
+			return nil
+		}
+		invitee, status := rel.Buddy, database.InviteDeclined
+		if rel.BuddyID == rel.InvitedByID {
+			invitee = rel.User
+		}
+		if rel.InvitedByID == user.ID {
+			status = database.InviteCancelled
+		}
+		if _, err := tx.BuddyInvites().Resolve(ctx, rel.InvitedByID, invitee.Email, status, time.Now()); err != nil {
+			return fmt.Errorf("failed to resolve invites: %w", err)
+		}
+		return nil
+	})
+}
+
+// CancelInvite withdraws an emailed invite to someone without an account
+func (h *BuddyHandler) CancelInvite(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	pending, err := h.store.BuddyInvites().Pending(ctx, user.ID, time.Now())
+	if err != nil {
+		respondInternalError(c, "Failed to load invites", err)
+		return
+	}
+	email := ""
+	for _, invite := range pending {
+		if invite.ID == id {
+			email = invite.Email
+		}
+	}
+	if email == "" {
+		respondError(c, http.StatusNotFound, "Invite not found")
+		return
+	}
+
+	if _, err := h.store.BuddyInvites().Resolve(ctx, user.ID, email, database.InviteCancelled, time.Now()); err != nil {
+		respondInternalError(c, "Failed to cancel invite", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"message": "Invite cancelled",
+	})
+}
+
+// relationship loads the current user's relationship named by the id
+// parameter, writing an error response on failure
+func (h *BuddyHandler) relationship(c *gin.Context) (*database.User, *database.BuddyRelationship, bool) {
+	user, ok := currentUser(c)
+	if !ok {
+		return nil, nil, false
+	}
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return nil, nil, false
+	}
+
+	rel, err := h.store.Buddies().Get(c.Request.Context(), user.ID, id)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		respondError(c, http.StatusNotFound, "Relationship not found")
+		return nil, nil, false
+	case err != nil:
+		respondInternalError(c, "Failed to load relationship", err)
+		return nil, nil, false
+	}
+	return user, rel, true
+}
+
+// resolveInvite answers the pending invites from inviterID to email,
+// returning errInviteClosed when a concurrent request answered them first
+func resolveInvite(ctx context.Context, tx store.Store, inviterID uint, email, status string, at time.Time) error {
+	n, err := tx.BuddyInvites().Resolve(ctx, inviterID, email, status, at)
+	if err != nil {
+		return fmt.Errorf("failed to resolve invites: %w", err)
+	}
+	if n == 0 {
+		return errInviteClosed
+	}
+	return nil
+}
+
+// activate marks a pending relationship accepted at now. Coaches start out
+// with every permission.
+func activate(ctx context.Context, tx store.Store, rel *database.BuddyRelationship, now time.Time) error {
+	rel.Status, rel.AcceptedAt = database.BuddyActive, &now
+	if err := tx.Buddies().Update(ctx, rel); err != nil {
+		return fmt.Errorf("failed to accept relationship: %w", err)
+	}
+	return grantCoachPermissions(ctx, tx, rel, now)
+}
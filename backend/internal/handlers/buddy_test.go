@@ -0,0 +1,374 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/mail"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+)
+
+var inviteLink = regexp.MustCompile(`https://ferrovis\.app/buddy-invite\?token=(\S+)`)
+
+// newBuddyTest returns a BuddyHandler over a store holding Sam and Kim
+func newBuddyTest(t *testing.T) (*BuddyHandler, *mail.MemoryMailer, store.Store, *database.User, *database.User) {
+	t.Helper()
+	s := store.NewMemory()
+	mailer := mail.NewMemoryMailer()
+	sam := newTestUser(t, s)
+	kim := &database.User{Email: "kim@example.com", Name: "Kim"}
+	if err := s.Users().Create(context.Background(), kim); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	return NewBuddyHandler(s, mailer, "https://ferrovis.app/"), mailer, s, sam, kim
+}
+
+// performIDRequest runs handler with the id path parameter set
+func performIDRequest(t *testing.T, handler gin.HandlerFunc, method string, id uint, body string) (int, map[string]any) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(id)}}
+	c.Request = httptest.NewRequest(method, "/", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	handler(c)
+	return w.Code, decode(t, w)
+}
+
+// lastInviteToken returns the token linked in the last email sent to address
+func lastInviteToken(t *testing.T, mailer *mail.MemoryMailer, address string) string {
+	t.Helper()
+	sent := mailer.Sent()
+	for i := len(sent) - 1; i >= 0; i-- {
+		if sent[i].To != address {
+			continue
+		}
+		match := inviteLink.FindStringSubmatch(sent[i].Body)
+		if match == nil {
+			t.Fatalf("Expected an invite link, got %q", sent[i].Body)
+		}
+		token, err := url.QueryUnescape(match[1])
+		if err != nil {
+			t.Fatalf("Invalid token %q: %v", match[1], err)
+		}
+		return token
+	}
+	t.Fatalf("Expected an invite emailed to %s", address)
+	return ""
+}
+
+// listBuddies returns the user's relationships and pending invites
+func listBuddies(t *testing.T, h *BuddyHandler, user *database.User) ([]any, []any) {
+	t.Helper()
+	code, resp := performRequest(t, withUser(user, h.List), http.MethodGet, "")
+	if code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %v", code, resp)
+	}
+	buddies, ok := resp["buddies"].([]any)
+	if !ok {
+		t.Fatalf("Expected a list of buddies, got %v", resp)
+	}
+	invites, ok := resp["invites"].([]any)
+	if !ok {
+		t.Fatalf("Expected a list of invites, got %v", resp)
+	}
+	return buddies, invites
+}
+
+func TestInviteExistingUser(t *testing.T) {
+	h, mailer, _, sam, kim := newBuddyTest(t)
+
+	code, resp := performRequest(t, withUser(sam, h.Invite), http.MethodPost, `{"email":"Kim@Example.com"}`)
+	if code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %v", code, resp)
+	}
+	lastInviteToken(t, mailer, kim.Email)
+
+	buddies, invites := listBuddies(t, h, sam)
+	if len(buddies) != 1 || len(invites) != 0 {
+		t.Fatalf("Expected one pending relationship and no open invites, got %v and %v", buddies, invites)
+	}
+	outgoing := object(t, buddies[0])
+	if outgoing["direction"] != directionOutgoing || outgoing["status"] != database.BuddyPending || outgoing["buddy_name"] != kim.Name {
+		t.Errorf("Expected an outgoing invite to Kim, got %v", outgoing)
+	}
+	buddies, _ = listBuddies(t, h, kim)
+	if len(buddies) != 1 || object(t, buddies[0])["direction"] != directionIncoming || object(t, buddies[0])["buddy_name"] != sam.Name {
+		t.Fatalf("Expected Kim to see Sam's invite, got %v", buddies)
+	}
+	rawID, ok := object(t, buddies[0])["id"].(float64)
+	if !ok {
+		t.Fatalf("Expected relationship id, got %v", buddies[0])
+	}
+	id := uint(rawID)
+
+	if code, resp := performIDRequest(t, withUser(sam, h.Accept), http.MethodPost, id, ""); code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for the inviter accepting, got %d: %v", code, resp)
+	}
+	if code, resp := performIDRequest(t, withUser(kim, h.Resume), http.MethodPost, id, ""); code != http.StatusConflict {
+		t.Errorf("Expected status 409 resuming a pending relationship, got %d: %v", code, resp)
+	}
+	code, resp = performIDRequest(t, withUser(kim, h.Accept), http.MethodPost, id, "")
+	if code != http.StatusOK || object(t, resp["buddy"])["status"] != database.BuddyActive {
+		t.Fatalf("Expected Kim to accept, got %d: %v", code, resp)
+	}
+
+	// Accepting in the app also closes the emailed token
+	code, resp = performRequest(t, withUser(kim, h.AcceptInvite), http.MethodPost, `{"token":"`+lastInviteToken(t, mailer, kim.Email)+`"}`)
+	if code != http.StatusGone {
+		t.Errorf("Expected status 410 for an answered invite, got %d: %v", code, resp)
+	}
+	if code, resp := performRequest(t, withUser(kim, h.Invite), http.MethodPost, `{"email":"sam@example.com"}`); code != http.StatusConflict {
+		t.Errorf("Expected status 409 inviting a buddy, got %d: %v", code, resp)
+	}
+
+	for _, step := range []struct {
+		handler gin.HandlerFunc
+		status  string
+	}{{h.Pause, database.BuddyPaused}, {h.Resume, database.BuddyActive}} {
+		code, resp := performIDRequest(t, withUser(sam, step.handler), http.MethodPost, id, "")
+		if code != http.StatusOK || object(t, resp["buddy"])["status"] != step.status {
+			t.Errorf("Expected the relationship to be %s, got %d: %v", step.status, code, resp)
+		}
+	}
+
+	other := &database.User{ID: 99, Email: "lee@example.com"}
+	if code, resp := performIDRequest(t, withUser(other, h.Remove), http.MethodDelete, id, ""); code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an outsider, got %d: %v", code, resp)
+	}
+	if code, resp := performIDRequest(t, withUser(kim, h.Remove), http.MethodDelete, id, ""); code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %v", code, resp)
+	}
+	if buddies, _ := listBuddies(t, h, sam); len(buddies) != 0 {
+		t.Errorf("Expected no buddies after removal, got %v", buddies)
+	}
+}
+
+func TestInviteNewUser(t *testing.T) {
+	ctx := context.Background()
+	h, mailer, s, sam, kim := newBuddyTest(t)
+
+	invite := `{"email":"lee@example.com","role":"client"}`
+	if code, resp := performRequest(t, withUser(sam, h.Invite), http.MethodPost, invite); code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %v", code, resp)
+	}
+	if code, resp := performRequest(t, withUser(sam, h.Invite), http.MethodPost, invite); code != http.StatusConflict {
+		t.Errorf("Expected status 409 for a repeated invite, got %d: %v", code, resp)
+	}
+	if _, invites := listBuddies(t, h, sam); len(invites) != 1 || object(t, invites[0])["email"] != "lee@example.com" {
+		t.Fatalf("Expected the open invite to Lee, got %v", invites)
+	}
+	token := lastInviteToken(t, mailer, "lee@example.com")
+
+	// Only the addressee can use the token
+	if code, resp := performRequest(t, withUser(kim, h.AcceptInvite), http.MethodPost, `{"token":"`+token+`"}`); code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for another account, got %d: %v", code, resp)
+	}
+	if code, resp := performRequest(t, withUser(kim, h.AcceptInvite), http.MethodPost, `{"token":"nope"}`); code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown token, got %d: %v", code, resp)
+	}
+
+	lee := &database.User{Email: "lee@example.com", Name: "Lee"}
+	if err := s.Users().Create(ctx, lee); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	code, resp := performRequest(t, withUser(lee, h.AcceptInvite), http.MethodPost, `{"token":"`+token+`"}`)
+	if code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %v", code, resp)
+	}
+	buddy := object(t, resp["buddy"])
+	if buddy["role"] != database.InviteRoleCoach || buddy["buddy_name"] != sam.Name || buddy["status"] != database.BuddyActive {
+		t.Errorf("Expected Sam to coach Lee, got %v", buddy)
+	}
+	rel, err := s.Buddies().Between(ctx, sam.ID, lee.ID)
+	if err != nil {
+		t.Fatalf("Failed to load relationship: %v", err)
+	}
+	if rel.UserID != lee.ID || rel.BuddyID != sam.ID || rel.RelationshipType != database.RelationshipCoach || rel.AcceptedAt == nil {
+		t.Errorf("Expected Lee as Sam's client, got %+v", rel)
+	}
+
+	if code, resp := performRequest(t, withUser(lee, h.AcceptInvite), http.MethodPost, `{"token":"`+token+`"}`); code != http.StatusGone {
+		t.Errorf("Expected status 410 reusing the token, got %d: %v", code, resp)
+	}
+	if buddies, invites := listBuddies(t, h, sam); len(buddies) != 1 || len(invites) != 0 {
+		t.Errorf("Expected Lee listed as a client only, got %v and %v", buddies, invites)
+	}
+}
+
+// failingMailer fails every send
+type failingMailer struct{}
+
+func (failingMailer) Send(context.Context, mail.Message) error {
+	return errors.New("smtp: connection refused")
+}
+
+func TestInviteWithdrawnWhenEmailFails(t *testing.T) {
+	for _, email := range []string{"kim@example.com", "lee@example.com"} {
+		t.Run(email, func(t *testing.T) {
+			h, mailer, s, sam, _ := newBuddyTest(t)
+			broken := NewBuddyHandler(s, failingMailer{}, "https://ferrovis.app/")
+
+			body := `{"email":"` + email + `"}`
+			if code, resp := performRequest(t, withUser(sam, broken.Invite), http.MethodPost, body); code != http.StatusInternalServerError {
+				t.Fatalf("Expected status 500, got %d: %v", code, resp)
+			}
+			if buddies, invites := listBuddies(t, h, sam); len(buddies) != 0 || len(invites) != 0 {
+				t.Fatalf("Expected the invite withdrawn, got %v and %v", buddies, invites)
+			}
+
+			// Sending again works once the mailer recovers
+			if code, resp := performRequest(t, withUser(sam, h.Invite), http.MethodPost, body); code != http.StatusCreated {
+				t.Fatalf("Expected status 201 on retry, got %d: %v", code, resp)
+			}
+			lastInviteToken(t, mailer, email)
+		})
+	}
+}
+
+func TestInviteReplacesExpiredInvite(t *testing.T) {
+	ctx := context.Background()
+	h, _, s, sam, _ := newBuddyTest(t)
+	expired := &database.BuddyInvite{
+		InviterID: sam.ID,
+		Email:     "lee@example.com",
+		Role:      database.InviteRolePeer,
+		Status:    database.InvitePending,
+		TokenHash: "expired",
+		ExpiresAt: time.Now().Add(-time.Hour),
+	}
+	if err := s.BuddyInvites().Create(ctx, expired); err != nil {
+		t.Fatalf("Failed to store invite: %v", err)
+	}
+
+	if code, resp := performRequest(t, withUser(sam, h.Invite), http.MethodPost, `{"email":"lee@example.com"}`); code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %v", code, resp)
+	}
+	old, err := s.BuddyInvites().GetByHash(ctx, "expired")
+	if err != nil {
+		t.Fatalf("Failed to load invite: %v", err)
+	}
+	if old.Status != database.InviteCancelled {
+		t.Errorf("Expected the expired invite to be cancelled, got %s", old.Status)
+	}
+}
+
+func TestInviteRejectsSelfAndBadInput(t *testing.T) {
+	h, mailer, _, sam, _ := newBuddyTest(t)
+
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"self", `{"email":"SAM@example.com"}`, http.StatusBadRequest},
+		{"invalid email", `{"email":"sam"}`, http.StatusBadRequest},
+		{"unknown role", `{"email":"lee@example.com","role":"boss"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, resp := performRequest(t, withUser(sam, h.Invite), http.MethodPost, tt.body); code != tt.code {
+				t.Errorf("Expected status %d, got %d: %v", tt.code, code, resp)
+			}
+		})
+	}
+	if sent := mailer.Sent(); len(sent) != 0 {
+		t.Errorf("Expected no invites sent, got %d", len(sent))
+	}
+}
+
+func TestDeclineInvite(t *testing.T) {
+	ctx := context.Background()
+	h, mailer, s, sam, kim := newBuddyTest(t)
+
+	if code, resp := performRequest(t, withUser(sam, h.Invite), http.MethodPost, `{"email":"kim@example.com","role":"coach"}`); code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %v", code, resp)
+	}
+	token := lastInviteToken(t, mailer, kim.Email)
+	if code, resp := performRequest(t, withUser(kim, h.DeclineInvite), http.MethodPost, `{"token":"`+token+`"}`); code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %v", code, resp)
+	}
+	if _, err := s.Buddies().Between(ctx, sam.ID, kim.ID); err == nil {
+		t.Error("Expected the pending relationship to be removed")
+	}
+	if buddies, invites := listBuddies(t, h, sam); len(buddies) != 0 || len(invites) != 0 {
+		t.Errorf("Expected nothing left after declining, got %v and %v", buddies, invites)
+	}
+
+	// Declined invitations can be sent again
+	if code, resp := performRequest(t, withUser(sam, h.Invite), http.MethodPost, `{"email":"kim@example.com"}`); code != http.StatusCreated {
+		t.Errorf("Expected status 201 for a new invite, got %d: %v", code, resp)
+	}
+}
+
+func TestNewRelationshipRoles(t *testing.T) {
+	at := time.Date(2025, time.March, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		role        string
+		relType     string
+		userID      uint
+		buddyID     uint
+		inviterSees string
+		inviteeSees string
+	}{
+		{database.InviteRolePeer, database.RelationshipPeer, 1, 2, database.InviteRolePeer, database.InviteRolePeer},
+		{database.InviteRoleCoach, database.RelationshipCoach, 1, 2, database.InviteRoleCoach, database.InviteRoleClient},
+		{database.InviteRoleClient, database.RelationshipCoach, 2, 1, database.InviteRoleClient, database.InviteRoleCoach},
+	}
+	for _, tt := range tests {
+		t.Run(tt.role, func(t *testing.T) {
+			rel := newRelationship(1, 2, tt.role, at)
+			if rel.RelationshipType != tt.relType || rel.UserID != tt.userID || rel.BuddyID != tt.buddyID || rel.InvitedByID != 1 {
+				t.Fatalf("Unexpected relationship %+v", rel)
+			}
+			rel.User, rel.Buddy = database.User{ID: rel.UserID}, database.User{ID: rel.BuddyID}
+			if view := newBuddyView(rel, 1); view.Role != tt.inviterSees || view.BuddyID != 2 || view.Direction != directionOutgoing {
+				t.Errorf("Expected the inviter to see a %s, got %+v", tt.inviterSees, view)
+			}
+			if view := newBuddyView(rel, 2); view.Role != tt.inviteeSees || view.BuddyID != 1 || view.Direction != directionIncoming {
+				t.Errorf("Expected the invitee to see a %s, got %+v", tt.inviteeSees, view)
+			}
+		})
+	}
+}
+
+func TestCompareBuddies(t *testing.T) {
+	h, mailer, s, sam, kim := newBuddyTest(t)
+	if code, resp := performRequest(t, withUser(sam, h.Invite), http.MethodPost, `{"email":"kim@example.com"}`); code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %v", code, resp)
+	}
+	if code, resp := performRequest(t, withUser(kim, h.AcceptInvite), http.MethodPost, `{"token":"`+lastInviteToken(t, mailer, kim.Email)+`"}`); code != http.StatusOK {
+		t.Fatalf("Expected Kim to accept, got %d: %v", code, resp)
+	}
+	if err := s.Exercises().Save(context.Background(), &database.Exercise{Name: "Squat"}); err != nil {
+		t.Fatalf("Failed to add exercise: %v", err)
+	}
+	body := fmt.Sprintf(`{"completed_at":%q,"exercises":[{"name":"Squat","sets":[{"reps":5,"weight":100}]}]}`, time.Now().Format(time.RFC3339))
+	if code, resp := performRequest(t, withUser(kim, newTestWorkoutHandler(s).Create), http.MethodPost, body); code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %v", code, resp)
+	}
+
+	code, resp := performRequest(t, withUser(sam, h.Compare), http.MethodGet, "")
+	if code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %v", code, resp)
+	}
+	comparison := object(t, resp["comparison"])
+	buddies, ok := comparison["buddies"].([]any)
+	if !ok || len(buddies) != 1 || object(t, object(t, buddies[0])["this_week"])["volume"] != float64(500) {
+		t.Errorf("Expected Kim's 500 lb week, got %v", comparison)
+	}
+	insights, ok := comparison["insights"].([]any)
+	if !ok || len(insights) == 0 || object(t, insights[0])["text"] != "You're behind Kim by 1 workout this week." {
+		t.Errorf("Expected Sam to be behind Kim, got %v", comparison["insights"])
+	}
+}
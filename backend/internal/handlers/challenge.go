@@ -0,0 +1,343 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lucas-albers-lz4/ferrovis/internal/challenges"
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+)
+
+// Limits on when a challenge runs. Starts a few minutes in the past are
+// accepted so "starting now" survives clock skew.
+const (
+	maxChallengeDuration = 366 * 24 * time.Hour
+	challengeStartSlack  = 5 * time.Minute
+)
+
+// ChallengeHandler serves group challenges between buddies under /api/challenges
+type ChallengeHandler struct {
+	store store.Store
+}
+
+// NewChallengeHandler creates a ChallengeHandler
+func NewChallengeHandler(s store.Store) *ChallengeHandler {
+	return &ChallengeHandler{store: s}
+}
+
+// createChallengeRequest creates a challenge between the current user and
+// some of their active buddies. Exercise names a catalog exercise; it is
+// required for max_weight, optional for volume and not allowed for workouts.
+type createChallengeRequest struct {
+	Name           string    `json:"name" binding:"required,max=100"`
+	Description    string    `json:"description" binding:"max=500"`
+	Metric         string    `json:"metric" binding:"required,oneof=workouts volume max_weight"`
+	Exercise       string    `json:"exercise" binding:"max=100"`
+	StartsAt       time.Time `json:"starts_at" binding:"required"`
+	EndsAt         time.Time `json:"ends_at" binding:"required"`
+	ParticipantIDs []uint    `json:"participant_ids" binding:"required,min=1,max=50,dive,min=1"`
+}
+
+func (r *createChallengeRequest) normalize() {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Description = strings.TrimSpace(r.Description)
+	r.Exercise = strings.TrimSpace(r.Exercise)
+}
+
+// problems returns the field errors the validation tags cannot express
+func (r *createChallengeRequest) problems(now time.Time) map[string]string {
+	fields := make(map[string]string)
+	switch {
+	case r.StartsAt.Before(now.Add(-challengeStartSlack)):
+		fields["starts_at"] = "must not be in the past"
+	case !r.EndsAt.After(r.StartsAt):
+		fields["ends_at"] = "must be after starts_at"
+	case r.EndsAt.Sub(r.StartsAt) > maxChallengeDuration:
+		fields["ends_at"] = "must be at most a year after starts_at"
+	}
+	switch {
+	case r.Metric == database.ChallengeMaxWeight && r.Exercise == "":
+		fields["exercise"] = "is required"
+	case r.Metric == database.ChallengeWorkouts && r.Exercise != "":
+		fields["exercise"] = "must be empty for workouts"
+	}
+	return fields
+}
+
+// participantView describes a challenge participant
+type participantView struct {
+	UserID     uint       `json:"user_id"`
+	Name       string     `json:"name"`
+	Status     string     `json:"status"`
+	InvitedAt  time.Time  `json:"invited_at"`
+	JoinedAt   *time.Time `json:"joined_at,omitempty"`
+	FinalRank  *int       `json:"final_rank,omitempty"`
+	FinalScore *float64   `json:"final_score,omitempty"`
+}
+
+// challengeView describes a challenge with its participants and, when
+// requested, its leaderboard
+type challengeView struct {
+	*database.Challenge
+	Participants []participantView     `json:"participants"`
+	Leaderboard  []challenges.Standing `json:"leaderboard,omitempty"`
+}
+
+func newChallengeView(c *database.Challenge) challengeView {
+	view := challengeView{Challenge: c, Participants: make([]participantView, 0, len(c.Participants))}
+	for _, p := range c.Participants {
+		view.Participants = append(view.Participants, participantView{
+			UserID:     p.UserID,
+			Name:       p.User.Name,
+			Status:     p.Status,
+			InvitedAt:  p.InvitedAt,
+			JoinedAt:   p.JoinedAt,
+			FinalRank:  p.FinalRank,
+			FinalScore: p.FinalScore,
+		})
+	}
+	return view
+}
+
+// List returns the challenges the current user was invited to, most recent first
+func (h *ChallengeHandler) List(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	list, err := h.store.Challenges().List(c.Request.Context(), user.ID)
+	if err != nil {
+		respondInternalError(c, "Failed to load challenges", err)
+		return
+	}
+
+	views := make([]challengeView, 0, len(list))
+	for i := range list {
+		views = append(views, newChallengeView(&list[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":     "ok",
+		"challenges": views,
+	})
+}
+
+// Create starts a challenge. The creator joins right away; the buddies
+// named as participants are invited and can join until it ends.
+func (h *ChallengeHandler) Create(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	var req createChallengeRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	now := time.Now()
+	ctx := c.Request.Context()
+	fields := req.problems(now)
+	if req.Exercise != "" && fields["exercise"] == "" {
+		name, err := catalogName(ctx, h.store, req.Exercise)
+		if err != nil {
+			respondInternalError(c, "Failed to load exercises", err)
+			return
+		}
+		if name == "" {
+			fields["exercise"] = "unknown exercise"
+		}
+		req.Exercise = name
+	}
+	if ok, err := h.allBuddies(ctx, user.ID, req.ParticipantIDs); err != nil {
+		respondInternalError(c, "Failed to load buddies", err)
+		return
+	} else if !ok {
+		fields["participant_ids"] = "must all be your active buddies"
+	}
+	if len(fields) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Validation failed",
+			"errors":  fields,
+		})
+		return
+	}
+
+	challenge := &database.Challenge{
+		CreatorID:   user.ID,
+		Name:        req.Name,
+		Description: req.Description,
+		Metric:      req.Metric,
+		Exercise:    req.Exercise,
+		StartsAt:    req.StartsAt,
+		EndsAt:      req.EndsAt,
+		Participants: []database.ChallengeParticipant{
+			{UserID: user.ID, Status: database.ParticipantJoined, InvitedAt: now, JoinedAt: &now},
+		},
+	}
+	for _, id := range req.ParticipantIDs {
+		if !slices.ContainsFunc(challenge.Participants, func(p database.ChallengeParticipant) bool { return p.UserID == id }) {
+			challenge.Participants = append(challenge.Participants,
+				database.ChallengeParticipant{UserID: id, Status: database.ParticipantInvited, InvitedAt: now})
+		}
+	}
+	if err := h.store.Challenges().Create(ctx, challenge); err != nil {
+		respondInternalError(c, "Failed to create challenge", err)
+		return
+	}
+
+	h.respondWithChallenge(c, http.StatusCreated, challenge.ID)
+}
+
+// Get returns a challenge with its live leaderboard, or the final standings
+// once it has completed
+func (h *ChallengeHandler) Get(c *gin.Context) {
+	challenge, _, ok := h.challenge(c)
+	if !ok {
+		return
+	}
+
+	view := newChallengeView(challenge)
+	standings, err := challenges.Leaderboard(c.Request.Context(), h.store, challenge, time.Now())
+	if err != nil {
+		respondInternalError(c, "Failed to compute leaderboard", err)
+		return
+	}
+	view.Leaderboard = standings
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":    "ok",
+		"challenge": view,
+	})
+}
+
+// Join joins a challenge the current user was invited to. Joining is open
+// until the challenge ends, also after declining; workouts only count from
+// the moment of joining.
+func (h *ChallengeHandler) Join(c *gin.Context) {
+	challenge, p, ok := h.challenge(c)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	switch {
+	case p.Status == database.ParticipantJoined:
+		respondError(c, http.StatusConflict, "You have already joined this challenge")
+		return
+	case !now.Before(challenge.EndsAt):
+		respondError(c, http.StatusConflict, "This challenge has ended")
+		return
+	}
+
+	p.Status, p.JoinedAt = database.ParticipantJoined, &now
+	if err := h.store.Challenges().UpdateParticipant(c.Request.Context(), p); err != nil {
+		respondInternalError(c, "Failed to join challenge", err)
+		return
+	}
+	h.respondWithChallenge(c, http.StatusOK, challenge.ID)
+}
+
+// Decline turns down an invitation to a challenge. Participants who joined
+// stay on the leaderboard.
+func (h *ChallengeHandler) Decline(c *gin.Context) {
+	challenge, p, ok := h.challenge(c)
+	if !ok {
+		return
+	}
+	if p.Status != database.ParticipantInvited {
+		respondError(c, http.StatusConflict, "Only pending invitations can be declined")
+		return
+	}
+
+	p.Status = database.ParticipantDeclined
+	if err := h.store.Challenges().UpdateParticipant(c.Request.Context(), p); err != nil {
+		respondInternalError(c, "Failed to decline challenge", err)
+		return
+	}
+	h.respondWithChallenge(c, http.StatusOK, challenge.ID)
+}
+
+// challenge loads the challenge named by the id parameter and the current
+// user's participation, writing an error response on failure. Challenges
+// the user was not invited to are reported as not found.
+func (h *ChallengeHandler) challenge(c *gin.Context) (*database.Challenge, *database.ChallengeParticipant, bool) {
+	user, ok := currentUser(c)
+	if !ok {
+		return nil, nil, false
+	}
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return nil, nil, false
+	}
+
+	challenge, err := h.store.Challenges().Get(c.Request.Context(), id)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		respondError(c, http.StatusNotFound, "Challenge not found")
+		return nil, nil, false
+	case err != nil:
+		respondInternalError(c, "Failed to load challenge", err)
+		return nil, nil, false
+	}
+	i := slices.IndexFunc(challenge.Participants, func(p database.ChallengeParticipant) bool { return p.UserID == user.ID })
+	if i < 0 {
+		respondError(c, http.StatusNotFound, "Challenge not found")
+		return nil, nil, false
+	}
+	return challenge, &challenge.Participants[i], true
+}
+
+// respondWithChallenge loads a challenge and writes it with its participants
+func (h *ChallengeHandler) respondWithChallenge(c *gin.Context, status int, id uint) {
+	challenge, err := h.store.Challenges().Get(c.Request.Context(), id)
+	if err != nil {
+		respondInternalError(c, "Failed to load challenge", err)
+		return
+	}
+	c.JSON(status, gin.H{
+		"status":    "ok",
+		"challenge": newChallengeView(challenge),
+	})
+}
+
+// allBuddies reports whether every user in ids is an active buddy of userID
+func (h *ChallengeHandler) allBuddies(ctx context.Context, userID uint, ids []uint) (bool, error) {
+	rels, err := h.store.Buddies().List(ctx, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to load buddies: %w", err)
+	}
+	active := make(map[uint]bool, len(rels))
+	for _, rel := range rels {
+		if rel.Status == database.BuddyActive {
+			active[rel.UserID], active[rel.BuddyID] = true, true
+		}
+	}
+	for _, id := range ids {
+		if id == userID || !active[id] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// catalogName returns the catalog's spelling of an exercise name, matched
+// case-insensitively, or "" when the catalog has no such exercise
+func catalogName(ctx context.Context, s store.Store, name string) (string, error) {
+	catalog, err := s.Exercises().List(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to load exercises: %w", err)
+	}
+	for _, e := range catalog {
+		if strings.EqualFold(e.Name, name) {
+			return e.Name, nil
+		}
+	}
+	return "", nil
+}
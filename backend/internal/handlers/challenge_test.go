@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+)
+
+// newChallengeTest returns a handler over a store where Sam and Kim are
+// active buddies and Alex is a stranger to both
+func newChallengeTest(t *testing.T) (*ChallengeHandler, store.Store, *database.User, *database.User, *database.User) {
+	t.Helper()
+	ctx := context.Background()
+	s := store.NewMemory()
+	sam := newTestUser(t, s)
+	kim := &database.User{Email: "kim@example.com", Name: "Kim"}
+	alex := &database.User{Email: "alex@example.com", Name: "Alex"}
+	for _, user := range []*database.User{kim, alex} {
+		if err := s.Users().Create(ctx, user); err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+	}
+	rel := &database.BuddyRelationship{UserID: kim.ID, BuddyID: sam.ID, RelationshipType: database.RelationshipPeer, Status: database.BuddyActive}
+	if err := s.Buddies().Create(ctx, rel); err != nil {
+		t.Fatalf("Failed to create relationship: %v", err)
+	}
+	if err := s.Exercises().Save(ctx, &database.Exercise{Name: "Squat"}); err != nil {
+		t.Fatalf("Failed to add exercise: %v", err)
+	}
+	return NewChallengeHandler(s), s, sam, kim, alex
+}
+
+func TestCreateChallengeValidates(t *testing.T) {
+	h, _, sam, kim, alex := newChallengeTest(t)
+	now := time.Now()
+	window := func(start, end time.Time) string {
+		return fmt.Sprintf(`"starts_at":%q,"ends_at":%q`, start.Format(time.RFC3339), end.Format(time.RFC3339))
+	}
+	month := window(now, now.AddDate(0, 1, 0))
+
+	tests := []struct {
+		name  string
+		body  string
+		field string
+	}{
+		{"ends before it starts", fmt.Sprintf(`{"name":"Oops","metric":"workouts",%s,"participant_ids":[%d]}`, window(now, now.Add(-time.Hour)), kim.ID), "ends_at"},
+		{"starts in the past", fmt.Sprintf(`{"name":"Oops","metric":"workouts",%s,"participant_ids":[%d]}`, window(now.AddDate(0, 0, -7), now), kim.ID), "starts_at"},
+		{"longer than a year", fmt.Sprintf(`{"name":"Oops","metric":"workouts",%s,"participant_ids":[%d]}`, window(now, now.AddDate(2, 0, 0)), kim.ID), "ends_at"},
+		{"max weight without exercise", fmt.Sprintf(`{"name":"Oops","metric":"max_weight",%s,"participant_ids":[%d]}`, month, kim.ID), "exercise"},
+		{"unknown exercise", fmt.Sprintf(`{"name":"Oops","metric":"volume","exercise":"Curl",%s,"participant_ids":[%d]}`, month, kim.ID), "exercise"},
+		{"stranger", fmt.Sprintf(`{"name":"Oops","metric":"workouts",%s,"participant_ids":[%d]}`, month, alex.ID), "participant_ids"},
+		{"creator", fmt.Sprintf(`{"name":"Oops","metric":"workouts",%s,"participant_ids":[%d]}`, month, sam.ID), "participant_ids"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, resp := performRequest(t, withUser(sam, h.Create), http.MethodPost, tt.body)
+			if code != http.StatusBadRequest {
+				t.Fatalf("Expected status 400, got %d: %v", code, resp)
+			}
+			if _, ok := object(t, resp["errors"])[tt.field]; !ok {
+				t.Errorf("Expected an error for %s, got %v", tt.field, resp["errors"])
+			}
+		})
+	}
+}
+
+func TestChallengeLifecycle(t *testing.T) {
+	h, s, sam, kim, alex := newChallengeTest(t)
+	now := time.Now()
+	body := fmt.Sprintf(`{"name":"Squat month","metric":"volume","exercise":"squat","starts_at":%q,"ends_at":%q,"participant_ids":[%d,%d]}`,
+		now.Format(time.RFC3339), now.AddDate(0, 1, 0).Format(time.RFC3339), kim.ID, kim.ID)
+	code, resp := performRequest(t, withUser(sam, h.Create), http.MethodPost, body)
+	if code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %v", code, resp)
+	}
+	challenge := object(t, resp["challenge"])
+	if challenge["exercise"] != "Squat" {
+		t.Errorf("Expected the catalog's spelling of the exercise, got %v", challenge["exercise"])
+	}
+	participants, ok := challenge["participants"].([]any)
+	if !ok || len(participants) != 2 || object(t, participants[1])["status"] != database.ParticipantInvited {
+		t.Fatalf("Expected Sam joined and Kim invited once, got %v", challenge["participants"])
+	}
+	id := uint(challenge["id"].(float64))
+
+	if code, resp := performIDRequest(t, withUser(alex, h.Get), http.MethodGet, id, ""); code != http.StatusNotFound {
+		t.Errorf("Expected strangers to get 404, got %d: %v", code, resp)
+	}
+	if code, resp := performIDRequest(t, withUser(kim, h.Decline), http.MethodPost, id, ""); code != http.StatusOK {
+		t.Fatalf("Expected Kim to decline, got %d: %v", code, resp)
+	}
+	if code, resp := performIDRequest(t, withUser(kim, h.Decline), http.MethodPost, id, ""); code != http.StatusConflict {
+		t.Errorf("Expected a second decline to conflict, got %d: %v", code, resp)
+	}
+
+	// Kim changes their mind; only workouts from now on count
+	workout := fmt.Sprintf(`{"completed_at":%q,"exercises":[{"name":"Squat","sets":[{"reps":5,"weight":100}]}]}`, time.Now().Format(time.RFC3339Nano))
+	if code, resp := performRequest(t, withUser(kim, newTestWorkoutHandler(s).Create), http.MethodPost, workout); code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %v", code, resp)
+	}
+	if code, resp := performIDRequest(t, withUser(kim, h.Join), http.MethodPost, id, ""); code != http.StatusOK {
+		t.Fatalf("Expected Kim to join, got %d: %v", code, resp)
+	}
+	if code, resp := performIDRequest(t, withUser(kim, h.Join), http.MethodPost, id, ""); code != http.StatusConflict {
+		t.Errorf("Expected a second join to conflict, got %d: %v", code, resp)
+	}
+	workout = fmt.Sprintf(`{"completed_at":%q,"exercises":[{"name":"Squat","sets":[{"reps":5,"weight":200}]}]}`, time.Now().Format(time.RFC3339Nano))
+	if code, resp := performRequest(t, withUser(kim, newTestWorkoutHandler(s).Create), http.MethodPost, workout); code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %v", code, resp)
+	}
+
+	code, resp = performIDRequest(t, withUser(sam, h.Get), http.MethodGet, id, "")
+	if code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %v", code, resp)
+	}
+	leaderboard, ok := object(t, resp["challenge"])["leaderboard"].([]any)
+	if !ok || len(leaderboard) != 2 {
+		t.Fatalf("Expected Sam and Kim on the leaderboard, got %v", resp["challenge"])
+	}
+	if first := object(t, leaderboard[0]); first["name"] != "Kim" || first["score"] != float64(1000) {
+		t.Errorf("Expected Kim to lead with 1000, got %v", first)
+	}
+
+	code, resp = performRequest(t, withUser(kim, h.List), http.MethodGet, "")
+	if list, ok := resp["challenges"].([]any); code != http.StatusOK || !ok || len(list) != 1 {
+		t.Errorf("Expected Kim to see the challenge, got %d: %v", code, resp)
+	}
+}
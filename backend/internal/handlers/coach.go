@@ -0,0 +1,639 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/programdef"
+	"github.com/lucas-albers-lz4/ferrovis/internal/records"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+	"github.com/lucas-albers-lz4/ferrovis/internal/weasel"
+)
+
+// Periods the roster's adherence stats cover, in days
+const (
+	adherenceDays = 28
+	daysPerWeek   = 7
+	hoursPerDay   = 24
+)
+
+// errProgramNameTaken is returned when a customized program's name is in use
+var errProgramNameTaken = errors.New("program name is taken")
+
+// CoachHandler serves a coach's client roster and dashboards under
+// /api/clients and a client's view of their coaches under /api/coaches. A
+// coach only sees what each client has granted them permission to.
+type CoachHandler struct {
+	store       store.Store
+	engine      *weasel.Engine
+	enrollments *EnrollmentHandler
+}
+
+// NewCoachHandler creates a CoachHandler that sends coach messages through
+// engine. defaultWeeks is the program length used when assigning a program
+// that does not set one.
+func NewCoachHandler(s store.Store, engine *weasel.Engine, defaultWeeks int) *CoachHandler {
+	return &CoachHandler{store: s, engine: engine, enrollments: NewEnrollmentHandler(s, defaultWeeks)}
+}
+
+// coachView describes a coaching relationship from the client's side
+type coachView struct {
+	ID          uint                       `json:"id"`
+	CoachID     uint                       `json:"coach_id"`
+	CoachName   string                     `json:"coach_name"`
+	Status      string                     `json:"status"`
+	AcceptedAt  *time.Time                 `json:"accepted_at,omitempty"`
+	Permissions []database.CoachPermission `json:"permissions"`
+}
+
+// clientView is an entry in a coach's roster. Adherence is only reported
+// while coaching is active and the client lets the coach see their workouts.
+type clientView struct {
+	RelationshipID uint            `json:"relationship_id"`
+	ClientID       uint            `json:"client_id"`
+	ClientName     string          `json:"client_name"`
+	Status         string          `json:"status"`
+	AcceptedAt     *time.Time      `json:"accepted_at,omitempty"`
+	Permissions    []string        `json:"permissions"`
+	Adherence      *adherenceStats `json:"adherence,omitempty"`
+}
+
+// adherenceStats summarizes a client's recent training. The adherence rate
+// compares the sessions logged against the client's active program with the
+// sessions it planned over the last four weeks, or since enrolling.
+type adherenceStats struct {
+	WorkoutsLast7Days  int        `json:"workouts_last_7_days"`
+	WorkoutsLast28Days int        `json:"workouts_last_28_days"`
+	LastWorkoutAt      *time.Time `json:"last_workout_at,omitempty"`
+	CurrentStreak      int        `json:"current_streak"`
+	ProgramName        string     `json:"program_name,omitempty"`
+	PlannedSessions    int        `json:"planned_sessions,omitempty"`
+	ProgramSessions    int        `json:"program_sessions,omitempty"`
+	AdherenceRate      *float64   `json:"adherence_rate,omitempty"` // Capped at 1
+}
+
+// assignProgramRequest assigns a program to a client. A definition
+// customizes it: the result is saved as a new program owned by the coach
+// with the base program's difficulty and duration, named Name or after the
+// base program and the client.
+type assignProgramRequest struct {
+	ProgramID    uint               `json:"program_id" binding:"required"`
+	Name         string             `json:"name" binding:"max=100"`
+	Definition   json.RawMessage    `json:"definition"`
+	StartWeights map[string]float64 `json:"start_weights" binding:"omitempty,max=50,dive,min=0,max=2000"`
+}
+
+func (r *assignProgramRequest) normalize() {
+	r.Name = strings.TrimSpace(r.Name)
+}
+
+// customized reports whether the request carries a definition
+func (r *assignProgramRequest) customized() bool {
+	return len(r.Definition) > 0 && string(r.Definition) != "null"
+}
+
+// coachMessageRequest is a motivational message written by a coach
+type coachMessageRequest struct {
+	Content string `json:"content" binding:"required,max=500"`
+}
+
+func (r *coachMessageRequest) normalize() {
+	r.Content = strings.TrimSpace(r.Content)
+}
+
+// Clients returns the current user's coaching clients with their adherence
+// over the last four weeks
+func (h *CoachHandler) Clients(c *gin.Context) {
+	coach, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	rels, err := h.store.Buddies().List(ctx, coach.ID)
+	if err != nil {
+		respondInternalError(c, "Failed to load clients", err)
+		return
+	}
+
+	now := time.Now()
+	clients := make([]clientView, 0, len(rels))
+	for i := range rels {
+		rel := &rels[i]
+		if rel.RelationshipType != database.RelationshipCoach || rel.BuddyID != coach.ID || rel.Status == database.BuddyPending {
+			continue
+		}
+		perms, err := h.store.CoachPermissions().List(ctx, rel.ID)
+		if err != nil {
+			respondInternalError(c, "Failed to load permissions", err)
+			return
+		}
+		view := clientView{
+			RelationshipID: rel.ID,
+			ClientID:       rel.UserID,
+			ClientName:     rel.User.Name,
+			Status:         rel.Status,
+			AcceptedAt:     rel.AcceptedAt,
+			Permissions:    permissionNames(perms),
+		}
+		if rel.Status == database.BuddyActive && slices.Contains(view.Permissions, database.PermissionViewWorkouts) {
+			if view.Adherence, err = clientAdherence(ctx, h.store, rel.UserID, now); err != nil {
+				respondInternalError(c, "Failed to compute adherence", err)
+				return
+			}
+		}
+		clients = append(clients, view)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"clients": clients,
+	})
+}
+
+// ClientWorkouts returns a client's workouts, most recent first
+func (h *CoachHandler) ClientWorkouts(c *gin.Context) {
+	_, client, ok := h.client(c, database.PermissionViewWorkouts)
+	if !ok {
+		return
+	}
+
+	limit, offset := pagination(c)
+	workouts, total, err := h.store.Workouts().List(c.Request.Context(), client.ID, store.Page{Limit: limit, Offset: offset})
+	if err != nil {
+		respondInternalError(c, "Failed to fetch workouts", err)
+		return
+	}
+
+	out := make([]workoutResponse, 0, len(workouts))
+	for i := range workouts {
+		out = append(out, newWorkoutResponse(&workouts[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   "ok",
+		"workouts": out,
+		"pagination": gin.H{
+			"limit":  limit,
+			"offset": offset,
+			"total":  total,
+		},
+	})
+}
+
+// ClientRecords returns a client's heaviest completed set of each exercise
+// they have logged, including exercises logged only once
+func (h *CoachHandler) ClientRecords(c *gin.Context) {
+	_, client, ok := h.client(c, database.PermissionViewRecords)
+	if !ok {
+		return
+	}
+
+	workouts, err := h.store.Workouts().History(c.Request.Context(), store.HistoryQuery{UserID: client.ID})
+	if err != nil {
+		respondInternalError(c, "Failed to load workout history", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"records": records.Heaviest(workouts),
+	})
+}
+
+// AssignProgram enrolls a client in a program, optionally customized,
+// abandoning their current enrollment like a switch would
+func (h *CoachHandler) AssignProgram(c *gin.Context) {
+	coach, client, ok := h.client(c, database.PermissionManagePrograms)
+	if !ok {
+		return
+	}
+
+	var req assignProgramRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	var structure string
+	if req.customized() {
+		def, err := programdef.Parse(req.Definition)
+		if err != nil {
+			respondDefinitionError(c, err)
+			return
+		}
+		catalog, err := exerciseCatalog(ctx, h.store)
+		if err != nil {
+			respondInternalError(c, "Failed to load exercises", err)
+			return
+		}
+		if err := def.ValidateExercises(catalog); err != nil {
+			respondDefinitionError(c, err)
+			return
+		}
+		if structure, err = def.JSON(); err != nil {
+			respondInternalError(c, "Failed to encode program", err)
+			return
+		}
+	}
+
+	var (
+		custom     *database.Program
+		enrollment *database.Enrollment
+	)
+	err := h.store.Transaction(ctx, func(tx store.Store) error {
+		if structure != "" {
+			var err error
+			if custom, err = customProgram(ctx, tx, coach, client, &req, structure); err != nil {
+				return err
+			}
+			req.ProgramID = custom.ID
+		}
+
+		var carried map[string]float64
+		current, err := loadCurrentEnrollment(ctx, tx, client.ID)
+		switch {
+		case errors.Is(err, errNotEnrolled):
+		case err != nil:
+			return err
+		case current.ProgramID == req.ProgramID:
+			return errSameProgram
+		default:
+			if carried, err = abandonForSwitch(ctx, tx, current); err != nil {
+				return err
+			}
+		}
+
+		enrollment, err = h.enrollments.createEnrollment(ctx, tx, client.ID,
+			&enrollRequest{ProgramID: req.ProgramID, StartWeights: req.StartWeights}, carried)
+		return err
+	})
+	if errors.Is(err, errProgramNameTaken) {
+		respondError(c, http.StatusConflict, "A program with this name already exists")
+		return
+	}
+	if !h.enrollments.handleWriteError(c, err, "Failed to assign program") {
+		return
+	}
+
+	def, _ := programdef.Parse([]byte(enrollment.Program.Structure)) //nolint:errcheck // day name is omitted for unusable definitions
+	resp := gin.H{
+		"status":     "ok",
+		"enrollment": newEnrollmentResponse(enrollment, def),
+	}
+	if custom != nil {
+		resp["program"] = custom
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+// customProgram saves a coach's customization of the requested program
+func customProgram(ctx context.Context, tx store.Store, coach, client *database.User,
+	req *assignProgramRequest, structure string,
+) (*database.Program, error) {
+	base, err := tx.Programs().Get(ctx, req.ProgramID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, errUnknownProgram
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load program: %w", err)
+	}
+
+	name := req.Name
+	if name == "" {
+		name = fmt.Sprintf("%s for %s", base.Name, client.Name)
+	}
+	program := database.Program{
+		Name:        name,
+		Description: base.Description,
+		Difficulty:  base.Difficulty,
+		Duration:    base.Duration,
+		Structure:   structure,
+		CreatedByID: &coach.ID,
+	}
+	if err := tx.Programs().Create(ctx, &program); err != nil {
+		if errors.Is(err, store.ErrDuplicate) {
+			return nil, errProgramNameTaken
+		}
+		return nil, fmt.Errorf("failed to create program: %w", err)
+	}
+	return &program, nil
+}
+
+// SendMessage delivers a coach's message to a client through the Weasel
+// pipeline, subject to the client's Weasel Mode setting
+func (h *CoachHandler) SendMessage(c *gin.Context) {
+	coach, client, ok := h.client(c, database.PermissionSendMessages)
+	if !ok {
+		return
+	}
+
+	var req coachMessageRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	msg, err := h.engine.Compose(c.Request.Context(), weasel.Request{
+		UserID:   client.ID,
+		Type:     database.MessageCoach,
+		Content:  req.Content,
+		SenderID: &coach.ID,
+	})
+	switch {
+	case errors.Is(err, weasel.ErrDisabled), errors.Is(err, weasel.ErrNotAllowed):
+		respondError(c, http.StatusConflict, client.Name+" has turned off Weasel Mode messages")
+		return
+	case err != nil:
+		respondInternalError(c, "Failed to send message", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":  "ok",
+		"message": msg,
+	})
+}
+
+// client loads the coach's client named by the id parameter, writing an
+// error response unless coaching is active and the client granted permission
+func (h *CoachHandler) client(c *gin.Context, permission string) (coach, client *database.User, ok bool) {
+	coach, ok = currentUser(c)
+	if !ok {
+		return nil, nil, false
+	}
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return nil, nil, false
+	}
+	ctx := c.Request.Context()
+
+	rel, err := h.store.Buddies().Between(ctx, coach.ID, id)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		respondError(c, http.StatusNotFound, "Client not found")
+		return nil, nil, false
+	case err != nil:
+		respondInternalError(c, "Failed to load client", err)
+		return nil, nil, false
+	case rel.RelationshipType != database.RelationshipCoach || rel.BuddyID != coach.ID:
+		respondError(c, http.StatusNotFound, "Client not found")
+		return nil, nil, false
+	case rel.Status != database.BuddyActive:
+		respondError(c, http.StatusForbidden, "Coaching this client is "+rel.Status)
+		return nil, nil, false
+	}
+
+	perms, err := h.store.CoachPermissions().List(ctx, rel.ID)
+	if err != nil {
+		respondInternalError(c, "Failed to load permissions", err)
+		return nil, nil, false
+	}
+	if !slices.Contains(permissionNames(perms), permission) {
+		respondError(c, http.StatusForbidden, rel.User.Name+" has not granted you the "+permission+" permission")
+		return nil, nil, false
+	}
+	return coach, &rel.User, true
+}
+
+// Coaches returns the current user's coaches with the permissions granted to each
+func (h *CoachHandler) Coaches(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	rels, err := h.store.Buddies().List(ctx, user.ID)
+	if err != nil {
+		respondInternalError(c, "Failed to load coaches", err)
+		return
+	}
+
+	coaches := make([]coachView, 0, len(rels))
+	for i := range rels {
+		rel := &rels[i]
+		if rel.RelationshipType != database.RelationshipCoach || rel.UserID != user.ID || rel.Status == database.BuddyPending {
+			continue
+		}
+		view, err := h.coachView(ctx, rel)
+		if err != nil {
+			respondInternalError(c, "Failed to load permissions", err)
+			return
+		}
+		coaches = append(coaches, view)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"coaches": coaches,
+	})
+}
+
+// GrantPermission grants one of the current user's coaches a permission.
+// Granting a permission the coach already has is a no-op.
+func (h *CoachHandler) GrantPermission(c *gin.Context) {
+	rel, permission, ok := h.coachPermission(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	err := h.store.CoachPermissions().Grant(ctx, &database.CoachPermission{
+		RelationshipID: rel.ID,
+		Permission:     permission,
+		GrantedAt:      time.Now(),
+	})
+	if err != nil && !errors.Is(err, store.ErrDuplicate) {
+		respondInternalError(c, "Failed to grant permission", err)
+		return
+	}
+	h.respondWithCoach(c, rel)
+}
+
+// RevokePermission revokes a permission from one of the current user's coaches
+func (h *CoachHandler) RevokePermission(c *gin.Context) {
+	rel, permission, ok := h.coachPermission(c)
+	if !ok {
+		return
+	}
+
+	err := h.store.CoachPermissions().Revoke(c.Request.Context(), rel.ID, permission)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		respondError(c, http.StatusNotFound, "Permission not granted")
+		return
+	case err != nil:
+		respondInternalError(c, "Failed to revoke permission", err)
+		return
+	}
+	h.respondWithCoach(c, rel)
+}
+
+// coachPermission loads the coaching relationship named by the id parameter
+// and validates the permission parameter, writing an error response on failure
+func (h *CoachHandler) coachPermission(c *gin.Context) (*database.BuddyRelationship, string, bool) {
+	user, ok := currentUser(c)
+	if !ok {
+		return nil, "", false
+	}
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return nil, "", false
+	}
+	permission := c.Param("permission")
+	if !slices.Contains(database.CoachPermissions, permission) {
+		respondError(c, http.StatusBadRequest, "Invalid permission")
+		return nil, "", false
+	}
+
+	rel, err := h.store.Buddies().Get(c.Request.Context(), user.ID, id)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		respondError(c, http.StatusNotFound, "Coach not found")
+		return nil, "", false
+	case err != nil:
+		respondInternalError(c, "Failed to load coach", err)
+		return nil, "", false
+	case rel.RelationshipType != database.RelationshipCoach || rel.UserID != user.ID:
+		respondError(c, http.StatusNotFound, "Coach not found")
+		return nil, "", false
+	case rel.Status == database.BuddyPending:
+		respondError(c, http.StatusConflict, "Accept the coaching invite first")
+		return nil, "", false
+	}
+	return rel, permission, true
+}
+
+// respondWithCoach writes a coaching relationship with its current permissions
+func (h *CoachHandler) respondWithCoach(c *gin.Context, rel *database.BuddyRelationship) {
+	view, err := h.coachView(c.Request.Context(), rel)
+	if err != nil {
+		respondInternalError(c, "Failed to load permissions", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+		"coach":  view,
+	})
+}
+
+// coachView describes rel with its permissions from the client's side
+func (h *CoachHandler) coachView(ctx context.Context, rel *database.BuddyRelationship) (coachView, error) {
+	perms, err := h.store.CoachPermissions().List(ctx, rel.ID)
+	if err != nil {
+		return coachView{}, fmt.Errorf("failed to load permissions: %w", err)
+	}
+	if perms == nil {
+		perms = []database.CoachPermission{}
+	}
+	return coachView{
+		ID:          rel.ID,
+		CoachID:     rel.BuddyID,
+		CoachName:   rel.Buddy.Name,
+		Status:      rel.Status,
+		AcceptedAt:  rel.AcceptedAt,
+		Permissions: perms,
+	}, nil
+}
+
+// grantCoachPermissions grants the coach in a newly accepted relationship
+// every permission; other relationships are left alone
+func grantCoachPermissions(ctx context.Context, tx store.Store, rel *database.BuddyRelationship, now time.Time) error {
+	if rel.RelationshipType != database.RelationshipCoach {
+		return nil
+	}
+	for _, permission := range database.CoachPermissions {
+		err := tx.CoachPermissions().Grant(ctx, &database.CoachPermission{
+			RelationshipID: rel.ID,
+			Permission:     permission,
+			GrantedAt:      now,
+		})
+		if err != nil && !errors.Is(err, store.ErrDuplicate) {
+			return fmt.Errorf("failed to grant %s: %w", permission, err)
+		}
+	}
+	return nil
+}
+
+// permissionNames returns the names of granted permissions
+func permissionNames(perms []database.CoachPermission) []string {
+	names := make([]string, 0, len(perms))
+	for _, p := range perms {
+		names = append(names, p.Permission)
+	}
+	return names
+}
+
+// clientAdherence summarizes the client's training up to now
+func clientAdherence(ctx context.Context, s store.Store, clientID uint, now time.Time) (*adherenceStats, error) {
+	since := now.AddDate(0, 0, -adherenceDays)
+	workouts, err := s.Workouts().History(ctx, store.HistoryQuery{UserID: clientID, Since: since, Until: &now})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load workout history: %w", err)
+	}
+	stats := &adherenceStats{WorkoutsLast28Days: len(workouts)}
+	weekAgo := now.AddDate(0, 0, -daysPerWeek)
+	for i := range workouts {
+		if !workouts[i].CompletedAt.Before(weekAgo) {
+			stats.WorkoutsLast7Days++
+		}
+	}
+
+	recent, _, err := s.Workouts().List(ctx, clientID, store.Page{Limit: 1})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load workouts: %w", err)
+	}
+	if len(recent) > 0 {
+		stats.LastWorkoutAt = &recent[0].CompletedAt
+	}
+
+	streak, err := s.Streaks().Get(ctx, clientID, database.StreakWorkout)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+	case err != nil:
+		return nil, fmt.Errorf("failed to load streak: %w", err)
+	case streak.IsActive:
+		stats.CurrentStreak = streak.Current
+	}
+
+	enrollment, err := s.Enrollments().Current(ctx, clientID)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return stats, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to load enrollment: %w", err)
+	}
+	stats.ProgramName = enrollment.Program.Name
+	def, err := programdef.Parse([]byte(enrollment.Program.Structure))
+	if err != nil || enrollment.Status != database.EnrollmentActive {
+		return stats, nil //nolint:nilerr // adherence is only measured against an active, usable program
+	}
+
+	start := since
+	if enrollment.StartedAt.After(start) {
+		start = enrollment.StartedAt
+	}
+	weeks := now.Sub(start).Hours() / hoursPerDay / daysPerWeek
+	stats.PlannedSessions = int(math.Round(weeks * float64(def.SessionsPerWeek())))
+	for i := range workouts {
+		w := &workouts[i]
+		if w.ProgramID != nil && *w.ProgramID == enrollment.ProgramID && !w.CompletedAt.Before(start) {
+			stats.ProgramSessions++
+		}
+	}
+	if stats.PlannedSessions > 0 {
+		rate := math.Min(1, float64(stats.ProgramSessions)/float64(stats.PlannedSessions))
+		stats.AdherenceRate = &rate
+	}
+	return stats, nil
+}
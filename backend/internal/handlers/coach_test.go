@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/programdef"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+	"github.com/lucas-albers-lz4/ferrovis/internal/weasel"
+)
+
+// coachTest is Sam coached by Kim, set up through an accepted invite
+type coachTest struct {
+	h       *CoachHandler
+	s       store.Store
+	sam     *database.User
+	kim     *database.User
+	relID   uint
+	program *database.Program
+}
+
+func newCoachTest(t *testing.T) *coachTest {
+	t.Helper()
+	ctx := context.Background()
+	buddies, mailer, s, sam, kim := newBuddyTest(t)
+	if err := s.Users().Update(ctx, sam.ID, map[string]any{"weasel_mode_enabled": true}); err != nil {
+		t.Fatalf("Failed to enable Weasel Mode: %v", err)
+	}
+
+	if code, resp := performRequest(t, withUser(sam, buddies.Invite), http.MethodPost, `{"email":"kim@example.com","role":"coach"}`); code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %v", code, resp)
+	}
+	code, resp := performRequest(t, withUser(kim, buddies.AcceptInvite), http.MethodPost, `{"token":"`+lastInviteToken(t, mailer, kim.Email)+`"}`)
+	if code != http.StatusOK {
+		t.Fatalf("Expected Kim to accept, got %d: %v", code, resp)
+	}
+	rawID, ok := object(t, resp["buddy"])["id"].(float64)
+	if !ok {
+		t.Fatalf("Expected relationship id, got %v", resp)
+	}
+
+	for _, name := range programdef.StrongLifts().Exercises() {
+		if err := s.Exercises().Save(ctx, &database.Exercise{Name: name}); err != nil {
+			t.Fatalf("Failed to add exercise: %v", err)
+		}
+	}
+	structure, err := programdef.StrongLifts().JSON()
+	if err != nil {
+		t.Fatalf("Failed to encode program: %v", err)
+	}
+	program := &database.Program{Name: programdef.StrongLiftsName, Difficulty: "beginner", Duration: 12, Structure: structure}
+	if err := s.Programs().Create(ctx, program); err != nil {
+		t.Fatalf("Failed to create program: %v", err)
+	}
+
+	engine := weasel.NewEngine(s, nil, nil, nil, nil, rand.New(rand.NewPCG(1, 2)))
+	return &coachTest{h: NewCoachHandler(s, engine, 12), s: s, sam: sam, kim: kim, relID: uint(rawID), program: program}
+}
+
+// performParamsRequest runs handler with the given path parameters set
+func performParamsRequest(t *testing.T, handler gin.HandlerFunc, method string, params gin.Params, body string) (int, map[string]any) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = params
+	c.Request = httptest.NewRequest(method, "/", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	handler(c)
+	return w.Code, decode(t, w)
+}
+
+// permissionParams names a coaching relationship and permission
+func permissionParams(id uint, permission string) gin.Params {
+	return gin.Params{{Key: "id", Value: fmt.Sprint(id)}, {Key: "permission", Value: permission}}
+}
+
+func TestCoachesStartWithEveryPermission(t *testing.T) {
+	ct := newCoachTest(t)
+
+	code, resp := performRequest(t, withUser(ct.sam, ct.h.Coaches), http.MethodGet, "")
+	if code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %v", code, resp)
+	}
+	coaches, ok := resp["coaches"].([]any)
+	if !ok || len(coaches) != 1 {
+		t.Fatalf("Expected one coach, got %v", resp)
+	}
+	coach := object(t, coaches[0])
+	perms, ok := coach["permissions"].([]any)
+	if coach["coach_name"] != ct.kim.Name || !ok || len(perms) != len(database.CoachPermissions) {
+		t.Errorf("Expected Kim with every permission, got %v", coach)
+	}
+
+	// Kim coaches Sam, not the other way round
+	if code, resp := performRequest(t, withUser(ct.kim, ct.h.Coaches), http.MethodGet, ""); code != http.StatusOK || len(resp["coaches"].([]any)) != 0 {
+		t.Errorf("Expected Kim to have no coaches, got %d: %v", code, resp)
+	}
+}
+
+func TestClientRosterAndDashboards(t *testing.T) {
+	ctx := context.Background()
+	ct := newCoachTest(t)
+	workouts := newTestWorkoutHandler(ct.s)
+
+	now := time.Now()
+	for i, weight := range []int{100, 120, 110} {
+		body := fmt.Sprintf(`{"completed_at":%q,"exercises":[{"name":"Squat","sets":[{"reps":5,"weight":%d}]}]}`,
+			now.AddDate(0, 0, -10+i*4).Format(time.RFC3339), weight)
+		if code, resp := performRequest(t, withUser(ct.sam, workouts.Create), http.MethodPost, body); code != http.StatusCreated {
+			t.Fatalf("Expected status 201, got %d: %v", code, resp)
+		}
+	}
+
+	code, resp := performRequest(t, withUser(ct.kim, ct.h.Clients), http.MethodGet, "")
+	if code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %v", code, resp)
+	}
+	clients, ok := resp["clients"].([]any)
+	if !ok || len(clients) != 1 {
+		t.Fatalf("Expected one client, got %v", resp)
+	}
+	adherence := object(t, object(t, clients[0])["adherence"])
+	if adherence["workouts_last_28_days"] != float64(3) || adherence["workouts_last_7_days"] != float64(2) {
+		t.Errorf("Expected 3 workouts in four weeks and 2 this week, got %v", adherence)
+	}
+
+	id := gin.Params{{Key: "id", Value: fmt.Sprint(ct.sam.ID)}}
+	code, resp = performParamsRequest(t, withUser(ct.kim, ct.h.ClientWorkouts), http.MethodGet, id, "")
+	if code != http.StatusOK || object(t, resp["pagination"])["total"] != float64(3) {
+		t.Errorf("Expected Sam's 3 workouts, got %d: %v", code, resp)
+	}
+	code, resp = performParamsRequest(t, withUser(ct.kim, ct.h.ClientRecords), http.MethodGet, id, "")
+	records, ok := resp["records"].([]any)
+	if code != http.StatusOK || !ok || len(records) != 1 || object(t, records[0])["weight"] != float64(120) {
+		t.Errorf("Expected a 120lb squat record, got %d: %v", code, resp)
+	}
+
+	// Sam's workouts are not visible to people who don't coach Sam
+	kimID := gin.Params{{Key: "id", Value: fmt.Sprint(ct.kim.ID)}}
+	if code, resp := performParamsRequest(t, withUser(ct.sam, ct.h.ClientWorkouts), http.MethodGet, kimID, ""); code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a client's own coach, got %d: %v", code, resp)
+	}
+
+	// Revoking a permission closes the matching dashboard only
+	code, resp = performParamsRequest(t, withUser(ct.sam, ct.h.RevokePermission), http.MethodDelete, permissionParams(ct.relID, database.PermissionViewWorkouts), "")
+	if code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %v", code, resp)
+	}
+	if code, resp := performParamsRequest(t, withUser(ct.kim, ct.h.ClientWorkouts), http.MethodGet, id, ""); code != http.StatusForbidden {
+		t.Errorf("Expected status 403 after revoking, got %d: %v", code, resp)
+	}
+	if code, resp := performParamsRequest(t, withUser(ct.kim, ct.h.ClientRecords), http.MethodGet, id, ""); code != http.StatusOK {
+		t.Errorf("Expected records to stay visible, got %d: %v", code, resp)
+	}
+	_, resp = performRequest(t, withUser(ct.kim, ct.h.Clients), http.MethodGet, "")
+	if client := object(t, resp["clients"].([]any)[0]); client["adherence"] != nil {
+		t.Errorf("Expected no adherence without view_workouts, got %v", client)
+	}
+	if code, resp := performParamsRequest(t, withUser(ct.sam, ct.h.RevokePermission), http.MethodDelete, permissionParams(ct.relID, database.PermissionViewWorkouts), ""); code != http.StatusNotFound {
+		t.Errorf("Expected status 404 revoking twice, got %d: %v", code, resp)
+	}
+	if code, resp := performParamsRequest(t, withUser(ct.sam, ct.h.RevokePermission), http.MethodDelete, permissionParams(ct.relID, "read_minds"), ""); code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown permission, got %d: %v", code, resp)
+	}
+	if code, resp := performParamsRequest(t, withUser(ct.kim, ct.h.RevokePermission), http.MethodDelete, permissionParams(ct.relID, database.PermissionViewRecords), ""); code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for the coach revoking, got %d: %v", code, resp)
+	}
+
+	code, resp = performParamsRequest(t, withUser(ct.sam, ct.h.GrantPermission), http.MethodPut, permissionParams(ct.relID, database.PermissionViewWorkouts), "")
+	if code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %v", code, resp)
+	}
+	if code, resp := performParamsRequest(t, withUser(ct.kim, ct.h.ClientWorkouts), http.MethodGet, id, ""); code != http.StatusOK {
+		t.Errorf("Expected workouts to be visible again, got %d: %v", code, resp)
+	}
+
+	// Pausing coaching closes every dashboard
+	rel, err := ct.s.Buddies().Get(ctx, ct.sam.ID, ct.relID)
+	if err != nil {
+		t.Fatalf("Failed to load relationship: %v", err)
+	}
+	rel.Status = database.BuddyPaused
+	if err := ct.s.Buddies().Update(ctx, rel); err != nil {
+		t.Fatalf("Failed to pause relationship: %v", err)
+	}
+	if code, resp := performParamsRequest(t, withUser(ct.kim, ct.h.ClientRecords), http.MethodGet, id, ""); code != http.StatusForbidden {
+		t.Errorf("Expected status 403 while paused, got %d: %v", code, resp)
+	}
+}
+
+func TestAssignCustomProgram(t *testing.T) {
+	ctx := context.Background()
+	ct := newCoachTest(t)
+	id := gin.Params{{Key: "id", Value: fmt.Sprint(ct.sam.ID)}}
+
+	enroll := fmt.Sprintf(`{"program_id":%d}`, ct.program.ID)
+	if code, resp := performParamsRequest(t, withUser(ct.kim, ct.h.AssignProgram), http.MethodPost, id, enroll); code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %v", code, resp)
+	}
+	if code, resp := performParamsRequest(t, withUser(ct.kim, ct.h.AssignProgram), http.MethodPost, id, enroll); code != http.StatusConflict {
+		t.Errorf("Expected status 409 assigning the current program, got %d: %v", code, resp)
+	}
+
+	custom := programdef.StrongLifts()
+	custom.Days[1].Slots[2].Scheme = "3x5"
+	def, err := json.Marshal(custom)
+	if err != nil {
+		t.Fatalf("Failed to encode definition: %v", err)
+	}
+	body := fmt.Sprintf(`{"program_id":%d,"definition":%s,"start_weights":{"Squat":135}}`, ct.program.ID, def)
+	code, resp := performParamsRequest(t, withUser(ct.kim, ct.h.AssignProgram), http.MethodPost, id, body)
+	if code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %v", code, resp)
+	}
+	program := object(t, resp["program"])
+	if program["name"] != "StrongLifts 5x5 for Sam" || program["created_by_id"] != float64(ct.kim.ID) {
+		t.Errorf("Expected Kim's customized program, got %v", program)
+	}
+
+	enrollment, err := ct.s.Enrollments().Current(ctx, ct.sam.ID)
+	if err != nil {
+		t.Fatalf("Failed to load enrollment: %v", err)
+	}
+	if enrollment.Program.Name != "StrongLifts 5x5 for Sam" || enrollment.Lifts[0].StartWeight != 135 {
+		t.Errorf("Expected Sam on the customized program squatting 135, got %+v", enrollment)
+	}
+
+	bad := fmt.Sprintf(`{"program_id":%d,"definition":{"version":1,"days":[]}}`, ct.program.ID)
+	if code, resp := performParamsRequest(t, withUser(ct.kim, ct.h.AssignProgram), http.MethodPost, id, bad); code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid definition, got %d: %v", code, resp)
+	}
+}
+
+func TestCoachMessages(t *testing.T) {
+	ctx := context.Background()
+	ct := newCoachTest(t)
+	id := gin.Params{{Key: "id", Value: fmt.Sprint(ct.sam.ID)}}
+
+	code, resp := performParamsRequest(t, withUser(ct.kim, ct.h.SendMessage), http.MethodPost, id, `{"content":"  Squats won't do themselves  "}`)
+	if code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %v", code, resp)
+	}
+	msgs, err := ct.s.Messages().List(ctx, ct.sam.ID, store.Page{})
+	if err != nil {
+		t.Fatalf("Failed to load messages: %v", err)
+	}
+	if len(msgs) != 1 || msgs[0].Content != "Squats won't do themselves" || msgs[0].MessageType != database.MessageCoach ||
+		msgs[0].SenderID == nil || *msgs[0].SenderID != ct.kim.ID {
+		t.Errorf("Expected Kim's message stored for Sam, got %+v", msgs)
+	}
+
+	if err := ct.s.Users().Update(ctx, ct.sam.ID, map[string]any{"weasel_mode_enabled": false}); err != nil {
+		t.Fatalf("Failed to disable Weasel Mode: %v", err)
+	}
+	if code, resp := performParamsRequest(t, withUser(ct.kim, ct.h.SendMessage), http.MethodPost, id, `{"content":"Hello?"}`); code != http.StatusConflict {
+		t.Errorf("Expected status 409 with Weasel Mode off, got %d: %v", code, resp)
+	}
+
+	code, resp = performParamsRequest(t, withUser(ct.sam, ct.h.RevokePermission), http.MethodDelete, permissionParams(ct.relID, database.PermissionSendMessages), "")
+	if code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %v", code, resp)
+	}
+	if code, resp := performParamsRequest(t, withUser(ct.kim, ct.h.SendMessage), http.MethodPost, id, `{"content":"Hello?"}`); code != http.StatusForbidden {
+		t.Errorf("Expected status 403 after revoking, got %d: %v", code, resp)
+	}
+}
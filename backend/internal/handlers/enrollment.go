@@ -0,0 +1,563 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/programdef"
+	"github.com/lucas-albers-lz4/ferrovis/internal/progression"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+)
+
+var (
+	// errNotEnrolled is returned when a user has no active or paused enrollment
+	errNotEnrolled = errors.New("not enrolled in a program")
+	// errInvalidDefinition is returned when a stored program definition cannot be used
+	errInvalidDefinition = errors.New("program has no valid definition")
+
+	// Errors for requests that conflict with the enrollment's current state
+	errAlreadyEnrolled   = errors.New("already enrolled in a program")
+	errSameProgram       = errors.New("already enrolled in this program")
+	errInvalidTransition = errors.New("invalid enrollment transition")
+)
+
+// EnrollmentHandler serves the /api/enrollments endpoints
+type EnrollmentHandler struct {
+	store store.Store
+	// defaultWeeks is the program length used when a program does not set one
+	defaultWeeks int
+}
+
+// NewEnrollmentHandler creates an EnrollmentHandler
+func NewEnrollmentHandler(s store.Store, defaultWeeks int) *EnrollmentHandler {
+	return &EnrollmentHandler{store: s, defaultWeeks: defaultWeeks}
+}
+
+// enrollRequest is the body for enrolling in or switching to a program.
+// Start weights are in pounds and keyed by exercise name; lifts without one
+// start from the program's default.
+type enrollRequest struct {
+	ProgramID    uint               `json:"program_id" binding:"required"`
+	StartWeights map[string]float64 `json:"start_weights" binding:"omitempty,max=50,dive,min=0,max=2000"`
+}
+
+// enrollmentResponse is the API representation of an enrollment
+type enrollmentResponse struct {
+	ID                uint                 `json:"id"`
+	ProgramID         uint                 `json:"program_id"`
+	ProgramName       string               `json:"program_name"`
+	Status            string               `json:"status"`
+	StartedAt         time.Time            `json:"started_at"`
+	PausedAt          *time.Time           `json:"paused_at,omitempty"`
+	EndedAt           *time.Time           `json:"ended_at,omitempty"`
+	DurationWeeks     int                  `json:"duration_weeks"`
+	CurrentWeek       int                  `json:"current_week"`
+	CurrentDay        int                  `json:"current_day"`
+	CurrentDayName    string               `json:"current_day_name,omitempty"`
+	SessionsCompleted int                  `json:"sessions_completed"`
+	Lifts             []enrollmentLiftView `json:"lifts"`
+}
+
+type enrollmentLiftView struct {
+	Exercise            string  `json:"exercise"`
+	StartWeight         float64 `json:"start_weight"`
+	WorkingWeight       float64 `json:"working_weight"`
+	ConsecutiveFailures int     `json:"consecutive_failures"`
+}
+
+// newEnrollmentResponse renders an enrollment; def may be nil when the
+// program definition is unavailable
+func newEnrollmentResponse(e *database.Enrollment, def *programdef.Definition) enrollmentResponse {
+	resp := enrollmentResponse{
+		ID:                e.ID,
+		ProgramID:         e.ProgramID,
+		ProgramName:       e.Program.Name,
+		Status:            e.Status,
+		StartedAt:         e.StartedAt,
+		PausedAt:          e.PausedAt,
+		EndedAt:           e.EndedAt,
+		DurationWeeks:     e.DurationWeeks,
+		CurrentWeek:       e.CurrentWeek,
+		CurrentDay:        e.CurrentDay,
+		SessionsCompleted: e.SessionsCompleted,
+		Lifts:             make([]enrollmentLiftView, 0, len(e.Lifts)),
+	}
+	if def != nil && e.CurrentDay >= 0 && e.CurrentDay < len(def.Days) {
+		resp.CurrentDayName = def.Days[e.CurrentDay].Name
+	}
+	for _, l := range e.Lifts {
+		resp.Lifts = append(resp.Lifts, enrollmentLiftView{
+			Exercise:            l.Exercise,
+			StartWeight:         l.StartWeight,
+			WorkingWeight:       l.WorkingWeight,
+			ConsecutiveFailures: l.ConsecutiveFailures,
+		})
+	}
+	return resp
+}
+
+// Enroll starts the current user on a program
+func (h *EnrollmentHandler) Enroll(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req enrollRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	var enrollment *database.Enrollment
+	err := h.store.Transaction(ctx, func(tx store.Store) error {
+		_, err := loadCurrentEnrollment(ctx, tx, user.ID)
+		switch {
+		case err == nil:
+			return errAlreadyEnrolled
+		case !errors.Is(err, errNotEnrolled):
+			return err
+		}
+
+		enrollment, err = h.createEnrollment(ctx, tx, user.ID, &req, nil)
+		return err
+	})
+	if !h.handleWriteError(c, err, "Failed to enroll") {
+		return
+	}
+
+	h.respondWithEnrollment(c, http.StatusCreated, enrollment)
+}
+
+// Current returns the user's active or paused enrollment with progress
+// refreshed from their logged workouts
+func (h *EnrollmentHandler) Current(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	var enrollment *database.Enrollment
+	err := h.store.Transaction(ctx, func(tx store.Store) error {
+		var err error
+		enrollment, err = loadCurrentEnrollment(ctx, tx, user.ID)
+		if err != nil {
+			return err
+		}
+		return syncEnrollment(ctx, tx, enrollment, time.Now())
+	})
+	if !h.handleWriteError(c, err, "Failed to fetch enrollment") {
+		return
+	}
+
+	h.respondWithEnrollment(c, http.StatusOK, enrollment)
+}
+
+// List returns every enrollment of the current user, most recent first
+func (h *EnrollmentHandler) List(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	enrollments, err := h.store.Enrollments().List(c.Request.Context(), user.ID)
+	if err != nil {
+		respondInternalError(c, "Failed to fetch enrollments", err)
+		return
+	}
+
+	out := make([]enrollmentResponse, 0, len(enrollments))
+	for i := range enrollments {
+		def, _ := programdef.Parse([]byte(enrollments[i].Program.Structure)) //nolint:errcheck // day name is omitted for unusable definitions
+		out = append(out, newEnrollmentResponse(&enrollments[i], def))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":      "ok",
+		"enrollments": out,
+	})
+}
+
+// Switch abandons the current enrollment and enrolls in another program.
+// Working weights carry over for lifts the programs share unless the
+// request sets a start weight for them.
+func (h *EnrollmentHandler) Switch(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req enrollRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	var enrollment *database.Enrollment
+	err := h.store.Transaction(ctx, func(tx store.Store) error {
+		current, err := loadCurrentEnrollment(ctx, tx, user.ID)
+		if err != nil {
+			return err
+		}
+		if current.ProgramID == req.ProgramID {
+			return errSameProgram
+		}
+
+		carried, err := abandonForSwitch(ctx, tx, current)
+		if err != nil {
+			return err
+		}
+		enrollment, err = h.createEnrollment(ctx, tx, user.ID, &req, carried)
+		return err
+	})
+	if !h.handleWriteError(c, err, "Failed to switch programs") {
+		return
+	}
+
+	h.respondWithEnrollment(c, http.StatusCreated, enrollment)
+}
+
+// Pause pauses the current enrollment
+func (h *EnrollmentHandler) Pause(c *gin.Context) {
+	h.transition(c, database.EnrollmentActive, func(ctx context.Context, tx store.Store, e *database.Enrollment, now time.Time) error {
+		e.Status = database.EnrollmentPaused
+		e.PausedAt = &now
+		return tx.Enrollments().Update(ctx, e)
+	})
+}
+
+// Resume resumes a paused enrollment
+func (h *EnrollmentHandler) Resume(c *gin.Context) {
+	h.transition(c, database.EnrollmentPaused, func(ctx context.Context, tx store.Store, e *database.Enrollment, _ time.Time) error {
+		e.Status = database.EnrollmentActive
+		e.PausedAt = nil
+		return tx.Enrollments().Update(ctx, e)
+	})
+}
+
+// Abandon ends the current enrollment without completing it
+func (h *EnrollmentHandler) Abandon(c *gin.Context) {
+	h.transition(c, "", func(ctx context.Context, tx store.Store, e *database.Enrollment, now time.Time) error {
+		return endEnrollment(ctx, tx, e, database.EnrollmentAbandoned, now)
+	})
+}
+
+// transition applies a state change to the current enrollment. from, when
+// set, is the status the enrollment must be in.
+func (h *EnrollmentHandler) transition(c *gin.Context, from string, apply func(ctx context.Context, tx store.Store, e *database.Enrollment, now time.Time) error) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	var enrollment *database.Enrollment
+	err := h.store.Transaction(ctx, func(tx store.Store) error {
+		var err error
+		enrollment, err = loadCurrentEnrollment(ctx, tx, user.ID)
+		if err != nil {
+			return err
+		}
+		if from != "" && enrollment.Status != from {
+			return fmt.Errorf("%w: enrollment is %s", errInvalidTransition, enrollment.Status)
+		}
+
+		// A broken program definition must not trap the user in the enrollment
+		now := time.Now()
+		if err := syncEnrollment(ctx, tx, enrollment, now); err != nil && !errors.Is(err, errInvalidDefinition) {
+			return err
+		}
+		if enrollment.Status == database.EnrollmentCompleted {
+			return nil // The final session was already logged; nothing left to change
+		}
+		if err := apply(ctx, tx, enrollment, now); err != nil {
+			return fmt.Errorf("failed to update enrollment: %w", err)
+		}
+		return nil
+	})
+	if !h.handleWriteError(c, err, "Failed to update enrollment") {
+		return
+	}
+
+	h.respondWithEnrollment(c, http.StatusOK, enrollment)
+}
+
+// startWeightError reports start weights for lifts the program does not contain
+type startWeightError struct {
+	fields map[string]string
+}
+
+func (e startWeightError) Error() string {
+	return fmt.Sprintf("invalid start weights: %v", e.fields)
+}
+
+// handleWriteError maps enrollment errors to responses and reports whether
+// the request may continue
+func (h *EnrollmentHandler) handleWriteError(c *gin.Context, err error, message string) bool {
+	var weights startWeightError
+	switch {
+	case err == nil:
+		return true
+	case errors.As(err, &weights):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Validation failed",
+			"errors":  weights.fields,
+		})
+	case errors.Is(err, errUnknownProgram):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Validation failed",
+			"errors":  map[string]string{"program_id": "unknown program"},
+		})
+	case errors.Is(err, errNotEnrolled):
+		respondError(c, http.StatusNotFound, "Not enrolled in a program")
+	case errors.Is(err, errAlreadyEnrolled):
+		respondError(c, http.StatusConflict, "Already enrolled in a program; switch programs instead")
+	case errors.Is(err, errSameProgram):
+		respondError(c, http.StatusConflict, "Already enrolled in this program")
+	case errors.Is(err, errInvalidTransition):
+		respondError(c, http.StatusConflict, "Enrollment cannot make that change in its current state")
+	case errors.Is(err, errInvalidDefinition):
+		respondError(c, http.StatusUnprocessableEntity, "Program has no valid progression rules")
+	case errors.Is(err, store.ErrDuplicate):
+		// The partial unique index caught a concurrent enrollment
+		respondError(c, http.StatusConflict, "Already enrolled in a program; switch programs instead")
+	default:
+		respondInternalError(c, message, err)
+	}
+	return false
+}
+
+// respondWithEnrollment writes an enrollment with its program definition
+func (h *EnrollmentHandler) respondWithEnrollment(c *gin.Context, status int, e *database.Enrollment) {
+	def, _ := programdef.Parse([]byte(e.Program.Structure)) //nolint:errcheck // day name is omitted for unusable definitions
+	c.JSON(status, gin.H{
+		"status":     "ok",
+		"enrollment": newEnrollmentResponse(e, def),
+	})
+}
+
+// createEnrollment enrolls userID in the requested program. carried holds
+// working weights in pounds from a previous enrollment keyed by lower-cased
+// exercise.
+func (h *EnrollmentHandler) createEnrollment(ctx context.Context, tx store.Store, userID uint, req *enrollRequest, carried map[string]float64) (*database.Enrollment, error) {
+	program, err := tx.Programs().Get(ctx, req.ProgramID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, errUnknownProgram
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load program: %w", err)
+	}
+
+	def, err := programdef.Parse([]byte(program.Structure))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errInvalidDefinition, err)
+	}
+	plan := def.Plan()
+
+	start := make(map[string]float64, len(carried)+len(req.StartWeights))
+	for name, w := range carried {
+		start[name] = database.ConvertWeight(w, database.UnitPounds, def.Unit())
+	}
+	fields := make(map[string]string)
+	for name, w := range req.StartWeights {
+		if _, ok := plan.Lifts[liftName(&plan, name)]; !ok {
+			fields["start_weights."+name] = "not a lift in this program"
+			continue
+		}
+		start[strings.ToLower(name)] = w
+	}
+	if len(fields) > 0 {
+		return nil, startWeightError{fields: fields}
+	}
+
+	weeks := program.Duration
+	if weeks <= 0 {
+		weeks = h.defaultWeeks
+	}
+
+	enrollment := database.Enrollment{
+		UserID:        userID,
+		ProgramID:     program.ID,
+		Program:       *program,
+		Status:        database.EnrollmentActive,
+		StartedAt:     time.Now(),
+		DurationWeeks: weeks,
+		CurrentWeek:   1,
+	}
+
+	// Replaying an empty history applies defaults and rounding to the start weights
+	states := progression.Replay(&plan, nil, start)
+	for _, name := range planLifts(&plan) {
+		st := states[strings.ToLower(name)]
+		enrollment.Lifts = append(enrollment.Lifts, database.EnrollmentLift{
+			Exercise:      name,
+			StartWeight:   st.WorkingWeight,
+			WorkingWeight: st.WorkingWeight,
+		})
+	}
+
+	if err := tx.Enrollments().Create(ctx, &enrollment); err != nil {
+		return nil, fmt.Errorf("failed to create enrollment: %w", err)
+	}
+	return &enrollment, nil
+}
+
+// syncEnrollment recomputes working weights and the current week and day
+// from the workouts logged against the program since the enrollment started.
+// A current enrollment whose final session has been logged is completed.
+func syncEnrollment(ctx context.Context, tx store.Store, e *database.Enrollment, now time.Time) error {
+	def, err := programdef.Parse([]byte(e.Program.Structure))
+	if err != nil {
+		return fmt.Errorf("%w: %w", errInvalidDefinition, err)
+	}
+	plan := def.Plan()
+
+	workouts, err := enrollmentWorkouts(ctx, tx, e)
+	if err != nil {
+		return err
+	}
+
+	states := progression.Replay(&plan, sessionsFromWorkouts(workouts, def.Unit()), enrollmentStartWeights(e))
+	for i := range e.Lifts {
+		lift := &e.Lifts[i]
+		st, ok := states[strings.ToLower(lift.Exercise)]
+		if !ok {
+			continue
+		}
+		if lift.WorkingWeight == st.WorkingWeight && lift.ConsecutiveFailures == st.ConsecutiveFailures {
+			continue
+		}
+		lift.WorkingWeight = st.WorkingWeight
+		lift.ConsecutiveFailures = st.ConsecutiveFailures
+		if err := tx.Enrollments().UpdateLift(ctx, lift); err != nil {
+			return fmt.Errorf("failed to update lift %s: %w", lift.Exercise, err)
+		}
+	}
+
+	schedule := progression.Schedule{Weeks: e.DurationWeeks, DaysPerWeek: def.SessionsPerWeek(), DaysInCycle: len(def.Days)}
+	pos := schedule.Locate(len(workouts))
+	e.CurrentWeek = pos.Week
+	e.CurrentDay = pos.Day
+	e.SessionsCompleted = pos.SessionsCompleted
+
+	if err := tx.Enrollments().Update(ctx, e); err != nil {
+		return fmt.Errorf("failed to update enrollment progress: %w", err)
+	}
+
+	if pos.Complete && isCurrentEnrollment(e) {
+		return endEnrollment(ctx, tx, e, database.EnrollmentCompleted, now)
+	}
+	return nil
+}
+
+// abandonForSwitch abandons the current enrollment to make way for another
+// program. It returns the working weights to carry over in pounds, keyed by
+// lower-cased exercise.
+func abandonForSwitch(ctx context.Context, tx store.Store, current *database.Enrollment) (map[string]float64, error) {
+	// Bring working weights up to date before carrying them over
+	now := time.Now()
+	if err := syncEnrollment(ctx, tx, current, now); err != nil && !errors.Is(err, errInvalidDefinition) {
+		return nil, err
+	}
+	if isCurrentEnrollment(current) {
+		if err := endEnrollment(ctx, tx, current, database.EnrollmentAbandoned, now); err != nil {
+			return nil, err
+		}
+	}
+
+	// Working weights are in the program's units
+	unit := database.UnitPounds
+	if def, err := programdef.Parse([]byte(current.Program.Structure)); err == nil {
+		unit = def.Unit()
+	}
+	carried := make(map[string]float64, len(current.Lifts))
+	for _, l := range current.Lifts {
+		carried[strings.ToLower(l.Exercise)] = database.ConvertWeight(l.WorkingWeight, unit, database.UnitPounds)
+	}
+	return carried, nil
+}
+
+// endEnrollment moves an enrollment to a final state
+func endEnrollment(ctx context.Context, tx store.Store, e *database.Enrollment, status string, now time.Time) error {
+	e.Status = status
+	e.EndedAt = &now
+	e.PausedAt = nil
+	if err := tx.Enrollments().Update(ctx, e); err != nil {
+		return fmt.Errorf("failed to end enrollment: %w", err)
+	}
+	return nil
+}
+
+// loadCurrentEnrollment returns the user's active or paused enrollment
+func loadCurrentEnrollment(ctx context.Context, tx store.Store, userID uint) (*database.Enrollment, error) {
+	e, err := tx.Enrollments().Current(ctx, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, errNotEnrolled
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load enrollment: %w", err)
+	}
+	return e, nil
+}
+
+// enrollmentWorkouts loads the workouts that count toward an enrollment
+func enrollmentWorkouts(ctx context.Context, tx store.Store, e *database.Enrollment) ([]database.Workout, error) {
+	workouts, err := tx.Workouts().History(ctx, store.HistoryQuery{
+		UserID:    e.UserID,
+		ProgramID: e.ProgramID,
+		Since:     e.StartedAt,
+		Until:     e.EndedAt,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load workout history: %w", err)
+	}
+	return workouts, nil
+}
+
+// enrollmentStartWeights returns the enrollment's start weights for the progression engine
+func enrollmentStartWeights(e *database.Enrollment) map[string]float64 {
+	start := make(map[string]float64, len(e.Lifts))
+	for _, l := range e.Lifts {
+		start[l.Exercise] = l.StartWeight
+	}
+	return start
+}
+
+// isCurrentEnrollment reports whether e is active or paused
+func isCurrentEnrollment(e *database.Enrollment) bool {
+	return e.Status == database.EnrollmentActive || e.Status == database.EnrollmentPaused
+}
+
+// planLifts returns the plan's progressed lifts in order of first appearance
+func planLifts(plan *progression.Plan) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, day := range plan.Days {
+		for _, slot := range day.Slots {
+			if k := strings.ToLower(slot.Exercise); !seen[k] {
+				seen[k] = true
+				out = append(out, slot.Exercise)
+			}
+		}
+	}
+	return out
+}
+
+// liftName returns the plan's spelling of a lift name, matched case-insensitively
+func liftName(plan *progression.Plan, name string) string {
+	for _, lift := range planLifts(plan) {
+		if strings.EqualFold(lift, name) {
+			return lift
+		}
+	}
+	return name
+}
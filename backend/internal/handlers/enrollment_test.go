@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/programdef"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+)
+
+func TestEnrollValidation(t *testing.T) {
+	h := NewEnrollmentHandler(store.NewMemory(), 12)
+	user := &database.User{ID: 1}
+
+	tests := []struct {
+		name          string
+		body          string
+		expectedField string
+	}{
+		{"missing program", `{}`, "program_id"},
+		{"negative start weight", `{"program_id": 1, "start_weights": {"Squat": -5}}`, "start_weights[Squat]"},
+		{"absurd start weight", `{"program_id": 1, "start_weights": {"Squat": 9000}}`, "start_weights[Squat]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, handler := range []func(*gin.Context){h.Enroll, h.Switch} {
+				code, resp := performRequest(t, withUser(user, handler), http.MethodPost, tt.body)
+				if code != http.StatusBadRequest {
+					t.Fatalf("Expected status 400, got %d: %v", code, resp)
+				}
+				errs, ok := resp["errors"].(map[string]any)
+				if !ok || errs[tt.expectedField] == nil {
+					t.Errorf("Expected %s field error, got %v", tt.expectedField, resp)
+				}
+			}
+		})
+	}
+}
+
+func TestNewEnrollmentResponse(t *testing.T) {
+	started := time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)
+	e := &database.Enrollment{
+		ID:                7,
+		ProgramID:         2,
+		Program:           database.Program{Name: programdef.StrongLiftsName},
+		Status:            database.EnrollmentActive,
+		StartedAt:         started,
+		DurationWeeks:     12,
+		CurrentWeek:       2,
+		CurrentDay:        1,
+		SessionsCompleted: 3,
+		Lifts:             []database.EnrollmentLift{{Exercise: "Squat", StartWeight: 95, WorkingWeight: 110, ConsecutiveFailures: 1}},
+	}
+
+	resp := newEnrollmentResponse(e, programdef.StrongLifts())
+	if resp.ProgramName != programdef.StrongLiftsName || resp.CurrentDayName != "B" || resp.CurrentWeek != 2 {
+		t.Errorf("Unexpected response %+v", resp)
+	}
+	if len(resp.Lifts) != 1 || resp.Lifts[0].WorkingWeight != 110 || resp.Lifts[0].ConsecutiveFailures != 1 {
+		t.Errorf("Unexpected lifts %+v", resp.Lifts)
+	}
+
+	if resp := newEnrollmentResponse(e, nil); resp.CurrentDayName != "" {
+		t.Errorf("Expected no day name without a definition, got %q", resp.CurrentDayName)
+	}
+}
+
+func TestPlanLifts(t *testing.T) {
+	plan := programdef.StartingStrength().Plan()
+
+	lifts := planLifts(&plan)
+	expected := []string{"Squat", "Bench Press", "Deadlift", "Overhead Press"}
+	if len(lifts) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, lifts)
+	}
+	for i := range expected {
+		if lifts[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected, lifts)
+		}
+	}
+
+	if got := liftName(&plan, "overhead press"); got != "Overhead Press" {
+		t.Errorf("Expected canonical name, got %q", got)
+	}
+	if got := liftName(&plan, "Curl"); got != "Curl" {
+		t.Errorf("Expected unknown lift unchanged, got %q", got)
+	}
+}
+
+func TestEnrollmentStartWeights(t *testing.T) {
+	e := &database.Enrollment{Lifts: []database.EnrollmentLift{{Exercise: "Squat", StartWeight: 135}, {Exercise: "Deadlift", StartWeight: 185}}}
+	start := enrollmentStartWeights(e)
+	if start["Squat"] != 135 || start["Deadlift"] != 185 {
+		t.Errorf("Unexpected start weights %v", start)
+	}
+}
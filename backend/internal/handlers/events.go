@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/gin-gonic/gin"
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/realtime"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+)
+
+// writeTimeout bounds how long a slow client may block one write
+const writeTimeout = 10 * time.Second
+
+// EventsHandler streams the current user's events over server-sent events
+// or, for clients without SSE support, a WebSocket
+type EventsHandler struct {
+	broker    realtime.Broker
+	heartbeat time.Duration
+}
+
+// NewEventsHandler creates an EventsHandler that pings idle streams every heartbeat
+func NewEventsHandler(broker realtime.Broker, heartbeat time.Duration) *EventsHandler {
+	return &EventsHandler{broker: broker, heartbeat: heartbeat}
+}
+
+// Stream sends the current user's events as server-sent events until the
+// client disconnects. Each event is named after its type and carries the
+// event as JSON.
+func (h *EventsHandler) Stream(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	sub := h.broker.Subscribe(user.ID)
+	defer sub.Close()
+
+	w := c.Writer
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Keep nginx from buffering the stream
+	w.WriteHeader(http.StatusOK)
+	// Tell the client how long to wait before reconnecting
+	fmt.Fprintf(w, "retry: %d\n\n", h.heartbeat.Milliseconds())
+	w.Flush()
+
+	ticker := time.NewTicker(h.heartbeat)
+	defer ticker.Stop()
+	ctx := c.Request.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case event, open := <-sub.Events:
+			if !open {
+				return
+			}
+			payload, err := json.Marshal(event)
+			if err != nil {
+				slog.Warn("Failed to encode event", "type", event.Type, "error", err)
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, payload)
+		}
+		w.Flush()
+	}
+}
+
+// WebSocket upgrades the request and sends the current user's events as
+// JSON text messages until the client disconnects. Messages from the
+// client are ignored.
+func (h *EventsHandler) WebSocket(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	conn, err := websocket.Accept(c.Writer, c.Request, nil)
+	if err != nil {
+		// Accept has already written the error response
+		slog.Debug("WebSocket upgrade failed", "error", err)
+		return
+	}
+	defer conn.CloseNow() //nolint:errcheck // the connection is being abandoned
+
+	sub := h.broker.Subscribe(user.ID)
+	defer sub.Close()
+
+	ctx := conn.CloseRead(c.Request.Context())
+	ticker := time.NewTicker(h.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := h.writeWebSocket(ctx, conn, nil); err != nil {
+				return
+			}
+		case event, open := <-sub.Events:
+			if !open {
+				conn.Close(websocket.StatusGoingAway, "stream closed") //nolint:errcheck // closing anyway
+				return
+			}
+			if err := h.writeWebSocket(ctx, conn, &event); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeWebSocket sends event, or pings when event is nil
+func (h *EventsHandler) writeWebSocket(ctx context.Context, conn *websocket.Conn, event *realtime.Event) error {
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+	if event == nil {
+		return conn.Ping(ctx) //nolint:wrapcheck // only ends the stream
+	}
+	return wsjson.Write(ctx, conn, event) //nolint:wrapcheck // only ends the stream
+}
+
+// publishWorkout tells the user's active peer buddies about a logged workout
+// and the user about the achievements it unlocked. Coaches are left out; they
+// see workouts only while granted view_workouts. The workout is already
+// saved, so failures are only logged.
+func publishWorkout(ctx context.Context, s store.Store, events realtime.Publisher, user *database.User,
+	workout *database.Workout, unlocked []database.Achievement,
+) {
+	if events == nil {
+		return
+	}
+
+	var published []realtime.Event
+	for i := range unlocked {
+		event, err := realtime.NewEvent(user.ID, realtime.EventAchievementUnlocked, &unlocked[i], workout.CompletedAt)
+		if err != nil {
+			slog.Warn("Failed to encode achievement event", "user_id", user.ID, "error", err)
+			continue
+		}
+		published = append(published, event)
+	}
+
+	rels, err := s.Buddies().List(ctx, user.ID)
+	if err != nil {
+		slog.Warn("Failed to load buddies for workout event", "user_id", user.ID, "error", err)
+	}
+	completed := gin.H{
+		"buddy_id":     user.ID,
+		"buddy_name":   user.Name,
+		"workout_id":   workout.ID,
+		"completed_at": workout.CompletedAt,
+	}
+	for _, buddy := range database.ActivePeers(rels, user.ID) {
+		event, err := realtime.NewEvent(buddy.ID, realtime.EventBuddyWorkout, completed, workout.CompletedAt)
+		if err != nil {
+			slog.Warn("Failed to encode workout event", "user_id", user.ID, "error", err)
+			break
+		}
+		published = append(published, event)
+	}
+
+	for _, event := range published {
+		if err := events.Publish(ctx, event); err != nil {
+			slog.Warn("Failed to publish event", "user_id", event.UserID, "type", event.Type, "error", err)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/gin-gonic/gin"
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/realtime"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+)
+
+// newEventsServer serves the events endpoints for user over a fresh hub
+func newEventsServer(t *testing.T, user *database.User) (*httptest.Server, *realtime.Hub) {
+	t.Helper()
+	hub := realtime.NewHub()
+	h := NewEventsHandler(hub, time.Minute)
+	r := gin.New()
+	r.GET("/events", withUser(user, h.Stream))
+	r.GET("/events/ws", withUser(user, h.WebSocket))
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+	return server, hub
+}
+
+// publishWhenSubscribed publishes a message event for the user once a
+// client has subscribed
+func publishWhenSubscribed(t *testing.T, hub *realtime.Hub, userID uint) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); hub.Subscribers(userID) == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the client to subscribe")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	event, err := realtime.NewEvent(userID, realtime.EventWeaselMessage, map[string]string{"content": "Move."}, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	if err := hub.Publish(context.Background(), event); err != nil {
+		t.Fatalf("Failed to publish event: %v", err)
+	}
+}
+
+func TestStreamSendsServerSentEvents(t *testing.T) {
+	user := &database.User{ID: 3}
+	server, hub := newEventsServer(t, user)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/events", nil)
+	if err != nil {
+		t.Fatalf("Failed to build request: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %q", ct)
+	}
+
+	publishWhenSubscribed(t, hub, user.ID)
+	// Skip the retry hint up to the event's name
+	lines := bufio.NewScanner(resp.Body)
+	found := false
+	for !found && lines.Scan() {
+		found = lines.Text() == "event: "+realtime.EventWeaselMessage
+	}
+	if !found || !lines.Scan() {
+		t.Fatalf("Expected event data, got %v", lines.Err())
+	}
+	var event realtime.Event
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(lines.Text(), "data: ")), &event); err != nil {
+		t.Fatalf("Invalid event data %q: %v", lines.Text(), err)
+	}
+	if event.Type != realtime.EventWeaselMessage || string(event.Data) != `{"content":"Move."}` {
+		t.Errorf("Expected the published message, got %+v", event)
+	}
+}
+
+func TestWebSocketSendsEvents(t *testing.T) {
+	user := &database.User{ID: 3}
+	server, hub := newEventsServer(t, user)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http")+"/events/ws", nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.CloseNow()
+
+	publishWhenSubscribed(t, hub, user.ID)
+	var event realtime.Event
+	if err := wsjson.Read(ctx, conn, &event); err != nil {
+		t.Fatalf("Failed to read event: %v", err)
+	}
+	if event.Type != realtime.EventWeaselMessage || string(event.Data) != `{"content":"Move."}` {
+		t.Errorf("Expected the published message, got %+v", event)
+	}
+
+	if err := conn.Close(websocket.StatusNormalClosure, ""); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+	for deadline := time.Now().Add(time.Second); hub.Subscribers(user.ID) > 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the subscription to end with the connection")
+		}
+	}
+}
+
+func TestCreateWorkoutPublishesToBuddies(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	if err := s.Exercises().Save(ctx, &database.Exercise{Name: "Squat"}); err != nil {
+		t.Fatalf("Failed to add exercise: %v", err)
+	}
+	user := newTestUser(t, s)
+	buddy := &database.User{Email: "kim@example.com", Name: "Kim"}
+	pending := &database.User{Email: "lee@example.com", Name: "Lee"}
+	coach := &database.User{Email: "alex@example.com", Name: "Alex"}
+	for _, u := range []*database.User{buddy, pending, coach} {
+		if err := s.Users().Create(ctx, u); err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+	}
+	rels := []*database.BuddyRelationship{
+		{UserID: buddy.ID, BuddyID: user.ID, RelationshipType: database.RelationshipPeer, Status: database.BuddyActive},
+		{UserID: user.ID, BuddyID: pending.ID, RelationshipType: database.RelationshipPeer, Status: database.BuddyPending},
+		{UserID: user.ID, BuddyID: coach.ID, RelationshipType: database.RelationshipCoach, Status: database.BuddyActive},
+	}
+	for _, rel := range rels {
+		if err := s.Buddies().Create(ctx, rel); err != nil {
+			t.Fatalf("Failed to create relationship: %v", err)
+		}
+	}
+	// The client has taken back the coach's view of their workouts
+	coaching := rels[2].ID
+	if err := s.CoachPermissions().Grant(ctx, &database.CoachPermission{RelationshipID: coaching, Permission: database.PermissionViewWorkouts}); err != nil {
+		t.Fatalf("Failed to grant permission: %v", err)
+	}
+	if err := s.CoachPermissions().Revoke(ctx, coaching, database.PermissionViewWorkouts); err != nil {
+		t.Fatalf("Failed to revoke permission: %v", err)
+	}
+
+	hub := realtime.NewHub()
+	buddySub, pendingSub, coachSub := hub.Subscribe(buddy.ID), hub.Subscribe(pending.ID), hub.Subscribe(coach.ID)
+	defer buddySub.Close()
+	defer pendingSub.Close()
+	defer coachSub.Close()
+	h := newTestWorkoutHandler(s)
+	h.events = hub
+
+	body := `{"exercises":[{"name":"Squat","sets":[{"reps":5,"weight":100}]}]}`
+	if code, resp := performRequest(t, withUser(user, h.Create), http.MethodPost, body); code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %v", code, resp)
+	}
+
+	select {
+	case event := <-buddySub.Events:
+		var data map[string]any
+		if err := json.Unmarshal(event.Data, &data); err != nil {
+			t.Fatalf("Invalid event data: %v", err)
+		}
+		if event.Type != realtime.EventBuddyWorkout || data["buddy_name"] != user.Name {
+			t.Errorf("Expected %s's workout, got %+v", user.Name, event)
+		}
+	default:
+		t.Error("Expected the active buddy to hear about the workout")
+	}
+	select {
+	case event := <-pendingSub.Events:
+		t.Errorf("Expected nothing for a pending buddy, got %+v", event)
+	default:
+	}
+	select {
+	case event := <-coachSub.Events:
+		t.Errorf("Expected nothing for a coach without view_workouts, got %+v", event)
+	default:
+	}
+}
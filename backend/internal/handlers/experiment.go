@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+	"github.com/lucas-albers-lz4/ferrovis/internal/weasel"
+)
+
+// ExperimentHandler serves the /api/admin/experiments endpoints
+type ExperimentHandler struct {
+	store             store.Store
+	experiments       []weasel.Experiment
+	attributionWindow time.Duration
+}
+
+// NewExperimentHandler creates an ExperimentHandler
+func NewExperimentHandler(s store.Store, experiments []weasel.Experiment, attributionWindow time.Duration) *ExperimentHandler {
+	return &ExperimentHandler{store: s, experiments: experiments, attributionWindow: attributionWindow}
+}
+
+// experimentSummary describes an experiment and its variants
+type experimentSummary struct {
+	Name        string           `json:"name"`
+	MessageType string           `json:"message_type"`
+	Active      bool             `json:"active"`
+	Variants    []variantSummary `json:"variants"`
+}
+
+// variantSummary describes one variant of an experiment
+type variantSummary struct {
+	Name      string `json:"name"`
+	Weight    int    `json:"weight"`
+	Control   bool   `json:"control"`
+	Templates int    `json:"templates"`
+}
+
+// variantResult is the workout conversion of one variant. The confidence
+// interval is the 95% Wilson score interval of the conversion rate.
+type variantResult struct {
+	Variant            string     `json:"variant"`
+	Weight             int        `json:"weight"`
+	Sent               int64      `json:"sent"`
+	Users              int64      `json:"users"`
+	Triggered          int64      `json:"triggered"`
+	ConversionRate     float64    `json:"conversion_rate"`
+	ConfidenceInterval [2]float64 `json:"confidence_interval"`
+}
+
+// List returns every configured experiment
+func (h *ExperimentHandler) List(c *gin.Context) {
+	out := make([]experimentSummary, 0, len(h.experiments))
+	for _, x := range h.experiments {
+		summary := experimentSummary{Name: x.Name, MessageType: x.MessageType, Active: x.Active}
+		for _, v := range x.Variants {
+			summary.Variants = append(summary.Variants, variantSummary{
+				Name:      v.Name,
+				Weight:    v.Weight,
+				Control:   len(v.Templates) == 0,
+				Templates: len(v.Templates),
+			})
+		}
+		out = append(out, summary)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":      "ok",
+		"experiments": out,
+	})
+}
+
+// Results reports the workout conversion of each variant of an experiment
+// over the last ?days= days. Variants that were removed from the
+// configuration but still have messages are reported with weight zero.
+func (h *ExperimentHandler) Results(c *gin.Context) {
+	x := h.experiment(c.Param("name"))
+	if x == nil {
+		respondError(c, http.StatusNotFound, "Experiment not found")
+		return
+	}
+
+	since, until, ok := reportPeriod(c, h.attributionWindow)
+	if !ok {
+		return
+	}
+
+	q := store.ConversionQuery{Since: since, Until: until, Experiment: x.Name}
+	stats, err := h.store.Messages().Variants(c.Request.Context(), q)
+	if err != nil {
+		respondInternalError(c, "Failed to build experiment results", err)
+		return
+	}
+
+	byVariant := make(map[string]store.VariantStats, len(stats))
+	for _, s := range stats {
+		byVariant[s.Variant] = s
+	}
+	results := make([]variantResult, 0, len(x.Variants))
+	for _, v := range x.Variants {
+		s := byVariant[v.Name]
+		s.Variant = v.Name
+		results = append(results, newVariantResult(s, v.Weight))
+		delete(byVariant, v.Name)
+	}
+	for _, s := range stats {
+		if _, retired := byVariant[s.Variant]; retired {
+			results = append(results, newVariantResult(s, 0))
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":     "ok",
+		"experiment": x.Name,
+		"since":      since,
+		"until":      until,
+		"variants":   results,
+	})
+}
+
+// experiment returns the named experiment, or nil
+func (h *ExperimentHandler) experiment(name string) *weasel.Experiment {
+	for i := range h.experiments {
+		if h.experiments[i].Name == name {
+			return &h.experiments[i]
+		}
+	}
+	return nil
+}
+
+// newVariantResult adds the conversion rate and its confidence interval
+func newVariantResult(s store.VariantStats, weight int) variantResult {
+	lo, hi := weasel.ConfidenceInterval(s.Triggered, s.Sent)
+	out := variantResult{
+		Variant:            s.Variant,
+		Weight:             weight,
+		Sent:               s.Sent,
+		Users:              s.Users,
+		Triggered:          s.Triggered,
+		ConfidenceInterval: [2]float64{lo, hi},
+	}
+	if s.Sent > 0 {
+		out.ConversionRate = float64(s.Triggered) / float64(s.Sent)
+	}
+	return out
+}
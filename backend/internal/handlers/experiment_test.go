@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+	"github.com/lucas-albers-lz4/ferrovis/internal/weasel"
+)
+
+func TestExperimentResults(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	user := newTestUser(t, s)
+	experiments, err := weasel.ParseExperiments([]byte(`
+experiments:
+  - name: funny-copy
+    message_type: funny
+    active: true
+    variants:
+      - {name: control, weight: 1}
+      - {name: puns, weight: 1, templates: [{intensity: gentle, text: "Weight for it."}]}
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse experiments: %v", err)
+	}
+	h := NewExperimentHandler(s, experiments, 24*time.Hour)
+
+	sentAt := time.Now().Add(-48 * time.Hour)
+	exposures := []struct {
+		variant   string
+		triggered bool
+	}{
+		{"control", true}, {"control", false}, {"control", false}, {"control", false},
+		{"puns", true}, {"puns", true}, {"retired", false},
+	}
+	for _, x := range exposures {
+		msg := &database.WeaselMessage{
+			UserID: user.ID, MessageType: database.MessageFunny, Content: "hi", SentAt: sentAt,
+			Experiment: "funny-copy", Variant: x.variant, TriggeredWorkout: x.triggered,
+		}
+		if err := s.Messages().Create(ctx, msg); err != nil {
+			t.Fatalf("Failed to store message: %v", err)
+		}
+	}
+
+	results := func(name string) (int, map[string]any) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "name", Value: name}}
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		h.Results(c)
+		return w.Code, decode(t, w)
+	}
+
+	if code, resp := results("nope"); code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown experiment, got %d: %v", code, resp)
+	}
+
+	code, resp := results("funny-copy")
+	if code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %v", code, resp)
+	}
+	variants, ok := resp["variants"].([]any)
+	if !ok || len(variants) != 3 {
+		t.Fatalf("Expected control, puns and the retired variant, got %v", resp["variants"])
+	}
+
+	control, puns, retired := object(t, variants[0]), object(t, variants[1]), object(t, variants[2])
+	if control["sent"] != 4.0 || control["conversion_rate"] != 0.25 || control["users"] != 1.0 {
+		t.Errorf("Unexpected control result %v", control)
+	}
+	ci, ok := puns["confidence_interval"].([]any)
+	if !ok || len(ci) != 2 || ci[0] == 0.0 || ci[1] != 1.0 {
+		t.Errorf("Expected a confidence interval reaching 1 for 2/2 conversions, got %v", puns)
+	}
+	if retired["variant"] != "retired" || retired["weight"] != 0.0 {
+		t.Errorf("Expected the retired variant with weight 0, got %v", retired)
+	}
+}
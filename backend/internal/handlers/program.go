@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/programdef"
+	"github.com/lucas-albers-lz4/ferrovis/internal/progression"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+)
+
+// ProgramHandler serves the /api/programs endpoints
+type ProgramHandler struct {
+	store store.Store
+}
+
+// NewProgramHandler creates a ProgramHandler
+func NewProgramHandler(s store.Store) *ProgramHandler {
+	return &ProgramHandler{store: s}
+}
+
+// List returns every available program
+func (h *ProgramHandler) List(c *gin.Context) {
+	programs, err := h.store.Programs().List(c.Request.Context())
+	if err != nil {
+		respondInternalError(c, "Failed to fetch programs", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   "ok",
+		"programs": programs,
+	})
+}
+
+// Get returns a single program
+func (h *ProgramHandler) Get(c *gin.Context) {
+	program, ok := h.loadProgram(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"program": program,
+	})
+}
+
+// programRequest is the body for creating a program
+type programRequest struct {
+	Name        string          `json:"name" binding:"required,max=100"`
+	Description string          `json:"description" binding:"max=1000"`
+	Difficulty  string          `json:"difficulty" binding:"required,oneof=beginner intermediate advanced"`
+	Duration    int             `json:"duration" binding:"required,min=1,max=52"`
+	Definition  json.RawMessage `json:"definition" binding:"required"`
+}
+
+func (r *programRequest) normalize() {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Description = strings.TrimSpace(r.Description)
+}
+
+// Create adds a program after validating its definition, including that
+// every exercise exists in the catalog
+func (h *ProgramHandler) Create(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req programRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	def, err := programdef.Parse(req.Definition)
+	if err != nil {
+		respondDefinitionError(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	catalog, err := exerciseCatalog(ctx, h.store)
+	if err != nil {
+		respondInternalError(c, "Failed to load exercises", err)
+		return
+	}
+	if err := def.ValidateExercises(catalog); err != nil {
+		respondDefinitionError(c, err)
+		return
+	}
+
+	structure, err := def.JSON()
+	if err != nil {
+		respondInternalError(c, "Failed to encode program", err)
+		return
+	}
+
+	program := database.Program{
+		Name:        req.Name,
+		Description: req.Description,
+		Difficulty:  req.Difficulty,
+		Duration:    req.Duration,
+		Structure:   structure,
+		CreatedByID: &user.ID,
+	}
+	if err := h.store.Programs().Create(ctx, &program); err != nil {
+		if errors.Is(err, store.ErrDuplicate) {
+			respondError(c, http.StatusConflict, "A program with this name already exists")
+			return
+		}
+		respondInternalError(c, "Failed to create program", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":  "ok",
+		"program": program,
+	})
+}
+
+// respondDefinitionError writes a 400 response for an invalid program
+// definition, keying each problem by its path under "definition"
+func respondDefinitionError(c *gin.Context, err error) {
+	fields := map[string]string{"definition": err.Error()}
+
+	var verr *programdef.ValidationError
+	if errors.As(err, &verr) {
+		fields = make(map[string]string, len(verr.Errors))
+		for path, msg := range verr.Fields() {
+			fields["definition."+path] = msg
+		}
+	}
+
+	c.JSON(http.StatusBadRequest, gin.H{
+		"status":  "error",
+		"message": "Validation failed",
+		"errors":  fields,
+	})
+}
+
+// NextWorkout computes the current user's next session in a program from the
+// program's progression rules and the workouts they have logged against it
+func (h *ProgramHandler) NextWorkout(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	program, ok := h.loadProgram(c)
+	if !ok {
+		return
+	}
+
+	def, err := programdef.Parse([]byte(program.Structure))
+	if err != nil {
+		respondError(c, http.StatusUnprocessableEntity, "Program has no valid progression rules")
+		return
+	}
+	plan := def.Plan()
+
+	ctx := c.Request.Context()
+
+	// An enrollment in this program supplies the lifter's start weights and
+	// limits history to the sessions logged since they enrolled
+	var (
+		workouts []database.Workout
+		start    map[string]float64
+	)
+	enrollment, err := loadCurrentEnrollment(ctx, h.store, user.ID)
+	switch {
+	case err == nil && enrollment.ProgramID == program.ID:
+		start = enrollmentStartWeights(enrollment)
+		workouts, err = enrollmentWorkouts(ctx, h.store, enrollment)
+	case err == nil || errors.Is(err, errNotEnrolled):
+		workouts, err = h.store.Workouts().History(ctx, store.HistoryQuery{UserID: user.ID, ProgramID: program.ID})
+	}
+	if err != nil {
+		respondInternalError(c, "Failed to load workout history", err)
+		return
+	}
+
+	next, err := progression.Next(&plan, sessionsFromWorkouts(workouts, def.Unit()), start)
+	if err != nil {
+		respondInternalError(c, "Failed to compute next workout", err)
+		return
+	}
+
+	instructions, err := exerciseInstructions(ctx, h.store)
+	if err != nil {
+		respondInternalError(c, "Failed to load exercises", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":       "ok",
+		"next_workout": newNextWorkoutResponse(program, next, def.Unit(), instructions),
+	})
+}
+
+// loadProgram loads the program named by the :id path parameter
+func (h *ProgramHandler) loadProgram(c *gin.Context) (*database.Program, bool) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return nil, false
+	}
+
+	program, err := h.store.Programs().Get(c.Request.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		respondError(c, http.StatusNotFound, "Program not found")
+		return nil, false
+	}
+	if err != nil {
+		respondInternalError(c, "Failed to fetch program", err)
+		return nil, false
+	}
+	return program, true
+}
+
+// nextExercise is one prescribed exercise in the next-workout response
+type nextExercise struct {
+	Name                string  `json:"name"`
+	Sets                int     `json:"sets"`
+	Reps                int     `json:"reps"`
+	Weight              float64 `json:"weight"`
+	Unit                string  `json:"unit"`
+	Deload              bool    `json:"deload"`
+	ConsecutiveFailures int     `json:"consecutive_failures"`
+	Instructions        string  `json:"instructions"`
+}
+
+// newNextWorkoutResponse renders a prescription with weights in unit, in the
+// shape the mobile app expects
+func newNextWorkoutResponse(program *database.Program, next *progression.Prescription, unit string, instructions map[string]string) gin.H {
+	exercises := make([]nextExercise, 0, len(next.Exercises))
+	for _, t := range next.Exercises {
+		exercises = append(exercises, nextExercise{
+			Name:                t.Exercise,
+			Sets:                t.Sets,
+			Reps:                t.Reps,
+			Weight:              t.Weight,
+			Unit:                unit,
+			Deload:              t.Deload,
+			ConsecutiveFailures: t.ConsecutiveFailures,
+			Instructions:        instructions[strings.ToLower(t.Exercise)],
+		})
+	}
+
+	return gin.H{
+		"program_name":       program.Name,
+		"workout_day":        next.Day,
+		"session_number":     next.SessionNumber,
+		"exercises":          exercises,
+		"estimated_duration": "45-60 minutes",
+		"rest_between_sets":  "3-5 minutes for compound movements",
+	}
+}
+
+// sessionsFromWorkouts converts logged workouts into progression engine
+// input, with weights in unit
+func sessionsFromWorkouts(workouts []database.Workout, unit string) []progression.Session {
+	sessions := make([]progression.Session, 0, len(workouts))
+	for i := range workouts {
+		w := &workouts[i]
+		session := progression.Session{CompletedAt: w.CompletedAt}
+
+		byOrder := make(map[int]int)
+		for _, s := range w.Sets {
+			idx, ok := byOrder[s.ExerciseOrder]
+			if !ok {
+				idx = len(session.Exercises)
+				byOrder[s.ExerciseOrder] = idx
+				session.Exercises = append(session.Exercises, progression.PerformedExercise{Name: s.Exercise.Name})
+			}
+			session.Exercises[idx].Sets = append(session.Exercises[idx].Sets, progression.PerformedSet{
+				Reps:      s.Reps,
+				Weight:    database.ConvertWeight(s.Weight, s.Unit, unit),
+				Completed: s.Completed,
+			})
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions
+}
+
+// exerciseInstructions maps lower-cased exercise names to their instructions
+func exerciseInstructions(ctx context.Context, s store.Store) (map[string]string, error) {
+	exercises, err := s.Exercises().List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load exercises: %w", err)
+	}
+
+	out := make(map[string]string, len(exercises))
+	for _, e := range exercises {
+		out[strings.ToLower(e.Name)] = e.Instructions
+	}
+	return out, nil
+}
+
+// exerciseCatalog returns the lower-cased names of every known exercise
+func exerciseCatalog(ctx context.Context, s store.Store) (map[string]bool, error) {
+	exercises, err := s.Exercises().List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load exercises: %w", err)
+	}
+
+	out := make(map[string]bool, len(exercises))
+	for _, e := range exercises {
+		out[strings.ToLower(e.Name)] = true
+	}
+	return out, nil
+}
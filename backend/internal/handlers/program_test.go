@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/progression"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+)
+
+func TestSessionsFromWorkouts(t *testing.T) {
+	completed := time.Date(2025, 5, 1, 18, 0, 0, 0, time.UTC)
+	workouts := []database.Workout{{
+		CompletedAt: completed,
+		Sets: []database.WorkoutSet{
+			{ExerciseOrder: 0, Exercise: database.Exercise{Name: "Squat"}, Reps: 5, Weight: 100, Unit: database.UnitKilograms, Completed: true},
+			{ExerciseOrder: 0, Exercise: database.Exercise{Name: "Squat"}, Reps: 5, Weight: 100, Unit: database.UnitKilograms, Completed: false},
+			{ExerciseOrder: 1, Exercise: database.Exercise{Name: "Bench Press"}, Reps: 5, Weight: 95, Unit: database.UnitPounds, Completed: true},
+		},
+	}}
+
+	sessions := sessionsFromWorkouts(workouts, database.UnitPounds)
+	if len(sessions) != 1 || !sessions[0].CompletedAt.Equal(completed) {
+		t.Fatalf("Expected one session at %v, got %+v", completed, sessions)
+	}
+
+	exercises := sessions[0].Exercises
+	if len(exercises) != 2 || exercises[0].Name != "Squat" || len(exercises[0].Sets) != 2 {
+		t.Fatalf("Expected squat with 2 sets then bench, got %+v", exercises)
+	}
+	if got := exercises[0].Sets[0].Weight; math.Abs(got-220.462) > 0.001 {
+		t.Errorf("Expected kilograms converted to pounds, got %v", got)
+	}
+	if exercises[0].Sets[1].Completed {
+		t.Error("Expected incomplete set to stay incomplete")
+	}
+}
+
+func TestNewNextWorkoutResponse(t *testing.T) {
+	program := &database.Program{Name: "StrongLifts 5x5"}
+	next := &progression.Prescription{
+		Day:           "B",
+		SessionNumber: 4,
+		Exercises:     []progression.Target{{Exercise: "Deadlift", Sets: 1, Reps: 5, Weight: 135}},
+	}
+
+	resp := newNextWorkoutResponse(program, next, database.UnitPounds, map[string]string{"deadlift": "Pull from the floor"})
+	if resp["workout_day"] != "B" || resp["session_number"] != 4 {
+		t.Errorf("Unexpected day/session in %v", resp)
+	}
+
+	exercises, ok := resp["exercises"].([]nextExercise)
+	if !ok || len(exercises) != 1 {
+		t.Fatalf("Expected one exercise, got %v", resp["exercises"])
+	}
+	if exercises[0].Weight != 135 || exercises[0].Instructions != "Pull from the floor" || exercises[0].Unit != "lb" {
+		t.Errorf("Unexpected exercise %+v", exercises[0])
+	}
+}
+
+func TestNextWorkoutInProgramUnits(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	if err := s.Exercises().Save(ctx, &database.Exercise{Name: "Squat"}); err != nil {
+		t.Fatalf("Failed to add exercise: %v", err)
+	}
+	program := &database.Program{Name: "Metric Squats", Structure: `{"version": 1, "units": "kg", "round_to": 2.5,
+		"days": [{"name": "A", "slots": [{"exercise": "Squat", "scheme": "1x5"}]}],
+		"progression": {"default": {"increment": 2.5, "start_weight": 20}}}`}
+	if err := s.Programs().Create(ctx, program); err != nil {
+		t.Fatalf("Failed to create program: %v", err)
+	}
+	user := newTestUser(t, s)
+
+	body := fmt.Sprintf(`{"program_id":%d,"exercises":[{"name":"Squat","sets":[{"reps":5,"weight":60,"unit":"kg"}]}]}`, program.ID)
+	if code, resp := performRequest(t, withUser(user, newTestWorkoutHandler(s).Create), http.MethodPost, body); code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %v", code, resp)
+	}
+
+	code, resp := performIDRequest(t, withUser(user, NewProgramHandler(s).NextWorkout), http.MethodGet, program.ID, "")
+	if code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %v", code, resp)
+	}
+	exercises, ok := object(t, resp["next_workout"])["exercises"].([]any)
+	if !ok || len(exercises) != 1 {
+		t.Fatalf("Expected one exercise, got %v", resp["next_workout"])
+	}
+	// 60 kg plus the 2.5 kg increment, not 60 kg in pounds plus 2.5 lb
+	if squat := object(t, exercises[0]); squat["weight"] != 62.5 || squat["unit"] != database.UnitKilograms {
+		t.Errorf("Expected 62.5 kg, got %v", squat)
+	}
+}
+
+func TestCreateProgramValidation(t *testing.T) {
+	h := NewProgramHandler(store.NewMemory())
+	user := &database.User{ID: 1}
+
+	tests := []struct {
+		name          string
+		body          string
+		expectedField string
+	}{
+		{"missing definition", `{"name": "Mine", "difficulty": "beginner", "duration": 8}`, "definition"},
+		{"bad difficulty", `{"name": "Mine", "difficulty": "expert", "duration": 8, "definition": {}}`, "difficulty"},
+		{"unknown field", `{"name": "Mine", "difficulty": "beginner", "duration": 8, "definition": {"version": 1, "weeks": 4}}`, "definition"},
+		{
+			"nested path",
+			`{"name": "Mine", "difficulty": "beginner", "duration": 8, "definition": {"version": 1, "days": [
+				{"name": "A", "slots": [{"exercise": "Squat", "scheme": "5x5"}]},
+				{"name": "B", "slots": [{"exercise": "Squat", "scheme": "5x5"}, {"exercise": "Deadlift", "sets": 1, "reps": 0}]}
+			], "progression": {"default": {}}}}`,
+			"definition.days[1].slots[1].reps",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, resp := performRequest(t, withUser(user, h.Create), http.MethodPost, tt.body)
+			if code != http.StatusBadRequest {
+				t.Fatalf("Expected status 400, got %d: %v", code, resp)
+			}
+			errs, ok := resp["errors"].(map[string]any)
+			if !ok || errs[tt.expectedField] == nil {
+				t.Errorf("Expected %s field error, got %v", tt.expectedField, resp)
+			}
+		})
+	}
+}
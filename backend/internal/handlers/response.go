@@ -0,0 +1,73 @@
+// Package handlers implements the HTTP handlers for the Ferrovis API.
+// Handlers are grouped by domain into small structs so their dependencies
+// are explicit and can be wired up in cmd/server.
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/middleware"
+)
+
+// Page size bounds for list endpoints
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// respondError writes the standard error envelope used across the API
+func respondError(c *gin.Context, status int, message string) {
+	c.JSON(status, gin.H{
+		"status":  "error",
+		"message": message,
+	})
+}
+
+// respondInternalError writes a 500 response including the underlying error
+func respondInternalError(c *gin.Context, message string, err error) {
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"status":  "error",
+		"message": message,
+		"error":   err.Error(),
+	})
+}
+
+// parseIDParam reads a positive numeric path parameter, writing a 400 on failure
+func parseIDParam(c *gin.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 64)
+	if err != nil || id == 0 {
+		respondError(c, http.StatusBadRequest, "Invalid "+name)
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// pagination reads limit/offset query parameters with sane bounds
+func pagination(c *gin.Context) (limit, offset int) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultPageSize)))
+	if err != nil || limit <= 0 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	offset, err = strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
+
+// currentUser returns the authenticated user, writing a 401 when absent
+func currentUser(c *gin.Context) (*database.User, bool) {
+	user, ok := middleware.CurrentUser(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "Authentication required")
+		return nil, false
+	}
+	return user, true
+}
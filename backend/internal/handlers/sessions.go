@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lucas-albers-lz4/ferrovis/internal/auth"
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+)
+
+const (
+	msgInvalidRefreshToken = "Invalid or expired refresh token"
+	// maxUserAgentLength bounds the device description stored with a session
+	maxUserAgentLength = 255
+)
+
+// errRefreshRejected is returned when a refresh token cannot be rotated
+var errRefreshRejected = errors.New("refresh token rejected")
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// Refresh exchanges a refresh token for a new access token and a new refresh
+// token. Each refresh token is single-use: presenting one that was already
+// rotated is treated as theft and revokes every token in its family.
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req refreshRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	var (
+		user    *database.User
+		refresh string
+		reused  *database.RefreshToken
+	)
+
+	err := h.store.Transaction(ctx, func(tx store.Store) error {
+		stored, err := tx.Sessions().GetByHash(ctx, auth.HashRefreshToken(req.RefreshToken))
+		if errors.Is(err, store.ErrNotFound) {
+			return errRefreshRejected
+		}
+		if err != nil {
+			return fmt.Errorf("failed to look up refresh token: %w", err)
+		}
+
+		if stored.RevokedAt != nil || time.Now().After(stored.ExpiresAt) {
+			return errRefreshRejected
+		}
+		if stored.UsedAt != nil {
+			reused = stored
+			return errRefreshRejected
+		}
+
+		// Claim the token atomically so two concurrent refreshes cannot both succeed
+		claimed, err := tx.Sessions().Claim(ctx, stored.ID, time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to rotate refresh token: %w", err)
+		}
+		if !claimed {
+			reused = stored
+			return errRefreshRejected
+		}
+
+		user, err = tx.Users().Get(ctx, stored.UserID)
+		if errors.Is(err, store.ErrNotFound) {
+			return errRefreshRejected
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load user: %w", err)
+		}
+
+		next, token, err := h.createRefreshToken(ctx, tx, user.ID, stored.FamilyID, c.Request.UserAgent())
+		if err != nil {
+			return err
+		}
+		refresh = token
+
+		if err := tx.Sessions().SetReplacedBy(ctx, stored.ID, next.ID); err != nil {
+			return fmt.Errorf("failed to link refresh tokens: %w", err)
+		}
+		return nil
+	})
+
+	if reused != nil {
+		slog.Warn("Refresh token reuse detected, revoking family",
+			"user_id", reused.UserID, "family_id", reused.FamilyID)
+		if err := h.revokeFamily(ctx, reused.FamilyID); err != nil {
+			slog.Error("Failed to revoke refresh token family", "family_id", reused.FamilyID, "error", err)
+		}
+	}
+
+	switch {
+	case errors.Is(err, errRefreshRejected):
+		respondError(c, http.StatusUnauthorized, msgInvalidRefreshToken)
+		return
+	case err != nil:
+		respondInternalError(c, "Failed to refresh session", err)
+		return
+	}
+
+	h.respondWithTokens(c, http.StatusOK, user, refresh)
+}
+
+// Logout revokes the session that owns the given refresh token. It always
+// succeeds so clients can clear local state without handling errors.
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var req refreshRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	stored, err := h.store.Sessions().GetByHash(ctx, auth.HashRefreshToken(req.RefreshToken))
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		// Unknown token: nothing to revoke
+	case err != nil:
+		respondInternalError(c, "Failed to log out", err)
+		return
+	default:
+		if err := h.revokeFamily(ctx, stored.FamilyID); err != nil {
+			respondInternalError(c, "Failed to log out", err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"message": "Logged out",
+	})
+}
+
+// LogoutAll revokes every refresh token the current user holds and bumps
+// their token version so outstanding access tokens stop working immediately
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	err := h.store.Transaction(ctx, func(tx store.Store) error {
+		if err := tx.Sessions().RevokeAll(ctx, user.ID, time.Now()); err != nil {
+			return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+		}
+		if err := tx.Users().IncrementTokenVersion(ctx, user.ID); err != nil {
+			return fmt.Errorf("failed to bump token version: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		respondInternalError(c, "Failed to log out all devices", err)
+		return
+	}
+
+	slog.Info("User logged out of all devices", "user_id", user.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"message": "Logged out of all devices",
+	})
+}
+
+// respondWithNewSession starts a new refresh token family for a fresh login
+func (h *AuthHandler) respondWithNewSession(c *gin.Context, status int, user *database.User) {
+	family, err := auth.NewFamilyID()
+	if err != nil {
+		respondInternalError(c, "Failed to start session", err)
+		return
+	}
+
+	_, refresh, err := h.createRefreshToken(c.Request.Context(), h.store, user.ID, family, c.Request.UserAgent())
+	if err != nil {
+		respondInternalError(c, "Failed to start session", err)
+		return
+	}
+
+	h.respondWithTokens(c, status, user, refresh)
+}
+
+// respondWithTokens issues an access token and writes the auth response
+func (h *AuthHandler) respondWithTokens(c *gin.Context, status int, user *database.User, refresh string) {
+	token, err := h.tokens.IssueAccessToken(user.ID, user.Email, user.TokenVersion)
+	if err != nil {
+		respondInternalError(c, "Failed to issue access token", err)
+		return
+	}
+
+	c.JSON(status, gin.H{
+		"status":             "ok",
+		"user":               user,
+		"token":              token,
+		"token_type":         "Bearer",
+		"expires_in":         int(h.tokens.TTL().Seconds()),
+		"refresh_token":      refresh,
+		"refresh_expires_in": int(h.tokens.RefreshTTL().Seconds()),
+	})
+}
+
+// createRefreshToken persists a new refresh token in the given family and
+// returns the stored row along with the opaque token to hand to the client
+func (h *AuthHandler) createRefreshToken(ctx context.Context, tx store.Store, userID uint, family, userAgent string) (*database.RefreshToken, string, error) {
+	token, hash, err := auth.NewRefreshToken()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create refresh token: %w", err)
+	}
+
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+
+	row := database.RefreshToken{
+		UserID:    userID,
+		FamilyID:  family,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(h.tokens.RefreshTTL()),
+		UserAgent: userAgent,
+	}
+	if err := tx.Sessions().Create(ctx, &row); err != nil {
+		return nil, "", fmt.Errorf("failed to store refresh token: %w", err)
+	}
+	return &row, token, nil
+}
+
+// revokeFamily revokes every live token descended from the same login
+func (h *AuthHandler) revokeFamily(ctx context.Context, family string) error {
+	if err := h.store.Sessions().RevokeFamily(ctx, family, time.Now()); err != nil {
+		return fmt.Errorf("failed to revoke token family: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,504 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lucas-albers-lz4/ferrovis/internal/achievements"
+	"github.com/lucas-albers-lz4/ferrovis/internal/auth"
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/mail"
+	"github.com/lucas-albers-lz4/ferrovis/internal/programdef"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+	"github.com/lucas-albers-lz4/ferrovis/internal/streaks"
+	"github.com/lucas-albers-lz4/ferrovis/internal/weasel"
+)
+
+// newTestTokens returns a token manager with a fixed signing key
+func newTestTokens() *auth.TokenManager {
+	return auth.NewTokenManager(&auth.Config{
+		Keys:            map[string]string{"test": "test-secret"},
+		ActiveKeyID:     "test",
+		AccessTokenTTL:  time.Hour,
+		RefreshTokenTTL: 24 * time.Hour,
+	})
+}
+
+// newTestUser creates a user in s
+func newTestUser(t *testing.T, s store.Store) *database.User {
+	t.Helper()
+	user := &database.User{Email: "sam@example.com", Name: "Sam"}
+	if err := s.Users().Create(context.Background(), user); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	return user
+}
+
+// newTestWorkoutHandler returns a WorkoutHandler with default streak policies
+func newTestWorkoutHandler(s store.Store) *WorkoutHandler {
+	return NewWorkoutHandler(s, streaks.NewService(s, streaks.DefaultConfig()), achievements.NewEngine(s), nil)
+}
+
+// object asserts that v is a JSON object
+func object(t *testing.T, v any) map[string]any {
+	t.Helper()
+	m, ok := v.(map[string]any)
+	if !ok {
+		t.Fatalf("Expected JSON object, got %v", v)
+	}
+	return m
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	mailer := mail.NewMemoryMailer()
+	h := NewAuthHandler(store.NewMemory(), newTestTokens(), mailer, "")
+	body := `{"email":"Sam@Example.com","password":"correct horse","name":"Sam"}`
+
+	code, resp := performRequest(t, h.Register, http.MethodPost, body)
+	if code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %v", code, resp)
+	}
+	if resp["token"] == "" || resp["refresh_token"] == "" {
+		t.Errorf("Expected tokens in response, got %v", resp)
+	}
+
+	if sent := mailer.Sent(); len(sent) != 1 || sent[0].To != "sam@example.com" {
+		t.Errorf("Expected one verification email to sam@example.com, got %v", sent)
+	}
+
+	code, resp = performRequest(t, h.Register, http.MethodPost, body)
+	if code != http.StatusConflict {
+		t.Errorf("Expected status 409 for duplicate email, got %d: %v", code, resp)
+	}
+
+	tests := []struct {
+		name         string
+		body         string
+		expectedCode int
+	}{
+		{"correct password", `{"email":"sam@example.com","password":"correct horse"}`, http.StatusOK},
+		{"wrong password", `{"email":"sam@example.com","password":"wrong horse"}`, http.StatusUnauthorized},
+		{"unknown email", `{"email":"kim@example.com","password":"correct horse"}`, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, resp := performRequest(t, h.Login, http.MethodPost, tt.body)
+			if code != tt.expectedCode {
+				t.Errorf("Expected status %d, got %d: %v", tt.expectedCode, code, resp)
+			}
+		})
+	}
+}
+
+func TestCreateAndListWorkouts(t *testing.T) {
+	s := store.NewMemory()
+	if err := s.Exercises().Save(context.Background(), &database.Exercise{Name: "Squat"}); err != nil {
+		t.Fatalf("Failed to add exercise: %v", err)
+	}
+	user := newTestUser(t, s)
+	h := newTestWorkoutHandler(s)
+
+	body := `{"exercises":[{"name":"squat","sets":[{"reps":5,"weight":100,"unit":"kg"},{"reps":3,"weight":100,"unit":"kg","completed":false}]}]}`
+	code, resp := performRequest(t, withUser(user, h.Create), http.MethodPost, body)
+	if code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %v", code, resp)
+	}
+
+	workout := object(t, resp["workout"])
+	exercises, ok := workout["exercises"].([]any)
+	if !ok || len(exercises) != 1 {
+		t.Fatalf("Expected one exercise, got %v", workout["exercises"])
+	}
+	sets, ok := object(t, exercises[0])["sets"].([]any)
+	if !ok || len(sets) != 2 {
+		t.Fatalf("Expected two sets, got %v", exercises[0])
+	}
+	for i, expected := range []bool{true, false} {
+		if got := object(t, sets[i])["completed"]; got != expected {
+			t.Errorf("Expected set %d completed=%v, got %v", i, expected, got)
+		}
+	}
+
+	code, resp = performRequest(t, withUser(user, h.List), http.MethodGet, "")
+	if code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %v", code, resp)
+	}
+	if total := object(t, resp["pagination"])["total"]; total != float64(1) {
+		t.Errorf("Expected total 1, got %v", total)
+	}
+
+	other := &database.User{ID: user.ID + 1}
+	_, resp = performRequest(t, withUser(other, h.List), http.MethodGet, "")
+	if total := object(t, resp["pagination"])["total"]; total != float64(0) {
+		t.Errorf("Expected other users to see no workouts, got %v", total)
+	}
+}
+
+func TestCreateWorkoutUnlocksAchievements(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	if err := s.Exercises().Save(ctx, &database.Exercise{Name: "Deadlift"}); err != nil {
+		t.Fatalf("Failed to add exercise: %v", err)
+	}
+	rule := `{"metric": "max_weight", "exercise": "Deadlift", "unit": "lb"}`
+	if err := s.Achievements().Save(ctx, &database.Achievement{Name: "Century Club", Target: 100, Rule: &rule}); err != nil {
+		t.Fatalf("Failed to add achievement: %v", err)
+	}
+	user := newTestUser(t, s)
+	h := newTestWorkoutHandler(s)
+
+	body := `{"exercises":[{"name":"Deadlift","sets":[{"reps":5,"weight":45,"unit":"kg"}]}]}`
+	_, resp := performRequest(t, withUser(user, h.Create), http.MethodPost, body)
+	if _, ok := resp["unlocked_achievements"]; ok {
+		t.Errorf("Expected 45kg (99lb) to fall short, got %v", resp["unlocked_achievements"])
+	}
+
+	body = `{"exercises":[{"name":"Deadlift","sets":[{"reps":5,"weight":100}]}]}`
+	code, resp := performRequest(t, withUser(user, h.Create), http.MethodPost, body)
+	if code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %v", code, resp)
+	}
+	unlocked, ok := resp["unlocked_achievements"].([]any)
+	if !ok || len(unlocked) != 1 || object(t, unlocked[0])["name"] != "Century Club" {
+		t.Errorf("Expected Century Club to unlock, got %v", resp["unlocked_achievements"])
+	}
+}
+
+func TestWorkoutsUpdateStreaks(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	if err := s.Exercises().Save(ctx, &database.Exercise{Name: "Squat"}); err != nil {
+		t.Fatalf("Failed to add exercise: %v", err)
+	}
+	user := newTestUser(t, s)
+	h := newTestWorkoutHandler(s)
+	streakHandler := NewStreakHandler(streaks.NewService(s, streaks.DefaultConfig()), achievements.NewEngine(s))
+
+	today := time.Now()
+	var ids []float64
+	for _, daysAgo := range []int{2, 1, 0} {
+		body := fmt.Sprintf(`{"completed_at":%q,"exercises":[{"name":"Squat","sets":[{"reps":5,"weight":100}]}]}`,
+			today.AddDate(0, 0, -daysAgo).Format(time.RFC3339))
+		code, resp := performRequest(t, withUser(user, h.Create), http.MethodPost, body)
+		if code != http.StatusCreated {
+			t.Fatalf("Expected status 201, got %d: %v", code, resp)
+		}
+		id, ok := object(t, resp["workout"])["id"].(float64)
+		if !ok {
+			t.Fatalf("Expected workout id, got %v", resp["workout"])
+		}
+		ids = append(ids, id)
+	}
+
+	current := func() float64 {
+		t.Helper()
+		code, resp := performRequest(t, withUser(user, streakHandler.List), http.MethodGet, "")
+		if code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %v", code, resp)
+		}
+		list, ok := resp["streaks"].([]any)
+		if !ok || len(list) != len(database.StreakTypes) {
+			t.Fatalf("Expected every streak type, got %v", resp["streaks"])
+		}
+		workout := object(t, list[0])
+		if workout["streak_type"] != database.StreakWorkout {
+			t.Fatalf("Expected the workout streak first, got %v", workout)
+		}
+		value, ok := workout["current"].(float64)
+		if !ok {
+			t.Fatalf("Expected current streak, got %v", workout)
+		}
+		return value
+	}
+	if got := current(); got != 3 {
+		t.Errorf("Expected a 3 day streak, got %v", got)
+	}
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(ids[2])}}
+	c.Request = httptest.NewRequest(http.MethodDelete, "/", nil)
+	withUser(user, h.Delete)(c)
+	if got := current(); got != 2 {
+		t.Errorf("Expected deleting today's workout to recompute a 2 day streak, got %v", got)
+	}
+}
+
+func TestUpdateWorkoutKeepsProgram(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	if err := s.Exercises().Save(ctx, &database.Exercise{Name: "Squat"}); err != nil {
+		t.Fatalf("Failed to add exercise: %v", err)
+	}
+	program := &database.Program{Name: "Squat Everyday"}
+	if err := s.Programs().Create(ctx, program); err != nil {
+		t.Fatalf("Failed to create program: %v", err)
+	}
+	user := newTestUser(t, s)
+	h := newTestWorkoutHandler(s)
+
+	body := fmt.Sprintf(`{"program_id":%d,"exercises":[{"name":"Squat","sets":[{"reps":5,"weight":100}]}]}`, program.ID)
+	code, resp := performRequest(t, withUser(user, h.Create), http.MethodPost, body)
+	if code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %v", code, resp)
+	}
+	id, ok := object(t, resp["workout"])["id"].(float64)
+	if !ok {
+		t.Fatalf("Expected workout id, got %v", resp["workout"])
+	}
+
+	body = `{"notes":"Felt heavy","exercises":[{"name":"Squat","sets":[{"reps":5,"weight":105}]}]}`
+	code, resp = performIDRequest(t, withUser(user, h.Update), http.MethodPut, uint(id), body)
+	if code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %v", code, resp)
+	}
+	if got := object(t, resp["workout"])["program_id"]; got != float64(program.ID) {
+		t.Errorf("Expected the edit to keep program %d, got %v", program.ID, got)
+	}
+}
+
+func TestGenerateWeaselMessage(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	user := newTestUser(t, s)
+	templates, err := weasel.Builtin()
+	if err != nil {
+		t.Fatalf("Failed to load templates: %v", err)
+	}
+	h := NewWeaselHandler(s, weasel.NewEngine(s, templates, nil, nil, nil, rand.New(rand.NewPCG(1, 2))), 24*time.Hour)
+
+	code, resp := performRequest(t, withUser(user, h.GenerateMessage), http.MethodPost, `{"type":"funny"}`)
+	if code != http.StatusCreated || object(t, resp["message"])["sent_at"] == nil {
+		t.Fatalf("Expected status 201 with a sent message, got %d: %v", code, resp)
+	}
+
+	if err := s.Users().Update(ctx, user.ID, map[string]any{"weasel_mode_enabled": false}); err != nil {
+		t.Fatalf("Failed to update user: %v", err)
+	}
+	code, resp = performRequest(t, withUser(user, h.GenerateMessage), http.MethodPost, `{"type":"funny"}`)
+	if code != http.StatusConflict {
+		t.Errorf("Expected status 409 with Weasel Mode off, got %d: %v", code, resp)
+	}
+
+	code, resp = performRequest(t, withUser(user, h.ListMessages), http.MethodGet, "")
+	if msgs, ok := resp["messages"].([]any); code != http.StatusOK || !ok || len(msgs) != 1 {
+		t.Errorf("Expected one stored message, got %d: %v", code, resp)
+	}
+}
+
+func TestUpdateWeaselMessage(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	user := newTestUser(t, s)
+	h := NewWeaselHandler(s, nil, 24*time.Hour)
+
+	msg := &database.WeaselMessage{UserID: user.ID, MessageType: database.MessageFunny, Content: "hi", SentAt: time.Now()}
+	if err := s.Messages().Create(ctx, msg); err != nil {
+		t.Fatalf("Failed to store message: %v", err)
+	}
+	update := func(id uint, body string) (int, map[string]any) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(id)}}
+		c.Request = httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		withUser(user, h.UpdateMessage)(c)
+		return w.Code, decode(t, w)
+	}
+
+	code, resp := update(msg.ID, `{"reaction":"motivated"}`)
+	if code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %v", code, resp)
+	}
+	stored, err := s.Messages().Get(ctx, user.ID, msg.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if stored.UserReaction != database.ReactionMotivated || stored.ReadAt == nil {
+		t.Errorf("Expected a reaction to mark the message read, got %+v", stored)
+	}
+
+	if code, resp := update(msg.ID, `{"read":false}`); code != http.StatusOK || object(t, resp["message"])["read_at"] != nil {
+		t.Errorf("Expected the message to be unread, got %d: %v", code, resp)
+	}
+
+	if code, resp := update(msg.ID, `{"reaction":"thrilled"}`); code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown reaction, got %d: %v", code, resp)
+	}
+
+	other := &database.User{Email: "kim@example.com", Name: "Kim"}
+	if err := s.Users().Create(ctx, other); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	theirs := &database.WeaselMessage{UserID: other.ID, MessageType: database.MessageFunny, Content: "hi", SentAt: time.Now()}
+	if err := s.Messages().Create(ctx, theirs); err != nil {
+		t.Fatalf("Failed to store message: %v", err)
+	}
+	if code, resp := update(theirs.ID, `{"read":true}`); code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for another user's message, got %d: %v", code, resp)
+	}
+}
+
+func TestWeaselConversion(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	user := newTestUser(t, s)
+	h := NewWeaselHandler(s, nil, 24*time.Hour)
+
+	now := time.Now()
+	read := now.Add(-47 * time.Hour)
+	msgs := []database.WeaselMessage{
+		{MessageType: database.MessageGuilt, Intensity: database.IntensityGentle, SentAt: now.Add(-48 * time.Hour), TriggeredWorkout: true, ReadAt: &read},
+		{MessageType: database.MessageGuilt, Intensity: database.IntensityGentle, SentAt: now.Add(-72 * time.Hour)},
+		{MessageType: database.MessageFunny, Intensity: database.IntensityMedium, SentAt: now.Add(-72 * time.Hour)},
+		// Still inside the attribution window, so left out
+		{MessageType: database.MessageFunny, Intensity: database.IntensityMedium, SentAt: now.Add(-time.Hour)},
+	}
+	for i := range msgs {
+		msgs[i].UserID = user.ID
+		msgs[i].Content = "hi"
+		if err := s.Messages().Create(ctx, &msgs[i]); err != nil {
+			t.Fatalf("Failed to store message: %v", err)
+		}
+	}
+
+	code, resp := performRequest(t, h.Conversion, http.MethodGet, "")
+	if code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %v", code, resp)
+	}
+	total := object(t, resp["total"])
+	if total["sent"] != 3.0 || total["triggered"] != 1.0 {
+		t.Errorf("Expected 3 sent and 1 triggered in total, got %v", total)
+	}
+	rows, ok := resp["conversions"].([]any)
+	if !ok || len(rows) != 2 {
+		t.Fatalf("Expected 2 report rows, got %v", resp["conversions"])
+	}
+	guilt := object(t, rows[1])
+	if guilt["message_type"] != database.MessageGuilt || guilt["conversion_rate"] != 0.5 || guilt["read_rate"] != 0.5 {
+		t.Errorf("Expected half of the gentle guilt trips to convert, got %v", guilt)
+	}
+}
+
+func TestEnrollAndFetchCurrent(t *testing.T) {
+	s := store.NewMemory()
+	user := newTestUser(t, s)
+	structure, err := programdef.StrongLifts().JSON()
+	if err != nil {
+		t.Fatalf("Failed to encode program: %v", err)
+	}
+	program := &database.Program{Name: programdef.StrongLiftsName, Structure: structure}
+	if err := s.Programs().Create(context.Background(), program); err != nil {
+		t.Fatalf("Failed to create program: %v", err)
+	}
+	h := NewEnrollmentHandler(s, 12)
+
+	code, resp := performRequest(t, withUser(user, h.Current), http.MethodGet, "")
+	if code != http.StatusNotFound {
+		t.Errorf("Expected status 404 before enrolling, got %d: %v", code, resp)
+	}
+
+	body := fmt.Sprintf(`{"program_id":%d}`, program.ID)
+	code, resp = performRequest(t, withUser(user, h.Enroll), http.MethodPost, body)
+	if code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %v", code, resp)
+	}
+
+	code, resp = performRequest(t, withUser(user, h.Enroll), http.MethodPost, body)
+	if code != http.StatusConflict {
+		t.Errorf("Expected status 409 when already enrolled, got %d: %v", code, resp)
+	}
+
+	code, resp = performRequest(t, withUser(user, h.Current), http.MethodGet, "")
+	if code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %v", code, resp)
+	}
+	enrollment := object(t, resp["enrollment"])
+	if enrollment["program_name"] != programdef.StrongLiftsName || enrollment["status"] != database.EnrollmentActive {
+		t.Errorf("Unexpected enrollment %v", enrollment)
+	}
+}
+
+func TestSwitchConvertsCarriedWeights(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	user := newTestUser(t, s)
+	structure, err := programdef.StrongLifts().JSON()
+	if err != nil {
+		t.Fatalf("Failed to encode program: %v", err)
+	}
+	pounds := &database.Program{Name: programdef.StrongLiftsName, Structure: structure}
+	kilograms := &database.Program{Name: "Metric Squats", Structure: `{"version": 1, "units": "kg", "round_to": 2.5,
+		"days": [{"name": "A", "slots": [{"exercise": "Squat", "scheme": "5x5"}]}],
+		"progression": {"default": {"increment": 2.5, "start_weight": 20}}}`}
+	for _, p := range []*database.Program{pounds, kilograms} {
+		if err := s.Programs().Create(ctx, p); err != nil {
+			t.Fatalf("Failed to create program: %v", err)
+		}
+	}
+	h := NewEnrollmentHandler(s, 12)
+
+	body := fmt.Sprintf(`{"program_id":%d,"start_weights":{"Squat":220}}`, pounds.ID)
+	if code, resp := performRequest(t, withUser(user, h.Enroll), http.MethodPost, body); code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %v", code, resp)
+	}
+	body = fmt.Sprintf(`{"program_id":%d}`, kilograms.ID)
+	if code, resp := performRequest(t, withUser(user, h.Switch), http.MethodPost, body); code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %v", code, resp)
+	}
+
+	current, err := s.Enrollments().Current(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("Failed to load enrollment: %v", err)
+	}
+	// 220 lb is 99.8 kg, rounded to the program's 2.5 kg step
+	if len(current.Lifts) != 1 || current.Lifts[0].StartWeight != 100 {
+		t.Errorf("Expected squat to carry over as 100 kg, got %+v", current.Lifts)
+	}
+}
+
+func TestLoggingFinalSessionCompletesEnrollment(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	if err := s.Exercises().Save(ctx, &database.Exercise{Name: "Squat"}); err != nil {
+		t.Fatalf("Failed to add exercise: %v", err)
+	}
+	user := newTestUser(t, s)
+	structure, err := programdef.StrongLifts().JSON()
+	if err != nil {
+		t.Fatalf("Failed to encode program: %v", err)
+	}
+	program := &database.Program{Name: programdef.StrongLiftsName, Structure: structure}
+	if err := s.Programs().Create(ctx, program); err != nil {
+		t.Fatalf("Failed to create program: %v", err)
+	}
+	// A one week program is three sessions
+	enroll := NewEnrollmentHandler(s, 1)
+	code, resp := performRequest(t, withUser(user, enroll.Enroll), http.MethodPost, fmt.Sprintf(`{"program_id":%d}`, program.ID))
+	if code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %v", code, resp)
+	}
+
+	h := newTestWorkoutHandler(s)
+	body := `{"exercises":[{"name":"Squat","sets":[{"reps":5,"weight":100}]}]}`
+	for range 3 {
+		if code, resp := performRequest(t, withUser(user, h.Create), http.MethodPost, body); code != http.StatusCreated {
+			t.Fatalf("Expected status 201, got %d: %v", code, resp)
+		}
+	}
+
+	enrollments, err := s.Enrollments().List(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("Failed to list enrollments: %v", err)
+	}
+	if len(enrollments) != 1 || enrollments[0].Status != database.EnrollmentCompleted || enrollments[0].SessionsCompleted != 3 {
+		t.Errorf("Expected logging the final session to complete the enrollment, got %+v", enrollments)
+	}
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lucas-albers-lz4/ferrovis/internal/achievements"
+	"github.com/lucas-albers-lz4/ferrovis/internal/streaks"
+)
+
+// StreakHandler serves the /api/streaks endpoints
+type StreakHandler struct {
+	streaks      *streaks.Service
+	achievements *achievements.Engine
+}
+
+// NewStreakHandler creates a StreakHandler
+func NewStreakHandler(streakService *streaks.Service, engine *achievements.Engine) *StreakHandler {
+	return &StreakHandler{streaks: streakService, achievements: engine}
+}
+
+// List returns the current user's streaks, ending any that lapsed since
+// their last workout
+func (h *StreakHandler) List(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	list, err := h.streaks.Refresh(c.Request.Context(), user.ID)
+	if err != nil {
+		respondInternalError(c, "Failed to fetch streaks", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"streaks": list,
+	})
+}
+
+// Recompute rebuilds the current user's streaks from their workout history
+func (h *StreakHandler) Recompute(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	list, err := h.streaks.Recompute(ctx, user.ID)
+	if err != nil {
+		respondInternalError(c, "Failed to recompute streaks", err)
+		return
+	}
+
+	resp := gin.H{
+		"status":  "ok",
+		"streaks": list,
+	}
+	unlocked, err := h.achievements.Evaluate(ctx, user.ID, achievements.StreakChanged)
+	if err != nil {
+		respondInternalError(c, "Failed to evaluate achievements", err)
+		return
+	}
+	if len(unlocked) > 0 {
+		resp["unlocked_achievements"] = unlocked
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+)
+
+// UserHandler serves the /api/user endpoints
+type UserHandler struct {
+	store store.Store
+}
+
+// NewUserHandler creates a UserHandler
+func NewUserHandler(s store.Store) *UserHandler {
+	return &UserHandler{store: s}
+}
+
+// profileUpdateRequest uses pointer fields so that omitted fields are left
+// untouched; only fields present in the body are written (PATCH semantics).
+// The oneof lists mirror the constants in database/enums.go.
+type profileUpdateRequest struct {
+	Name                 *string `json:"name" binding:"omitempty,min=1,max=100"`
+	PreferredWorkoutTime *string `json:"preferred_workout_time" binding:"omitempty,oneof=morning afternoon evening"`
+	FitnessGoal          *string `json:"fitness_goal" binding:"omitempty,oneof=strength endurance weight_loss general"`
+	Timezone             *string `json:"timezone" binding:"omitempty,timezone"`
+
+	WeaselModeEnabled   *bool   `json:"weasel_mode_enabled"`
+	WeaselIntensity     *string `json:"weasel_intensity" binding:"omitempty,oneof=gentle medium aggressive full_chaos"`
+	AllowGuiltTrips     *bool   `json:"allow_guilt_trips"`
+	AllowFakeStats      *bool   `json:"allow_fake_stats"`
+	AllowSocialPressure *bool   `json:"allow_social_pressure"`
+}
+
+// changes returns the column updates requested by the body
+func (r *profileUpdateRequest) changes() map[string]any {
+	updates := make(map[string]any)
+	setIfPresent(updates, "name", r.Name)
+	setIfPresent(updates, "preferred_workout_time", r.PreferredWorkoutTime)
+	setIfPresent(updates, "fitness_goal", r.FitnessGoal)
+	setIfPresent(updates, "timezone", r.Timezone)
+	setIfPresent(updates, "weasel_mode_enabled", r.WeaselModeEnabled)
+	setIfPresent(updates, "weasel_intensity", r.WeaselIntensity)
+	setIfPresent(updates, "allow_guilt_trips", r.AllowGuiltTrips)
+	setIfPresent(updates, "allow_fake_stats", r.AllowFakeStats)
+	setIfPresent(updates, "allow_social_pressure", r.AllowSocialPressure)
+	return updates
+}
+
+// setIfPresent records column = *value when value is non-nil
+func setIfPresent[T any](updates map[string]any, column string, value *T) {
+	if value != nil {
+		updates[column] = *value
+	}
+}
+
+// GetProfile returns the current user's profile and Weasel Mode settings
+func (h *UserHandler) GetProfile(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+		"user":   user,
+	})
+}
+
+// UpdateProfile applies a partial update to the current user's profile.
+// Fields omitted from the body keep their current values, so the mobile
+// settings screen can toggle a single flag.
+func (h *UserHandler) UpdateProfile(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req profileUpdateRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	updates := req.changes()
+	if len(updates) > 0 {
+		ctx := c.Request.Context()
+		if err := h.store.Users().Update(ctx, user.ID, updates); err != nil {
+			respondInternalError(c, "Failed to update profile", err)
+			return
+		}
+		reloaded, err := h.store.Users().Get(ctx, user.ID)
+		if err != nil {
+			respondInternalError(c, "Failed to reload profile", err)
+			return
+		}
+		user = reloaded
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+		"user":   user,
+	})
+}
+
+// pushTokenRequest registers the device that receives push notifications
+type pushTokenRequest struct {
+	Token string `json:"token" binding:"required,max=255,startswith=ExponentPushToken[,endswith=]"`
+}
+
+// SetPushToken registers the Expo push token of the current user's device,
+// replacing any earlier one
+func (h *UserHandler) SetPushToken(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req pushTokenRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.store.Users().Update(c.Request.Context(), user.ID, map[string]any{"expo_push_token": req.Token}); err != nil {
+		respondInternalError(c, "Failed to register push token", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"message": "Push notifications enabled",
+	})
+}
+
+// DeletePushToken forgets the current user's push token, turning push
+// notifications off
+func (h *UserHandler) DeletePushToken(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if err := h.store.Users().Update(c.Request.Context(), user.ID, map[string]any{"expo_push_token": ""}); err != nil {
+		respondInternalError(c, "Failed to remove push token", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"message": "Push notifications disabled",
+	})
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/middleware"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+)
+
+// withUser wraps a handler so it runs as the given authenticated user
+func withUser(user *database.User, handler gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		middleware.SetCurrentUser(c, user)
+		handler(c)
+	}
+}
+
+func TestGetProfile(t *testing.T) {
+	h := NewUserHandler(store.NewMemory())
+	user := &database.User{ID: 3, Email: "sam@example.com", WeaselIntensity: database.IntensityAggressive}
+
+	code, resp := performRequest(t, withUser(user, h.GetProfile), http.MethodGet, "")
+	if code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", code)
+	}
+
+	profile, ok := resp["user"].(map[string]any)
+	if !ok {
+		t.Fatalf("Expected user in response, got %v", resp)
+	}
+	if profile["weasel_intensity"] != database.IntensityAggressive {
+		t.Errorf("Expected intensity %s, got %v", database.IntensityAggressive, profile["weasel_intensity"])
+	}
+	if _, leaked := profile["password"]; leaked {
+		t.Error("Password must never be serialized")
+	}
+}
+
+func TestGetProfileRequiresUser(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+
+	NewUserHandler(store.NewMemory()).GetProfile(c)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401, got %d", w.Code)
+	}
+}
+
+func TestUpdateProfileValidation(t *testing.T) {
+	h := NewUserHandler(store.NewMemory())
+	user := &database.User{ID: 3}
+
+	tests := []struct {
+		name          string
+		body          string
+		expectedField string
+	}{
+		{"unknown intensity", `{"weasel_intensity":"nuclear"}`, "weasel_intensity"},
+		{"unknown workout time", `{"preferred_workout_time":"midnight"}`, "preferred_workout_time"},
+		{"unknown goal", `{"fitness_goal":"vibes"}`, "fitness_goal"},
+		{"empty name", `{"name":""}`, "name"},
+		{"wrong type", `{"allow_guilt_trips":"yes"}`, "allow_guilt_trips"},
+		{"unknown time zone", `{"timezone":"Mars/Olympus_Mons"}`, "timezone"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, resp := performRequest(t, withUser(user, h.UpdateProfile), http.MethodPatch, tt.body)
+			if code != http.StatusBadRequest {
+				t.Fatalf("Expected status 400, got %d", code)
+			}
+			errs, ok := resp["errors"].(map[string]any)
+			if !ok || errs[tt.expectedField] == nil {
+				t.Errorf("Expected %s field error, got %v", tt.expectedField, resp)
+			}
+		})
+	}
+}
+
+func TestUpdateProfileAcceptsEveryIntensity(t *testing.T) {
+	registerJSONTagNames()
+	for _, intensity := range database.WeaselIntensities {
+		t.Run(intensity, func(t *testing.T) {
+			var req profileUpdateRequest
+			body := fmt.Sprintf(`{"weasel_intensity":%q}`, intensity)
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(body))
+			if !bindJSON(c, &req) {
+				t.Errorf("Expected intensity %s to be accepted", intensity)
+			}
+		})
+	}
+}
+
+func TestUpdateProfileEmptyBodyIsNoop(t *testing.T) {
+	h := NewUserHandler(store.NewMemory())
+	user := &database.User{ID: 3, Name: "Sam"}
+
+	code, resp := performRequest(t, withUser(user, h.UpdateProfile), http.MethodPatch, `{}`)
+	if code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %v", code, resp)
+	}
+}
+
+func TestProfileUpdateChanges(t *testing.T) {
+	off := false
+	intensity := database.IntensityGentle
+	req := profileUpdateRequest{AllowGuiltTrips: &off, WeaselIntensity: &intensity}
+
+	changes := req.changes()
+	if len(changes) != 2 {
+		t.Fatalf("Expected 2 changes, got %v", changes)
+	}
+	// false must be written explicitly, not skipped as a zero value
+	if v, ok := changes["allow_guilt_trips"]; !ok || v != false {
+		t.Errorf("Expected allow_guilt_trips=false, got %v", changes)
+	}
+	if changes["weasel_intensity"] != database.IntensityGentle {
+		t.Errorf("Expected weasel_intensity=gentle, got %v", changes["weasel_intensity"])
+	}
+}
+
+func TestSetPushToken(t *testing.T) {
+	s := store.NewMemory()
+	h := NewUserHandler(s)
+	user := newTestUser(t, s)
+
+	tests := []struct {
+		name     string
+		body     string
+		expected int
+	}{
+		{"missing token", `{}`, http.StatusBadRequest},
+		{"not an Expo token", `{"token":"abc123"}`, http.StatusBadRequest},
+		{"unterminated token", `{"token":"ExponentPushToken[abc123"}`, http.StatusBadRequest},
+		{"valid token", `{"token":"ExponentPushToken[abc123]"}`, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, resp := performRequest(t, withUser(user, h.SetPushToken), http.MethodPut, tt.body)
+			if code != tt.expected {
+				t.Fatalf("Expected status %d, got %d: %v", tt.expected, code, resp)
+			}
+		})
+	}
+
+	stored, err := s.Users().Get(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("Failed to load user: %v", err)
+	}
+	if stored.ExpoPushToken != "ExponentPushToken[abc123]" {
+		t.Errorf("Expected the token to be stored, got %q", stored.ExpoPushToken)
+	}
+
+	if code, resp := performRequest(t, withUser(user, h.DeletePushToken), http.MethodDelete, ""); code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %v", code, resp)
+	}
+	if stored, err = s.Users().Get(context.Background(), user.ID); err != nil {
+		t.Fatalf("Failed to load user: %v", err)
+	}
+	if stored.ExpoPushToken != "" {
+		t.Errorf("Expected the token to be removed, got %q", stored.ExpoPushToken)
+	}
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// normalizer is implemented by request bodies that clean up their input
+// (trimming, lower-casing) before validation runs
+type normalizer interface {
+	normalize()
+}
+
+var registerTagNameOnce sync.Once
+
+// registerJSONTagNames makes validation errors report JSON field names
+// instead of Go struct field names
+func registerJSONTagNames() {
+	registerTagNameOnce.Do(func() {
+		v, ok := binding.Validator.Engine().(*validator.Validate)
+		if !ok {
+			return
+		}
+		v.RegisterTagNameFunc(func(fld reflect.StructField) string {
+			name := strings.SplitN(fld.Tag.Get("json"), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			return name
+		})
+	})
+}
+
+// bindJSON decodes the request body into req, normalizes it and validates it.
+// On failure it writes a 400 response with field-level errors and returns false.
+func bindJSON(c *gin.Context, req any) bool {
+	registerJSONTagNames()
+
+	if err := json.NewDecoder(c.Request.Body).Decode(req); err != nil {
+		// A value of the wrong type is reported against its field
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": "Validation failed",
+				"errors":  map[string]string{typeErr.Field: "must be a " + typeErr.Type.String()},
+			})
+			return false
+		}
+		respondError(c, http.StatusBadRequest, "Invalid JSON request body")
+		return false
+	}
+
+	if n, ok := req.(normalizer); ok {
+		n.normalize()
+	}
+
+	if err := binding.Validator.ValidateStruct(req); err != nil {
+		respondValidationError(c, err)
+		return false
+	}
+	return true
+}
+
+// respondValidationError writes a 400 response describing each invalid field
+func respondValidationError(c *gin.Context, err error) {
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		respondError(c, http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	c.JSON(http.StatusBadRequest, gin.H{
+		"status":  "error",
+		"message": "Validation failed",
+		"errors":  fieldErrors(verrs),
+	})
+}
+
+// fieldErrors converts validator errors into a field -> message map
+func fieldErrors(verrs validator.ValidationErrors) map[string]string {
+	out := make(map[string]string, len(verrs))
+	for _, fe := range verrs {
+		out[fieldPath(fe)] = describeFieldError(fe)
+	}
+	return out
+}
+
+// fieldPath returns the JSON path of a field error without the root struct name
+func fieldPath(fe validator.FieldError) string {
+	ns := fe.Namespace()
+	if i := strings.Index(ns, "."); i >= 0 {
+		return ns[i+1:]
+	}
+	return fe.Field()
+}
+
+// describeFieldError renders a human readable message for a single field error
+func describeFieldError(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required", "required_without":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "min", "gte":
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("must be at least %s characters", fe.Param())
+		}
+		return fmt.Sprintf("must be at least %s", fe.Param())
+	case "max", "lte":
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("must be at most %s characters", fe.Param())
+		}
+		return fmt.Sprintf("must be at most %s", fe.Param())
+	case "timezone":
+		return "must be an IANA time zone such as Europe/Berlin"
+	case "oneof":
+		return fmt.Sprintf("must be one of: %s", strings.ReplaceAll(fe.Param(), " ", ", "))
+	case "startswith":
+		return fmt.Sprintf("must start with %s", fe.Param())
+	case "endswith":
+		return fmt.Sprintf("must end with %s", fe.Param())
+	default:
+		return fmt.Sprintf("failed %s validation", fe.Tag())
+	}
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// performRequest runs a single request through a handler and decodes the JSON response
+func performRequest(t *testing.T, handler gin.HandlerFunc, method, body string) (int, map[string]any) {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(method, "/", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	handler(c)
+	return w.Code, decode(t, w)
+}
+
+// decode parses a recorded JSON object response
+func decode(t *testing.T, w *httptest.ResponseRecorder) map[string]any {
+	t.Helper()
+	var resp map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response %q: %v", w.Body.String(), err)
+	}
+	return resp
+}
+
+func TestRegisterValidation(t *testing.T) {
+	h := NewAuthHandler(store.NewMemory(), nil, nil, "")
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedFields []string
+	}{
+		{"missing fields", `{}`, []string{"email", "password"}},
+		{"bad email", `{"email":"not-an-email","password":"longenough"}`, []string{"email"}},
+		{"short password", `{"email":"a@b.co","password":"short"}`, []string{"password"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, resp := performRequest(t, h.Register, http.MethodPost, tt.body)
+			if code != http.StatusBadRequest {
+				t.Fatalf("Expected status 400, got %d", code)
+			}
+
+			errs, ok := resp["errors"].(map[string]any)
+			if !ok {
+				t.Fatalf("Expected field errors in response, got %v", resp)
+			}
+			for _, field := range tt.expectedFields {
+				if _, ok := errs[field]; !ok {
+					t.Errorf("Expected error for field %q, got %v", field, errs)
+				}
+			}
+		})
+	}
+}
+
+func TestBindJSONRejectsMalformedBody(t *testing.T) {
+	h := NewAuthHandler(store.NewMemory(), nil, nil, "")
+
+	code, resp := performRequest(t, h.Login, http.MethodPost, `{"email":`)
+	if code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", code)
+	}
+	if resp["status"] != "error" {
+		t.Errorf("Expected error status, got %v", resp["status"])
+	}
+}
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"Lifter@Example.com", "lifter@example.com"},
+		{"  spaced@example.com  ", "spaced@example.com"},
+		{"plain@example.com", "plain@example.com"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeEmail(tt.input); got != tt.expected {
+			t.Errorf("normalizeEmail(%q) = %q, expected %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestRefreshRequiresToken(t *testing.T) {
+	h := NewAuthHandler(store.NewMemory(), nil, nil, "")
+
+	for name, handler := range map[string]gin.HandlerFunc{"refresh": h.Refresh, "logout": h.Logout} {
+		t.Run(name, func(t *testing.T) {
+			code, resp := performRequest(t, handler, http.MethodPost, `{}`)
+			if code != http.StatusBadRequest {
+				t.Fatalf("Expected status 400, got %d", code)
+			}
+			errs, ok := resp["errors"].(map[string]any)
+			if !ok || errs["refresh_token"] == nil {
+				t.Errorf("Expected refresh_token field error, got %v", resp)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+	"github.com/lucas-albers-lz4/ferrovis/internal/weasel"
+)
+
+// Bounds for the conversion report period, in days
+const (
+	defaultReportDays = 30
+	maxReportDays     = 365
+)
+
+// WeaselHandler serves the /api/weasel endpoints
+type WeaselHandler struct {
+	store  store.Store
+	engine *weasel.Engine
+	// attributionWindow is how long a message waits for a workout before
+	// its outcome is known
+	attributionWindow time.Duration
+}
+
+// NewWeaselHandler creates a WeaselHandler
+func NewWeaselHandler(s store.Store, engine *weasel.Engine, attributionWindow time.Duration) *WeaselHandler {
+	return &WeaselHandler{store: s, engine: engine, attributionWindow: attributionWindow}
+}
+
+// generateMessageRequest is the body for generating a message on demand
+type generateMessageRequest struct {
+	Type string `json:"type" binding:"required,oneof=guilt fomo urgency social funny"`
+}
+
+// updateMessageRequest records a read receipt or a reaction. Read false
+// marks the message unread again.
+type updateMessageRequest struct {
+	Read     *bool   `json:"read"`
+	Reaction *string `json:"reaction" binding:"omitempty,oneof=ignored annoyed motivated worked_out"`
+}
+
+// conversionStats is one row of the conversion report
+type conversionStats struct {
+	MessageType    string  `json:"message_type"`
+	Intensity      string  `json:"intensity"`
+	Sent           int64   `json:"sent"`
+	Read           int64   `json:"read"`
+	Triggered      int64   `json:"triggered"`
+	ReadRate       float64 `json:"read_rate"`
+	ConversionRate float64 `json:"conversion_rate"`
+}
+
+// ListMessages returns the current user's messages, most recent first
+func (h *WeaselHandler) ListMessages(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	limit, offset := pagination(c)
+	msgs, err := h.store.Messages().List(c.Request.Context(), user.ID, store.Page{Limit: limit, Offset: offset})
+	if err != nil {
+		respondInternalError(c, "Failed to fetch messages", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   "ok",
+		"messages": msgs,
+	})
+}
+
+// GenerateMessage renders and stores a message of the requested type for
+// the current user, subject to their Weasel Mode settings
+func (h *WeaselHandler) GenerateMessage(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req generateMessageRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	msg, err := h.engine.Generate(c.Request.Context(), user.ID, req.Type)
+	switch {
+	case errors.Is(err, weasel.ErrDisabled):
+		respondError(c, http.StatusConflict, "Weasel Mode is disabled")
+		return
+	case errors.Is(err, weasel.ErrNotAllowed):
+		respondError(c, http.StatusConflict, "Message type is turned off in your settings")
+		return
+	case errors.Is(err, weasel.ErrNoTemplate):
+		respondError(c, http.StatusUnprocessableEntity, "No message applies yet")
+		return
+	case err != nil:
+		respondInternalError(c, "Failed to generate message", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":  "ok",
+		"message": msg,
+	})
+}
+
+// UpdateMessage records that the current user read or reacted to one of
+// their messages. Reacting also marks the message read.
+func (h *WeaselHandler) UpdateMessage(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req updateMessageRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	msg, err := h.store.Messages().Get(ctx, user.ID, id)
+	if errors.Is(err, store.ErrNotFound) {
+		respondError(c, http.StatusNotFound, "Message not found")
+		return
+	}
+	if err != nil {
+		respondInternalError(c, "Failed to fetch message", err)
+		return
+	}
+
+	changes := map[string]any{}
+	now := time.Now()
+	// The first read time is kept when a message is marked read again
+	markRead := req.Read != nil && *req.Read || req.Reaction != nil && req.Read == nil
+	switch {
+	case markRead && msg.ReadAt == nil:
+		msg.ReadAt = &now
+		changes["read_at"] = now
+	case req.Read != nil && !*req.Read:
+		msg.ReadAt = nil
+		changes["read_at"] = nil
+	}
+	if req.Reaction != nil {
+		msg.UserReaction = *req.Reaction
+		changes["user_reaction"] = *req.Reaction
+	}
+
+	if len(changes) > 0 {
+		if err := h.store.Messages().Update(ctx, user.ID, id, changes); err != nil {
+			respondInternalError(c, "Failed to update message", err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"message": msg,
+	})
+}
+
+// Conversion reports how many messages of each type and intensity were
+// sent, read and followed by a workout over the last ?days= days
+func (h *WeaselHandler) Conversion(c *gin.Context) {
+	since, until, ok := reportPeriod(c, h.attributionWindow)
+	if !ok {
+		return
+	}
+
+	rows, err := h.store.Messages().Conversion(c.Request.Context(), store.ConversionQuery{Since: since, Until: until})
+	if err != nil {
+		respondInternalError(c, "Failed to build conversion report", err)
+		return
+	}
+
+	report := make([]conversionStats, 0, len(rows))
+	total := store.ConversionStats{MessageType: "all", Intensity: "all"}
+	for _, row := range rows {
+		report = append(report, newConversionStats(row))
+		total.Sent += row.Sent
+		total.Read += row.Read
+		total.Triggered += row.Triggered
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":      "ok",
+		"since":       since,
+		"until":       until,
+		"total":       newConversionStats(total),
+		"conversions": report,
+	})
+}
+
+// reportPeriod reads the ?days= a report covers, writing a 400 when it is
+// invalid. The period ends attributionWindow ago, since the outcome of
+// later messages is not known yet.
+func reportPeriod(c *gin.Context, attributionWindow time.Duration) (since, until time.Time, ok bool) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", strconv.Itoa(defaultReportDays)))
+	if err != nil || days <= 0 || days > maxReportDays {
+		respondError(c, http.StatusBadRequest, "Invalid days")
+		return time.Time{}, time.Time{}, false
+	}
+
+	until = time.Now().Add(-attributionWindow)
+	return until.AddDate(0, 0, -days), until, true
+}
+
+// newConversionStats adds read and conversion rates to a report row
+func newConversionStats(row store.ConversionStats) conversionStats {
+	out := conversionStats{
+		MessageType: row.MessageType,
+		Intensity:   row.Intensity,
+		Sent:        row.Sent,
+		Read:        row.Read,
+		Triggered:   row.Triggered,
+	}
+	if row.Sent > 0 {
+		out.ReadRate = float64(row.Read) / float64(row.Sent)
+		out.ConversionRate = float64(row.Triggered) / float64(row.Sent)
+	}
+	return out
+}
@@ -0,0 +1,536 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lucas-albers-lz4/ferrovis/internal/achievements"
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/realtime"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+	"github.com/lucas-albers-lz4/ferrovis/internal/streaks"
+)
+
+var (
+	// errUnknownExercises is returned when a workout references exercises we don't know
+	errUnknownExercises = errors.New("unknown exercises")
+	// errUnknownProgram is returned when a workout references a missing program
+	errUnknownProgram = errors.New("unknown program")
+)
+
+// WorkoutHandler serves the /api/workouts endpoints
+type WorkoutHandler struct {
+	store        store.Store
+	streaks      *streaks.Service
+	achievements *achievements.Engine
+	events       realtime.Publisher
+}
+
+// NewWorkoutHandler creates a WorkoutHandler that updates streaks and
+// evaluates achievements whenever a workout changes. Logged workouts are
+// published to events, which may be nil.
+func NewWorkoutHandler(s store.Store, streakService *streaks.Service, engine *achievements.Engine, events realtime.Publisher) *WorkoutHandler {
+	return &WorkoutHandler{store: s, streaks: streakService, achievements: engine, events: events}
+}
+
+// workoutRequest is the body for creating or replacing a workout. It accepts
+// the shape sent by the mobile app (exercises[].sets[] with reps/weight/
+// completed, camelCase timestamps) as well as snake_case fields.
+type workoutRequest struct {
+	ProgramID   *uint      `json:"program_id"`
+	StartedAt   *time.Time `json:"started_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+	Duration    *int       `json:"duration" binding:"omitempty,min=0,max=1440"`
+	Notes       string     `json:"notes" binding:"max=2000"`
+
+	Exercises []exerciseEntry `json:"exercises" binding:"required,min=1,max=50,dive"`
+
+	// Aliases used by the mobile client
+	StartedAtAlias   *time.Time `json:"startedAt"`
+	CompletedAtAlias *time.Time `json:"completedAt"`
+}
+
+type exerciseEntry struct {
+	ExerciseID *uint      `json:"exercise_id"`
+	Name       string     `json:"name" binding:"required_without=ExerciseID,max=100"`
+	Sets       []setEntry `json:"sets" binding:"required,min=1,max=50,dive"`
+}
+
+type setEntry struct {
+	Reps      int      `json:"reps" binding:"min=0,max=1000"`
+	Weight    float64  `json:"weight" binding:"min=0,max=5000"`
+	Unit      string   `json:"unit" binding:"omitempty,oneof=lb kg"`
+	RPE       *float64 `json:"rpe" binding:"omitempty,min=1,max=10"`
+	Completed *bool    `json:"completed"`
+}
+
+func (r *workoutRequest) normalize() {
+	if r.StartedAt == nil {
+		r.StartedAt = r.StartedAtAlias
+	}
+	if r.CompletedAt == nil {
+		r.CompletedAt = r.CompletedAtAlias
+	}
+	r.Notes = strings.TrimSpace(r.Notes)
+	for i := range r.Exercises {
+		r.Exercises[i].Name = strings.TrimSpace(r.Exercises[i].Name)
+	}
+}
+
+// workoutResponse is the API representation of a workout with its sets
+// grouped back into exercises
+type workoutResponse struct {
+	ID                uint               `json:"id"`
+	CreatedAt         time.Time          `json:"created_at"`
+	UpdatedAt         time.Time          `json:"updated_at"`
+	ProgramID         *uint              `json:"program_id,omitempty"`
+	CompletedAt       time.Time          `json:"completed_at"`
+	Duration          int                `json:"duration"`
+	Notes             string             `json:"notes"`
+	IsPersonalRecord  bool               `json:"is_personal_record"`
+	FakeProgressBoost int                `json:"fake_progress_boost"`
+	Exercises         []exerciseResponse `json:"exercises"`
+}
+
+type exerciseResponse struct {
+	ExerciseID uint          `json:"exercise_id"`
+	Name       string        `json:"name"`
+	Sets       []setResponse `json:"sets"`
+}
+
+type setResponse struct {
+	SetIndex  int      `json:"set_index"`
+	Reps      int      `json:"reps"`
+	Weight    float64  `json:"weight"`
+	Unit      string   `json:"unit"`
+	RPE       *float64 `json:"rpe,omitempty"`
+	Completed bool     `json:"completed"`
+}
+
+// newWorkoutResponse groups a workout's sets by exercise order
+func newWorkoutResponse(w *database.Workout) workoutResponse {
+	resp := workoutResponse{
+		ID:                w.ID,
+		CreatedAt:         w.CreatedAt,
+		UpdatedAt:         w.UpdatedAt,
+		ProgramID:         w.ProgramID,
+		CompletedAt:       w.CompletedAt,
+		Duration:          w.Duration,
+		Notes:             w.Notes,
+		IsPersonalRecord:  w.IsPersonalRecord,
+		FakeProgressBoost: w.FakeProgressBoost,
+		Exercises:         []exerciseResponse{},
+	}
+
+	byOrder := make(map[int]int) // exercise order -> index in resp.Exercises
+	for _, s := range w.Sets {
+		idx, ok := byOrder[s.ExerciseOrder]
+		if !ok {
+			idx = len(resp.Exercises)
+			byOrder[s.ExerciseOrder] = idx
+			resp.Exercises = append(resp.Exercises, exerciseResponse{
+				ExerciseID: s.ExerciseID,
+				Name:       s.Exercise.Name,
+				Sets:       []setResponse{},
+			})
+		}
+		resp.Exercises[idx].Sets = append(resp.Exercises[idx].Sets, setResponse{
+			SetIndex:  s.SetIndex,
+			Reps:      s.Reps,
+			Weight:    s.Weight,
+			Unit:      s.Unit,
+			RPE:       s.RPE,
+			Completed: s.Completed,
+		})
+	}
+	return resp
+}
+
+// Create logs a new workout for the current user
+func (h *WorkoutHandler) Create(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req workoutRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	workout := database.Workout{UserID: user.ID}
+	err := h.store.Transaction(ctx, func(tx store.Store) error {
+		// Sessions logged without a program count toward the active enrollment
+		if req.ProgramID == nil {
+			programID, err := activeProgramID(ctx, tx, user.ID)
+			if err != nil {
+				return err
+			}
+			req.ProgramID = programID
+		}
+		if err := applyWorkoutRequest(ctx, tx, &workout, &req); err != nil {
+			return err
+		}
+		if err := tx.Workouts().Create(ctx, &workout); err != nil {
+			return fmt.Errorf("failed to create workout: %w", err)
+		}
+		return syncActiveEnrollment(ctx, tx, &workout)
+	})
+	if !h.handleWriteError(c, err, "Failed to log workout") {
+		return
+	}
+
+	unlocked := h.afterWorkoutChange(ctx, user.ID, &workout.CompletedAt)
+	publishWorkout(ctx, h.store, h.events, user, &workout, unlocked)
+	h.respondWithWorkout(c, http.StatusCreated, user.ID, workout.ID, unlocked)
+}
+
+// List returns the current user's workouts, most recent first
+func (h *WorkoutHandler) List(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	limit, offset := pagination(c)
+	workouts, total, err := h.store.Workouts().List(c.Request.Context(), user.ID, store.Page{Limit: limit, Offset: offset})
+	if err != nil {
+		respondInternalError(c, "Failed to fetch workouts", err)
+		return
+	}
+
+	out := make([]workoutResponse, 0, len(workouts))
+	for i := range workouts {
+		out = append(out, newWorkoutResponse(&workouts[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   "ok",
+		"workouts": out,
+		"pagination": gin.H{
+			"limit":  limit,
+			"offset": offset,
+			"total":  total,
+		},
+	})
+}
+
+// Get returns a single workout owned by the current user
+func (h *WorkoutHandler) Get(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	h.respondWithWorkout(c, http.StatusOK, user.ID, id, nil)
+}
+
+// Update replaces a workout's details and sets
+func (h *WorkoutHandler) Update(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req workoutRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	err := h.store.Transaction(ctx, func(tx store.Store) error {
+		workout, err := tx.Workouts().Get(ctx, user.ID, id)
+		if err != nil {
+			return fmt.Errorf("failed to load workout: %w", err)
+		}
+		// Edits that omit the program keep the workout attached to it
+		if req.ProgramID == nil {
+			req.ProgramID = workout.ProgramID
+		}
+		if err := applyWorkoutRequest(ctx, tx, workout, &req); err != nil {
+			return err
+		}
+		if err := tx.Workouts().Replace(ctx, workout); err != nil {
+			return fmt.Errorf("failed to update workout: %w", err)
+		}
+		return syncActiveEnrollment(ctx, tx, workout)
+	})
+	if !h.handleWriteError(c, err, "Failed to update workout") {
+		return
+	}
+
+	unlocked := h.afterWorkoutChange(ctx, user.ID, nil)
+	h.respondWithWorkout(c, http.StatusOK, user.ID, id, unlocked)
+}
+
+// Delete soft-deletes a workout and its sets
+func (h *WorkoutHandler) Delete(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	err := h.store.Workouts().Delete(ctx, user.ID, id)
+	if !h.handleWriteError(c, err, "Failed to delete workout") {
+		return
+	}
+	h.afterWorkoutChange(ctx, user.ID, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"message": "Workout deleted",
+	})
+}
+
+// afterWorkoutChange updates streaks and achievement progress after a
+// workout changes and returns the achievements unlocked. A new workout
+// completed at loggedAt extends the streaks; edits and deletes (nil) rebuild
+// them. The workout is already saved, so failures are logged rather than
+// failing the request; the next change re-evaluates everything.
+func (h *WorkoutHandler) afterWorkoutChange(ctx context.Context, userID uint, loggedAt *time.Time) []database.Achievement {
+	var err error
+	if loggedAt != nil {
+		_, err = h.streaks.WorkoutLogged(ctx, userID, *loggedAt)
+	} else {
+		_, err = h.streaks.Recompute(ctx, userID)
+	}
+	if err != nil {
+		slog.Warn("Failed to update streaks", "user_id", userID, "error", err)
+	}
+
+	unlocked, err := h.achievements.WorkoutLogged(ctx, userID)
+	if err != nil {
+		slog.Warn("Failed to evaluate achievements", "user_id", userID, "error", err)
+	}
+	streakUnlocked, err := h.achievements.Evaluate(ctx, userID, achievements.StreakChanged)
+	if err != nil {
+		slog.Warn("Failed to evaluate streak achievements", "user_id", userID, "error", err)
+	}
+	return append(unlocked, streakUnlocked...)
+}
+
+// respondWithWorkout loads a workout with its sets and writes it along with
+// any achievements the change unlocked
+func (h *WorkoutHandler) respondWithWorkout(c *gin.Context, status int, userID, id uint, unlocked []database.Achievement) {
+	workout, err := h.store.Workouts().Get(c.Request.Context(), userID, id)
+	if errors.Is(err, store.ErrNotFound) {
+		respondError(c, http.StatusNotFound, "Workout not found")
+		return
+	}
+	if err != nil {
+		respondInternalError(c, "Failed to fetch workout", err)
+		return
+	}
+
+	resp := gin.H{
+		"status":  "ok",
+		"workout": newWorkoutResponse(workout),
+	}
+	if len(unlocked) > 0 {
+		resp["unlocked_achievements"] = unlocked
+	}
+	c.JSON(status, resp)
+}
+
+// handleWriteError maps write errors to responses; it returns true when err is nil
+func (h *WorkoutHandler) handleWriteError(c *gin.Context, err error, message string) bool {
+	var unknown unknownExerciseError
+	switch {
+	case err == nil:
+		return true
+	case errors.As(err, &unknown):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Validation failed",
+			"errors":  unknown.fields,
+		})
+	case errors.Is(err, errUnknownProgram):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Validation failed",
+			"errors":  map[string]string{"program_id": "unknown program"},
+		})
+	case errors.Is(err, store.ErrNotFound):
+		respondError(c, http.StatusNotFound, "Workout not found")
+	default:
+		respondInternalError(c, message, err)
+	}
+	return false
+}
+
+// unknownExerciseError carries per-field errors for unresolved exercises
+type unknownExerciseError struct {
+	fields map[string]string
+}
+
+func (e unknownExerciseError) Error() string {
+	return fmt.Sprintf("%s: %v", errUnknownExercises, e.fields)
+}
+
+func (e unknownExerciseError) Unwrap() error {
+	return errUnknownExercises
+}
+
+// activeProgramID returns the program of the user's active enrollment, or nil
+func activeProgramID(ctx context.Context, tx store.Store, userID uint) (*uint, error) {
+	enrollment, err := tx.Enrollments().Current(ctx, userID)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && enrollment.Status != database.EnrollmentActive) {
+		return nil, nil //nolint:nilnil // no active enrollment is not an error
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load enrollment: %w", err)
+	}
+	return &enrollment.ProgramID, nil
+}
+
+// syncActiveEnrollment refreshes the progress of the user's active
+// enrollment when workout counts toward it, so logging the final session
+// completes the program right away
+func syncActiveEnrollment(ctx context.Context, tx store.Store, workout *database.Workout) error {
+	if workout.ProgramID == nil {
+		return nil
+	}
+	enrollment, err := tx.Enrollments().Current(ctx, workout.UserID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load enrollment: %w", err)
+	}
+	if enrollment.Status != database.EnrollmentActive || enrollment.ProgramID != *workout.ProgramID {
+		return nil
+	}
+	// A broken program definition must not keep the workout from being logged
+	if err := syncEnrollment(ctx, tx, enrollment, time.Now()); err != nil && !errors.Is(err, errInvalidDefinition) {
+		return err
+	}
+	return nil
+}
+
+// applyWorkoutRequest copies a validated request onto workout, resolving
+// exercise names to catalog rows and flattening sets into rows
+func applyWorkoutRequest(ctx context.Context, tx store.Store, workout *database.Workout, req *workoutRequest) error {
+	if req.ProgramID != nil {
+		_, err := tx.Programs().Get(ctx, *req.ProgramID)
+		if errors.Is(err, store.ErrNotFound) {
+			return errUnknownProgram
+		}
+		if err != nil {
+			return fmt.Errorf("failed to check program: %w", err)
+		}
+	}
+
+	exerciseIDs, err := resolveExercises(ctx, tx, req.Exercises)
+	if err != nil {
+		return err
+	}
+
+	completedAt := time.Now()
+	if req.CompletedAt != nil {
+		completedAt = *req.CompletedAt
+	}
+
+	duration := 0
+	switch {
+	case req.Duration != nil:
+		duration = *req.Duration
+	case req.StartedAt != nil && completedAt.After(*req.StartedAt):
+		duration = int(completedAt.Sub(*req.StartedAt).Minutes())
+	}
+
+	workout.ProgramID = req.ProgramID
+	workout.CompletedAt = completedAt
+	workout.Duration = duration
+	workout.Notes = req.Notes
+	workout.Sets = nil
+
+	for i, entry := range req.Exercises {
+		for j, set := range entry.Sets {
+			unit := set.Unit
+			if unit == "" {
+				unit = database.UnitPounds
+			}
+			completed := true
+			if set.Completed != nil {
+				completed = *set.Completed
+			}
+
+			workout.Sets = append(workout.Sets, database.WorkoutSet{
+				WorkoutID:     workout.ID,
+				ExerciseID:    exerciseIDs[i],
+				ExerciseOrder: i,
+				SetIndex:      j,
+				Reps:          set.Reps,
+				Weight:        set.Weight,
+				Unit:          unit,
+				RPE:           set.RPE,
+				Completed:     completed,
+			})
+		}
+	}
+	return nil
+}
+
+// resolveExercises maps each entry to an exercise ID, matching names
+// case-insensitively against the catalog
+func resolveExercises(ctx context.Context, tx store.Store, entries []exerciseEntry) ([]uint, error) {
+	catalog, err := tx.Exercises().List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load exercises: %w", err)
+	}
+
+	byName := make(map[string]uint, len(catalog))
+	byID := make(map[uint]bool, len(catalog))
+	for _, e := range catalog {
+		byName[strings.ToLower(e.Name)] = e.ID
+		byID[e.ID] = true
+	}
+
+	ids := make([]uint, len(entries))
+	unknown := make(map[string]string)
+	for i, entry := range entries {
+		if entry.ExerciseID != nil {
+			if !byID[*entry.ExerciseID] {
+				unknown[fmt.Sprintf("exercises[%d].exercise_id", i)] = "unknown exercise"
+				continue
+			}
+			ids[i] = *entry.ExerciseID
+			continue
+		}
+
+		id, ok := byName[strings.ToLower(entry.Name)]
+		if !ok {
+			unknown[fmt.Sprintf("exercises[%d].name", i)] = "unknown exercise"
+			continue
+		}
+		ids[i] = id
+	}
+
+	if len(unknown) > 0 {
+		return nil, unknownExerciseError{fields: unknown}
+	}
+	return ids, nil
+}
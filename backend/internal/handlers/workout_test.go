@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+)
+
+// bindWorkout runs bindJSON on body and returns the decoded request
+func bindWorkout(t *testing.T, body string) (*workoutRequest, *httptest.ResponseRecorder, bool) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+
+	var req workoutRequest
+	ok := bindJSON(c, &req)
+	return &req, w, ok
+}
+
+func TestWorkoutRequestAcceptsMobileShape(t *testing.T) {
+	body := `{
+		"exercises": [
+			{"name": "Squat", "sets": [{"reps": 5, "weight": 135, "completed": true}, {"reps": 5, "weight": 135, "completed": false}]},
+			{"name": " bench press ", "sets": [{"reps": 5, "weight": 95, "completed": true}]}
+		],
+		"startedAt": "2025-05-01T17:00:00Z",
+		"completedAt": "2025-05-01T17:45:00Z",
+		"notes": "felt strong"
+	}`
+
+	req, w, ok := bindWorkout(t, body)
+	if !ok {
+		t.Fatalf("Expected mobile payload to bind, got %s", w.Body.String())
+	}
+
+	if req.CompletedAt == nil || !req.CompletedAt.Equal(time.Date(2025, 5, 1, 17, 45, 0, 0, time.UTC)) {
+		t.Errorf("Expected completedAt alias to populate CompletedAt, got %v", req.CompletedAt)
+	}
+	if req.StartedAt == nil {
+		t.Error("Expected startedAt alias to populate StartedAt")
+	}
+	if req.Exercises[1].Name != "bench press" {
+		t.Errorf("Expected exercise name to be trimmed, got %q", req.Exercises[1].Name)
+	}
+	if *req.Exercises[0].Sets[1].Completed {
+		t.Error("Expected second squat set to be incomplete")
+	}
+}
+
+func TestWorkoutRequestValidation(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		expectedField string
+	}{
+		{"no exercises", `{"exercises": []}`, "exercises"},
+		{"missing name", `{"exercises": [{"sets": [{"reps": 5}]}]}`, "exercises[0].name"},
+		{"no sets", `{"exercises": [{"name": "Squat", "sets": []}]}`, "exercises[0].sets"},
+		{"negative reps", `{"exercises": [{"name": "Squat", "sets": [{"reps": -1}]}]}`, "exercises[0].sets[0].reps"},
+		{"bad unit", `{"exercises": [{"name": "Squat", "sets": [{"reps": 5, "unit": "stone"}]}]}`, "exercises[0].sets[0].unit"},
+		{"rpe out of range", `{"exercises": [{"name": "Squat", "sets": [{"reps": 5, "rpe": 11}]}]}`, "exercises[0].sets[0].rpe"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, w, ok := bindWorkout(t, tt.body)
+			if ok {
+				t.Fatal("Expected validation to fail")
+			}
+			if !strings.Contains(w.Body.String(), `"`+tt.expectedField+`"`) {
+				t.Errorf("Expected error for %s, got %s", tt.expectedField, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestNewWorkoutResponseGroupsSets(t *testing.T) {
+	programID := uint(2)
+	workout := &database.Workout{
+		ID:        10,
+		ProgramID: &programID,
+		Sets: []database.WorkoutSet{
+			{ExerciseID: 1, Exercise: database.Exercise{Name: "Squat"}, ExerciseOrder: 0, SetIndex: 0, Reps: 5, Weight: 135, Unit: "lb", Completed: true},
+			{ExerciseID: 1, Exercise: database.Exercise{Name: "Squat"}, ExerciseOrder: 0, SetIndex: 1, Reps: 5, Weight: 135, Unit: "lb", Completed: true},
+			{ExerciseID: 3, Exercise: database.Exercise{Name: "Bench Press"}, ExerciseOrder: 1, SetIndex: 0, Reps: 5, Weight: 95, Unit: "lb"},
+		},
+	}
+
+	resp := newWorkoutResponse(workout)
+	if len(resp.Exercises) != 2 {
+		t.Fatalf("Expected 2 exercises, got %d", len(resp.Exercises))
+	}
+	if resp.Exercises[0].Name != "Squat" || len(resp.Exercises[0].Sets) != 2 {
+		t.Errorf("Expected 2 squat sets first, got %+v", resp.Exercises[0])
+	}
+	if resp.Exercises[1].Name != "Bench Press" || resp.Exercises[1].Sets[0].Completed {
+		t.Errorf("Expected an incomplete bench set second, got %+v", resp.Exercises[1])
+	}
+	if resp.ProgramID == nil || *resp.ProgramID != programID {
+		t.Errorf("Expected program ID %d, got %v", programID, resp.ProgramID)
+	}
+}
+
+func TestParseIDParam(t *testing.T) {
+	tests := []struct {
+		value string
+		ok    bool
+	}{
+		{"12", true},
+		{"0", false},
+		{"-3", false},
+		{"1; DROP TABLE workouts", false},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: tt.value}}
+
+		if _, ok := parseIDParam(c, "id"); ok != tt.ok {
+			t.Errorf("parseIDParam(%q) ok = %v, expected %v", tt.value, ok, tt.ok)
+		}
+	}
+}
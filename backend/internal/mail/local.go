@@ -0,0 +1,94 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Permissions for the local mail drop
+	mailDirPerm  = 0o750
+	mailFilePerm = 0o600
+)
+
+// MemoryMailer records messages in memory; intended for tests
+type MemoryMailer struct {
+	mu   sync.Mutex
+	sent []Message
+}
+
+// NewMemoryMailer creates an empty MemoryMailer
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+// Send records msg
+func (m *MemoryMailer) Send(_ context.Context, msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// Sent returns a copy of every message recorded so far
+func (m *MemoryMailer) Sent() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.sent...)
+}
+
+// FileMailer writes each message as an .eml file so developers can open
+// verification and reset links without a mail server
+type FileMailer struct {
+	dir  string
+	from string
+	now  func() time.Time
+	mu   sync.Mutex
+	seq  int
+}
+
+// NewFileMailer creates a FileMailer writing into dir
+func NewFileMailer(dir, from string) *FileMailer {
+	return &FileMailer{dir: dir, from: from, now: time.Now}
+}
+
+// Send writes msg to a new file in the mail directory
+func (m *FileMailer) Send(_ context.Context, msg Message) error {
+	if err := os.MkdirAll(m.dir, mailDirPerm); err != nil {
+		return fmt.Errorf("failed to create mail directory: %w", err)
+	}
+
+	m.mu.Lock()
+	m.seq++
+	seq := m.seq
+	m.mu.Unlock()
+
+	now := m.now()
+	name := fmt.Sprintf("%s-%03d-%s.eml", now.Format("20060102T150405"), seq, sanitize(msg.To))
+	path := filepath.Join(m.dir, name)
+
+	if err := os.WriteFile(path, render(m.from, msg, now), mailFilePerm); err != nil {
+		return fmt.Errorf("failed to write mail file: %w", err)
+	}
+
+	slog.Info("Mail written to file", "to", msg.To, "subject", msg.Subject, "path", path)
+	return nil
+}
+
+// sanitize makes an address safe to embed in a file name
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+}
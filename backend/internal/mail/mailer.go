@@ -0,0 +1,92 @@
+// Package mail sends transactional email for the Ferrovis API. Delivery goes
+// through the Mailer interface so the SMTP transport can be swapped for the
+// in-memory or file implementations during local development and tests.
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/env"
+)
+
+const (
+	// Supported MAILER values
+	driverSMTP   = "smtp"
+	driverFile   = "file"
+	driverMemory = "memory"
+
+	defaultSMTPPort = 587
+	defaultFrom     = "Ferrovis <no-reply@ferrovis.app>"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers email messages
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Config holds mailer configuration
+type Config struct {
+	Driver   string
+	From     string
+	Host     string
+	Port     int
+	Username string
+	Password string
+	Dir      string
+}
+
+// LoadConfig loads mailer configuration from environment variables
+func LoadConfig() *Config {
+	return &Config{
+		Driver:   strings.ToLower(env.String("MAILER", driverFile)),
+		From:     env.String("MAIL_FROM", defaultFrom),
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     env.Int("SMTP_PORT", defaultSMTPPort, 1),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		Dir:      env.String("MAIL_DIR", filepath.Join(os.TempDir(), "ferrovis-mail")),
+	}
+}
+
+// New creates the Mailer selected by cfg.Driver
+func New(cfg *Config) (Mailer, error) {
+	switch cfg.Driver {
+	case driverSMTP:
+		if cfg.Host == "" {
+			return nil, fmt.Errorf("SMTP_HOST is required when MAILER=%s", driverSMTP)
+		}
+		return NewSMTPMailer(cfg), nil
+	case driverFile:
+		return NewFileMailer(cfg.Dir, cfg.From), nil
+	case driverMemory:
+		return NewMemoryMailer(), nil
+	default:
+		return nil, fmt.Errorf("unknown mailer driver %q", cfg.Driver)
+	}
+}
+
+// render formats a message as an RFC 5322 document
+func render(from string, msg Message, now time.Time) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
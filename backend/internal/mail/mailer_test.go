@@ -0,0 +1,96 @@
+package mail
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     *Config
+		wantErr bool
+	}{
+		{"memory", &Config{Driver: driverMemory}, false},
+		{"file", &Config{Driver: driverFile, Dir: t.TempDir()}, false},
+		{"smtp with host", &Config{Driver: driverSMTP, Host: "smtp.example.com", Port: defaultSMTPPort}, false},
+		{"smtp without host", &Config{Driver: driverSMTP}, true},
+		{"unknown driver", &Config{Driver: "pigeon"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := New(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if !tt.wantErr && m == nil {
+				t.Error("Expected a mailer")
+			}
+		})
+	}
+}
+
+func TestMemoryMailer(t *testing.T) {
+	m := NewMemoryMailer()
+	msg := Message{To: "lifter@example.com", Subject: "Hi", Body: "Lift"}
+
+	if err := m.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+
+	sent := m.Sent()
+	if len(sent) != 1 || sent[0] != msg {
+		t.Errorf("Expected recorded message %v, got %v", msg, sent)
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	m := NewFileMailer(dir, defaultFrom)
+
+	msg := Message{To: "lifter@example.com", Subject: "Reset", Body: "line one\nline two"}
+	if err := m.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir returned error: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 mail file, got %d", len(entries))
+	}
+	if !strings.HasSuffix(entries[0].Name(), "lifter_example.com.eml") {
+		t.Errorf("Unexpected file name %s", entries[0].Name())
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, entries[0].Name()))
+	if err != nil {
+		t.Fatalf("ReadFile returned error: %v", err)
+	}
+	if !strings.Contains(string(content), "Subject: Reset\r\n") {
+		t.Errorf("Expected subject header in %q", content)
+	}
+	if !strings.Contains(string(content), "line one\r\nline two") {
+		t.Errorf("Expected CRLF line endings in body, got %q", content)
+	}
+}
+
+func TestRender(t *testing.T) {
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	out := string(render("from@example.com", Message{To: "to@example.com", Subject: "S", Body: "B"}, now))
+
+	for _, header := range []string{"From: from@example.com\r\n", "To: to@example.com\r\n", "Date: Thu, 01 May 2025 12:00:00 +0000\r\n"} {
+		if !strings.Contains(out, header) {
+			t.Errorf("Expected header %q in %q", header, out)
+		}
+	}
+	if !strings.HasSuffix(out, "\r\n\r\nB") {
+		t.Errorf("Expected body after blank line, got %q", out)
+	}
+}
@@ -0,0 +1,150 @@
+// Package middleware provides Gin middleware shared by the Ferrovis API routes.
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lucas-albers-lz4/ferrovis/internal/auth"
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+)
+
+// Context keys for values set by RequireAuth
+const (
+	userContextKey   = "ferrovis.user"
+	claimsContextKey = "ferrovis.claims"
+)
+
+// ErrUserNotFound is returned by a UserLoader when the token subject no longer exists
+var ErrUserNotFound = errors.New("user not found")
+
+// UserLoader loads the user referenced by a verified token
+type UserLoader func(ctx context.Context, id uint) (*database.User, error)
+
+// StoreUserLoader loads users from s
+func StoreUserLoader(s store.Store) UserLoader {
+	return func(ctx context.Context, id uint) (*database.User, error) {
+		user, err := s.Users().Get(ctx, id)
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrUserNotFound
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load user %d: %w", id, err)
+		}
+		return user, nil
+	}
+}
+
+// RequireAuth validates the bearer token on each request, loads the user it
+// belongs to and stores both on the Gin context. Requests without a valid
+// token are rejected with 401.
+func RequireAuth(tokens *auth.TokenManager, loadUser UserLoader) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw, ok := bearerToken(c.GetHeader("Authorization"))
+		if !ok {
+			abortUnauthorized(c, "Missing bearer token")
+			return
+		}
+
+		claims, err := tokens.ParseAccessToken(raw)
+		if err != nil {
+			slog.Debug("Rejected access token", "error", err)
+			abortUnauthorized(c, "Invalid or expired token")
+			return
+		}
+
+		userID, err := claims.UserID()
+		if err != nil {
+			abortUnauthorized(c, "Invalid or expired token")
+			return
+		}
+
+		user, err := loadUser(c.Request.Context(), userID)
+		if errors.Is(err, ErrUserNotFound) {
+			abortUnauthorized(c, "Invalid or expired token")
+			return
+		}
+		if err != nil {
+			slog.Error("Failed to load authenticated user", "user_id", userID, "error", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"status":  "error",
+				"message": "Failed to load user",
+			})
+			return
+		}
+
+		// Tokens minted before a "log out all devices" carry a stale version
+		if claims.TokenVersion != user.TokenVersion {
+			abortUnauthorized(c, "Invalid or expired token")
+			return
+		}
+
+		c.Set(userContextKey, user)
+		c.Set(claimsContextKey, claims)
+		c.Next()
+	}
+}
+
+// RequireAdmin rejects users without admin access; it must run after RequireAuth
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if user, ok := CurrentUser(c); !ok || !user.IsAdmin {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"status":  "error",
+				"message": "Admin access required",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// CurrentUser returns the authenticated user stored by RequireAuth
+func CurrentUser(c *gin.Context) (*database.User, bool) {
+	v, ok := c.Get(userContextKey)
+	if !ok {
+		return nil, false
+	}
+	user, ok := v.(*database.User)
+	return user, ok
+}
+
+// CurrentClaims returns the verified token claims stored by RequireAuth
+func CurrentClaims(c *gin.Context) (*auth.Claims, bool) {
+	v, ok := c.Get(claimsContextKey)
+	if !ok {
+		return nil, false
+	}
+	claims, ok := v.(*auth.Claims)
+	return claims, ok
+}
+
+// SetCurrentUser stores a user on the context; useful for tests and internal callers
+func SetCurrentUser(c *gin.Context, user *database.User) {
+	c.Set(userContextKey, user)
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer <token>" header
+func bearerToken(header string) (string, bool) {
+	scheme, token, ok := strings.Cut(strings.TrimSpace(header), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// abortUnauthorized stops the request with a 401 and a Bearer challenge
+func abortUnauthorized(c *gin.Context, message string) {
+	c.Header("WWW-Authenticate", `Bearer realm="ferrovis"`)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+		"status":  "error",
+		"message": message,
+	})
+}
@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lucas-albers-lz4/ferrovis/internal/auth"
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func testTokens() *auth.TokenManager {
+	return auth.NewTokenManager(&auth.Config{
+		Keys:           map[string]string{"k1": "secret"},
+		ActiveKeyID:    "k1",
+		Issuer:         "ferrovis-api",
+		Audience:       "ferrovis-mobile",
+		AccessTokenTTL: time.Minute,
+	})
+}
+
+// fakeLoader returns a fixed user for ID 1 and ErrUserNotFound otherwise
+func fakeLoader(_ context.Context, id uint) (*database.User, error) {
+	if id == 1 {
+		return &database.User{ID: 1, Email: "lifter@example.com"}, nil
+	}
+	return nil, ErrUserNotFound
+}
+
+func newRouter(tokens *auth.TokenManager, loader UserLoader) *gin.Engine {
+	r := gin.New()
+	r.GET("/me", RequireAuth(tokens, loader), func(c *gin.Context) {
+		user, ok := CurrentUser(c)
+		if !ok {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"email": user.Email})
+	})
+	return r
+}
+
+func TestRequireAuth(t *testing.T) {
+	tokens := testTokens()
+
+	valid, err := tokens.IssueAccessToken(1, "lifter@example.com", 0)
+	if err != nil {
+		t.Fatalf("IssueAccessToken returned error: %v", err)
+	}
+	deleted, err := tokens.IssueAccessToken(2, "gone@example.com", 0)
+	if err != nil {
+		t.Fatalf("IssueAccessToken returned error: %v", err)
+	}
+	stale, err := tokens.IssueAccessToken(1, "lifter@example.com", 3)
+	if err != nil {
+		t.Fatalf("IssueAccessToken returned error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		header   string
+		expected int
+	}{
+		{"valid token", "Bearer " + valid, http.StatusOK},
+		{"lower-case scheme", "bearer " + valid, http.StatusOK},
+		{"missing header", "", http.StatusUnauthorized},
+		{"wrong scheme", "Basic " + valid, http.StatusUnauthorized},
+		{"malformed token", "Bearer nonsense", http.StatusUnauthorized},
+		{"deleted user", "Bearer " + deleted, http.StatusUnauthorized},
+		{"stale token version", "Bearer " + stale, http.StatusUnauthorized},
+	}
+
+	r := newRouter(tokens, fakeLoader)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/me", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+			}
+			if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("Expected WWW-Authenticate header on 401")
+			}
+		})
+	}
+}
+
+func TestRequireAuthLoaderFailure(t *testing.T) {
+	tokens := testTokens()
+	valid, err := tokens.IssueAccessToken(1, "lifter@example.com", 0)
+	if err != nil {
+		t.Fatalf("IssueAccessToken returned error: %v", err)
+	}
+
+	failing := func(context.Context, uint) (*database.User, error) {
+		return nil, errors.New("connection refused")
+	}
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.Header.Set("Authorization", "Bearer "+valid)
+	newRouter(tokens, failing).ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", w.Code)
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	tests := []struct {
+		name     string
+		user     *database.User
+		expected int
+	}{
+		{"admin", &database.User{ID: 1, IsAdmin: true}, http.StatusOK},
+		{"regular user", &database.User{ID: 2}, http.StatusForbidden},
+		{"no user", nil, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/admin", func(c *gin.Context) {
+				if tt.user != nil {
+					SetCurrentUser(c, tt.user)
+				}
+			}, RequireAdmin(), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin", nil))
+			if w.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,569 @@
+// Package migrate applies ordered, versioned SQL migrations to PostgreSQL.
+//
+// Migrations are pairs of files named NNNN_name.up.sql and NNNN_name.down.sql.
+// Each migration runs in its own transaction together with the row that
+// records it in the schema_migrations table, so a failed migration leaves no
+// trace. A session-level advisory lock serializes concurrent migrators, such
+// as several server replicas starting at once.
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// DefaultLockID is the advisory lock key used when none is configured
+const DefaultLockID int64 = 7_316_222_187
+
+var (
+	// ErrChecksumMismatch is returned when an applied migration's file has changed
+	ErrChecksumMismatch = errors.New("migration checksum mismatch")
+	// ErrUnknownMigration is returned when the database has a migration this binary does not know
+	ErrUnknownMigration = errors.New("database has unknown migration")
+	// ErrIrreversible is returned when rolling back a migration without a down step
+	ErrIrreversible = errors.New("migration has no down step")
+	// ErrNothingApplied is returned by Redo when no migration has been applied
+	ErrNothingApplied = errors.New("no migrations have been applied")
+)
+
+// fileName matches migration files such as 0002_add_enrollments.up.sql
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration is one versioned schema change
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string // Empty when the migration cannot be rolled back
+	Checksum string // SHA-256 of Up
+}
+
+// Record is a row of the schema_migrations table
+type Record struct {
+	Version   int64
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// Status describes one migration as known to the binary and the database
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+	// Modified is set when the migration file changed after it was applied
+	Modified bool
+	// Unknown is set when the database records a migration the binary lacks
+	Unknown bool
+}
+
+// Load reads migrations from dir in fsys, ordered by version
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		m := fileName.FindStringSubmatch(entry.Name())
+		if m == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", entry.Name())
+		}
+
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version in %q", entry.Name())
+		}
+
+		body, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", entry.Name(), err)
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, mig.Name, m[2])
+		}
+
+		switch m[3] {
+		case "up":
+			mig.Up = string(body)
+		case "down":
+			mig.Down = string(body)
+		}
+	}
+
+	out := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up step", mig.Version, mig.Name)
+		}
+		mig.Checksum = checksum(mig.Up)
+		out = append(out, *mig)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
+
+// checksum fingerprints a migration's up step
+func checksum(sql string) string {
+	sum := sha256.Sum256([]byte(sql))
+	return hex.EncodeToString(sum[:])
+}
+
+// Baseline adopts databases whose schema predates the migrator. When the
+// schema_migrations table is empty and Detect reports an existing schema,
+// the migrations up to Version are run over it and recorded in a single
+// transaction. Those migrations must therefore be idempotent (CREATE TABLE
+// IF NOT EXISTS, ADD COLUMN IF NOT EXISTS).
+type Baseline struct {
+	Version int64
+	Detect  func(ctx context.Context, conn *sql.Conn) (bool, error)
+}
+
+// Migrator applies migrations to a database
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+	lockID     int64
+	baseline   *Baseline
+	now        func() time.Time
+}
+
+// Option configures a Migrator
+type Option func(*Migrator)
+
+// WithLockID sets the advisory lock key
+func WithLockID(id int64) Option {
+	return func(m *Migrator) { m.lockID = id }
+}
+
+// WithBaseline enables adoption of pre-existing schemas
+func WithBaseline(b Baseline) Option {
+	return func(m *Migrator) { m.baseline = &b }
+}
+
+// New creates a Migrator for the given migrations
+func New(db *sql.DB, migrations []Migration, opts ...Option) *Migrator {
+	m := &Migrator{
+		db:         db,
+		migrations: migrations,
+		lockID:     DefaultLockID,
+		now:        time.Now,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Migrations returns the migrations known to the migrator
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// Up applies every pending migration and returns the ones it applied
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		records, err := m.prepare(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		pending, err := pendingMigrations(m.migrations, records)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range pending {
+			if err := m.apply(ctx, conn, mig); err != nil {
+				return err
+			}
+			applied = append(applied, mig)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back the most recent steps migrations and returns them in the
+// order they were rolled back
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var rolledBack []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		records, err := m.prepare(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := verify(m.migrations, records); err != nil {
+			return err
+		}
+
+		targets, err := rollbackTargets(m.migrations, records, steps)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range targets {
+			if err := m.revert(ctx, conn, mig); err != nil {
+				return err
+			}
+			rolledBack = append(rolledBack, mig)
+		}
+		return nil
+	})
+	return rolledBack, err
+}
+
+// Redo rolls back the most recently applied migration and applies it again
+func (m *Migrator) Redo(ctx context.Context) (*Migration, error) {
+	var redone *Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		records, err := m.prepare(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := verify(m.migrations, records); err != nil {
+			return err
+		}
+
+		targets, err := rollbackTargets(m.migrations, records, 1)
+		if err != nil {
+			return err
+		}
+		if len(targets) == 0 {
+			return ErrNothingApplied
+		}
+
+		mig := targets[0]
+		if err := m.revert(ctx, conn, mig); err != nil {
+			return err
+		}
+		if err := m.apply(ctx, conn, mig); err != nil {
+			return err
+		}
+		redone = &mig
+		return nil
+	})
+	return redone, err
+}
+
+// Status reports every known and applied migration, ordered by version
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Close() //nolint:errcheck // returns the connection to the pool
+
+	if err := ensureTable(ctx, conn); err != nil {
+		return nil, err
+	}
+	records, err := loadRecords(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	return statuses(m.migrations, records), nil
+}
+
+// Pending returns the migrations that Up would apply
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	status, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int64]bool, len(status))
+	for _, s := range status {
+		applied[s.Version] = s.Applied
+	}
+
+	var pending []Migration
+	for _, mig := range m.migrations {
+		if !applied[mig.Version] {
+			pending = append(pending, mig)
+		}
+	}
+	return pending, nil
+}
+
+// withLock runs fn on a dedicated connection holding the advisory lock.
+// Advisory locks belong to the session, so the lock, the migrations and the
+// unlock must all use the same connection.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Close() //nolint:errcheck // returns the connection to the pool
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", m.lockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer func() {
+		// Use a fresh context so the lock is released even if ctx was cancelled
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", m.lockID); err != nil {
+			slog.Error("Failed to release migration lock", "error", err)
+		}
+	}()
+
+	return fn(conn)
+}
+
+// prepare creates the history table, adopts a pre-existing schema if
+// configured, and returns the applied migrations
+func (m *Migrator) prepare(ctx context.Context, conn *sql.Conn) (map[int64]Record, error) {
+	if err := ensureTable(ctx, conn); err != nil {
+		return nil, err
+	}
+	records, err := loadRecords(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 && m.baseline != nil {
+		adopted, err := m.adopt(ctx, conn)
+		if err != nil {
+			return nil, err
+		}
+		if adopted {
+			return loadRecords(ctx, conn)
+		}
+	}
+	return records, nil
+}
+
+// adopt records the baseline migrations as applied on a pre-existing schema
+func (m *Migrator) adopt(ctx context.Context, conn *sql.Conn) (bool, error) {
+	existing, err := m.baseline.Detect(ctx, conn)
+	if err != nil {
+		return false, fmt.Errorf("failed to detect existing schema: %w", err)
+	}
+	if !existing {
+		return false, nil
+	}
+
+	slog.Info("Adopting existing schema as migration baseline", "version", m.baseline.Version)
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op after commit
+
+	for _, mig := range m.migrations {
+		if mig.Version > m.baseline.Version {
+			break
+		}
+		if _, err := tx.ExecContext(ctx, mig.Up); err != nil {
+			return false, fmt.Errorf("baseline migration %d_%s failed on existing schema: %w", mig.Version, mig.Name, err)
+		}
+		if err := m.record(ctx, tx, mig); err != nil {
+			return false, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to record baseline: %w", err)
+	}
+	return true, nil
+}
+
+// apply runs a migration's up step and records it in one transaction
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, mig Migration) error {
+	started := m.now()
+	slog.Info("Applying migration", "version", mig.Version, "name", mig.Name)
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op after commit
+
+	if _, err := tx.ExecContext(ctx, mig.Up); err != nil {
+		return fmt.Errorf("migration %d_%s failed: %w", mig.Version, mig.Name, err)
+	}
+	if err := m.record(ctx, tx, mig); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %d_%s: %w", mig.Version, mig.Name, err)
+	}
+
+	slog.Info("Applied migration", "version", mig.Version, "name", mig.Name, "duration", m.now().Sub(started))
+	return nil
+}
+
+// revert runs a migration's down step and removes its record in one transaction
+func (m *Migrator) revert(ctx context.Context, conn *sql.Conn, mig Migration) error {
+	slog.Info("Rolling back migration", "version", mig.Version, "name", mig.Name)
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op after commit
+
+	if _, err := tx.ExecContext(ctx, mig.Down); err != nil {
+		return fmt.Errorf("rollback of %d_%s failed: %w", mig.Version, mig.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = $1", mig.Version); err != nil {
+		return fmt.Errorf("failed to remove migration record: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit rollback of %d_%s: %w", mig.Version, mig.Name, err)
+	}
+	return nil
+}
+
+// record inserts a migration into schema_migrations
+func (m *Migrator) record(ctx context.Context, tx *sql.Tx, mig Migration) error {
+	_, err := tx.ExecContext(ctx,
+		"INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES ($1, $2, $3, $4)",
+		mig.Version, mig.Name, mig.Checksum, m.now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to record migration %d_%s: %w", mig.Version, mig.Name, err)
+	}
+	return nil
+}
+
+// ensureTable creates the schema_migrations table if needed
+func ensureTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    bigint PRIMARY KEY,
+		name       text NOT NULL,
+		checksum   text NOT NULL,
+		applied_at timestamptz NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	return nil
+}
+
+// loadRecords reads the applied migrations keyed by version
+func loadRecords(ctx context.Context, conn *sql.Conn) (map[int64]Record, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, name, checksum, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close() //nolint:errcheck // rows.Err reports read errors
+
+	records := make(map[int64]Record)
+	for rows.Next() {
+		var r Record
+		if err := rows.Scan(&r.Version, &r.Name, &r.Checksum, &r.AppliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan migration record: %w", err)
+		}
+		records[r.Version] = r
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	return records, nil
+}
+
+// verify checks that applied migrations are known and unchanged
+func verify(migrations []Migration, records map[int64]Record) error {
+	known := make(map[int64]Migration, len(migrations))
+	for _, mig := range migrations {
+		known[mig.Version] = mig
+	}
+
+	versions := make([]int64, 0, len(records))
+	for v := range records {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+
+	for _, v := range versions {
+		r := records[v]
+		mig, ok := known[v]
+		if !ok {
+			return fmt.Errorf("%w: %d_%s", ErrUnknownMigration, r.Version, r.Name)
+		}
+		if mig.Checksum != r.Checksum {
+			return fmt.Errorf("%w: %d_%s was modified after it was applied", ErrChecksumMismatch, mig.Version, mig.Name)
+		}
+	}
+	return nil
+}
+
+// pendingMigrations returns the migrations not yet applied, after verifying
+// the applied ones. Migrations older than the newest applied one are still
+// applied, which lets branches merge migrations out of order.
+func pendingMigrations(migrations []Migration, records map[int64]Record) ([]Migration, error) {
+	if err := verify(migrations, records); err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, mig := range migrations {
+		if _, ok := records[mig.Version]; !ok {
+			pending = append(pending, mig)
+		}
+	}
+	return pending, nil
+}
+
+// rollbackTargets returns the newest steps applied migrations, newest first
+func rollbackTargets(migrations []Migration, records map[int64]Record, steps int) ([]Migration, error) {
+	var targets []Migration
+	for i := len(migrations) - 1; i >= 0 && len(targets) < steps; i-- {
+		mig := migrations[i]
+		if _, ok := records[mig.Version]; !ok {
+			continue
+		}
+		if mig.Down == "" {
+			return nil, fmt.Errorf("%w: %d_%s", ErrIrreversible, mig.Version, mig.Name)
+		}
+		targets = append(targets, mig)
+	}
+	return targets, nil
+}
+
+// statuses merges known migrations with the applied records
+func statuses(migrations []Migration, records map[int64]Record) []Status {
+	out := make([]Status, 0, len(migrations))
+	seen := make(map[int64]bool, len(migrations))
+	for _, mig := range migrations {
+		seen[mig.Version] = true
+		s := Status{Version: mig.Version, Name: mig.Name}
+		if r, ok := records[mig.Version]; ok {
+			appliedAt := r.AppliedAt
+			s.Applied = true
+			s.AppliedAt = &appliedAt
+			s.Modified = r.Checksum != mig.Checksum
+		}
+		out = append(out, s)
+	}
+
+	for v, r := range records {
+		if !seen[v] {
+			appliedAt := r.AppliedAt
+			out = append(out, Status{Version: v, Name: r.Name, Applied: true, AppliedAt: &appliedAt, Unknown: true})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out
+}
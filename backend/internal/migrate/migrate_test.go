@@ -0,0 +1,155 @@
+package migrate
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func testFS() fstest.MapFS {
+	return fstest.MapFS{
+		"sql/0002_add_widgets.up.sql":   {Data: []byte("CREATE TABLE widgets (id bigint);")},
+		"sql/0002_add_widgets.down.sql": {Data: []byte("DROP TABLE widgets;")},
+		"sql/0001_baseline.up.sql":      {Data: []byte("CREATE TABLE IF NOT EXISTS users (id bigint);")},
+		"sql/0001_baseline.down.sql":    {Data: []byte("DROP TABLE users;")},
+		"sql/0010_backfill.up.sql":      {Data: []byte("UPDATE widgets SET id = id;")},
+	}
+}
+
+func load(t *testing.T) []Migration {
+	t.Helper()
+	migrations, err := Load(testFS(), "sql")
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	return migrations
+}
+
+// applied records the given migrations as applied
+func applied(migrations ...Migration) map[int64]Record {
+	out := make(map[int64]Record)
+	for _, m := range migrations {
+		out[m.Version] = Record{Version: m.Version, Name: m.Name, Checksum: m.Checksum, AppliedAt: time.Now()}
+	}
+	return out
+}
+
+func TestLoad(t *testing.T) {
+	migrations := load(t)
+
+	if len(migrations) != 3 {
+		t.Fatalf("Expected 3 migrations, got %d", len(migrations))
+	}
+	for i, expected := range []int64{1, 2, 10} {
+		if migrations[i].Version != expected {
+			t.Errorf("Expected version %d at %d, got %d", expected, i, migrations[i].Version)
+		}
+	}
+	if migrations[1].Name != "add_widgets" || migrations[1].Down != "DROP TABLE widgets;" {
+		t.Errorf("Unexpected migration %+v", migrations[1])
+	}
+	if migrations[2].Down != "" {
+		t.Error("Expected migration without down file to have no down step")
+	}
+	if len(migrations[0].Checksum) != 64 || migrations[0].Checksum == migrations[1].Checksum {
+		t.Errorf("Expected distinct SHA-256 checksums, got %q and %q", migrations[0].Checksum, migrations[1].Checksum)
+	}
+}
+
+func TestLoadRejectsBadFiles(t *testing.T) {
+	tests := []struct {
+		name string
+		fs   fstest.MapFS
+	}{
+		{"bad name", fstest.MapFS{"sql/add_widgets.up.sql": {Data: []byte("SELECT 1")}}},
+		{"zero version", fstest.MapFS{"sql/0000_nothing.up.sql": {Data: []byte("SELECT 1")}}},
+		{"missing up", fstest.MapFS{"sql/0001_baseline.down.sql": {Data: []byte("SELECT 1")}}},
+		{"conflicting names", fstest.MapFS{
+			"sql/0001_baseline.up.sql": {Data: []byte("SELECT 1")},
+			"sql/0001_other.up.sql":    {Data: []byte("SELECT 1")},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Load(tt.fs, "sql"); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestPendingMigrations(t *testing.T) {
+	migrations := load(t)
+
+	t.Run("fresh database", func(t *testing.T) {
+		pending, err := pendingMigrations(migrations, nil)
+		if err != nil || len(pending) != 3 {
+			t.Errorf("Expected all 3 pending, got %d (%v)", len(pending), err)
+		}
+	})
+
+	t.Run("out of order", func(t *testing.T) {
+		pending, err := pendingMigrations(migrations, applied(migrations[0], migrations[2]))
+		if err != nil || len(pending) != 1 || pending[0].Version != 2 {
+			t.Errorf("Expected migration 2 pending, got %+v (%v)", pending, err)
+		}
+	})
+
+	t.Run("modified after applying", func(t *testing.T) {
+		records := applied(migrations[0])
+		r := records[1]
+		r.Checksum = "edited"
+		records[1] = r
+		if _, err := pendingMigrations(migrations, records); !errors.Is(err, ErrChecksumMismatch) {
+			t.Errorf("Expected ErrChecksumMismatch, got %v", err)
+		}
+	})
+
+	t.Run("unknown applied migration", func(t *testing.T) {
+		records := applied(migrations...)
+		records[99] = Record{Version: 99, Name: "from_the_future"}
+		if _, err := pendingMigrations(migrations, records); !errors.Is(err, ErrUnknownMigration) {
+			t.Errorf("Expected ErrUnknownMigration, got %v", err)
+		}
+	})
+}
+
+func TestRollbackTargets(t *testing.T) {
+	migrations := load(t)
+
+	targets, err := rollbackTargets(migrations, applied(migrations[0], migrations[1]), 1)
+	if err != nil || len(targets) != 1 || targets[0].Version != 2 {
+		t.Errorf("Expected to roll back migration 2, got %+v (%v)", targets, err)
+	}
+
+	targets, err = rollbackTargets(migrations, applied(migrations[0], migrations[1]), 5)
+	if err != nil || len(targets) != 2 || targets[0].Version != 2 || targets[1].Version != 1 {
+		t.Errorf("Expected to roll back 2 then 1, got %+v (%v)", targets, err)
+	}
+
+	if _, err := rollbackTargets(migrations, applied(migrations...), 1); !errors.Is(err, ErrIrreversible) {
+		t.Errorf("Expected ErrIrreversible, got %v", err)
+	}
+}
+
+func TestStatuses(t *testing.T) {
+	migrations := load(t)
+	records := applied(migrations[0])
+	records[42] = Record{Version: 42, Name: "gone", AppliedAt: time.Now()}
+
+	status := statuses(migrations, records)
+	if len(status) != 4 {
+		t.Fatalf("Expected 4 statuses, got %+v", status)
+	}
+	if !status[0].Applied || status[0].AppliedAt == nil || status[0].Modified {
+		t.Errorf("Expected baseline applied and unmodified, got %+v", status[0])
+	}
+	if status[1].Applied || status[2].Applied {
+		t.Errorf("Expected later migrations pending, got %+v", status[1:3])
+	}
+	if !status[3].Unknown || status[3].Version != 42 {
+		t.Errorf("Expected unknown migration last, got %+v", status[3])
+	}
+}
@@ -0,0 +1,121 @@
+package notify
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/env"
+	"github.com/lucas-albers-lz4/ferrovis/internal/mail"
+)
+
+const (
+	// DefaultExpoURL is the Expo push API endpoint
+	DefaultExpoURL = "https://exp.host/--/api/v2/push/send"
+
+	defaultInterval    = 30 * time.Second
+	defaultBatchSize   = 50
+	defaultMaxAttempts = 8
+	defaultBaseBackoff = 30 * time.Second
+	defaultMaxBackoff  = time.Hour
+	defaultLease       = 5 * time.Minute
+	defaultTimeout     = 10 * time.Second
+)
+
+// Config controls which channels are used and how deliveries are retried
+type Config struct {
+	// Channels are the channels messages are queued on
+	Channels []string
+	// Interval is how often the worker looks for due notifications
+	Interval time.Duration
+	// BatchSize is how many notifications the worker claims at once
+	BatchSize int
+	// MaxAttempts is how many deliveries are tried before giving up
+	MaxAttempts int
+	// BaseBackoff is the wait after the first failure; it doubles with
+	// every further failure up to MaxBackoff
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// Lease is how long a claimed notification is hidden from other
+	// workers; it must exceed the time a delivery takes
+	Lease time.Duration
+
+	// ExpoURL and ExpoAccessToken address the Expo push API
+	ExpoURL         string
+	ExpoAccessToken string
+	// WebhookURL receives signed POSTs when the webhook channel is on
+	WebhookURL    string
+	WebhookSecret string
+}
+
+// DefaultConfig returns push-only delivery with eight attempts backing off
+// from 30 seconds to an hour
+func DefaultConfig() *Config {
+	return &Config{
+		Channels:    []string{database.ChannelPush},
+		Interval:    defaultInterval,
+		BatchSize:   defaultBatchSize,
+		MaxAttempts: defaultMaxAttempts,
+		BaseBackoff: defaultBaseBackoff,
+		MaxBackoff:  defaultMaxBackoff,
+		Lease:       defaultLease,
+		ExpoURL:     DefaultExpoURL,
+	}
+}
+
+// LoadConfig loads the defaults, overriding them from NOTIFY_CHANNELS (a
+// comma-separated list), NOTIFY_INTERVAL, NOTIFY_BATCH_SIZE,
+// NOTIFY_MAX_ATTEMPTS, NOTIFY_BASE_BACKOFF, NOTIFY_MAX_BACKOFF,
+// NOTIFY_LEASE, EXPO_PUSH_URL, EXPO_ACCESS_TOKEN, NOTIFY_WEBHOOK_URL and
+// NOTIFY_WEBHOOK_SECRET
+func LoadConfig() *Config {
+	cfg := DefaultConfig()
+	if v, ok := os.LookupEnv("NOTIFY_CHANNELS"); ok {
+		cfg.Channels = nil
+		for _, channel := range strings.Split(v, ",") {
+			if channel = strings.TrimSpace(channel); channel != "" {
+				cfg.Channels = append(cfg.Channels, channel)
+			}
+		}
+	}
+	cfg.Interval = env.Duration("NOTIFY_INTERVAL", cfg.Interval)
+	cfg.BatchSize = env.Int("NOTIFY_BATCH_SIZE", cfg.BatchSize, 1)
+	cfg.MaxAttempts = env.Int("NOTIFY_MAX_ATTEMPTS", cfg.MaxAttempts, 1)
+	cfg.BaseBackoff = env.Duration("NOTIFY_BASE_BACKOFF", cfg.BaseBackoff)
+	cfg.MaxBackoff = env.Duration("NOTIFY_MAX_BACKOFF", cfg.MaxBackoff)
+	cfg.Lease = env.Duration("NOTIFY_LEASE", cfg.Lease)
+	cfg.ExpoURL = env.String("EXPO_PUSH_URL", cfg.ExpoURL)
+	cfg.ExpoAccessToken = env.String("EXPO_ACCESS_TOKEN", "")
+	cfg.WebhookURL = env.String("NOTIFY_WEBHOOK_URL", "")
+	cfg.WebhookSecret = env.String("NOTIFY_WEBHOOK_SECRET", "")
+	return cfg
+}
+
+// Notifiers creates a Notifier for every configured channel, keyed by
+// channel. Email goes out through mailer.
+func Notifiers(cfg *Config, mailer mail.Mailer) (map[string]Notifier, error) {
+	client := &http.Client{Timeout: defaultTimeout}
+	out := make(map[string]Notifier, len(cfg.Channels))
+	for _, channel := range cfg.Channels {
+		if _, dup := out[channel]; dup {
+			return nil, fmt.Errorf("notification channel %q is listed twice", channel)
+		}
+		switch channel {
+		case database.ChannelPush:
+			out[channel] = NewExpo(cfg.ExpoURL, cfg.ExpoAccessToken, client)
+		case database.ChannelEmail:
+			out[channel] = NewEmail(mailer)
+		case database.ChannelWebhook:
+			if cfg.WebhookURL == "" {
+				return nil, fmt.Errorf("NOTIFY_WEBHOOK_URL is required for the %s channel", channel)
+			}
+			out[channel] = NewWebhook(cfg.WebhookURL, cfg.WebhookSecret, client)
+		default:
+			return nil, fmt.Errorf("unknown notification channel %q, expected one of %v", channel, database.Channels)
+		}
+	}
+	return out, nil
+}
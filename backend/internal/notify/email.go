@@ -0,0 +1,35 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/mail"
+)
+
+// emailSubject heads every notification email
+const emailSubject = "A word from the Weasel"
+
+// Email sends messages as plain-text email
+type Email struct {
+	mailer mail.Mailer
+}
+
+// NewEmail creates an Email notifier sending through mailer
+func NewEmail(mailer mail.Mailer) *Email {
+	return &Email{mailer: mailer}
+}
+
+// Notify mails msg to the user's verified address
+func (e *Email) Notify(ctx context.Context, user *database.User, msg *database.WeaselMessage) error {
+	if user.Email == "" || user.EmailVerifiedAt == nil {
+		return Permanent(errors.New("user has no verified email address"))
+	}
+	err := e.mailer.Send(ctx, mail.Message{To: user.Email, Subject: emailSubject, Body: msg.Content})
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
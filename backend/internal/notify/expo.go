@@ -0,0 +1,106 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+)
+
+const (
+	// pushTitle heads every push notification
+	pushTitle = "Ferrovis"
+	// DeviceNotRegistered is the Expo error for a token that no longer
+	// reaches a device
+	DeviceNotRegistered = "DeviceNotRegistered"
+)
+
+// ExpoMessage is a message in the Expo push API format
+type ExpoMessage struct {
+	To    string         `json:"to"`
+	Title string         `json:"title,omitempty"`
+	Body  string         `json:"body"`
+	Sound string         `json:"sound,omitempty"`
+	Data  map[string]any `json:"data,omitempty"`
+}
+
+// ExpoTicket is the Expo push API's receipt for one message
+type ExpoTicket struct {
+	Status  string             `json:"status"`
+	ID      string             `json:"id,omitempty"`
+	Message string             `json:"message,omitempty"`
+	Details *ExpoTicketDetails `json:"details,omitempty"`
+}
+
+// ExpoTicketDetails explains a failed ticket
+type ExpoTicketDetails struct {
+	Error string `json:"error,omitempty"`
+}
+
+// expoResponse is the Expo push API's reply to a single message
+type expoResponse struct {
+	Data   ExpoTicket `json:"data"`
+	Errors []struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// Expo sends push notifications through the Expo push API
+type Expo struct {
+	url         string
+	accessToken string
+	client      *http.Client
+}
+
+// NewExpo creates an Expo notifier posting to url. The access token is only
+// needed when push security is enabled for the Expo project.
+func NewExpo(url, accessToken string, client *http.Client) *Expo {
+	return &Expo{url: url, accessToken: accessToken, client: client}
+}
+
+// Notify pushes msg to the user's registered device. A token Expo reports
+// as no longer registered fails permanently.
+func (e *Expo) Notify(ctx context.Context, user *database.User, msg *database.WeaselMessage) error {
+	if user.ExpoPushToken == "" {
+		return Permanent(errors.New("user has no push token"))
+	}
+
+	body, err := json.Marshal(ExpoMessage{
+		To:    user.ExpoPushToken,
+		Title: pushTitle,
+		Body:  msg.Content,
+		Sound: "default",
+		Data:  map[string]any{"message_id": msg.ID, "message_type": msg.MessageType},
+	})
+	if err != nil {
+		return Permanent(fmt.Errorf("failed to encode push message: %w", err))
+	}
+	header := http.Header{}
+	if e.accessToken != "" {
+		header.Set("Authorization", "Bearer "+e.accessToken)
+	}
+
+	raw, err := post(ctx, e.client, e.url, body, header)
+	if err != nil {
+		return fmt.Errorf("failed to push message: %w", err)
+	}
+	var resp expoResponse
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return fmt.Errorf("failed to decode push ticket: %w", err)
+	}
+	if len(resp.Errors) > 0 {
+		return fmt.Errorf("push rejected: %s: %s", resp.Errors[0].Code, resp.Errors[0].Message)
+	}
+	if resp.Data.Status != "ok" {
+		err := fmt.Errorf("push rejected: %s", resp.Data.Message)
+		if resp.Data.Details != nil && resp.Data.Details.Error == DeviceNotRegistered {
+			return Permanent(err)
+		}
+		return err
+	}
+	return nil
+}
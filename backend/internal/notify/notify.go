@@ -0,0 +1,89 @@
+// Package notify delivers Weasel messages to users over push, email and
+// webhooks.
+//
+// Delivery goes through a transactional outbox: the weasel engine queues a
+// notification per channel in the transaction that stores the message, and
+// the Worker delivers queued notifications through the channel's Notifier.
+// Failed deliveries are retried with exponential backoff until they succeed,
+// fail permanently or run out of attempts.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+)
+
+// Notifier delivers a message to a user over one channel
+type Notifier interface {
+	Notify(ctx context.Context, user *database.User, msg *database.WeaselMessage) error
+}
+
+// permanentError marks a failure that retrying cannot fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as a failure that retrying cannot fix, such as a
+// rejected address; the worker gives up on the notification at once
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked by Permanent
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+// Outbox queues messages on the configured channels. It implements
+// weasel.Outbox.
+type Outbox struct {
+	channels []string
+}
+
+// NewOutbox creates an Outbox queueing on channels
+func NewOutbox(channels []string) *Outbox {
+	return &Outbox{channels: channels}
+}
+
+// Enqueue queues msg on every channel the user can be reached on, due when
+// the message is sent. Push needs a registered token and email a verified
+// address.
+func (o *Outbox) Enqueue(ctx context.Context, tx store.Store, user *database.User, msg *database.WeaselMessage) error {
+	for _, channel := range o.channels {
+		if !reachable(user, channel) {
+			continue
+		}
+		n := &database.Notification{
+			UserID:        user.ID,
+			MessageID:     msg.ID,
+			Channel:       channel,
+			Status:        database.NotificationPending,
+			NextAttemptAt: msg.SentAt,
+		}
+		if err := tx.Notifications().Create(ctx, n); err != nil {
+			return fmt.Errorf("failed to queue %s notification: %w", channel, err)
+		}
+	}
+	return nil
+}
+
+// reachable reports whether the user can receive notifications on channel
+func reachable(user *database.User, channel string) bool {
+	switch channel {
+	case database.ChannelPush:
+		return user.ExpoPushToken != ""
+	case database.ChannelEmail:
+		return user.Email != "" && user.EmailVerifiedAt != nil
+	default:
+		return true
+	}
+}
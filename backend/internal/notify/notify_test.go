@@ -0,0 +1,225 @@
+package notify_test
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/mail"
+	"github.com/lucas-albers-lz4/ferrovis/internal/notify"
+	"github.com/lucas-albers-lz4/ferrovis/internal/notify/notifytest"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+	"github.com/lucas-albers-lz4/ferrovis/internal/weasel"
+)
+
+const (
+	testToken  = "ExponentPushToken[sam]"
+	testSecret = "shh"
+)
+
+var sentAt = time.Date(2025, time.March, 10, 12, 0, 0, 0, time.UTC)
+
+// pipeline is a weasel engine queueing into a worker that delivers to a
+// fake receiver and a memory mailer
+type pipeline struct {
+	store    store.Store
+	engine   *weasel.Engine
+	worker   *notify.Worker
+	receiver *notifytest.Receiver
+	mailer   *mail.MemoryMailer
+	user     *database.User
+}
+
+func newPipeline(t *testing.T, cfg *notify.Config, user *database.User) *pipeline {
+	t.Helper()
+	ctx := context.Background()
+	p := &pipeline{store: store.NewMemory(), receiver: notifytest.NewReceiver(), mailer: mail.NewMemoryMailer(), user: user}
+	server := httptest.NewServer(p.receiver)
+	t.Cleanup(server.Close)
+
+	if err := p.store.Users().Create(ctx, user); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	streak := &database.Streak{UserID: user.ID, StreakType: database.StreakWorkout, Current: 4, Longest: 4, IsActive: true}
+	if err := p.store.Streaks().Save(ctx, streak); err != nil {
+		t.Fatalf("Failed to save streak: %v", err)
+	}
+
+	templates, err := weasel.ParseTemplates([]byte(`
+templates:
+  - {type: urgency, intensity: gentle, text: "{{.Streak}} day streak, {{.Name}}"}
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse templates: %v", err)
+	}
+	p.engine = weasel.NewEngine(p.store, templates, nil, notify.NewOutbox(cfg.Channels), nil, rand.New(rand.NewPCG(1, 2)))
+
+	cfg.ExpoURL = server.URL + notifytest.PushPath
+	cfg.WebhookURL = server.URL + "/webhook"
+	cfg.WebhookSecret = testSecret
+	notifiers, err := notify.Notifiers(cfg, p.mailer)
+	if err != nil {
+		t.Fatalf("Failed to create notifiers: %v", err)
+	}
+	p.worker = notify.NewWorker(p.store, notifiers, cfg)
+	return p
+}
+
+// send composes a message for the pipeline's user
+func (p *pipeline) send(t *testing.T) *database.WeaselMessage {
+	t.Helper()
+	msg, err := p.engine.Compose(context.Background(), weasel.Request{UserID: p.user.ID, Type: database.MessageUrgency, SentAt: sentAt})
+	if err != nil {
+		t.Fatalf("Failed to compose message: %v", err)
+	}
+	return msg
+}
+
+// deliver runs the worker at now and checks how many notifications it delivered
+func (p *pipeline) deliver(t *testing.T, now time.Time, expected int) {
+	t.Helper()
+	delivered, err := p.worker.Deliver(context.Background(), now)
+	if err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	if delivered != expected {
+		t.Errorf("Expected %d delivered at %v, got %d", expected, now, delivered)
+	}
+}
+
+// notification returns the outbox entry with id
+func (p *pipeline) notification(t *testing.T, id uint) *database.Notification {
+	t.Helper()
+	n, err := p.store.Notifications().Get(context.Background(), id)
+	if err != nil {
+		t.Fatalf("Failed to load notification: %v", err)
+	}
+	return n
+}
+
+func pushUser() *database.User {
+	return &database.User{Email: "sam@example.com", Name: "Sam", ExpoPushToken: testToken, WeaselIntensity: database.IntensityGentle}
+}
+
+func TestPipelineDeliversOnEveryChannel(t *testing.T) {
+	cfg := notify.DefaultConfig()
+	cfg.Channels = database.Channels
+	user := pushUser()
+	verified := sentAt.Add(-time.Hour)
+	user.EmailVerifiedAt = &verified
+	p := newPipeline(t, cfg, user)
+
+	msg := p.send(t)
+	p.deliver(t, sentAt.Add(-time.Second), 0)
+	p.deliver(t, sentAt, len(database.Channels))
+	p.deliver(t, sentAt.Add(time.Hour), 0)
+
+	requests := p.receiver.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("Expected a push and a webhook request, got %d", len(requests))
+	}
+	for _, req := range requests {
+		switch req.Path {
+		case notifytest.PushPath:
+			var push notify.ExpoMessage
+			if err := json.Unmarshal(req.Body, &push); err != nil {
+				t.Fatalf("Invalid push body: %v", err)
+			}
+			if push.To != testToken || push.Body != msg.Content {
+				t.Errorf("Expected %q pushed to %s, got %+v", msg.Content, testToken, push)
+			}
+		default:
+			if got := req.Header.Get(notify.SignatureHeader); got != notify.Sign(testSecret, req.Body) {
+				t.Errorf("Expected a valid webhook signature, got %q", got)
+			}
+		}
+	}
+
+	sent := p.mailer.Sent()
+	if len(sent) != 1 || sent[0].To != user.Email || sent[0].Body != msg.Content {
+		t.Errorf("Expected the message mailed to %s, got %+v", user.Email, sent)
+	}
+}
+
+func TestOutboxSkipsUnreachableChannels(t *testing.T) {
+	cfg := notify.DefaultConfig()
+	cfg.Channels = database.Channels
+	p := newPipeline(t, cfg, &database.User{Email: "sam@example.com", Name: "Sam", WeaselIntensity: database.IntensityGentle})
+
+	p.send(t)
+	p.deliver(t, sentAt, 1)
+	requests := p.receiver.Requests()
+	if len(requests) != 1 || requests[0].Path != "/webhook" {
+		t.Errorf("Expected only the webhook without a push token or verified email, got %+v", requests)
+	}
+	if sent := p.mailer.Sent(); len(sent) != 0 {
+		t.Errorf("Expected no email to an unverified address, got %d", len(sent))
+	}
+}
+
+func TestDeliverRetriesWithBackoff(t *testing.T) {
+	cfg := notify.DefaultConfig()
+	p := newPipeline(t, cfg, pushUser())
+
+	p.send(t)
+	p.receiver.FailNext(http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	p.deliver(t, sentAt, 0)
+	n := p.notification(t, 1)
+	if n.Status != database.NotificationPending || n.Attempts != 1 || !n.NextAttemptAt.Equal(sentAt.Add(cfg.BaseBackoff)) {
+		t.Fatalf("Expected a retry after %v, got %+v", cfg.BaseBackoff, n)
+	}
+
+	// The second failure doubles the wait
+	retry := n.NextAttemptAt
+	p.deliver(t, retry.Add(-time.Second), 0)
+	p.deliver(t, retry, 0)
+	if n = p.notification(t, 1); !n.NextAttemptAt.Equal(retry.Add(2 * cfg.BaseBackoff)) {
+		t.Fatalf("Expected the next retry after %v, got %v", 2*cfg.BaseBackoff, n.NextAttemptAt.Sub(retry))
+	}
+
+	p.deliver(t, n.NextAttemptAt, 1)
+	if n = p.notification(t, 1); n.Status != database.NotificationDelivered || n.Attempts != 3 || n.LastError != "" {
+		t.Errorf("Expected delivery on the third attempt, got %+v", n)
+	}
+}
+
+func TestDeliverGivesUp(t *testing.T) {
+	tests := []struct {
+		name     string
+		setup    func(p *pipeline, cfg *notify.Config)
+		attempts int
+	}{
+		{"unregistered device", func(p *pipeline, _ *notify.Config) {
+			p.receiver.Unregister(testToken)
+		}, 1},
+		{"rejected request", func(p *pipeline, _ *notify.Config) {
+			p.receiver.FailNext(http.StatusBadRequest)
+		}, 1},
+		{"attempts exhausted", func(p *pipeline, cfg *notify.Config) {
+			cfg.MaxAttempts = 2
+			p.receiver.FailNext(http.StatusInternalServerError, http.StatusInternalServerError)
+		}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := notify.DefaultConfig()
+			p := newPipeline(t, cfg, pushUser())
+			tt.setup(p, cfg)
+
+			p.send(t)
+			for now := sentAt; now.Before(sentAt.Add(time.Hour)); now = now.Add(cfg.BaseBackoff) {
+				p.deliver(t, now, 0)
+			}
+			n := p.notification(t, 1)
+			if n.Status != database.NotificationFailed || n.Attempts != tt.attempts || n.LastError == "" {
+				t.Errorf("Expected failure after %d attempts, got %+v", tt.attempts, n)
+			}
+		})
+	}
+}
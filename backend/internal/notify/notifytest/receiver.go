@@ -0,0 +1,124 @@
+// Package notifytest provides a fake HTTP receiver for the Expo push API
+// and notification webhooks, so the delivery pipeline can run offline in
+// tests and local development.
+package notifytest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/notify"
+)
+
+// PushPath is where the receiver answers like the Expo push API; every
+// other path answers like a webhook endpoint
+const PushPath = "/--/api/v2/push/send"
+
+// Request is a request the receiver recorded
+type Request struct {
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// Receiver records every request it serves. Failures can be injected to
+// exercise retries: queued status codes are answered first, and push
+// tokens marked unregistered get a DeviceNotRegistered ticket.
+type Receiver struct {
+	mu           sync.Mutex
+	requests     []Request
+	failures     []int
+	unregistered map[string]bool
+	tickets      int
+}
+
+// NewReceiver creates a Receiver that accepts everything
+func NewReceiver() *Receiver {
+	return &Receiver{unregistered: map[string]bool{}}
+}
+
+// FailNext answers the next requests with the given status codes, in order
+func (r *Receiver) FailNext(statuses ...int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures = append(r.failures, statuses...)
+}
+
+// Unregister makes pushes to token fail as DeviceNotRegistered
+func (r *Receiver) Unregister(token string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.unregistered[token] = true
+}
+
+// Requests returns a copy of every request recorded so far
+func (r *Receiver) Requests() []Request {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Request(nil), r.requests...)
+}
+
+// ServeHTTP records the request and answers it
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	r.mu.Lock()
+	r.requests = append(r.requests, Request{Path: req.URL.Path, Header: req.Header.Clone(), Body: body})
+	status := 0
+	if len(r.failures) > 0 {
+		status, r.failures = r.failures[0], r.failures[1:]
+	}
+	r.mu.Unlock()
+
+	slog.Info("Fake receiver got request", "path", req.URL.Path, "body", string(body))
+	if status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	if !strings.HasSuffix(req.URL.Path, PushPath) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+		return
+	}
+
+	var msg notify.ExpoMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{
+			"errors": []map[string]string{{"code": "VALIDATION_ERROR", "message": err.Error()}},
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"data": r.ticket(msg.To)})
+}
+
+// ticket issues the push ticket for a token
+func (r *Receiver) ticket(token string) notify.ExpoTicket {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.unregistered[token] {
+		return notify.ExpoTicket{
+			Status:  "error",
+			Message: fmt.Sprintf("%q is not a registered push notification recipient", token),
+			Details: &notify.ExpoTicketDetails{Error: notify.DeviceNotRegistered},
+		}
+	}
+	r.tickets++
+	return notify.ExpoTicket{Status: "ok", ID: fmt.Sprintf("ticket-%d", r.tickets)}
+}
+
+// writeJSON writes v as the JSON response body
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Warn("Fake receiver failed to write response", "error", err)
+	}
+}
@@ -0,0 +1,71 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+)
+
+// SignatureHeader carries the HMAC-SHA256 of a webhook body, as "sha256=<hex>"
+const SignatureHeader = "X-Ferrovis-Signature"
+
+// WebhookPayload is the JSON body posted for each message
+type WebhookPayload struct {
+	UserID      uint      `json:"user_id"`
+	MessageID   uint      `json:"message_id"`
+	MessageType string    `json:"message_type"`
+	Intensity   string    `json:"intensity"`
+	Content     string    `json:"content"`
+	SentAt      time.Time `json:"sent_at"`
+}
+
+// Webhook posts messages to an HTTP endpoint
+type Webhook struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+// NewWebhook creates a Webhook notifier posting to url. Bodies are signed
+// with secret when it is set.
+func NewWebhook(url, secret string, client *http.Client) *Webhook {
+	return &Webhook{url: url, secret: secret, client: client}
+}
+
+// Notify posts msg to the endpoint
+func (w *Webhook) Notify(ctx context.Context, user *database.User, msg *database.WeaselMessage) error {
+	body, err := json.Marshal(WebhookPayload{
+		UserID:      user.ID,
+		MessageID:   msg.ID,
+		MessageType: msg.MessageType,
+		Intensity:   msg.Intensity,
+		Content:     msg.Content,
+		SentAt:      msg.SentAt,
+	})
+	if err != nil {
+		return Permanent(fmt.Errorf("failed to encode webhook payload: %w", err))
+	}
+	header := http.Header{}
+	if w.secret != "" {
+		header.Set(SignatureHeader, Sign(w.secret, body))
+	}
+
+	if _, err := post(ctx, w.client, w.url, body, header); err != nil {
+		return fmt.Errorf("failed to call webhook: %w", err)
+	}
+	return nil
+}
+
+// Sign returns the signature header value of body under secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write(body) //nolint:errcheck // hash writes never fail
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,115 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+)
+
+// errNoNotifier fails notifications queued on a channel that is no longer configured
+var errNoNotifier = errors.New("no notifier for channel")
+
+// Worker delivers queued notifications
+type Worker struct {
+	store     store.Store
+	notifiers map[string]Notifier
+	cfg       *Config
+}
+
+// NewWorker creates a Worker delivering through notifiers, keyed by channel
+func NewWorker(s store.Store, notifiers map[string]Notifier, cfg *Config) *Worker {
+	return &Worker{store: s, notifiers: notifiers, cfg: cfg}
+}
+
+// Run calls Deliver every configured interval until ctx is done
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			delivered, err := w.Deliver(ctx, now)
+			if err != nil {
+				slog.Error("Notification delivery failed", "error", err)
+			}
+			if delivered > 0 {
+				slog.Info("Notifications delivered", "count", delivered)
+			}
+		}
+	}
+}
+
+// Deliver attempts every notification due at now and returns how many were
+// delivered. A failed delivery is rescheduled or given up on; only store
+// errors are returned.
+func (w *Worker) Deliver(ctx context.Context, now time.Time) (int, error) {
+	delivered := 0
+	for {
+		batch, err := w.store.Notifications().Claim(ctx, now, w.cfg.BatchSize, w.cfg.Lease)
+		if err != nil {
+			return delivered, fmt.Errorf("failed to claim notifications: %w", err)
+		}
+		for i := range batch {
+			ok, err := w.attempt(ctx, &batch[i], now)
+			if err != nil {
+				return delivered, err
+			}
+			if ok {
+				delivered++
+			}
+		}
+		if len(batch) < w.cfg.BatchSize {
+			return delivered, nil
+		}
+	}
+}
+
+// attempt delivers one notification and records the outcome, reporting
+// whether it was delivered
+func (w *Worker) attempt(ctx context.Context, n *database.Notification, now time.Time) (bool, error) {
+	err := errNoNotifier
+	if notifier, ok := w.notifiers[n.Channel]; ok {
+		err = notifier.Notify(ctx, &n.User, &n.Message)
+	}
+	n.Attempts++
+
+	switch {
+	case err == nil:
+		n.Status = database.NotificationDelivered
+		n.DeliveredAt = &now
+		n.LastError = ""
+	case IsPermanent(err) || errors.Is(err, errNoNotifier) || n.Attempts >= w.cfg.MaxAttempts:
+		n.Status = database.NotificationFailed
+		n.LastError = err.Error()
+		slog.Warn("Giving up on notification", "notification_id", n.ID, "channel", n.Channel, "attempts", n.Attempts, "error", err)
+	default:
+		n.NextAttemptAt = now.Add(w.backoff(n.Attempts))
+		n.LastError = err.Error()
+	}
+
+	if err := w.store.Notifications().Update(ctx, n); err != nil {
+		return false, fmt.Errorf("failed to record notification attempt: %w", err)
+	}
+	return n.Status == database.NotificationDelivered, nil
+}
+
+// backoff returns the wait after the given number of failed attempts:
+// BaseBackoff doubled for every attempt after the first, capped at MaxBackoff
+func (w *Worker) backoff(attempts int) time.Duration {
+	d := w.cfg.BaseBackoff
+	for range attempts - 1 {
+		if d >= w.cfg.MaxBackoff/2 {
+			return w.cfg.MaxBackoff
+		}
+		d *= 2
+	}
+	return min(d, w.cfg.MaxBackoff)
+}
@@ -0,0 +1,69 @@
+package nudge
+
+import (
+	"math/rand/v2"
+	"time"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/env"
+)
+
+const (
+	defaultInterval = 5 * time.Minute
+	defaultDailyCap = 3
+	defaultRestDays = 1
+)
+
+// Step is one point of the schedule: Probability is the chance a nudge is
+// sent once After has passed since the workout was missed
+type Step struct {
+	After       time.Duration
+	Probability float64
+}
+
+// Config controls when and how often users are nudged
+type Config struct {
+	// Steps are the nudge points, in increasing order of After
+	Steps []Step
+	// Repeat adds a step this long after the previous one, with the last
+	// step's probability, until the user works out; zero stops after Steps
+	Repeat time.Duration
+	// RestDays is how many days may pass between workouts before one counts
+	// as missed
+	RestDays int
+	// DailyCap limits the messages a user receives per calendar day
+	DailyCap int
+	// Interval is how often the scheduler runs
+	Interval time.Duration
+	// Seed makes the coin flips reproducible
+	Seed uint64
+}
+
+// DefaultConfig returns the variable-ratio schedule from the feature plan
+// with a random seed
+func DefaultConfig() *Config {
+	return &Config{
+		Steps: []Step{
+			{After: 2 * time.Hour, Probability: 0.3},
+			{After: 6 * time.Hour, Probability: 0.7},
+			{After: 24 * time.Hour, Probability: 0.9},
+			{After: 48 * time.Hour, Probability: 1.0},
+		},
+		Repeat:   24 * time.Hour,
+		RestDays: defaultRestDays,
+		DailyCap: defaultDailyCap,
+		Interval: defaultInterval,
+		Seed:     rand.Uint64(), //nolint:gosec // scheduling jitter, not security
+	}
+}
+
+// LoadConfig loads the default schedule, overriding the run interval from
+// NUDGE_INTERVAL, the daily cap from NUDGE_DAILY_CAP, the rest days from
+// NUDGE_REST_DAYS and the seed from NUDGE_SEED
+func LoadConfig() *Config {
+	cfg := DefaultConfig()
+	cfg.Interval = env.Duration("NUDGE_INTERVAL", cfg.Interval)
+	cfg.DailyCap = env.Int("NUDGE_DAILY_CAP", cfg.DailyCap, 0)
+	cfg.RestDays = env.Int("NUDGE_REST_DAYS", cfg.RestDays, 0)
+	cfg.Seed = env.Uint64("NUDGE_SEED", cfg.Seed)
+	return cfg
+}
@@ -0,0 +1,228 @@
+package nudge
+
+import (
+	"context"
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+	"github.com/lucas-albers-lz4/ferrovis/internal/weasel"
+)
+
+// lastWorkout is a Monday evening; with one rest day the next workout is
+// missed on Wednesday at 22:00
+var (
+	lastWorkout = time.Date(2025, time.March, 3, 19, 0, 0, 0, time.UTC)
+	missed      = time.Date(2025, time.March, 5, 22, 0, 0, 0, time.UTC)
+)
+
+func testConfig() *Config {
+	cfg := DefaultConfig()
+	cfg.Seed = 42
+	return cfg
+}
+
+// newTestScheduler returns a scheduler over a memory store holding one
+// evening user whose last workout was lastWorkout
+func newTestScheduler(t *testing.T, cfg *Config) (*Scheduler, store.Store, *database.User) {
+	t.Helper()
+	ctx := context.Background()
+	s := store.NewMemory()
+
+	user := &database.User{
+		Email:                "sam@example.com",
+		Name:                 "Sam",
+		WeaselIntensity:      database.IntensityFullChaos,
+		PreferredWorkoutTime: database.WorkoutTimeEvening,
+	}
+	if err := s.Users().Create(ctx, user); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	if err := s.Workouts().Create(ctx, &database.Workout{UserID: user.ID, CompletedAt: lastWorkout}); err != nil {
+		t.Fatalf("Failed to log workout: %v", err)
+	}
+
+	templates, err := weasel.Builtin()
+	if err != nil {
+		t.Fatalf("Failed to load templates: %v", err)
+	}
+	engine := weasel.NewEngine(s, templates, nil, nil, nil, rand.New(rand.NewPCG(1, 2)))
+	return NewScheduler(s, engine, cfg), s, user
+}
+
+// runHourly ticks every hour from start for the given number of hours
+func runHourly(t *testing.T, sched *Scheduler, start time.Time, hours int) {
+	t.Helper()
+	for h := range hours {
+		if _, err := sched.Tick(context.Background(), start.Add(time.Duration(h)*time.Hour)); err != nil {
+			t.Fatalf("Tick failed: %v", err)
+		}
+	}
+}
+
+func TestDueStep(t *testing.T) {
+	sched := &Scheduler{cfg: testConfig()}
+	tests := []struct {
+		elapsed  time.Duration
+		expected int
+	}{
+		{time.Hour, -1},
+		{2 * time.Hour, 0},
+		{5 * time.Hour, 0},
+		{6 * time.Hour, 1},
+		{24 * time.Hour, 2},
+		{47 * time.Hour, 2},
+		{48 * time.Hour, 3},
+		{72 * time.Hour, 4},
+		{99 * time.Hour, 5},
+	}
+
+	for _, tt := range tests {
+		if step := sched.dueStep(tt.elapsed); step != tt.expected {
+			t.Errorf("Expected step %d after %v, got %d", tt.expected, tt.elapsed, step)
+		}
+	}
+}
+
+func TestQuietHours(t *testing.T) {
+	tests := []struct {
+		preferred string
+		hour      int
+		expected  bool
+	}{
+		{database.WorkoutTimeMorning, 21, true},
+		{database.WorkoutTimeMorning, 5, false},
+		{database.WorkoutTimeEvening, 22, false},
+		{database.WorkoutTimeEvening, 3, true},
+		{database.WorkoutTimeEvening, 8, false},
+		{"", 23, true},
+		{"", 12, false},
+	}
+
+	for _, tt := range tests {
+		if quiet := routineFor(tt.preferred).quiet(tt.hour); quiet != tt.expected {
+			t.Errorf("Expected quiet=%v at %d:00 for %q, got %v", tt.expected, tt.hour, tt.preferred, quiet)
+		}
+	}
+}
+
+func TestTickIsDeterministic(t *testing.T) {
+	run := func() []database.WeaselMessage {
+		sched, s, user := newTestScheduler(t, testConfig())
+		runHourly(t, sched, missed, 5*hoursPerDay)
+		msgs, err := s.Messages().Since(context.Background(), user.ID, missed)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return msgs
+	}
+
+	first, second := run(), run()
+	if len(first) == 0 || len(first) != len(second) {
+		t.Fatalf("Expected the same non-empty nudges on both runs, got %d and %d", len(first), len(second))
+	}
+	for i := range first {
+		if !first[i].SentAt.Equal(second[i].SentAt) || *first[i].NudgeStep != *second[i].NudgeStep || first[i].Content != second[i].Content {
+			t.Errorf("Run differs at nudge %d: %+v vs %+v", i, first[i], second[i])
+		}
+	}
+}
+
+func TestTickFollowsSchedule(t *testing.T) {
+	sched, s, user := newTestScheduler(t, testConfig())
+	runHourly(t, sched, missed, 5*hoursPerDay)
+
+	msgs, err := s.Messages().Since(context.Background(), user.ID, missed)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	perDay := map[int]int{}
+	steps := map[int]bool{}
+	for i, msg := range msgs {
+		if routineFor(user.PreferredWorkoutTime).quiet(msg.SentAt.Hour()) {
+			t.Errorf("Nudge sent during quiet hours at %v", msg.SentAt)
+		}
+		perDay[msg.SentAt.YearDay()]++
+		steps[*msg.NudgeStep] = true
+
+		// Intensity never drops and follows the days since the miss
+		days := int(msg.SentAt.Sub(missed) / (hoursPerDay * time.Hour))
+		limit := min(days, len(database.WeaselIntensities)-1)
+		if got := slices.Index(database.WeaselIntensities, msg.Intensity); got > limit {
+			t.Errorf("Expected at most %s on day %d, got %s", database.WeaselIntensities[limit], days, msg.Intensity)
+		}
+		if i > 0 && *msg.NudgeStep <= *msgs[i-1].NudgeStep {
+			t.Errorf("Expected steps to increase, got %d after %d", *msg.NudgeStep, *msgs[i-1].NudgeStep)
+		}
+	}
+	for day, count := range perDay {
+		if count > sched.cfg.DailyCap {
+			t.Errorf("Expected at most %d nudges on day %d, got %d", sched.cfg.DailyCap, day, count)
+		}
+	}
+	// The two-day step always fires, on Friday at 22:00
+	if !steps[3] {
+		t.Errorf("Expected the certain step to be sent, got steps %v", steps)
+	}
+}
+
+func TestTickStopsAfterWorkout(t *testing.T) {
+	ctx := context.Background()
+	sched, s, user := newTestScheduler(t, testConfig())
+
+	// Working out on Wednesday pushes the next miss to Friday
+	if err := s.Workouts().Create(ctx, &database.Workout{UserID: user.ID, CompletedAt: missed.Add(-time.Hour)}); err != nil {
+		t.Fatalf("Failed to log workout: %v", err)
+	}
+	runHourly(t, sched, missed, 2*hoursPerDay)
+
+	msgs, err := s.Messages().Since(ctx, user.ID, missed)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(msgs) != 0 {
+		t.Errorf("Expected no nudges before the next miss, got %d", len(msgs))
+	}
+}
+
+func TestTickRespectsDailyCap(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig()
+	cfg.DailyCap = 1
+	sched, s, user := newTestScheduler(t, cfg)
+
+	// A message earlier on Friday uses up the cap before the certain step
+	friday := missed.Add(2 * hoursPerDay * time.Hour)
+	earlier := &database.WeaselMessage{UserID: user.ID, MessageType: database.MessageFunny, Content: "hi", SentAt: friday.Add(-2 * time.Hour)}
+	if err := s.Messages().Create(ctx, earlier); err != nil {
+		t.Fatalf("Failed to store message: %v", err)
+	}
+
+	sent, err := sched.Tick(ctx, friday)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if sent != 0 {
+		t.Errorf("Expected the cap to hold back the nudge, got %d sent", sent)
+	}
+}
+
+func TestTickSkipsDisabledUsers(t *testing.T) {
+	ctx := context.Background()
+	sched, s, user := newTestScheduler(t, testConfig())
+	if err := s.Users().Update(ctx, user.ID, map[string]any{"weasel_mode_enabled": false}); err != nil {
+		t.Fatalf("Failed to update user: %v", err)
+	}
+
+	sent, err := sched.Tick(ctx, missed.Add(2*hoursPerDay*time.Hour))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if sent != 0 {
+		t.Errorf("Expected no nudges with Weasel Mode off, got %d", sent)
+	}
+}
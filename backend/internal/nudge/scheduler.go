@@ -0,0 +1,262 @@
+// Package nudge sends Weasel messages to users who missed a workout.
+//
+// The schedule is variable-ratio: once a workout counts as missed, each step
+// of the schedule sends a nudge with the step's probability, so users never
+// know which check-in will actually arrive. Intensity escalates with every
+// day since the miss, but never beyond the user's WeaselIntensity.
+//
+// A workout counts as missed at the end of the user's preferred workout
+// time on the first day after their rest days. Nothing is sent during the
+// quiet hours that go with that preferred time; a due nudge waits until they
+// end. Each user gets at most DailyCap messages per calendar day, counted in
+// their time zone.
+//
+// Coin flips are derived from the seed, the user and the step, so a step
+// that lost its flip stays lost on later runs, restarts included.
+package nudge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+	"github.com/lucas-albers-lz4/ferrovis/internal/weasel"
+)
+
+const (
+	hoursPerDay = 24
+	userBatch   = 100
+
+	// Separates the step from the miss time when seeding a coin flip
+	stepsPerMiss = 1 << 16
+)
+
+// routine is what the scheduler assumes about a preferred workout time
+type routine struct {
+	// deadline is the local hour after which the day's workout is missed
+	deadline int
+	// quietFrom and quietUntil bound the local hours without nudges
+	quietFrom, quietUntil int
+}
+
+var (
+	routines = map[string]routine{
+		database.WorkoutTimeMorning:   {deadline: 12, quietFrom: 21, quietUntil: 5},
+		database.WorkoutTimeAfternoon: {deadline: 17, quietFrom: 22, quietUntil: 7},
+		database.WorkoutTimeEvening:   {deadline: 22, quietFrom: 23, quietUntil: 8},
+	}
+	// defaultRoutine applies to users without a preferred time
+	defaultRoutine = routine{deadline: 20, quietFrom: 22, quietUntil: 7}
+)
+
+// routineFor returns the routine of a preferred workout time
+func routineFor(preferred string) routine {
+	if r, ok := routines[preferred]; ok {
+		return r
+	}
+	return defaultRoutine
+}
+
+// quiet reports whether hour falls in the quiet hours, which may wrap
+// around midnight
+func (r routine) quiet(hour int) bool {
+	if r.quietFrom <= r.quietUntil {
+		return hour >= r.quietFrom && hour < r.quietUntil
+	}
+	return hour >= r.quietFrom || hour < r.quietUntil
+}
+
+// Scheduler decides who is due a nudge and sends it through the weasel engine
+type Scheduler struct {
+	store  store.Store
+	engine *weasel.Engine
+	cfg    *Config
+}
+
+// NewScheduler creates a Scheduler
+func NewScheduler(s store.Store, engine *weasel.Engine, cfg *Config) *Scheduler {
+	return &Scheduler{store: s, engine: engine, cfg: cfg}
+}
+
+// Run calls Tick every configured interval until ctx is done
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			sent, err := s.Tick(ctx, now)
+			if err != nil {
+				slog.Error("Nudge run failed", "error", err)
+			}
+			if sent > 0 {
+				slog.Info("Nudges sent", "count", sent)
+			}
+		}
+	}
+}
+
+// Tick sends the nudges due at now to users with Weasel Mode on and returns
+// how many were sent. A failure for one user is logged and does not stop
+// the others.
+func (s *Scheduler) Tick(ctx context.Context, now time.Time) (int, error) {
+	sent := 0
+	var afterID uint
+	for {
+		users, err := s.store.Users().ListWeaselEnabled(ctx, afterID, userBatch)
+		if err != nil {
+			return sent, fmt.Errorf("failed to load users: %w", err)
+		}
+		for i := range users {
+			ok, err := s.nudge(ctx, &users[i], now)
+			if err != nil {
+				slog.Warn("Failed to nudge user", "user_id", users[i].ID, "error", err)
+				continue
+			}
+			if ok {
+				sent++
+			}
+		}
+		if len(users) < userBatch {
+			return sent, nil
+		}
+		afterID = users[len(users)-1].ID
+	}
+}
+
+// nudge sends the user's due nudge, reporting whether one was sent
+func (s *Scheduler) nudge(ctx context.Context, user *database.User, now time.Time) (bool, error) {
+	loc := user.Location()
+	local := now.In(loc)
+	r := routineFor(user.PreferredWorkoutTime)
+	if r.quiet(local.Hour()) {
+		return false, nil
+	}
+
+	missed, err := s.missedAt(ctx, user, r)
+	if err != nil {
+		return false, err
+	}
+	due := s.dueStep(now.Sub(missed))
+	if due < 0 {
+		return false, nil
+	}
+
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	msgs, err := s.store.Messages().Since(ctx, user.ID, earliest(midnight, missed))
+	if err != nil {
+		return false, fmt.Errorf("failed to load messages: %w", err)
+	}
+	today, delivered := 0, -1
+	for _, msg := range msgs {
+		if !msg.SentAt.Before(midnight) {
+			today++
+		}
+		if msg.NudgeStep != nil && !msg.SentAt.Before(missed) {
+			delivered = max(delivered, *msg.NudgeStep)
+		}
+	}
+	if today >= s.cfg.DailyCap {
+		return false, nil
+	}
+
+	// After downtime or quiet hours only the latest winning step is sent
+	for step := due; step > delivered; step-- {
+		rng := s.flip(user.ID, missed, step)
+		if rng.Float64() < s.probability(step) {
+			return s.send(ctx, user, step, now.Sub(missed), now, rng)
+		}
+	}
+	return false, nil
+}
+
+// missedAt returns when the workout following the user's last one counts
+// as missed. Users who never worked out are measured from sign-up.
+func (s *Scheduler) missedAt(ctx context.Context, user *database.User, r routine) (time.Time, error) {
+	last := user.CreatedAt
+	recent, _, err := s.store.Workouts().List(ctx, user.ID, store.Page{Limit: 1})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to load workouts: %w", err)
+	}
+	if len(recent) > 0 {
+		last = recent[0].CompletedAt
+	}
+
+	loc := user.Location()
+	y, m, d := last.In(loc).Date()
+	return time.Date(y, m, d+s.cfg.RestDays+1, r.deadline, 0, 0, 0, loc), nil
+}
+
+// dueStep returns the latest step reached elapsed after the miss, or -1
+// before the first
+func (s *Scheduler) dueStep(elapsed time.Duration) int {
+	due := -1
+	for i, step := range s.cfg.Steps {
+		if elapsed >= step.After {
+			due = i
+		}
+	}
+	last := len(s.cfg.Steps) - 1
+	if last >= 0 && due == last && s.cfg.Repeat > 0 {
+		due += int((elapsed - s.cfg.Steps[last].After) / s.cfg.Repeat)
+	}
+	return due
+}
+
+// probability returns the chance that step sends a nudge; repeated steps
+// share the last step's
+func (s *Scheduler) probability(step int) float64 {
+	return s.cfg.Steps[min(step, len(s.cfg.Steps)-1)].Probability
+}
+
+// flip returns the random source deciding a step for a miss
+func (s *Scheduler) flip(userID uint, missed time.Time, step int) *rand.Rand {
+	stream := uint64(missed.Unix()*stepsPerMiss + int64(step))      //nolint:gosec // only seeds the flip, wrapping is harmless
+	return rand.New(rand.NewPCG(s.cfg.Seed^uint64(userID), stream)) //nolint:gosec // scheduling, not security
+}
+
+// send composes a nudge for step, trying message types in random order
+// until one is allowed and has a template
+func (s *Scheduler) send(ctx context.Context, user *database.User, step int, elapsed time.Duration, now time.Time, rng *rand.Rand) (bool, error) {
+	days := int(elapsed / (hoursPerDay * time.Hour))
+	escalation := database.WeaselIntensities[min(days, len(database.WeaselIntensities)-1)]
+
+	types := slices.Clone(database.MessageTypes)
+	rng.Shuffle(len(types), func(i, j int) { types[i], types[j] = types[j], types[i] })
+	for _, messageType := range types {
+		_, err := s.engine.Compose(ctx, weasel.Request{
+			UserID:       user.ID,
+			Type:         messageType,
+			MaxIntensity: escalation,
+			NudgeStep:    &step,
+			SentAt:       now,
+		})
+		switch {
+		case err == nil:
+			return true, nil
+		case errors.Is(err, weasel.ErrDisabled):
+			return false, nil
+		case errors.Is(err, weasel.ErrNotAllowed), errors.Is(err, weasel.ErrNoTemplate):
+		default:
+			return false, fmt.Errorf("failed to send nudge: %w", err)
+		}
+	}
+	return false, nil
+}
+
+// earliest returns the earlier of two times
+func earliest(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,95 @@
+package programdef
+
+import "strings"
+
+// Built-in program names as seeded into the programs table
+const (
+	StartingStrengthName = "Starting Strength"
+	StrongLiftsName      = "StrongLifts 5x5"
+)
+
+// Default progression shared by the built-in novice programs
+const (
+	standardDaysPerWeek   = 3
+	standardRoundTo       = 5
+	standardDeloadAfter   = 3
+	standardDeloadPercent = 10
+	upperBodyIncrement    = 5
+	lowerBodyIncrement    = 10
+	emptyBarWeight        = 45
+	deadliftStartWeight   = 95
+	rowStartWeight        = 65
+)
+
+// StartingStrength returns the novice phase of Starting Strength:
+// squat every session, alternating bench and press, deadlift for one set of five
+func StartingStrength() *Definition {
+	return &Definition{
+		Version:     CurrentVersion,
+		DaysPerWeek: standardDaysPerWeek,
+		Units:       "lb",
+		RoundTo:     standardRoundTo,
+		Days: []Day{
+			{Name: "A", Slots: []Slot{
+				{Exercise: "Squat", Scheme: "3x5"},
+				{Exercise: "Bench Press", Scheme: "3x5"},
+				{Exercise: "Deadlift", Scheme: "1x5"},
+			}},
+			{Name: "B", Slots: []Slot{
+				{Exercise: "Squat", Scheme: "3x5"},
+				{Exercise: "Overhead Press", Scheme: "3x5"},
+				{Exercise: "Deadlift", Scheme: "1x5"},
+			}},
+		},
+		Progression: Progression{
+			Default: LiftRule{Increment: upperBodyIncrement, StartWeight: emptyBarWeight, MinWeight: emptyBarWeight},
+			Lifts: map[string]LiftRule{
+				"Squat":    {Increment: lowerBodyIncrement},
+				"Deadlift": {Increment: lowerBodyIncrement, StartWeight: deadliftStartWeight},
+			},
+		},
+		Deload: &Deload{AfterFailures: standardDeloadAfter, Percent: standardDeloadPercent},
+	}
+}
+
+// StrongLifts returns the StrongLifts 5x5 A/B rotation
+func StrongLifts() *Definition {
+	return &Definition{
+		Version:     CurrentVersion,
+		DaysPerWeek: standardDaysPerWeek,
+		Units:       "lb",
+		RoundTo:     standardRoundTo,
+		Days: []Day{
+			{Name: "A", Slots: []Slot{
+				{Exercise: "Squat", Scheme: "5x5"},
+				{Exercise: "Bench Press", Scheme: "5x5"},
+				{Exercise: "Barbell Row", Scheme: "5x5"},
+			}},
+			{Name: "B", Slots: []Slot{
+				{Exercise: "Squat", Scheme: "5x5"},
+				{Exercise: "Overhead Press", Scheme: "5x5"},
+				{Exercise: "Deadlift", Scheme: "1x5"},
+			}},
+		},
+		Progression: Progression{
+			Default: LiftRule{Increment: upperBodyIncrement, StartWeight: emptyBarWeight, MinWeight: emptyBarWeight},
+			Lifts: map[string]LiftRule{
+				"Barbell Row": {StartWeight: rowStartWeight},
+				"Deadlift":    {Increment: lowerBodyIncrement, StartWeight: deadliftStartWeight},
+			},
+		},
+		Deload: &Deload{AfterFailures: standardDeloadAfter, Percent: standardDeloadPercent},
+	}
+}
+
+// Builtin returns the built-in definition for a program name
+func Builtin(name string) (*Definition, bool) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case strings.ToLower(StartingStrengthName):
+		return StartingStrength(), true
+	case strings.ToLower(StrongLiftsName):
+		return StrongLifts(), true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,238 @@
+// Package programdef defines the versioned schema stored in Program.Structure.
+// A definition lists the training days of a program, the exercise slots and
+// set/rep schemes on each day, how each lift progresses and when to deload.
+//
+// Definitions are JSON documents. Slots either spell out "sets" and "reps"
+// or use the compact "scheme" shorthand such as "5x5":
+//
+//	{
+//	  "version": 1,
+//	  "days_per_week": 3,
+//	  "days": [
+//	    {"name": "A", "slots": [{"exercise": "Squat", "scheme": "5x5"}]},
+//	    {"name": "B", "slots": [{"exercise": "Deadlift", "sets": 1, "reps": 5}]}
+//	  ],
+//	  "progression": {"default": {"increment": 5}, "lifts": {"Deadlift": {"increment": 10}}},
+//	  "deload": {"after_failures": 3, "percent": 10}
+//	}
+package programdef
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/progression"
+)
+
+// CurrentVersion is the schema version written by this package
+const CurrentVersion = 1
+
+// Definition is a complete program definition
+type Definition struct {
+	Version     int         `json:"version"`
+	DaysPerWeek int         `json:"days_per_week,omitempty"`
+	Units       string      `json:"units,omitempty"`    // lb (default) or kg
+	RoundTo     float64     `json:"round_to,omitempty"` // Smallest loadable step
+	Days        []Day       `json:"days"`
+	Progression Progression `json:"progression"`
+	Deload      *Deload     `json:"deload,omitempty"`
+}
+
+// Day is one training day in the rotation
+type Day struct {
+	Name        string `json:"name"`
+	Slots       []Slot `json:"slots"`
+	Accessories []Slot `json:"accessories,omitempty"` // Optional work that does not drive progression
+}
+
+// Slot prescribes an exercise with a set/rep scheme
+type Slot struct {
+	Exercise string `json:"exercise"`
+	Scheme   string `json:"scheme,omitempty"` // Shorthand for sets x reps, e.g. "5x5"
+	Sets     int    `json:"sets,omitempty"`
+	Reps     int    `json:"reps,omitempty"`
+}
+
+// Progression holds the default lift rule and per-lift overrides
+type Progression struct {
+	Default LiftRule            `json:"default"`
+	Lifts   map[string]LiftRule `json:"lifts,omitempty"`
+}
+
+// LiftRule controls linear progression of a lift
+type LiftRule struct {
+	Increment   float64 `json:"increment,omitempty"`
+	StartWeight float64 `json:"start_weight,omitempty"`
+	MinWeight   float64 `json:"min_weight,omitempty"`
+	Deload      *Deload `json:"deload,omitempty"` // Overrides the program deload rule
+}
+
+// Deload describes when and how far to drop weight after repeated failures
+type Deload struct {
+	AfterFailures int     `json:"after_failures"`
+	Percent       float64 `json:"percent"` // Percentage points, e.g. 10 for 10%
+}
+
+// Parse decodes and validates a definition. Unknown fields are rejected so
+// typos in hand-written definitions surface as errors instead of defaults.
+func Parse(raw []byte) (*Definition, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+
+	var d Definition
+	if err := dec.Decode(&d); err != nil {
+		return nil, fmt.Errorf("invalid program definition: %w", err)
+	}
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// JSON encodes the definition for storage in Program.Structure
+func (d *Definition) JSON() (string, error) {
+	out, err := json.Marshal(d)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode program definition: %w", err)
+	}
+	return string(out), nil
+}
+
+// Exercises returns every exercise referenced by the definition, in order of first use
+func (d *Definition) Exercises() []string {
+	seen := make(map[string]bool)
+	var out []string
+	add := func(name string) {
+		k := strings.ToLower(name)
+		if name != "" && !seen[k] {
+			seen[k] = true
+			out = append(out, name)
+		}
+	}
+	for _, day := range d.Days {
+		for _, s := range day.Slots {
+			add(s.Exercise)
+		}
+		for _, s := range day.Accessories {
+			add(s.Exercise)
+		}
+	}
+	return out
+}
+
+// SessionsPerWeek returns the planned sessions per week, defaulting to one
+// pass through the rotation
+func (d *Definition) SessionsPerWeek() int {
+	if d.DaysPerWeek > 0 {
+		return d.DaysPerWeek
+	}
+	return len(d.Days)
+}
+
+// Unit returns the unit of the definition's weights, defaulting to pounds
+func (d *Definition) Unit() string {
+	if d.Units == "" {
+		return "lb"
+	}
+	return d.Units
+}
+
+// Plan converts the definition into progression engine rules. Weights stay
+// in the definition's Unit, so history must be converted to it.
+func (d *Definition) Plan() progression.Plan {
+	plan := progression.Plan{
+		RoundTo: d.RoundTo,
+		Lifts:   make(map[string]progression.LiftRule),
+	}
+
+	for _, day := range d.Days {
+		pd := progression.Day{Name: day.Name}
+		for _, s := range day.Slots {
+			sets, reps := s.SetsReps()
+			pd.Slots = append(pd.Slots, progression.Slot{Exercise: s.Exercise, Sets: sets, Reps: reps})
+			if _, ok := plan.Lifts[s.Exercise]; !ok {
+				plan.Lifts[s.Exercise] = d.liftRule(s.Exercise)
+			}
+		}
+		plan.Days = append(plan.Days, pd)
+	}
+	return plan
+}
+
+// liftRule merges the default rule, the lift override and the deload rule
+func (d *Definition) liftRule(exercise string) progression.LiftRule {
+	rule := d.Progression.Default
+	if override, ok := d.lookupLift(exercise); ok {
+		if override.Increment > 0 {
+			rule.Increment = override.Increment
+		}
+		if override.StartWeight > 0 {
+			rule.StartWeight = override.StartWeight
+		}
+		if override.MinWeight > 0 {
+			rule.MinWeight = override.MinWeight
+		}
+		if override.Deload != nil {
+			rule.Deload = override.Deload
+		}
+	}
+
+	deload := rule.Deload
+	if deload == nil {
+		deload = d.Deload
+	}
+
+	out := progression.LiftRule{
+		StartWeight: rule.StartWeight,
+		Increment:   rule.Increment,
+		MinWeight:   rule.MinWeight,
+	}
+	if deload != nil {
+		out.DeloadAfter = deload.AfterFailures
+		out.DeloadPercent = deload.Percent / 100
+	}
+	return out
+}
+
+// lookupLift finds a per-lift override by case-insensitive name
+func (d *Definition) lookupLift(exercise string) (LiftRule, bool) {
+	if r, ok := d.Progression.Lifts[exercise]; ok {
+		return r, true
+	}
+	for name, r := range d.Progression.Lifts {
+		if strings.EqualFold(name, exercise) {
+			return r, true
+		}
+	}
+	return LiftRule{}, false
+}
+
+// SetsReps returns the slot's sets and reps, expanding the scheme shorthand
+func (s *Slot) SetsReps() (sets, reps int) {
+	if s.Scheme != "" {
+		if sets, reps, err := parseScheme(s.Scheme); err == nil {
+			return sets, reps
+		}
+	}
+	return s.Sets, s.Reps
+}
+
+// parseScheme parses "SETSxREPS" (case-insensitive, optional spaces)
+func parseScheme(scheme string) (sets, reps int, err error) {
+	left, right, ok := strings.Cut(strings.ToLower(strings.ReplaceAll(scheme, " ", "")), "x")
+	if !ok {
+		return 0, 0, fmt.Errorf("scheme %q must look like 5x5", scheme)
+	}
+	sets, err = strconv.Atoi(left)
+	if err != nil {
+		return 0, 0, fmt.Errorf("scheme %q has invalid sets", scheme)
+	}
+	reps, err = strconv.Atoi(right)
+	if err != nil {
+		return 0, 0, fmt.Errorf("scheme %q has invalid reps", scheme)
+	}
+	return sets, reps, nil
+}
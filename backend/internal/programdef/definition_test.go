@@ -0,0 +1,214 @@
+package programdef
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const validDefinition = `{
+	"version": 1,
+	"days_per_week": 3,
+	"days": [
+		{"name": "A", "slots": [{"exercise": "Squat", "scheme": "5x5"}, {"exercise": "Bench Press", "sets": 5, "reps": 5}]},
+		{"name": "B", "slots": [{"exercise": "Squat", "scheme": "5x5"}, {"exercise": "Deadlift", "scheme": "1x5"}],
+		 "accessories": [{"exercise": "Chin-up", "scheme": "3x8"}]}
+	],
+	"progression": {"default": {"increment": 5}, "lifts": {"deadlift": {"increment": 10, "start_weight": 95}}},
+	"deload": {"after_failures": 3, "percent": 10}
+}`
+
+// fieldErrors parses raw and returns its validation errors keyed by path
+func fieldErrors(t *testing.T, raw string) map[string]string {
+	t.Helper()
+	_, err := Parse([]byte(raw))
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Expected a ValidationError, got %v", err)
+	}
+	return verr.Fields()
+}
+
+func TestParseValid(t *testing.T) {
+	def, err := Parse([]byte(validDefinition))
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if len(def.Days) != 2 || def.Days[1].Accessories[0].Exercise != "Chin-up" {
+		t.Errorf("Unexpected definition %+v", def)
+	}
+}
+
+func TestValidationPaths(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(d *Definition)
+		path   string
+	}{
+		{"unsupported version", func(d *Definition) { d.Version = 2 }, "version"},
+		{"no days", func(d *Definition) { d.Days = nil }, "days"},
+		{"missing day name", func(d *Definition) { d.Days[1].Name = " " }, "days[1].name"},
+		{"duplicate day name", func(d *Definition) { d.Days[1].Name = "a" }, "days[1].name"},
+		{"empty day", func(d *Definition) { d.Days[0].Slots = nil }, "days[0].slots"},
+		{"zero reps", func(d *Definition) { d.Days[1].Slots[1] = Slot{Exercise: "Deadlift", Sets: 1} }, "days[1].slots[1].reps"},
+		{"too many sets", func(d *Definition) { d.Days[0].Slots[1].Sets = 50 }, "days[0].slots[1].sets"},
+		{"bad scheme", func(d *Definition) { d.Days[0].Slots[0].Scheme = "five by five" }, "days[0].slots[0].scheme"},
+		{"scheme and sets", func(d *Definition) { d.Days[0].Slots[0].Sets = 3 }, "days[0].slots[0].scheme"},
+		{"missing exercise", func(d *Definition) { d.Days[1].Accessories[0].Exercise = "" }, "days[1].accessories[0].exercise"},
+		{"bad units", func(d *Definition) { d.Units = "stone" }, "units"},
+		{"negative increment", func(d *Definition) { d.Progression.Default.Increment = -5 }, "progression.default.increment"},
+		{"min above start", func(d *Definition) {
+			d.Progression.Lifts["deadlift"] = LiftRule{StartWeight: 95, MinWeight: 135}
+		}, `progression.lifts["deadlift"].min_weight`},
+		{"deload percent", func(d *Definition) { d.Deload.Percent = 0 }, "deload.percent"},
+		{"lift deload", func(d *Definition) {
+			d.Progression.Lifts["deadlift"] = LiftRule{Deload: &Deload{AfterFailures: 0, Percent: 10}}
+		}, `progression.lifts["deadlift"].deload.after_failures`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			def, err := Parse([]byte(validDefinition))
+			if err != nil {
+				t.Fatalf("Parse returned error: %v", err)
+			}
+			tt.mutate(def)
+
+			err = def.Validate()
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("Expected a ValidationError, got %v", err)
+			}
+			if _, ok := verr.Fields()[tt.path]; !ok {
+				t.Errorf("Expected error at %s, got %v", tt.path, verr.Fields())
+			}
+		})
+	}
+}
+
+func TestParseReportsEveryError(t *testing.T) {
+	fields := fieldErrors(t, `{"version": 1, "days": [
+		{"name": "A", "slots": [{"exercise": "Squat", "sets": 5, "reps": 5}]},
+		{"name": "B", "slots": [{"exercise": "Squat", "sets": 5, "reps": 5}, {"exercise": "Press", "sets": 3, "reps": 5}, {"exercise": "", "sets": 1, "reps": 0}]}
+	], "progression": {"default": {}}}`)
+
+	for _, path := range []string{"days[1].slots[2].exercise", "days[1].slots[2].reps"} {
+		if _, ok := fields[path]; !ok {
+			t.Errorf("Expected error at %s, got %v", path, fields)
+		}
+	}
+	if len(fields) != 2 {
+		t.Errorf("Expected exactly 2 errors, got %v", fields)
+	}
+}
+
+func TestParseRejectsUnknownFields(t *testing.T) {
+	_, err := Parse([]byte(`{"version": 1, "dayz": []}`))
+	if err == nil || !strings.Contains(err.Error(), "dayz") {
+		t.Errorf("Expected unknown field error, got %v", err)
+	}
+}
+
+func TestValidateExercises(t *testing.T) {
+	def, err := Parse([]byte(validDefinition))
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	catalog := map[string]bool{"squat": true, "bench press": true, "deadlift": true}
+	err = def.ValidateExercises(catalog)
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Expected a ValidationError, got %v", err)
+	}
+	if msg := verr.Fields()["days[1].accessories[0].exercise"]; !strings.Contains(msg, "Chin-up") {
+		t.Errorf("Expected unknown accessory error, got %v", verr.Fields())
+	}
+
+	catalog["chin-up"] = true
+	if err := def.ValidateExercises(catalog); err != nil {
+		t.Errorf("Expected catalog to cover every exercise, got %v", err)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	def, err := Parse([]byte(validDefinition))
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	plan := def.Plan()
+	if err := plan.Validate(); err != nil {
+		t.Fatalf("Plan is invalid: %v", err)
+	}
+	if len(plan.Days) != 2 || len(plan.Days[1].Slots) != 2 {
+		t.Fatalf("Accessories should not become progression slots, got %+v", plan.Days)
+	}
+	if s := plan.Days[0].Slots[0]; s.Sets != 5 || s.Reps != 5 {
+		t.Errorf("Expected scheme 5x5 expanded, got %+v", s)
+	}
+
+	deadlift := plan.Lifts["Deadlift"]
+	if deadlift.Increment != 10 || deadlift.StartWeight != 95 || deadlift.DeloadAfter != 3 || deadlift.DeloadPercent != 0.1 {
+		t.Errorf("Expected case-insensitive override merged with deload, got %+v", deadlift)
+	}
+	if squat := plan.Lifts["Squat"]; squat.Increment != 5 {
+		t.Errorf("Expected default increment for squat, got %+v", squat)
+	}
+}
+
+func TestBuiltinsAreValid(t *testing.T) {
+	for _, name := range []string{StartingStrengthName, StrongLiftsName} {
+		def, ok := Builtin(name)
+		if !ok {
+			t.Fatalf("Expected builtin definition for %q", name)
+		}
+		raw, err := def.JSON()
+		if err != nil {
+			t.Fatalf("JSON returned error: %v", err)
+		}
+		if _, err := Parse([]byte(raw)); err != nil {
+			t.Errorf("%s does not round-trip: %v", name, err)
+		}
+	}
+	if _, ok := Builtin("Couch to 5k"); ok {
+		t.Error("Did not expect a builtin definition for an unknown program")
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	legacy := `{"schedule": "3x per week", "exercises": ["squat", "bench_press"], "sets": 5, "reps": 5, "progression": "linear"}`
+
+	t.Run("builtin replaced", func(t *testing.T) {
+		def, changed, err := Upgrade("StrongLifts 5x5", legacy)
+		if err != nil || !changed {
+			t.Fatalf("Expected upgrade, got changed=%v err=%v", changed, err)
+		}
+		if len(def.Days) != 2 || def.Days[0].Slots[2].Exercise != "Barbell Row" {
+			t.Errorf("Expected the StrongLifts definition, got %+v", def.Days)
+		}
+	})
+
+	t.Run("custom legacy program", func(t *testing.T) {
+		def, changed, err := Upgrade("Garage Program", legacy)
+		if err != nil || !changed {
+			t.Fatalf("Expected upgrade, got changed=%v err=%v", changed, err)
+		}
+		if def.DaysPerWeek != 3 || len(def.Days) != 1 || def.Days[0].Slots[1].Exercise != "Bench Press" {
+			t.Errorf("Unexpected upgraded definition %+v", def)
+		}
+	})
+
+	t.Run("current version untouched", func(t *testing.T) {
+		_, changed, err := Upgrade("StrongLifts 5x5", validDefinition)
+		if err != nil || changed {
+			t.Errorf("Expected no change, got changed=%v err=%v", changed, err)
+		}
+	})
+
+	t.Run("invalid current version", func(t *testing.T) {
+		if _, _, err := Upgrade("x", `{"version": 1, "days": []}`); err == nil {
+			t.Error("Expected validation error")
+		}
+	})
+}
@@ -0,0 +1,85 @@
+package programdef
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// legacyStructure is the untyped format seeded before definitions were
+// versioned: a flat list of snake_case exercise ids with optional global
+// sets/reps and a free-text progression style
+type legacyStructure struct {
+	Schedule    string   `json:"schedule"`
+	Exercises   []string `json:"exercises"`
+	Sets        int      `json:"sets"`
+	Reps        int      `json:"reps"`
+	Progression any      `json:"progression"`
+}
+
+// Defaults for legacy structures that did not specify sets and reps
+const (
+	legacyDefaultSets = 3
+	legacyDefaultReps = 5
+)
+
+// Upgrade converts a stored structure of any known version into a current,
+// validated definition. Structures without a version are treated as the
+// legacy format: built-in programs are replaced by their typed definitions
+// and anything else becomes a single-day program of its listed exercises.
+// The boolean result reports whether the stored structure changed.
+func Upgrade(programName, raw string) (*Definition, bool, error) {
+	var probe struct {
+		Version *int `json:"version"`
+	}
+	if err := json.Unmarshal([]byte(raw), &probe); err != nil {
+		return nil, false, fmt.Errorf("invalid program structure: %w", err)
+	}
+
+	if probe.Version != nil {
+		def, err := Parse([]byte(raw))
+		return def, false, err
+	}
+
+	if def, ok := Builtin(programName); ok {
+		return def, true, nil
+	}
+
+	var legacy legacyStructure
+	if err := json.Unmarshal([]byte(raw), &legacy); err != nil {
+		return nil, false, fmt.Errorf("invalid legacy program structure: %w", err)
+	}
+
+	sets, reps := legacy.Sets, legacy.Reps
+	if sets <= 0 {
+		sets = legacyDefaultSets
+	}
+	if reps <= 0 {
+		reps = legacyDefaultReps
+	}
+
+	def := &Definition{
+		Version: CurrentVersion,
+		Days:    []Day{{Name: "A"}},
+	}
+	if _, err := fmt.Sscanf(legacy.Schedule, "%dx per week", &def.DaysPerWeek); err != nil {
+		def.DaysPerWeek = 0
+	}
+	for _, id := range legacy.Exercises {
+		def.Days[0].Slots = append(def.Days[0].Slots, Slot{Exercise: exerciseName(id), Sets: sets, Reps: reps})
+	}
+
+	if err := def.Validate(); err != nil {
+		return nil, false, err
+	}
+	return def, true, nil
+}
+
+// exerciseName turns a legacy id such as "bench_press" into "Bench Press"
+func exerciseName(id string) string {
+	words := strings.Fields(strings.ReplaceAll(id, "_", " "))
+	for i, w := range words {
+		words[i] = strings.ToUpper(w[:1]) + strings.ToLower(w[1:])
+	}
+	return strings.Join(words, " ")
+}
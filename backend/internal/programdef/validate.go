@@ -0,0 +1,216 @@
+package programdef
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Bounds for definition values
+const (
+	maxDays          = 7
+	maxSlotsPerDay   = 20
+	maxSets          = 20
+	maxReps          = 100
+	maxIncrement     = 100.0
+	maxWeight        = 2000.0
+	maxDeloadPercent = 90.0
+	maxAfterFailures = 10
+)
+
+// FieldError is a validation failure at a JSON path such as days[1].slots[2].reps
+type FieldError struct {
+	Path    string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// ValidationError collects every problem found in a definition
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fe.Error()
+	}
+	return "invalid program definition: " + strings.Join(msgs, "; ")
+}
+
+// Fields returns the errors as a path -> message map for API responses
+func (e *ValidationError) Fields() map[string]string {
+	out := make(map[string]string, len(e.Errors))
+	for _, fe := range e.Errors {
+		out[fe.Path] = fe.Message
+	}
+	return out
+}
+
+// validator accumulates field errors
+type validator struct {
+	errs []FieldError
+}
+
+func (v *validator) add(path, format string, args ...any) {
+	v.errs = append(v.errs, FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// Validate checks the definition and returns a *ValidationError listing every problem
+func (d *Definition) Validate() error {
+	v := &validator{}
+
+	if d.Version != CurrentVersion {
+		v.add("version", "unsupported version %d (expected %d)", d.Version, CurrentVersion)
+	}
+	if d.DaysPerWeek < 0 || d.DaysPerWeek > maxDays {
+		v.add("days_per_week", "must be between 1 and %d", maxDays)
+	}
+	if d.Units != "" && d.Units != "lb" && d.Units != "kg" {
+		v.add("units", "must be one of: lb, kg")
+	}
+	if d.RoundTo < 0 || d.RoundTo > maxIncrement {
+		v.add("round_to", "must be between 0 and %v", maxIncrement)
+	}
+
+	if len(d.Days) == 0 {
+		v.add("days", "at least one day is required")
+	}
+	if len(d.Days) > maxDays {
+		v.add("days", "at most %d days are allowed", maxDays)
+	}
+
+	names := make(map[string]bool)
+	for i, day := range d.Days {
+		path := fmt.Sprintf("days[%d]", i)
+		name := strings.TrimSpace(day.Name)
+		switch {
+		case name == "":
+			v.add(path+".name", "is required")
+		case names[strings.ToLower(name)]:
+			v.add(path+".name", "duplicate day name %q", name)
+		}
+		names[strings.ToLower(name)] = true
+
+		if len(day.Slots) == 0 {
+			v.add(path+".slots", "at least one exercise slot is required")
+		}
+		if len(day.Slots)+len(day.Accessories) > maxSlotsPerDay {
+			v.add(path+".slots", "at most %d exercises per day are allowed", maxSlotsPerDay)
+		}
+		for j := range day.Slots {
+			v.slot(fmt.Sprintf("%s.slots[%d]", path, j), &day.Slots[j])
+		}
+		for j := range day.Accessories {
+			v.slot(fmt.Sprintf("%s.accessories[%d]", path, j), &day.Accessories[j])
+		}
+	}
+
+	v.rule("progression.default", d.Progression.Default)
+	lifts := make([]string, 0, len(d.Progression.Lifts))
+	for name := range d.Progression.Lifts {
+		lifts = append(lifts, name)
+	}
+	sort.Strings(lifts) // Deterministic error order
+	for _, name := range lifts {
+		path := fmt.Sprintf("progression.lifts[%q]", name)
+		if strings.TrimSpace(name) == "" {
+			v.add(path, "lift name is required")
+		}
+		v.rule(path, d.Progression.Lifts[name])
+	}
+
+	if d.Deload != nil {
+		v.deload("deload", d.Deload)
+	}
+
+	if len(v.errs) > 0 {
+		return &ValidationError{Errors: v.errs}
+	}
+	return nil
+}
+
+// ValidateExercises checks that every referenced exercise exists in the
+// catalog (keys are lower-cased exercise names)
+func (d *Definition) ValidateExercises(catalog map[string]bool) error {
+	v := &validator{}
+	for i, day := range d.Days {
+		for j, s := range day.Slots {
+			if s.Exercise != "" && !catalog[strings.ToLower(s.Exercise)] {
+				v.add(fmt.Sprintf("days[%d].slots[%d].exercise", i, j), "unknown exercise %q", s.Exercise)
+			}
+		}
+		for j, s := range day.Accessories {
+			if s.Exercise != "" && !catalog[strings.ToLower(s.Exercise)] {
+				v.add(fmt.Sprintf("days[%d].accessories[%d].exercise", i, j), "unknown exercise %q", s.Exercise)
+			}
+		}
+	}
+	if len(v.errs) > 0 {
+		return &ValidationError{Errors: v.errs}
+	}
+	return nil
+}
+
+func (v *validator) slot(path string, s *Slot) {
+	if strings.TrimSpace(s.Exercise) == "" {
+		v.add(path+".exercise", "is required")
+	}
+
+	sets, reps := s.Sets, s.Reps
+	if s.Scheme != "" {
+		if s.Sets != 0 || s.Reps != 0 {
+			v.add(path+".scheme", "use either scheme or sets/reps, not both")
+			return
+		}
+		var err error
+		sets, reps, err = parseScheme(s.Scheme)
+		if err != nil {
+			v.add(path+".scheme", "%s", err)
+			return
+		}
+	}
+
+	field := func(name string) string {
+		if s.Scheme != "" {
+			return path + ".scheme"
+		}
+		return path + "." + name
+	}
+	if sets < 1 || sets > maxSets {
+		v.add(field("sets"), "sets must be between 1 and %d", maxSets)
+	}
+	if reps < 1 || reps > maxReps {
+		v.add(field("reps"), "reps must be between 1 and %d", maxReps)
+	}
+}
+
+func (v *validator) rule(path string, r LiftRule) {
+	if r.Increment < 0 || r.Increment > maxIncrement {
+		v.add(path+".increment", "must be between 0 and %v", maxIncrement)
+	}
+	if r.StartWeight < 0 || r.StartWeight > maxWeight {
+		v.add(path+".start_weight", "must be between 0 and %v", maxWeight)
+	}
+	if r.MinWeight < 0 || r.MinWeight > maxWeight {
+		v.add(path+".min_weight", "must be between 0 and %v", maxWeight)
+	}
+	if r.StartWeight > 0 && r.MinWeight > r.StartWeight {
+		v.add(path+".min_weight", "must not exceed start_weight")
+	}
+	if r.Deload != nil {
+		v.deload(path+".deload", r.Deload)
+	}
+}
+
+func (v *validator) deload(path string, d *Deload) {
+	if d.AfterFailures < 1 || d.AfterFailures > maxAfterFailures {
+		v.add(path+".after_failures", "must be between 1 and %d", maxAfterFailures)
+	}
+	if d.Percent <= 0 || d.Percent > maxDeloadPercent {
+		v.add(path+".percent", "must be greater than 0 and at most %v", maxDeloadPercent)
+	}
+}
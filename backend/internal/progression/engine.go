@@ -0,0 +1,223 @@
+package progression
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// weightEpsilon absorbs float noise when comparing logged and prescribed weights
+const weightEpsilon = 0.01
+
+// Session is a logged workout as seen by the engine
+type Session struct {
+	CompletedAt time.Time
+	Exercises   []PerformedExercise
+}
+
+// PerformedExercise is an exercise and the sets logged for it in a session
+type PerformedExercise struct {
+	Name string
+	Sets []PerformedSet
+}
+
+// PerformedSet is a single logged set
+type PerformedSet struct {
+	Reps      int
+	Weight    float64
+	Completed bool
+}
+
+// LiftState is the progression state of a single lift after replaying history
+type LiftState struct {
+	Exercise            string  `json:"exercise"`
+	WorkingWeight       float64 `json:"working_weight"`       // Weight to attempt next session
+	ConsecutiveFailures int     `json:"consecutive_failures"` // Failed sessions at the current weight
+	Deloaded            bool    `json:"deloaded"`             // The last session triggered a deload
+	Sessions            int     `json:"sessions"`             // Sessions in which the lift was trained
+}
+
+// Prescription is the concrete next session
+type Prescription struct {
+	Day           string   `json:"day"`
+	SessionNumber int      `json:"session_number"` // 1-based count of sessions in the program
+	Exercises     []Target `json:"exercises"`
+}
+
+// Target is what to lift for one exercise in the next session
+type Target struct {
+	Exercise string  `json:"exercise"`
+	Sets     int     `json:"sets"`
+	Reps     int     `json:"reps"`
+	Weight   float64 `json:"weight"`
+	Deload   bool    `json:"deload"`
+	// ConsecutiveFailures lets clients warn the lifter that a deload is near
+	ConsecutiveFailures int `json:"consecutive_failures"`
+}
+
+// Next computes the next session for plan given the lifter's history.
+// start optionally overrides each lift's starting weight (keyed by exercise).
+//
+// Days alternate in order based on how many sessions have been logged. Each
+// lift starts at its starting weight; a session where every prescribed set
+// hits its reps adds the lift's increment, a miss repeats the weight, and
+// DeloadAfter misses in a row drop the weight by DeloadPercent.
+func Next(plan *Plan, history []Session, start map[string]float64) (*Prescription, error) {
+	if err := plan.Validate(); err != nil {
+		return nil, err
+	}
+
+	states := Replay(plan, history, start)
+	day := plan.Days[len(history)%len(plan.Days)]
+
+	out := &Prescription{
+		Day:           day.Name,
+		SessionNumber: len(history) + 1,
+		Exercises:     make([]Target, 0, len(day.Slots)),
+	}
+	for _, slot := range day.Slots {
+		st := states[key(slot.Exercise)]
+		out.Exercises = append(out.Exercises, Target{
+			Exercise:            slot.Exercise,
+			Sets:                slot.Sets,
+			Reps:                slot.Reps,
+			Weight:              st.WorkingWeight,
+			Deload:              st.Deloaded,
+			ConsecutiveFailures: st.ConsecutiveFailures,
+		})
+	}
+	return out, nil
+}
+
+// Replay walks history in chronological order and returns the state of every
+// lift in the plan, keyed by lower-cased exercise name
+func Replay(plan *Plan, history []Session, start map[string]float64) map[string]*LiftState {
+	states := make(map[string]*LiftState)
+	for _, day := range plan.Days {
+		for _, slot := range day.Slots {
+			k := key(slot.Exercise)
+			if _, ok := states[k]; ok {
+				continue
+			}
+			rule := plan.rule(slot.Exercise)
+			weight := rule.StartWeight
+			if w, ok := lookup(start, slot.Exercise); ok && w > 0 {
+				weight = w
+			}
+			states[k] = &LiftState{Exercise: slot.Exercise, WorkingWeight: roundWeight(weight, plan.roundTo(), rule.MinWeight)}
+		}
+	}
+
+	sessions := append([]Session(nil), history...)
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].CompletedAt.Before(sessions[j].CompletedAt)
+	})
+
+	for i, session := range sessions {
+		day := plan.Days[i%len(plan.Days)]
+		for _, performed := range session.Exercises {
+			st, ok := states[key(performed.Name)]
+			if !ok {
+				continue // Accessory or off-plan exercise
+			}
+			slot := slotFor(plan, day, performed.Name)
+			apply(st, plan.rule(st.Exercise), slot, performed, plan.roundTo())
+		}
+	}
+	return states
+}
+
+// apply advances a lift's state by one logged session
+func apply(st *LiftState, rule LiftRule, slot Slot, performed PerformedExercise, step float64) {
+	st.Sessions++
+	st.Deloaded = false
+
+	// Lifters who went heavier than prescribed progress from what they lifted
+	base := st.WorkingWeight
+	if top := topWeight(performed.Sets); top > base+weightEpsilon {
+		base = top
+	}
+
+	if succeeded(performed.Sets, slot, base) {
+		st.WorkingWeight = roundWeight(base+rule.Increment, step, rule.MinWeight)
+		st.ConsecutiveFailures = 0
+		return
+	}
+
+	st.ConsecutiveFailures++
+	if st.ConsecutiveFailures >= rule.DeloadAfter {
+		st.WorkingWeight = roundWeight(base*(1-rule.DeloadPercent), step, rule.MinWeight)
+		st.ConsecutiveFailures = 0
+		st.Deloaded = true
+		return
+	}
+	st.WorkingWeight = base
+}
+
+// succeeded reports whether enough completed sets hit the target reps at weight
+func succeeded(sets []PerformedSet, slot Slot, weight float64) bool {
+	good := 0
+	for _, s := range sets {
+		if s.Completed && s.Reps >= slot.Reps && s.Weight+weightEpsilon >= weight {
+			good++
+		}
+	}
+	return good >= slot.Sets
+}
+
+// topWeight returns the heaviest completed set
+func topWeight(sets []PerformedSet) float64 {
+	top := 0.0
+	for _, s := range sets {
+		if s.Completed && s.Weight > top {
+			top = s.Weight
+		}
+	}
+	return top
+}
+
+// slotFor finds the prescription for exercise on day, falling back to the
+// first slot for that exercise anywhere in the plan
+func slotFor(plan *Plan, day Day, exercise string) Slot {
+	for _, s := range day.Slots {
+		if strings.EqualFold(s.Exercise, exercise) {
+			return s
+		}
+	}
+	for _, d := range plan.Days {
+		for _, s := range d.Slots {
+			if strings.EqualFold(s.Exercise, exercise) {
+				return s
+			}
+		}
+	}
+	return Slot{Exercise: exercise, Sets: 1, Reps: 1}
+}
+
+// roundWeight rounds to the nearest loadable step without going below min
+func roundWeight(w, step, minWeight float64) float64 {
+	rounded := math.Round(w/step) * step
+	if rounded < minWeight {
+		return minWeight
+	}
+	return rounded
+}
+
+// lookup finds a value in m by case-insensitive key
+func lookup(m map[string]float64, name string) (float64, bool) {
+	if v, ok := m[name]; ok {
+		return v, true
+	}
+	for k, v := range m {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+// key normalizes an exercise name for map lookups
+func key(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
@@ -0,0 +1,315 @@
+package progression
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var day0 = time.Date(2025, 5, 5, 18, 0, 0, 0, time.UTC)
+
+// session builds a logged session where each exercise has sets x reps at weight
+func session(n int, lifts ...PerformedExercise) Session {
+	return Session{CompletedAt: day0.Add(time.Duration(n) * 48 * time.Hour), Exercises: lifts}
+}
+
+func done(name string, sets, reps int, weight float64) PerformedExercise {
+	e := PerformedExercise{Name: name}
+	for i := 0; i < sets; i++ {
+		e.Sets = append(e.Sets, PerformedSet{Reps: reps, Weight: weight, Completed: true})
+	}
+	return e
+}
+
+func target(t *testing.T, p *Prescription, exercise string) Target {
+	t.Helper()
+	for _, e := range p.Exercises {
+		if e.Exercise == exercise {
+			return e
+		}
+	}
+	t.Fatalf("Exercise %s not in prescription %+v", exercise, p.Exercises)
+	return Target{}
+}
+
+// startingStrength returns the novice phase of Starting Strength:
+// squat every session, alternating bench and press, deadlift for one set of five
+func startingStrength() Plan {
+	return Plan{
+		Days: []Day{
+			{Name: "A", Slots: []Slot{
+				{Exercise: "Squat", Sets: 3, Reps: 5},
+				{Exercise: "Bench Press", Sets: 3, Reps: 5},
+				{Exercise: "Deadlift", Sets: 1, Reps: 5},
+			}},
+			{Name: "B", Slots: []Slot{
+				{Exercise: "Squat", Sets: 3, Reps: 5},
+				{Exercise: "Overhead Press", Sets: 3, Reps: 5},
+				{Exercise: "Deadlift", Sets: 1, Reps: 5},
+			}},
+		},
+		Lifts: map[string]LiftRule{
+			"Squat":          {Increment: 10},
+			"Deadlift":       {Increment: 10, StartWeight: 95},
+			"Bench Press":    {Increment: 5},
+			"Overhead Press": {Increment: 5},
+		},
+	}
+}
+
+// strongLifts5x5 returns the StrongLifts 5x5 A/B rotation
+func strongLifts5x5() Plan {
+	return Plan{
+		Days: []Day{
+			{Name: "A", Slots: []Slot{
+				{Exercise: "Squat", Sets: 5, Reps: 5},
+				{Exercise: "Bench Press", Sets: 5, Reps: 5},
+				{Exercise: "Barbell Row", Sets: 5, Reps: 5},
+			}},
+			{Name: "B", Slots: []Slot{
+				{Exercise: "Squat", Sets: 5, Reps: 5},
+				{Exercise: "Overhead Press", Sets: 5, Reps: 5},
+				{Exercise: "Deadlift", Sets: 1, Reps: 5},
+			}},
+		},
+		Lifts: map[string]LiftRule{
+			"Squat":          {Increment: 5},
+			"Bench Press":    {Increment: 5},
+			"Barbell Row":    {Increment: 5, StartWeight: 65},
+			"Overhead Press": {Increment: 5},
+			"Deadlift":       {Increment: 10, StartWeight: 95},
+		},
+	}
+}
+
+func TestNextFirstSession(t *testing.T) {
+	plan := strongLifts5x5()
+	p, err := Next(&plan, nil, nil)
+	if err != nil {
+		t.Fatalf("Next returned error: %v", err)
+	}
+
+	if p.Day != "A" || p.SessionNumber != 1 {
+		t.Errorf("Expected day A session 1, got %s session %d", p.Day, p.SessionNumber)
+	}
+	if got := target(t, p, "Squat"); got.Weight != 45 || got.Sets != 5 || got.Reps != 5 {
+		t.Errorf("Expected squat 5x5 @ 45, got %+v", got)
+	}
+	if got := target(t, p, "Barbell Row"); got.Weight != 65 {
+		t.Errorf("Expected row to start at 65, got %v", got.Weight)
+	}
+}
+
+func TestNextAlternatesDays(t *testing.T) {
+	plan := strongLifts5x5()
+	history := []Session{}
+	expected := []string{"A", "B", "A", "B"}
+
+	for i, want := range expected {
+		p, err := Next(&plan, history, nil)
+		if err != nil {
+			t.Fatalf("Next returned error: %v", err)
+		}
+		if p.Day != want {
+			t.Errorf("Session %d: expected day %s, got %s", i+1, want, p.Day)
+		}
+		history = append(history, session(i, done("Squat", 5, 5, 45)))
+	}
+}
+
+func TestLinearProgression(t *testing.T) {
+	plan := strongLifts5x5()
+	history := []Session{
+		session(0, done("Squat", 5, 5, 45), done("Bench Press", 5, 5, 45), done("Barbell Row", 5, 5, 65)),
+		session(1, done("Squat", 5, 5, 50), done("Overhead Press", 5, 5, 45), done("Deadlift", 1, 5, 95)),
+	}
+
+	p, err := Next(&plan, history, nil)
+	if err != nil {
+		t.Fatalf("Next returned error: %v", err)
+	}
+
+	tests := map[string]float64{"Squat": 55, "Bench Press": 50, "Barbell Row": 70}
+	for exercise, want := range tests {
+		if got := target(t, p, exercise).Weight; got != want {
+			t.Errorf("Expected %s at %v, got %v", exercise, want, got)
+		}
+	}
+
+	// Deadlift progresses by 10 lb and shows up on day B
+	states := Replay(&plan, history, nil)
+	if got := states["deadlift"].WorkingWeight; got != 105 {
+		t.Errorf("Expected deadlift at 105, got %v", got)
+	}
+}
+
+func TestFailureRepeatsWeight(t *testing.T) {
+	plan := strongLifts5x5()
+	missed := done("Squat", 5, 5, 45)
+	missed.Sets[4].Reps = 3
+
+	states := Replay(&plan, []Session{session(0, missed)}, nil)
+	st := states["squat"]
+	if st.WorkingWeight != 45 || st.ConsecutiveFailures != 1 {
+		t.Errorf("Expected squat to stay at 45 with 1 failure, got %+v", st)
+	}
+}
+
+func TestThreeFailuresTriggerDeload(t *testing.T) {
+	plan := strongLifts5x5()
+	start := map[string]float64{"Squat": 200}
+
+	miss := func(n int) Session {
+		e := done("Squat", 5, 5, 200)
+		e.Sets[2].Completed = false
+		return session(n, e)
+	}
+
+	history := []Session{miss(0), miss(1), miss(2)}
+	p, err := Next(&plan, history, start)
+	if err != nil {
+		t.Fatalf("Next returned error: %v", err)
+	}
+
+	got := target(t, p, "Squat")
+	if got.Weight != 180 {
+		t.Errorf("Expected 10%% deload from 200 to 180, got %v", got.Weight)
+	}
+	if !got.Deload || got.ConsecutiveFailures != 0 {
+		t.Errorf("Expected deload flag and reset failures, got %+v", got)
+	}
+
+	// Two misses are not enough
+	states := Replay(&plan, history[:2], start)
+	if states["squat"].WorkingWeight != 200 || states["squat"].ConsecutiveFailures != 2 {
+		t.Errorf("Expected 200 with 2 failures, got %+v", states["squat"])
+	}
+}
+
+func TestSuccessResetsFailures(t *testing.T) {
+	plan := strongLifts5x5()
+	start := map[string]float64{"squat": 100}
+
+	miss := done("Squat", 5, 5, 100)
+	miss.Sets[0].Reps = 4
+
+	history := []Session{session(0, miss), session(1, miss), session(2, done("Squat", 5, 5, 100)), session(3, miss)}
+	st := Replay(&plan, history, start)["squat"]
+	if st.WorkingWeight != 105 || st.ConsecutiveFailures != 1 {
+		t.Errorf("Expected 105 with 1 failure after success, got %+v", st)
+	}
+}
+
+func TestHeavierThanPrescribedProgressesFromLogged(t *testing.T) {
+	plan := strongLifts5x5()
+	history := []Session{session(0, done("Squat", 5, 5, 135))}
+
+	st := Replay(&plan, history, nil)["squat"]
+	if st.WorkingWeight != 140 {
+		t.Errorf("Expected 140 after 5x5 @ 135, got %v", st.WorkingWeight)
+	}
+}
+
+func TestDeloadRoundsAndRespectsMinimum(t *testing.T) {
+	tests := []struct {
+		name     string
+		weight   float64
+		expected float64
+	}{
+		{"rounds to nearest 5", 115, 105}, // 103.5 -> 105
+		{"never below bar", 45, 45},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := strongLifts5x5()
+			e := done("Overhead Press", 5, 5, tt.weight)
+			e.Sets[0].Reps = 1
+			history := []Session{session(0, e), session(1, e), session(2, e)}
+
+			st := Replay(&plan, history, map[string]float64{"Overhead Press": tt.weight})["overhead press"]
+			if st.WorkingWeight != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, st.WorkingWeight)
+			}
+		})
+	}
+}
+
+func TestOffPlanExercisesIgnored(t *testing.T) {
+	plan := startingStrength()
+	history := []Session{session(0, done("Bicep Curl", 3, 10, 25), done("Squat", 3, 5, 45))}
+
+	states := Replay(&plan, history, nil)
+	if _, ok := states["bicep curl"]; ok {
+		t.Error("Off-plan exercise should not be tracked")
+	}
+	if states["squat"].WorkingWeight != 55 {
+		t.Errorf("Expected squat +10 to 55, got %v", states["squat"].WorkingWeight)
+	}
+}
+
+func TestHistoryIsSortedChronologically(t *testing.T) {
+	plan := strongLifts5x5()
+	miss := done("Squat", 5, 5, 45)
+	miss.Sets[0].Completed = false
+
+	// Logged out of order: success first chronologically, then a miss
+	history := []Session{session(1, miss), session(0, done("Squat", 5, 5, 45))}
+	st := Replay(&plan, history, nil)["squat"]
+	if st.WorkingWeight != 50 || st.ConsecutiveFailures != 1 {
+		t.Errorf("Expected 50 with 1 failure, got %+v", st)
+	}
+}
+
+func TestPlanValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		plan    Plan
+		wantErr bool
+	}{
+		{"builtin", startingStrength(), false},
+		{"no days", Plan{}, true},
+		{"empty day", Plan{Days: []Day{{Name: "A"}}}, true},
+		{"zero reps", Plan{Days: []Day{{Name: "A", Slots: []Slot{{Exercise: "Squat", Sets: 3}}}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.plan.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	empty := Plan{}
+	if _, err := Next(&empty, nil, nil); !errors.Is(err, ErrEmptyPlan) {
+		t.Errorf("Expected ErrEmptyPlan, got %v", err)
+	}
+}
+
+func TestScheduleLocate(t *testing.T) {
+	s := Schedule{Weeks: 12, DaysPerWeek: 3, DaysInCycle: 2}
+
+	tests := []struct {
+		sessions int
+		expected Position
+	}{
+		{0, Position{Week: 1, Day: 0, SessionsCompleted: 0, TotalSessions: 36}},
+		{2, Position{Week: 1, Day: 0, SessionsCompleted: 2, TotalSessions: 36}},
+		{3, Position{Week: 2, Day: 1, SessionsCompleted: 3, TotalSessions: 36}},
+		{35, Position{Week: 12, Day: 1, SessionsCompleted: 35, TotalSessions: 36}},
+		{36, Position{Week: 12, Day: 0, SessionsCompleted: 36, TotalSessions: 36, Complete: true}},
+		{40, Position{Week: 12, Day: 0, SessionsCompleted: 40, TotalSessions: 36, Complete: true}},
+	}
+
+	for _, tt := range tests {
+		if got := s.Locate(tt.sessions); got != tt.expected {
+			t.Errorf("Locate(%d): expected %+v, got %+v", tt.sessions, tt.expected, got)
+		}
+	}
+
+	if got := (Schedule{}).Locate(1); !got.Complete || got.TotalSessions != 1 {
+		t.Errorf("Expected zero schedule to clamp to one session, got %+v", got)
+	}
+}
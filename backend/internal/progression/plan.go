@@ -0,0 +1,113 @@
+// Package progression computes the next workout for a strength program from
+// the program's rules and a lifter's logged history. It has no database or
+// HTTP dependencies so every rule can be unit tested directly.
+package progression
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// Defaults applied to lifts without explicit rules
+	defaultIncrement     = 5.0
+	defaultStartWeight   = 45.0 // Empty Olympic bar, in pounds
+	defaultMinWeight     = 45.0
+	defaultDeloadAfter   = 3
+	defaultDeloadPercent = 0.10
+	defaultRoundTo       = 5.0
+)
+
+// ErrEmptyPlan is returned when a plan has no training days
+var ErrEmptyPlan = errors.New("plan has no training days")
+
+// Plan describes a program's rotation of training days and how each lift progresses
+type Plan struct {
+	Days  []Day
+	Lifts map[string]LiftRule // Keyed by exercise name
+	// RoundTo is the smallest loadable weight step (e.g. 5 lb with 2.5 lb plates)
+	RoundTo float64
+}
+
+// Day is one training day in the rotation (e.g. workout "A")
+type Day struct {
+	Name  string
+	Slots []Slot
+}
+
+// Slot prescribes a set/rep scheme for an exercise on a given day
+type Slot struct {
+	Exercise string
+	Sets     int
+	Reps     int
+}
+
+// LiftRule controls how a single lift progresses and deloads
+type LiftRule struct {
+	StartWeight float64
+	Increment   float64 // Added after each successful session
+	MinWeight   float64 // Weight never drops below this (e.g. empty bar)
+	// DeloadAfter consecutive failed sessions trigger a deload of DeloadPercent
+	DeloadAfter   int
+	DeloadPercent float64
+}
+
+// Validate checks that the plan is usable
+func (p *Plan) Validate() error {
+	if len(p.Days) == 0 {
+		return ErrEmptyPlan
+	}
+	for i, day := range p.Days {
+		if len(day.Slots) == 0 {
+			return fmt.Errorf("days[%d]: no exercises", i)
+		}
+		for j, slot := range day.Slots {
+			if slot.Exercise == "" {
+				return fmt.Errorf("days[%d].slots[%d]: missing exercise", i, j)
+			}
+			if slot.Sets <= 0 || slot.Reps <= 0 {
+				return fmt.Errorf("days[%d].slots[%d]: sets and reps must be positive", i, j)
+			}
+		}
+	}
+	return nil
+}
+
+// rule returns the lift rule for exercise with defaults filled in
+func (p *Plan) rule(exercise string) LiftRule {
+	r, ok := p.Lifts[exercise]
+	if !ok {
+		for name, candidate := range p.Lifts {
+			if strings.EqualFold(name, exercise) {
+				r = candidate
+				break
+			}
+		}
+	}
+
+	if r.Increment <= 0 {
+		r.Increment = defaultIncrement
+	}
+	if r.MinWeight <= 0 {
+		r.MinWeight = defaultMinWeight
+	}
+	if r.StartWeight <= 0 {
+		r.StartWeight = r.MinWeight
+	}
+	if r.DeloadAfter <= 0 {
+		r.DeloadAfter = defaultDeloadAfter
+	}
+	if r.DeloadPercent <= 0 || r.DeloadPercent >= 1 {
+		r.DeloadPercent = defaultDeloadPercent
+	}
+	return r
+}
+
+// roundTo returns the plan's rounding step
+func (p *Plan) roundTo() float64 {
+	if p.RoundTo > 0 {
+		return p.RoundTo
+	}
+	return defaultRoundTo
+}
@@ -0,0 +1,38 @@
+package progression
+
+// Schedule describes the length of a fixed-duration program
+type Schedule struct {
+	Weeks       int // Program length in weeks
+	DaysPerWeek int // Planned sessions per week
+	DaysInCycle int // Training days in the plan's rotation
+}
+
+// Position is where a lifter stands in a program after some number of sessions
+type Position struct {
+	Week              int  `json:"current_week"` // 1-based, capped at the program length
+	Day               int  `json:"current_day"`  // 0-based index into the plan's days
+	SessionsCompleted int  `json:"sessions_completed"`
+	TotalSessions     int  `json:"total_sessions"`
+	Complete          bool `json:"complete"`
+}
+
+// Locate returns the position after sessions completed sessions. Progress is
+// counted in sessions rather than calendar days so pauses and missed weeks
+// do not eat into the program.
+func (s Schedule) Locate(sessions int) Position {
+	perWeek := max(s.DaysPerWeek, 1)
+	weeks := max(s.Weeks, 1)
+	cycle := max(s.DaysInCycle, 1)
+
+	pos := Position{
+		Week:              sessions/perWeek + 1,
+		Day:               sessions % cycle,
+		SessionsCompleted: sessions,
+		TotalSessions:     weeks * perWeek,
+	}
+	if sessions >= pos.TotalSessions {
+		pos.Complete = true
+		pos.Week = weeks
+	}
+	return pos
+}
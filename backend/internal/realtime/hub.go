@@ -0,0 +1,86 @@
+package realtime
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// subscriptionBuffer is how many events a subscription holds before new
+// ones are dropped
+const subscriptionBuffer = 16
+
+// Subscription receives the events of one user
+type Subscription struct {
+	// Events yields the user's events; it is closed by Close
+	Events <-chan Event
+
+	events chan Event
+	hub    *Hub
+	userID uint
+	once   sync.Once
+}
+
+// Close ends the subscription and closes Events
+func (s *Subscription) Close() {
+	s.once.Do(func() { s.hub.unsubscribe(s) })
+}
+
+// Hub fans events out to the subscriptions in this process
+type Hub struct {
+	mu   sync.Mutex
+	subs map[uint]map[*Subscription]struct{}
+}
+
+// NewHub creates a Hub without subscriptions
+func NewHub() *Hub {
+	return &Hub{subs: map[uint]map[*Subscription]struct{}{}}
+}
+
+// Publish hands event to every subscription of its user without waiting;
+// subscriptions whose buffer is full miss it
+func (h *Hub) Publish(_ context.Context, event Event) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subs[event.UserID] {
+		select {
+		case sub.events <- event:
+		default:
+			slog.Warn("Dropping event for slow subscriber", "user_id", event.UserID, "type", event.Type)
+		}
+	}
+	return nil
+}
+
+// Subscribe returns a subscription to the user's events
+func (h *Hub) Subscribe(userID uint) *Subscription {
+	events := make(chan Event, subscriptionBuffer)
+	sub := &Subscription{Events: events, events: events, hub: h, userID: userID}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subs[userID] == nil {
+		h.subs[userID] = map[*Subscription]struct{}{}
+	}
+	h.subs[userID][sub] = struct{}{}
+	return sub
+}
+
+// Subscribers returns how many subscriptions the user has
+func (h *Hub) Subscribers(userID uint) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs[userID])
+}
+
+// unsubscribe removes sub and closes its channel; publishers hold the lock
+// while sending, so none can send on the closed channel
+func (h *Hub) unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subs[sub.userID], sub)
+	if len(h.subs[sub.userID]) == 0 {
+		delete(h.subs, sub.userID)
+	}
+	close(sub.events)
+}
@@ -0,0 +1,110 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+)
+
+const (
+	// notifyChannel is the Postgres channel events travel on
+	notifyChannel = "ferrovis_events"
+	// maxPayload is the largest payload NOTIFY accepts, less a margin
+	maxPayload = 7900
+
+	minReconnectDelay = time.Second
+	maxReconnectDelay = time.Minute
+)
+
+// PGBroker shares events between API replicas through Postgres
+// LISTEN/NOTIFY. Publish sends an event to every replica, including this
+// one, and Run relays the events received to the local subscriptions.
+type PGBroker struct {
+	db  *gorm.DB
+	dsn string
+	hub *Hub
+}
+
+// NewPGBroker creates a PGBroker publishing through db and listening on a
+// dedicated connection to dsn
+func NewPGBroker(db *gorm.DB, dsn string) *PGBroker {
+	return &PGBroker{db: db, dsn: dsn, hub: NewHub()}
+}
+
+// Publish notifies every replica of event. The notification is sent when
+// the current transaction commits, if db is inside one.
+func (b *PGBroker) Publish(ctx context.Context, event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	if len(payload) > maxPayload {
+		return fmt.Errorf("event of %d bytes exceeds the notification limit", len(payload))
+	}
+	if err := b.db.WithContext(ctx).Exec("SELECT pg_notify(?, ?)", notifyChannel, string(payload)).Error; err != nil {
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+	return nil
+}
+
+// Subscribe returns a subscription to the user's events
+func (b *PGBroker) Subscribe(userID uint) *Subscription {
+	return b.hub.Subscribe(userID)
+}
+
+// Run listens for events until ctx is done, reconnecting with backoff when
+// the connection drops. Events published while disconnected are lost.
+func (b *PGBroker) Run(ctx context.Context) {
+	delay := minReconnectDelay
+	for {
+		listened, err := b.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if listened {
+			delay = minReconnectDelay
+		}
+		slog.Warn("Event listener disconnected", "error", err, "retry_in", delay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, maxReconnectDelay)
+	}
+}
+
+// listen relays notifications to the hub until the connection fails,
+// reporting whether it got as far as listening
+func (b *PGBroker) listen(ctx context.Context) (bool, error) {
+	conn, err := pgx.Connect(ctx, b.dsn)
+	if err != nil {
+		return false, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close(context.Background()) //nolint:errcheck // the connection is being abandoned
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{notifyChannel}.Sanitize()); err != nil {
+		return false, fmt.Errorf("failed to listen: %w", err)
+	}
+	slog.Info("Listening for events", "channel", notifyChannel)
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return true, fmt.Errorf("failed to wait for events: %w", err)
+		}
+		var event Event
+		if err := json.Unmarshal([]byte(n.Payload), &event); err != nil {
+			slog.Warn("Ignoring malformed event", "error", err)
+			continue
+		}
+		if err := b.hub.Publish(ctx, event); err != nil {
+			slog.Warn("Failed to relay event", "error", err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// Package realtime streams events to the clients of connected users.
+//
+// Publishers hand events to a Broker, which fans them out to every
+// subscription of the event's user. Hub does this in process; PGBroker
+// relays events through Postgres LISTEN/NOTIFY so every API replica sees
+// events published by the others. Delivery is best effort: a subscriber
+// that falls behind loses events rather than slowing down publishers, and
+// clients catch up through the regular endpoints after reconnecting.
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/env"
+)
+
+// Event types
+const (
+	EventWeaselMessage       = "weasel_message"
+	EventBuddyWorkout        = "buddy_workout"
+	EventAchievementUnlocked = "achievement_unlocked"
+	EventChallengeCompleted  = "challenge_completed"
+)
+
+// Event is something a user's clients should learn about right away
+type Event struct {
+	Type   string          `json:"type"`
+	UserID uint            `json:"user_id"`
+	Data   json.RawMessage `json:"data"`
+	At     time.Time       `json:"at"`
+}
+
+// NewEvent creates an event of eventType for a user carrying data as JSON
+func NewEvent(userID uint, eventType string, data any, at time.Time) (Event, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, fmt.Errorf("failed to encode %s event: %w", eventType, err)
+	}
+	return Event{Type: eventType, UserID: userID, Data: raw, At: at}, nil
+}
+
+// Publisher accepts events for delivery
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// Broker delivers published events to the subscriptions of their user
+type Broker interface {
+	Publisher
+	// Subscribe returns a subscription to the user's events; it must be
+	// closed once the client disconnects
+	Subscribe(userID uint) *Subscription
+}
+
+// Supported REALTIME_BROKER values
+const (
+	BrokerMemory   = "memory"
+	BrokerPostgres = "postgres"
+)
+
+const defaultHeartbeat = 25 * time.Second
+
+// Config selects the broker and how often idle streams are kept alive
+type Config struct {
+	// Broker is BrokerMemory for a single replica or BrokerPostgres to share
+	// events between replicas
+	Broker string
+	// Heartbeat is how often an idle stream is pinged so proxies keep it open
+	Heartbeat time.Duration
+}
+
+// LoadConfig loads the broker from REALTIME_BROKER (default memory) and the
+// heartbeat from REALTIME_HEARTBEAT (default 25s)
+func LoadConfig() *Config {
+	return &Config{
+		Broker:    strings.ToLower(env.String("REALTIME_BROKER", BrokerMemory)),
+		Heartbeat: env.Duration("REALTIME_HEARTBEAT", defaultHeartbeat),
+	}
+}
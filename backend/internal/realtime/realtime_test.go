@@ -0,0 +1,79 @@
+package realtime
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+var at = time.Date(2025, time.March, 10, 12, 0, 0, 0, time.UTC)
+
+func newTestEvent(t *testing.T, userID uint) Event {
+	t.Helper()
+	event, err := NewEvent(userID, EventWeaselMessage, map[string]string{"content": "hi"}, at)
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	return event
+}
+
+func TestHubFansOutPerUser(t *testing.T) {
+	ctx := context.Background()
+	hub := NewHub()
+	first, second, other := hub.Subscribe(1), hub.Subscribe(1), hub.Subscribe(2)
+	defer first.Close()
+	defer second.Close()
+	defer other.Close()
+
+	if err := hub.Publish(ctx, newTestEvent(t, 1)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for i, sub := range []*Subscription{first, second} {
+		select {
+		case event := <-sub.Events:
+			if event.UserID != 1 || string(event.Data) != `{"content":"hi"}` {
+				t.Errorf("Subscription %d got the wrong event: %+v", i, event)
+			}
+		default:
+			t.Errorf("Expected subscription %d to receive the event", i)
+		}
+	}
+	select {
+	case event := <-other.Events:
+		t.Errorf("Expected no event for another user, got %+v", event)
+	default:
+	}
+}
+
+func TestHubDropsEventsForSlowSubscribers(t *testing.T) {
+	ctx := context.Background()
+	hub := NewHub()
+	sub := hub.Subscribe(1)
+	defer sub.Close()
+
+	for range subscriptionBuffer + 5 {
+		if err := hub.Publish(ctx, newTestEvent(t, 1)); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if n := len(sub.Events); n != subscriptionBuffer {
+		t.Errorf("Expected %d buffered events, got %d", subscriptionBuffer, n)
+	}
+}
+
+func TestSubscriptionClose(t *testing.T) {
+	hub := NewHub()
+	sub := hub.Subscribe(1)
+	sub.Close()
+	sub.Close()
+
+	if _, open := <-sub.Events; open {
+		t.Error("Expected Events to be closed")
+	}
+	if n := hub.Subscribers(1); n != 0 {
+		t.Errorf("Expected no subscribers after Close, got %d", n)
+	}
+	if err := hub.Publish(context.Background(), newTestEvent(t, 1)); err != nil {
+		t.Errorf("Expected publishing without subscribers to succeed, got %v", err)
+	}
+}
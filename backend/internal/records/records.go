@@ -0,0 +1,114 @@
+// Package records finds personal records in workout history. Weights are
+// compared in pounds, so sets logged in different units rank together, and
+// only completed sets count.
+//
+// Heaviest reports each exercise's best set. Set reports the records broken
+// along the way: a workout's heaviest set of an exercise that is heavier
+// than every earlier set of it. The first workout with an exercise sets the
+// baseline and breaks no record, equalling a best does not beat it, and a
+// workout breaks at most one record per exercise.
+package records
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+)
+
+// Record is the set holding a best weight for an exercise, as logged
+type Record struct {
+	ExerciseID uint      `json:"exercise_id"`
+	Exercise   string    `json:"exercise"`
+	Weight     float64   `json:"weight"`
+	Unit       string    `json:"unit"`
+	Reps       int       `json:"reps"`
+	WorkoutID  uint      `json:"workout_id"`
+	AchievedAt time.Time `json:"achieved_at"`
+}
+
+// Heaviest returns each exercise's heaviest completed set in workouts,
+// which must be ordered oldest first, ordered by exercise name. The first
+// set to reach a weight holds it, so an exercise logged once still has one.
+func Heaviest(workouts []database.Workout) []Record {
+	best := make(map[uint]Record)
+	for i := range workouts {
+		w := &workouts[i]
+		for k := range w.Sets {
+			s := &w.Sets[k]
+			if !s.Completed {
+				continue
+			}
+			if current, ok := best[s.ExerciseID]; ok && s.Pounds() <= current.Pounds() {
+				continue
+			}
+			best[s.ExerciseID] = newRecord(w, s)
+		}
+	}
+
+	out := make([]Record, 0, len(best))
+	for _, r := range best {
+		out = append(out, r)
+	}
+	slices.SortFunc(out, func(a, b Record) int {
+		return cmp.Or(cmp.Compare(a.Exercise, b.Exercise), cmp.Compare(a.ExerciseID, b.ExerciseID))
+	})
+	return out
+}
+
+// Set returns the records broken in workouts, which must be ordered oldest
+// first, in the order they were set
+func Set(workouts []database.Workout) []Record {
+	var set []Record
+	best := make(map[uint]float64)
+	for i := range workouts {
+		w := &workouts[i]
+		heaviest := make(map[uint]*database.WorkoutSet)
+		var order []uint
+		for k := range w.Sets {
+			s := &w.Sets[k]
+			if !s.Completed {
+				continue
+			}
+			current, seen := heaviest[s.ExerciseID]
+			if !seen {
+				order = append(order, s.ExerciseID)
+			}
+			if !seen || s.Pounds() > current.Pounds() {
+				heaviest[s.ExerciseID] = s
+			}
+		}
+
+		for _, id := range order {
+			s := heaviest[id]
+			previous, ok := best[id]
+			if ok && s.Pounds() <= previous {
+				continue
+			}
+			if ok {
+				set = append(set, newRecord(w, s))
+			}
+			best[id] = s.Pounds()
+		}
+	}
+	return set
+}
+
+// newRecord returns the record of set s in workout w
+func newRecord(w *database.Workout, s *database.WorkoutSet) Record {
+	return Record{
+		ExerciseID: s.ExerciseID,
+		Exercise:   s.Exercise.Name,
+		Weight:     s.Weight,
+		Unit:       s.Unit,
+		Reps:       s.Reps,
+		WorkoutID:  w.ID,
+		AchievedAt: w.CompletedAt,
+	}
+}
+
+// Pounds returns the record's weight in pounds
+func (r *Record) Pounds() float64 {
+	return database.ConvertWeight(r.Weight, r.Unit, database.UnitPounds)
+}
@@ -0,0 +1,75 @@
+package records
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+)
+
+var (
+	squat = database.Exercise{ID: 1, Name: "Squat"}
+	bench = database.Exercise{ID: 2, Name: "Bench Press"}
+	start = time.Date(2025, time.March, 3, 18, 0, 0, 0, time.UTC)
+)
+
+// set returns a set of five reps
+func set(e database.Exercise, weight float64, unit string, completed bool) database.WorkoutSet {
+	return database.WorkoutSet{ExerciseID: e.ID, Exercise: e, Reps: 5, Weight: weight, Unit: unit, Completed: completed}
+}
+
+// history numbers the workouts from 1 and completes them a day apart
+func history(workouts ...database.Workout) []database.Workout {
+	for i := range workouts {
+		workouts[i].ID = uint(i + 1)
+		workouts[i].CompletedAt = start.AddDate(0, 0, i)
+	}
+	return workouts
+}
+
+func TestHeaviest(t *testing.T) {
+	workouts := history(
+		database.Workout{Sets: []database.WorkoutSet{set(squat, 100, database.UnitPounds, true), set(bench, 60, database.UnitPounds, true)}},
+		// Skipped sets do not count
+		database.Workout{Sets: []database.WorkoutSet{set(squat, 100, database.UnitPounds, true), set(bench, 200, database.UnitPounds, false)}},
+		// 50 kg is about 110 lb, lighter than 120 lb
+		database.Workout{Sets: []database.WorkoutSet{set(squat, 50, database.UnitKilograms, true), set(squat, 120, database.UnitPounds, true)}},
+		database.Workout{Sets: []database.WorkoutSet{set(squat, 120, database.UnitPounds, true)}},
+	)
+
+	got := Heaviest(workouts)
+	if len(got) != 2 || got[0].Exercise != "Bench Press" || got[1].Exercise != "Squat" {
+		t.Fatalf("Expected one set per exercise ordered by name, got %+v", got)
+	}
+	if got[0].Weight != 60 || got[0].WorkoutID != 1 {
+		t.Errorf("Expected the only completed bench press to hold its best, got %+v", got[0])
+	}
+	if got[1].Weight != 120 || got[1].WorkoutID != 3 || !got[1].AchievedAt.Equal(workouts[2].CompletedAt) {
+		t.Errorf("Expected the first 120 lb squat to hold the best, got %+v", got[1])
+	}
+}
+
+func TestSet(t *testing.T) {
+	workouts := history(
+		database.Workout{Sets: []database.WorkoutSet{set(squat, 100, database.UnitPounds, true), set(bench, 60, database.UnitPounds, true)}},
+		// Equalling a best and skipped sets do not count
+		database.Workout{Sets: []database.WorkoutSet{set(squat, 100, database.UnitPounds, true), set(bench, 200, database.UnitPounds, false)}},
+		// Two heavier squat sets are one record, held by the heaviest
+		database.Workout{Sets: []database.WorkoutSet{set(squat, 50, database.UnitKilograms, true), set(squat, 120, database.UnitPounds, true), set(bench, 65, database.UnitPounds, true)}},
+		database.Workout{Sets: []database.WorkoutSet{set(squat, 120, database.UnitPounds, true)}},
+	)
+
+	got := Set(workouts)
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 records, got %+v", got)
+	}
+	if got[0].Exercise != "Squat" || got[0].Weight != 120 || got[0].WorkoutID != 3 || !got[0].AchievedAt.Equal(workouts[2].CompletedAt) {
+		t.Errorf("Expected the 120 lb squat from workout 3, got %+v", got[0])
+	}
+	if got[1].Exercise != "Bench Press" || got[1].Weight != 65 || got[1].WorkoutID != 3 {
+		t.Errorf("Expected the 65 lb bench press from workout 3, got %+v", got[1])
+	}
+	if first := Set(workouts[:1]); len(first) != 0 {
+		t.Errorf("Expected first attempts to set the baseline only, got %+v", first)
+	}
+}
@@ -0,0 +1,321 @@
+package seed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/achievements"
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+)
+
+// Outcome describes what applying a seed entry did
+type Outcome string
+
+// Seed outcomes
+const (
+	Created   Outcome = "created"
+	Updated   Outcome = "updated"
+	Unchanged Outcome = "unchanged"
+)
+
+// Change records the outcome for one seed entry
+type Change struct {
+	Kind    string // exercise, program, achievement or social_activity
+	Name    string
+	Outcome Outcome
+}
+
+// Report lists the outcome of every entry in the order applied
+type Report struct {
+	Changes []Change
+}
+
+// Count returns how many entries had the given outcome
+func (r *Report) Count(o Outcome) int {
+	n := 0
+	for _, c := range r.Changes {
+		if c.Outcome == o {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *Report) add(kind, name string, o Outcome) {
+	r.Changes = append(r.Changes, Change{Kind: kind, Name: name, Outcome: o})
+}
+
+// Apply upserts the packs in a single transaction. When packs define the
+// same entry the later pack wins, so custom packs can override built-in data.
+func Apply(ctx context.Context, s store.Store, packs ...*Pack) (*Report, error) {
+	merged := merge(packs)
+	report := &Report{}
+	err := s.Transaction(ctx, func(tx store.Store) error {
+		catalog, err := applyExercises(ctx, tx, merged.Exercises, report)
+		if err != nil {
+			return err
+		}
+		if err := applyPrograms(ctx, tx, merged.Programs, catalog, report); err != nil {
+			return err
+		}
+		if err := applyAchievements(ctx, tx, merged.Achievements, report); err != nil {
+			return err
+		}
+		return applyActivities(ctx, tx, merged.Activities, report)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply seed data: %w", err)
+	}
+	return report, nil
+}
+
+// merge combines packs, replacing entries with the same natural key in
+// place so the first definition fixes their order
+func merge(packs []*Pack) *Pack {
+	out := &Pack{}
+	exercises := make(map[string]int)
+	programs := make(map[string]int)
+	achievements := make(map[string]int)
+	activities := make(map[string]int)
+
+	for _, p := range packs {
+		for _, e := range p.Exercises {
+			out.Exercises = upsert(out.Exercises, exercises, e.Name, e)
+		}
+		for _, prog := range p.Programs {
+			out.Programs = upsert(out.Programs, programs, prog.Name, prog)
+		}
+		for _, a := range p.Achievements {
+			out.Achievements = upsert(out.Achievements, achievements, a.Name, a)
+		}
+		for _, a := range p.Activities {
+			out.Activities = upsert(out.Activities, activities, a.key(), a)
+		}
+	}
+	return out
+}
+
+// upsert replaces the entry at index[key] or appends v
+func upsert[T any](list []T, index map[string]int, key string, v T) []T {
+	if i, ok := index[key]; ok {
+		list[i] = v
+		return list
+	}
+	index[key] = len(list)
+	return append(list, v)
+}
+
+// applyExercises upserts exercises and returns the resulting catalog of
+// lowercased names for validating program definitions
+func applyExercises(ctx context.Context, tx store.Store, seeds []Exercise, report *Report) (map[string]bool, error) {
+	existing, err := tx.Exercises().List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load exercises: %w", err)
+	}
+	byName := make(map[string]database.Exercise, len(existing))
+	catalog := make(map[string]bool, len(existing)+len(seeds))
+	for _, e := range existing {
+		byName[e.Name] = e
+		catalog[strings.ToLower(e.Name)] = true
+	}
+
+	for _, seed := range seeds {
+		old := byName[seed.Name]
+		row := old
+		row.Name = seed.Name
+		row.Category = seed.Category
+		row.Instructions = seed.Instructions
+		row.ProgressMultiplier = seed.ProgressMultiplier
+		if err := setJSON(&row.MuscleGroups, seed.MuscleGroups); err != nil {
+			return nil, fmt.Errorf("exercise %q: %w", seed.Name, err)
+		}
+
+		err := save(report, "exercise", seed.Name, &old, &row, func() error {
+			return tx.Exercises().Save(ctx, &row)
+		})
+		if err != nil {
+			return nil, err
+		}
+		catalog[strings.ToLower(seed.Name)] = true
+	}
+	return catalog, nil
+}
+
+func applyPrograms(ctx context.Context, tx store.Store, seeds []Program, catalog map[string]bool, report *Report) error {
+	existing, err := tx.Programs().List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load programs: %w", err)
+	}
+	byName := make(map[string]database.Program, len(existing))
+	for _, p := range existing {
+		byName[p.Name] = p
+	}
+
+	for _, seed := range seeds {
+		structure, err := encodeDefinition(&seed, catalog)
+		if err != nil {
+			return fmt.Errorf("program %q: %w", seed.Name, err)
+		}
+
+		old := byName[seed.Name]
+		row := old
+		row.Name = seed.Name
+		row.Description = seed.Description
+		row.Difficulty = seed.Difficulty
+		row.Duration = seed.DurationWeeks
+		if err := setJSON(&row.Structure, json.RawMessage(structure)); err != nil {
+			return fmt.Errorf("program %q: %w", seed.Name, err)
+		}
+
+		err = save(report, "program", seed.Name, &old, &row, func() error {
+			return tx.Programs().Save(ctx, &row)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyAchievements(ctx context.Context, tx store.Store, seeds []Achievement, report *Report) error {
+	existing, err := tx.Achievements().List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load achievements: %w", err)
+	}
+	byName := make(map[string]database.Achievement, len(existing))
+	for _, a := range existing {
+		byName[a.Name] = a
+	}
+
+	for _, seed := range seeds {
+		old := byName[seed.Name]
+		row := old
+		row.Name = seed.Name
+		row.Description = seed.Description
+		row.Category = seed.Category
+		row.Icon = seed.Icon
+		row.Target = seed.Target
+		row.IsFakeAchievement = seed.Fake
+		row.RarityPercent = seed.RarityPercent
+		row.WeaselMessage = seed.WeaselMessage
+		if err := setRule(&row.Rule, seed.Rule); err != nil {
+			return fmt.Errorf("achievement %q: %w", seed.Name, err)
+		}
+
+		err := save(report, "achievement", seed.Name, &old, &row, func() error {
+			return tx.Achievements().Save(ctx, &row)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyActivities(ctx context.Context, tx store.Store, seeds []Activity, report *Report) error {
+	existing, err := tx.Activities().List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load social activity: %w", err)
+	}
+	byKey := make(map[string]database.FakeSocialActivity, len(existing))
+	for _, a := range existing {
+		byKey[a.FakeUserName+": "+a.Details] = a
+	}
+
+	now := time.Now()
+	for _, seed := range seeds {
+		old, found := byKey[seed.key()]
+		row := old
+		row.ActivityType = seed.Type
+		row.FakeUserName = seed.FakeUser
+		row.Details = seed.Details
+		if !found {
+			row.Timestamp = now.Add(-time.Duration(seed.HoursAgo) * time.Hour)
+		}
+		if err := setJSON(&row.TargetUserGroups, seed.TargetUserGroups); err != nil {
+			return fmt.Errorf("social activity %q: %w", seed.key(), err)
+		}
+
+		err := save(report, "social_activity", seed.key(), &old, &row, func() error {
+			return tx.Activities().Save(ctx, &row)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// save writes row when it is new or differs from old and records the outcome
+func save[T any](report *Report, kind, name string, old, row *T, write func() error) error {
+	outcome := Updated
+	switch {
+	case reflect.ValueOf(old).Elem().FieldByName("ID").Uint() == 0:
+		outcome = Created
+	case reflect.DeepEqual(old, row):
+		report.add(kind, name, Unchanged)
+		return nil
+	}
+
+	if err := write(); err != nil {
+		return fmt.Errorf("failed to save %s %q: %w", kind, name, err)
+	}
+	report.add(kind, name, outcome)
+	return nil
+}
+
+// setJSON stores v as JSON in dst unless dst already holds an equivalent
+// document, so formatting differences (or jsonb normalization) do not count
+// as changes
+func setJSON(dst *string, v any) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode JSON: %w", err)
+	}
+
+	var current, desired any
+	if json.Unmarshal([]byte(*dst), &current) == nil && json.Unmarshal(raw, &desired) == nil &&
+		reflect.DeepEqual(current, desired) {
+		return nil
+	}
+	*dst = string(raw)
+	return nil
+}
+
+// setRule stores rule in dst, clearing it when the seed has no rule
+func setRule(dst **string, rule *achievements.Rule) error {
+	if rule == nil {
+		*dst = nil
+		return nil
+	}
+	var current string
+	if *dst != nil {
+		current = **dst
+	}
+	if err := setJSON(&current, rule); err != nil {
+		return err
+	}
+	*dst = &current
+	return nil
+}
+
+// encodeDefinition validates a program's definition, including its
+// exercises against the catalog, and encodes it for Program.Structure
+func encodeDefinition(p *Program, catalog map[string]bool) (string, error) {
+	def, err := p.definition()
+	if err != nil {
+		return "", err
+	}
+	if err := def.Validate(); err != nil {
+		return "", fmt.Errorf("program definition: %w", err)
+	}
+	if err := def.ValidateExercises(catalog); err != nil {
+		return "", fmt.Errorf("program definition: %w", err)
+	}
+	return def.JSON() //nolint:wrapcheck // programdef errors are already descriptive
+}
@@ -0,0 +1,283 @@
+// Package seed loads declarative seed data and upserts it into the store.
+//
+// A pack is a YAML (or JSON) document listing exercises, programs,
+// achievements and fake social activity:
+//
+//	exercises:
+//	  - name: Front Squat
+//	    category: compound
+//	    muscle_groups: [quadriceps, core]
+//	programs:
+//	  - name: Front Squat Focus
+//	    difficulty: intermediate
+//	    duration_weeks: 8
+//	    definition:
+//	      version: 1
+//	      days: [{name: A, slots: [{exercise: Front Squat, scheme: 5x5}]}]
+//	      progression: {default: {increment: 5}}
+//
+// Rows are matched by natural key: the name of exercises, programs and
+// achievements, and the fake user and details of social activity. Applying
+// a pack creates missing rows, updates rows that differ and leaves the rest
+// alone, so seeding can be rerun safely. The built-in pack is embedded in
+// the binary; ops can load further packs from disk.
+package seed
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/achievements"
+	"github.com/lucas-albers-lz4/ferrovis/internal/programdef"
+	"gopkg.in/yaml.v3"
+)
+
+// builtinFiles holds the built-in seed data
+//
+//go:embed data/*.yaml
+var builtinFiles embed.FS
+
+// Pack is one seed file
+type Pack struct {
+	Source string `yaml:"-"` // File the pack was loaded from, for error messages
+
+	Exercises    []Exercise    `yaml:"exercises"`
+	Programs     []Program     `yaml:"programs"`
+	Achievements []Achievement `yaml:"achievements"`
+	Activities   []Activity    `yaml:"social_activity"`
+}
+
+// Exercise seeds an exercise
+type Exercise struct {
+	Name               string   `yaml:"name"`
+	Category           string   `yaml:"category"`
+	MuscleGroups       []string `yaml:"muscle_groups"`
+	Instructions       string   `yaml:"instructions"`
+	ProgressMultiplier float64  `yaml:"progress_multiplier"`
+}
+
+// Program seeds a workout program. Exactly one of Builtin and Definition is set.
+type Program struct {
+	Name          string `yaml:"name"`
+	Description   string `yaml:"description"`
+	Difficulty    string `yaml:"difficulty"`
+	DurationWeeks int    `yaml:"duration_weeks"`
+
+	// Builtin names a definition shipped in package programdef
+	Builtin string `yaml:"builtin"`
+	// Definition is an inline program definition in the programdef format
+	Definition map[string]any `yaml:"definition"`
+}
+
+// Achievement seeds an achievement
+type Achievement struct {
+	Name          string `yaml:"name"`
+	Description   string `yaml:"description"`
+	Category      string `yaml:"category"`
+	Icon          string `yaml:"icon"`
+	Target        int    `yaml:"target"`
+	Fake          bool   `yaml:"fake"`
+	RarityPercent int    `yaml:"rarity_percent"`
+	WeaselMessage string `yaml:"weasel_message"`
+
+	// Rule is the unlock rule; achievements without one are awarded by hand
+	Rule *achievements.Rule `yaml:"rule"`
+}
+
+// Activity seeds a fake social activity entry
+type Activity struct {
+	Type             string   `yaml:"type"`
+	FakeUser         string   `yaml:"fake_user"`
+	Details          string   `yaml:"details"`
+	HoursAgo         int      `yaml:"hours_ago"` // Only used when the entry is created
+	TargetUserGroups []string `yaml:"target_user_groups"`
+}
+
+// key is the natural key of an activity
+func (a *Activity) key() string {
+	return a.FakeUser + ": " + a.Details
+}
+
+// Builtin returns the packs embedded in the binary
+func Builtin() ([]*Pack, error) {
+	return loadFS(builtinFiles, "data")
+}
+
+// Load reads packs from files and directories. Directories contribute their
+// .yaml, .yml and .json files in name order.
+func Load(paths ...string) ([]*Pack, error) {
+	var packs []*Pack
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read seed pack: %w", err)
+		}
+		if !info.IsDir() {
+			pack, err := loadFile(os.DirFS(filepath.Dir(p)), filepath.Base(p), p)
+			if err != nil {
+				return nil, err
+			}
+			packs = append(packs, pack)
+			continue
+		}
+
+		dirPacks, err := loadFS(os.DirFS(p), ".")
+		if err != nil {
+			return nil, err
+		}
+		for _, pack := range dirPacks {
+			pack.Source = filepath.Join(p, pack.Source)
+		}
+		packs = append(packs, dirPacks...)
+	}
+	return packs, nil
+}
+
+// loadFS loads every pack file in dir
+func loadFS(fsys fs.FS, dir string) ([]*Pack, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list seed packs: %w", err)
+	}
+
+	var names []string
+	for _, e := range entries {
+		switch strings.ToLower(path.Ext(e.Name())) {
+		case ".yaml", ".yml", ".json":
+			if !e.IsDir() {
+				names = append(names, e.Name())
+			}
+		}
+	}
+	sort.Strings(names)
+
+	packs := make([]*Pack, 0, len(names))
+	for _, name := range names {
+		pack, err := loadFile(fsys, path.Join(dir, name), name)
+		if err != nil {
+			return nil, err
+		}
+		packs = append(packs, pack)
+	}
+	return packs, nil
+}
+
+func loadFile(fsys fs.FS, name, source string) (*Pack, error) {
+	raw, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read seed pack: %w", err)
+	}
+	return Parse(source, raw)
+}
+
+// Parse decodes and validates a pack. JSON is accepted as a subset of YAML.
+// Unknown fields are rejected so typos surface as errors.
+func Parse(source string, raw []byte) (*Pack, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+
+	pack := &Pack{}
+	if err := dec.Decode(pack); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: invalid seed pack: %w", source, err)
+	}
+	pack.Source = source
+
+	if err := pack.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+	return pack, nil
+}
+
+// validate checks required fields and that no natural key repeats within the pack
+func (p *Pack) validate() error {
+	var problems []string
+	add := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	names := make(map[string]bool)
+	for i, e := range p.Exercises {
+		checkName(add, names, fmt.Sprintf("exercises[%d]", i), e.Name)
+	}
+
+	clear(names)
+	for i, prog := range p.Programs {
+		at := fmt.Sprintf("programs[%d]", i)
+		checkName(add, names, at, prog.Name)
+		if (prog.Builtin == "") == (prog.Definition == nil) {
+			add("%s: exactly one of builtin and definition is required", at)
+		}
+		if prog.Builtin != "" {
+			if _, ok := programdef.Builtin(prog.Builtin); !ok {
+				add("%s.builtin: unknown program %q", at, prog.Builtin)
+			}
+		}
+	}
+
+	clear(names)
+	for i, a := range p.Achievements {
+		at := fmt.Sprintf("achievements[%d]", i)
+		checkName(add, names, at, a.Name)
+		if a.Rule != nil {
+			if err := a.Rule.Validate(); err != nil {
+				add("%s.rule: %v", at, err)
+			}
+		}
+	}
+
+	clear(names)
+	for i, a := range p.Activities {
+		at := fmt.Sprintf("social_activity[%d]", i)
+		if a.Type == "" || a.FakeUser == "" {
+			add("%s: type and fake_user are required", at)
+			continue
+		}
+		if names[a.key()] {
+			add("%s: duplicate entry for %q", at, a.key())
+		}
+		names[a.key()] = true
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid seed pack: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// checkName reports a missing or repeated name
+func checkName(add func(string, ...any), seen map[string]bool, at, name string) {
+	switch {
+	case strings.TrimSpace(name) == "":
+		add("%s.name: required", at)
+	case seen[name]:
+		add("%s.name: duplicate %q", at, name)
+	}
+	seen[name] = true
+}
+
+// definition resolves the program's definition
+func (p *Program) definition() (*programdef.Definition, error) {
+	if p.Builtin != "" {
+		def, ok := programdef.Builtin(p.Builtin)
+		if !ok {
+			return nil, fmt.Errorf("unknown built-in program %q", p.Builtin)
+		}
+		return def, nil
+	}
+
+	raw, err := json.Marshal(p.Definition)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode program definition: %w", err)
+	}
+	return programdef.Parse(raw) //nolint:wrapcheck // programdef errors carry the field path
+}
@@ -0,0 +1,221 @@
+package seed
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/programdef"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+)
+
+func builtin(t *testing.T) []*Pack {
+	t.Helper()
+	packs, err := Builtin()
+	if err != nil {
+		t.Fatalf("Failed to load built-in seed data: %v", err)
+	}
+	return packs
+}
+
+func TestBuiltinValues(t *testing.T) {
+	merged := merge(builtin(t))
+	if len(merged.Exercises) == 0 || len(merged.Programs) == 0 || len(merged.Achievements) == 0 || len(merged.Activities) == 0 {
+		t.Fatalf("Expected every kind of seed data, got %+v", merged)
+	}
+
+	for _, e := range merged.Exercises {
+		if e.ProgressMultiplier < 1.0 || e.ProgressMultiplier > 2.0 {
+			t.Errorf("Progress multiplier for %s should be between 1.0 and 2.0, got %f", e.Name, e.ProgressMultiplier)
+		}
+	}
+	for _, a := range merged.Achievements {
+		if a.Target <= 0 {
+			t.Errorf("Achievement target for %s should be positive, got %d", a.Name, a.Target)
+		}
+		if a.RarityPercent <= 0 || a.RarityPercent > 100 {
+			t.Errorf("Achievement rarity for %s should be between 1-100, got %d", a.Name, a.RarityPercent)
+		}
+		if a.Rule == nil {
+			t.Errorf("Achievement %s should have an unlock rule", a.Name)
+		}
+	}
+	for _, p := range merged.Programs {
+		if p.DurationWeeks <= 0 {
+			t.Errorf("Program %s should have a duration, got %d", p.Name, p.DurationWeeks)
+		}
+	}
+}
+
+func TestApplyIsIdempotent(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	packs := builtin(t)
+
+	report, err := Apply(ctx, s, packs...)
+	if err != nil {
+		t.Fatalf("Failed to apply seed data: %v", err)
+	}
+	if report.Count(Updated) != 0 || report.Count(Unchanged) != 0 || report.Count(Created) != len(report.Changes) {
+		t.Errorf("Expected a fresh store to only see creates, got %+v", report.Changes)
+	}
+
+	programs, err := s.Programs().List(ctx)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, p := range programs {
+		if _, err := programdef.Parse([]byte(p.Structure)); err != nil {
+			t.Errorf("Seeded program %s has an invalid structure: %v", p.Name, err)
+		}
+	}
+
+	report, err = Apply(ctx, s, packs...)
+	if err != nil {
+		t.Fatalf("Failed to reapply seed data: %v", err)
+	}
+	if report.Count(Unchanged) != len(report.Changes) {
+		t.Errorf("Expected rerunning to change nothing, got %+v", report.Changes)
+	}
+}
+
+func TestApplyUpdatesAndAddsToExistingData(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+
+	// An older database: seeded once, then an achievement was edited by hand
+	old := &database.Achievement{Name: "Iron Will", Description: "stale", Target: 1, RarityPercent: 8}
+	if err := s.Achievements().Save(ctx, old); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	report, err := Apply(ctx, s, builtin(t)...)
+	if err != nil {
+		t.Fatalf("Failed to apply seed data: %v", err)
+	}
+
+	outcomes := make(map[string]Outcome)
+	for _, c := range report.Changes {
+		if c.Kind == "achievement" {
+			outcomes[c.Name] = c.Outcome
+		}
+	}
+	if outcomes["Iron Will"] != Updated {
+		t.Errorf("Expected existing achievement to be updated, got %q", outcomes["Iron Will"])
+	}
+	if outcomes["Gym Whisperer"] != Created {
+		t.Errorf("Expected missing achievement to be created, got %q", outcomes["Gym Whisperer"])
+	}
+
+	achievements, err := s.Achievements().List(ctx)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, a := range achievements {
+		if a.Name == "Iron Will" && (a.ID != old.ID || a.Target != 30) {
+			t.Errorf("Expected achievement %d to be updated in place, got %+v", old.ID, a)
+		}
+	}
+}
+
+func TestCustomPack(t *testing.T) {
+	dir := t.TempDir()
+	pack := `
+exercises:
+  - name: Front Squat
+    category: compound
+    muscle_groups: [quadriceps, core]
+    progress_multiplier: 1.1
+programs:
+  - name: Front Squat Focus
+    difficulty: intermediate
+    duration_weeks: 8
+    definition:
+      version: 1
+      days:
+        - name: A
+          slots: [{exercise: Front Squat, scheme: 5x5}, {exercise: Deadlift, scheme: 1x5}]
+      progression: {default: {increment: 5}}
+achievements:
+  - name: Iron Will
+    description: Maintain a 60-day workout streak
+    target: 60
+    rarity_percent: 3
+`
+	if err := os.WriteFile(filepath.Join(dir, "front-squat.yaml"), []byte(pack), 0o600); err != nil {
+		t.Fatalf("Failed to write pack: %v", err)
+	}
+	custom, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Failed to load pack: %v", err)
+	}
+
+	ctx := context.Background()
+	s := store.NewMemory()
+	if _, err := Apply(ctx, s, append(builtin(t), custom...)...); err != nil {
+		t.Fatalf("Failed to apply seed data: %v", err)
+	}
+
+	programs, err := s.Programs().List(ctx)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(programs) != 3 || programs[2].Name != "Front Squat Focus" {
+		t.Errorf("Expected custom program after built-in ones, got %+v", programs)
+	}
+
+	achievements, err := s.Achievements().List(ctx)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, a := range achievements {
+		if a.Name == "Iron Will" && a.Target != 60 {
+			t.Errorf("Expected later pack to override built-in achievement, got target %d", a.Target)
+		}
+	}
+}
+
+func TestParseRejectsInvalidPacks(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		expectedError string
+	}{
+		{"unknown field", "exercises:\n  - name: Squat\n    colour: red\n", "field colour not found"},
+		{"missing name", "achievements:\n  - target: 3\n", "achievements[0].name: required"},
+		{"duplicate name", "exercises:\n  - name: Squat\n  - name: Squat\n", `exercises[1].name: duplicate "Squat"`},
+		{"no definition", "programs:\n  - name: Empty\n", "exactly one of builtin and definition"},
+		{"unknown builtin", "programs:\n  - name: X\n    builtin: Nope\n", `unknown program "Nope"`},
+		{"invalid rule", "achievements:\n  - name: X\n    rule: {metric: max_weight}\n", "achievements[0].rule: invalid achievement rule: exercise: required"},
+		{"activity without user", "social_activity:\n  - type: pr_achieved\n", "type and fake_user are required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse("test.yaml", []byte(tt.body))
+			if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+				t.Errorf("Expected error containing %q, got %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestApplyRejectsProgramsWithUnknownExercises(t *testing.T) {
+	pack, err := Parse("test.json", []byte(`{"programs": [{"name": "Curls", "definition": {
+		"version": 1, "days": [{"name": "A", "slots": [{"exercise": "Curl", "scheme": "3x10"}]}],
+		"progression": {"default": {"increment": 5}}}}]}`))
+	if err != nil {
+		t.Fatalf("Expected JSON pack to parse, got %v", err)
+	}
+
+	s := store.NewMemory()
+	if _, err := Apply(context.Background(), s, pack); err == nil {
+		t.Fatal("Expected unknown exercise to be rejected")
+	}
+	if n, _ := s.Programs().Count(context.Background()); n != 0 { //nolint:errcheck // memory store count cannot fail
+		t.Errorf("Expected failed seeding to roll back, got %d programs", n)
+	}
+}
@@ -0,0 +1,1359 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"gorm.io/gorm/schema"
+)
+
+// Memory implements Store in process; intended for tests. Rows are stored
+// without their associations and loaded on read, mirroring the Postgres
+// store's preloads. Transactions hold a single lock and work on a copy of
+// every table that replaces the original on commit.
+type Memory struct {
+	mu   *sync.Mutex // nil inside a transaction, which already holds the lock
+	data *tables
+}
+
+// NewMemory creates an empty Memory store
+func NewMemory() *Memory {
+	return &Memory{mu: &sync.Mutex{}, data: newTables()}
+}
+
+// table holds rows of one model keyed by ID
+type table[T any] struct {
+	next uint
+	rows map[uint]T
+}
+
+func newTable[T any]() *table[T] {
+	return &table[T]{rows: make(map[uint]T)}
+}
+
+// nextID allocates the next ID, like a bigserial column
+func (t *table[T]) nextID() uint {
+	t.next++
+	return t.next
+}
+
+// all returns the rows accepted by keep in ID order
+func (t *table[T]) all(keep func(*T) bool) []T {
+	var out []T
+	for id := uint(1); id <= t.next; id++ {
+		row, ok := t.rows[id]
+		if ok && (keep == nil || keep(&row)) {
+			out = append(out, row)
+		}
+	}
+	return out
+}
+
+// first returns the first row accepted by keep in ID order
+func (t *table[T]) first(keep func(*T) bool) (T, bool) {
+	for id := uint(1); id <= t.next; id++ {
+		row, ok := t.rows[id]
+		if ok && keep(&row) {
+			return row, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// insert stores row with a new ID and timestamps, filling tag defaults the
+// way GORM does. The stored copy drops associations.
+func (t *table[T]) insert(row *T) error {
+	if err := applyDefaults(row); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	v := reflect.ValueOf(row).Elem()
+	v.FieldByName("ID").SetUint(uint64(t.nextID()))
+	v.FieldByName("CreatedAt").Set(reflect.ValueOf(now))
+	v.FieldByName("UpdatedAt").Set(reflect.ValueOf(now))
+	return t.put(row)
+}
+
+// put replaces the stored copy of row, dropping its associations
+func (t *table[T]) put(row *T) error {
+	s, err := parseModel(row)
+	if err != nil {
+		return err
+	}
+
+	flat := *row
+	v := reflect.ValueOf(&flat).Elem()
+	for _, rel := range s.Relationships.Relations {
+		if rel.Schema != s {
+			continue // Back-reference registered by a related model
+		}
+		v.FieldByName(rel.Field.Name).SetZero()
+	}
+	t.rows[uint(v.FieldByName("ID").Uint())] = flat
+	return nil
+}
+
+// save inserts row when it has no ID and replaces the stored copy otherwise,
+// like GORM's Save
+func (t *table[T]) save(row *T) error {
+	v := reflect.ValueOf(row).Elem()
+	if v.FieldByName("ID").Uint() == 0 {
+		return t.insert(row)
+	}
+	v.FieldByName("UpdatedAt").Set(reflect.ValueOf(time.Now()))
+	return t.put(row)
+}
+
+func (t *table[T]) clone() *table[T] {
+	return &table[T]{next: t.next, rows: maps.Clone(t.rows)}
+}
+
+type tables struct {
+	users            *table[database.User]
+	refreshTokens    *table[database.RefreshToken]
+	actionTokens     *table[database.ActionToken]
+	workouts         *table[database.Workout]
+	sets             *table[database.WorkoutSet]
+	programs         *table[database.Program]
+	exercises        *table[database.Exercise]
+	enrollments      *table[database.Enrollment]
+	lifts            *table[database.EnrollmentLift]
+	achievements     *table[database.Achievement]
+	userAchievements *table[database.UserAchievement]
+	buddies          *table[database.BuddyRelationship]
+	buddyInvites     *table[database.BuddyInvite]
+	coachPermissions *table[database.CoachPermission]
+	challenges       *table[database.Challenge]
+	participants     *table[database.ChallengeParticipant]
+	messages         *table[database.WeaselMessage]
+	streaks          *table[database.Streak]
+	activities       *table[database.FakeSocialActivity]
+	notifications    *table[database.Notification]
+}
+
+func newTables() *tables {
+	return &tables{
+		users:            newTable[database.User](),
+		refreshTokens:    newTable[database.RefreshToken](),
+		actionTokens:     newTable[database.ActionToken](),
+		workouts:         newTable[database.Workout](),
+		sets:             newTable[database.WorkoutSet](),
+		programs:         newTable[database.Program](),
+		exercises:        newTable[database.Exercise](),
+		enrollments:      newTable[database.Enrollment](),
+		lifts:            newTable[database.EnrollmentLift](),
+		achievements:     newTable[database.Achievement](),
+		userAchievements: newTable[database.UserAchievement](),
+		buddies:          newTable[database.BuddyRelationship](),
+		buddyInvites:     newTable[database.BuddyInvite](),
+		coachPermissions: newTable[database.CoachPermission](),
+		challenges:       newTable[database.Challenge](),
+		participants:     newTable[database.ChallengeParticipant](),
+		messages:         newTable[database.WeaselMessage](),
+		streaks:          newTable[database.Streak](),
+		activities:       newTable[database.FakeSocialActivity](),
+		notifications:    newTable[database.Notification](),
+	}
+}
+
+func (t *tables) clone() *tables {
+	return &tables{
+		users:            t.users.clone(),
+		refreshTokens:    t.refreshTokens.clone(),
+		actionTokens:     t.actionTokens.clone(),
+		workouts:         t.workouts.clone(),
+		sets:             t.sets.clone(),
+		programs:         t.programs.clone(),
+		exercises:        t.exercises.clone(),
+		enrollments:      t.enrollments.clone(),
+		lifts:            t.lifts.clone(),
+		achievements:     t.achievements.clone(),
+		userAchievements: t.userAchievements.clone(),
+		buddies:          t.buddies.clone(),
+		buddyInvites:     t.buddyInvites.clone(),
+		coachPermissions: t.coachPermissions.clone(),
+		challenges:       t.challenges.clone(),
+		participants:     t.participants.clone(),
+		messages:         t.messages.clone(),
+		streaks:          t.streaks.clone(),
+		activities:       t.activities.clone(),
+		notifications:    t.notifications.clone(),
+	}
+}
+
+// lock takes the store lock outside transactions and returns its release
+func (s *Memory) lock() func() {
+	if s.mu == nil {
+		return func() {}
+	}
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+// Users returns the user repository
+func (s *Memory) Users() UserRepository { return memUsers{s} }
+
+// Sessions returns the refresh token repository
+func (s *Memory) Sessions() SessionRepository { return memSessions{s} }
+
+// ActionTokens returns the action token repository
+func (s *Memory) ActionTokens() ActionTokenRepository { return memActionTokens{s} }
+
+// Workouts returns the workout repository
+func (s *Memory) Workouts() WorkoutRepository { return memWorkouts{s} }
+
+// Programs returns the program repository
+func (s *Memory) Programs() ProgramRepository { return memPrograms{s} }
+
+// Exercises returns the exercise repository
+func (s *Memory) Exercises() ExerciseRepository { return memExercises{s} }
+
+// Enrollments returns the enrollment repository
+func (s *Memory) Enrollments() EnrollmentRepository { return memEnrollments{s} }
+
+// Achievements returns the achievement repository
+func (s *Memory) Achievements() AchievementRepository { return memAchievements{s} }
+
+// Buddies returns the buddy relationship repository
+func (s *Memory) Buddies() BuddyRepository { return memBuddies{s} }
+
+// BuddyInvites returns the buddy invitation repository
+func (s *Memory) BuddyInvites() BuddyInviteRepository { return memBuddyInvites{s} }
+
+// CoachPermissions returns the coach permission repository
+func (s *Memory) CoachPermissions() CoachPermissionRepository { return memCoachPermissions{s} }
+
+// Challenges returns the group challenge repository
+func (s *Memory) Challenges() ChallengeRepository { return memChallenges{s} }
+
+// Messages returns the Weasel Mode message repository
+func (s *Memory) Messages() MessageRepository { return memMessages{s} }
+
+// Streaks returns the streak repository
+func (s *Memory) Streaks() StreakRepository { return memStreaks{s} }
+
+// Activities returns the social activity repository
+func (s *Memory) Activities() ActivityRepository { return memActivities{s} }
+
+// Notifications returns the notification outbox repository
+func (s *Memory) Notifications() NotificationRepository { return memNotifications{s} }
+
+// Transaction runs fn against a copy of the data that replaces the original
+// when fn succeeds
+func (s *Memory) Transaction(_ context.Context, fn func(tx Store) error) error {
+	if s.mu == nil {
+		return fn(s)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := &Memory{data: s.data.clone()}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	s.data = tx.data
+	return nil
+}
+
+// notFound and duplicate build errors matching the Postgres store's
+func notFound(action string) error {
+	return fmt.Errorf("failed to %s: %w", action, ErrNotFound)
+}
+
+func duplicate(action string) error {
+	return fmt.Errorf("failed to %s: %w", action, ErrDuplicate)
+}
+
+// page applies limit and offset to rows
+func page[T any](rows []T, p Page) []T {
+	if p.Offset >= len(rows) {
+		return nil
+	}
+	rows = rows[p.Offset:]
+	if p.Limit > 0 && p.Limit < len(rows) {
+		rows = rows[:p.Limit]
+	}
+	return rows
+}
+
+// schemaCache caches parsed model schemas
+var schemaCache sync.Map
+
+func parseModel(row any) (*schema.Schema, error) {
+	s, err := schema.Parse(row, &schemaCache, schema.NamingStrategy{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse model: %w", err)
+	}
+	return s, nil
+}
+
+// applyDefaults fills zero fields that have a default tag, as GORM does on insert
+func applyDefaults(row any) error {
+	s, err := parseModel(row)
+	if err != nil {
+		return err
+	}
+
+	value := reflect.ValueOf(row)
+	for _, field := range s.Fields {
+		if field.DefaultValueInterface == nil {
+			continue
+		}
+		if _, zero := field.ValueOf(context.Background(), value); !zero {
+			continue
+		}
+		if err := field.Set(context.Background(), value, field.DefaultValueInterface); err != nil {
+			return fmt.Errorf("failed to set default for %s: %w", field.DBName, err)
+		}
+	}
+	return nil
+}
+
+// applyChanges sets fields from a column => value map the way GORM's Updates does
+func applyChanges(row any, changes map[string]any) error {
+	s, err := parseModel(row)
+	if err != nil {
+		return err
+	}
+
+	value := reflect.ValueOf(row)
+	for column, v := range changes {
+		field := s.LookUpField(column)
+		if field == nil {
+			return fmt.Errorf("unknown column %q", column)
+		}
+		if err := field.Set(context.Background(), value, v); err != nil {
+			return fmt.Errorf("failed to set %s: %w", column, err)
+		}
+	}
+	return nil
+}
+
+type memUsers struct{ s *Memory }
+
+func (r memUsers) ListWeaselEnabled(_ context.Context, afterID uint, limit int) ([]database.User, error) {
+	defer r.s.lock()()
+	users := r.s.data.users.all(func(u *database.User) bool { return u.ID > afterID && u.WeaselModeEnabled })
+	if len(users) > limit {
+		users = users[:limit]
+	}
+	return users, nil
+}
+
+func (r memUsers) Get(_ context.Context, id uint) (*database.User, error) {
+	defer r.s.lock()()
+	user, ok := r.s.data.users.rows[id]
+	if !ok {
+		return nil, notFound("load user")
+	}
+	return &user, nil
+}
+
+func (r memUsers) GetByEmail(_ context.Context, email string) (*database.User, error) {
+	defer r.s.lock()()
+	user, ok := r.s.data.users.first(func(u *database.User) bool { return u.Email == email })
+	if !ok {
+		return nil, notFound("load user")
+	}
+	return &user, nil
+}
+
+func (r memUsers) Create(_ context.Context, user *database.User) error {
+	defer r.s.lock()()
+	users := r.s.data.users
+	if _, taken := users.first(func(u *database.User) bool { return u.Email == user.Email }); taken {
+		return duplicate("create user")
+	}
+	return users.insert(user)
+}
+
+func (r memUsers) Update(_ context.Context, id uint, changes map[string]any) error {
+	defer r.s.lock()()
+	return r.update(id, func(u *database.User) error {
+		return applyChanges(u, changes)
+	})
+}
+
+func (r memUsers) SetPassword(_ context.Context, id uint, hash string) error {
+	defer r.s.lock()()
+	return r.update(id, func(u *database.User) error {
+		u.Password = hash
+		u.TokenVersion++
+		return nil
+	})
+}
+
+func (r memUsers) IncrementTokenVersion(_ context.Context, id uint) error {
+	defer r.s.lock()()
+	return r.update(id, func(u *database.User) error {
+		u.TokenVersion++
+		return nil
+	})
+}
+
+func (r memUsers) MarkEmailVerified(_ context.Context, id uint, at time.Time) error {
+	defer r.s.lock()()
+	return r.update(id, func(u *database.User) error {
+		if u.EmailVerifiedAt == nil {
+			u.EmailVerifiedAt = &at
+		}
+		return nil
+	})
+}
+
+// update applies fn to a stored user; like an UPDATE, a missing row is not an error
+func (r memUsers) update(id uint, fn func(*database.User) error) error {
+	user, ok := r.s.data.users.rows[id]
+	if !ok {
+		return nil
+	}
+	if err := fn(&user); err != nil {
+		return err
+	}
+	user.UpdatedAt = time.Now()
+	r.s.data.users.rows[id] = user
+	return nil
+}
+
+type memSessions struct{ s *Memory }
+
+func (r memSessions) Create(_ context.Context, token *database.RefreshToken) error {
+	defer r.s.lock()()
+	tokens := r.s.data.refreshTokens
+	if _, taken := tokens.first(func(t *database.RefreshToken) bool { return t.TokenHash == token.TokenHash }); taken {
+		return duplicate("store refresh token")
+	}
+	return tokens.insert(token)
+}
+
+func (r memSessions) GetByHash(_ context.Context, hash string) (*database.RefreshToken, error) {
+	defer r.s.lock()()
+	token, ok := r.s.data.refreshTokens.first(func(t *database.RefreshToken) bool { return t.TokenHash == hash })
+	if !ok {
+		return nil, notFound("look up refresh token")
+	}
+	return &token, nil
+}
+
+func (r memSessions) Claim(_ context.Context, id uint, at time.Time) (bool, error) {
+	defer r.s.lock()()
+	token, ok := r.s.data.refreshTokens.rows[id]
+	if !ok || token.UsedAt != nil || token.RevokedAt != nil {
+		return false, nil
+	}
+	token.UsedAt = &at
+	r.s.data.refreshTokens.rows[id] = token
+	return true, nil
+}
+
+func (r memSessions) SetReplacedBy(_ context.Context, id, replacedByID uint) error {
+	defer r.s.lock()()
+	if token, ok := r.s.data.refreshTokens.rows[id]; ok {
+		token.ReplacedByID = &replacedByID
+		r.s.data.refreshTokens.rows[id] = token
+	}
+	return nil
+}
+
+func (r memSessions) RevokeFamily(_ context.Context, familyID string, at time.Time) error {
+	defer r.s.lock()()
+	r.revoke(at, func(t *database.RefreshToken) bool { return t.FamilyID == familyID })
+	return nil
+}
+
+func (r memSessions) RevokeAll(_ context.Context, userID uint, at time.Time) error {
+	defer r.s.lock()()
+	r.revoke(at, func(t *database.RefreshToken) bool { return t.UserID == userID })
+	return nil
+}
+
+// revoke stamps RevokedAt on every live token accepted by match
+func (r memSessions) revoke(at time.Time, match func(*database.RefreshToken) bool) {
+	tokens := r.s.data.refreshTokens
+	for _, t := range tokens.all(match) {
+		if t.RevokedAt == nil {
+			t.RevokedAt = &at
+			tokens.rows[t.ID] = t
+		}
+	}
+}
+
+type memActionTokens struct{ s *Memory }
+
+func (r memActionTokens) Create(_ context.Context, token *database.ActionToken) error {
+	defer r.s.lock()()
+	tokens := r.s.data.actionTokens
+	if _, taken := tokens.first(func(t *database.ActionToken) bool { return t.TokenID == token.TokenID }); taken {
+		return duplicate("store action token")
+	}
+	return tokens.insert(token)
+}
+
+func (r memActionTokens) Redeem(_ context.Context, tokenID string, userID uint, purpose string, at time.Time) (bool, error) {
+	defer r.s.lock()()
+	tokens := r.s.data.actionTokens
+	token, ok := tokens.first(func(t *database.ActionToken) bool {
+		return t.TokenID == tokenID && t.UserID == userID && t.Purpose == purpose &&
+			t.UsedAt == nil && t.ExpiresAt.After(at)
+	})
+	if !ok {
+		return false, nil
+	}
+	token.UsedAt = &at
+	tokens.rows[token.ID] = token
+	return true, nil
+}
+
+func (r memActionTokens) ExpireAll(_ context.Context, userID uint, purpose string, at time.Time) error {
+	defer r.s.lock()()
+	tokens := r.s.data.actionTokens
+	for _, t := range tokens.all(func(t *database.ActionToken) bool {
+		return t.UserID == userID && t.Purpose == purpose && t.UsedAt == nil
+	}) {
+		t.UsedAt = &at
+		tokens.rows[t.ID] = t
+	}
+	return nil
+}
+
+type memWorkouts struct{ s *Memory }
+
+// load attaches a workout's sets, in logging order, and their exercises
+func (r memWorkouts) load(w database.Workout) database.Workout {
+	d := r.s.data
+	w.Sets = d.sets.all(func(s *database.WorkoutSet) bool { return s.WorkoutID == w.ID })
+	sort.SliceStable(w.Sets, func(i, j int) bool {
+		a, b := w.Sets[i], w.Sets[j]
+		if a.ExerciseOrder != b.ExerciseOrder {
+			return a.ExerciseOrder < b.ExerciseOrder
+		}
+		return a.SetIndex < b.SetIndex
+	})
+	for i := range w.Sets {
+		w.Sets[i].Exercise = d.exercises.rows[w.Sets[i].ExerciseID]
+	}
+	return w
+}
+
+func (r memWorkouts) Get(_ context.Context, userID, id uint) (*database.Workout, error) {
+	defer r.s.lock()()
+	w, ok := r.s.data.workouts.rows[id]
+	if !ok || w.UserID != userID {
+		return nil, notFound("load workout")
+	}
+	w = r.load(w)
+	return &w, nil
+}
+
+func (r memWorkouts) List(_ context.Context, userID uint, p Page) ([]database.Workout, int64, error) {
+	defer r.s.lock()()
+	rows := r.s.data.workouts.all(func(w *database.Workout) bool { return w.UserID == userID })
+	sort.SliceStable(rows, func(i, j int) bool {
+		if !rows[i].CompletedAt.Equal(rows[j].CompletedAt) {
+			return rows[i].CompletedAt.After(rows[j].CompletedAt)
+		}
+		return rows[i].ID > rows[j].ID
+	})
+
+	out := page(rows, p)
+	for i := range out {
+		out[i] = r.load(out[i])
+	}
+	return out, int64(len(rows)), nil
+}
+
+func (r memWorkouts) History(_ context.Context, q HistoryQuery) ([]database.Workout, error) {
+	defer r.s.lock()()
+	rows := r.s.data.workouts.all(func(w *database.Workout) bool {
+		return w.UserID == q.UserID && (q.ProgramID == 0 || w.ProgramID != nil && *w.ProgramID == q.ProgramID) &&
+			!w.CompletedAt.Before(q.Since) && (q.Until == nil || !w.CompletedAt.After(*q.Until))
+	})
+	sort.SliceStable(rows, func(i, j int) bool {
+		if !rows[i].CompletedAt.Equal(rows[j].CompletedAt) {
+			return rows[i].CompletedAt.Before(rows[j].CompletedAt)
+		}
+		return rows[i].ID < rows[j].ID
+	})
+	for i := range rows {
+		rows[i] = r.load(rows[i])
+	}
+	return rows, nil
+}
+
+func (r memWorkouts) Create(_ context.Context, workout *database.Workout) error {
+	defer r.s.lock()()
+	if err := r.s.data.workouts.insert(workout); err != nil {
+		return err
+	}
+	return r.insertSets(workout)
+}
+
+func (r memWorkouts) Replace(_ context.Context, workout *database.Workout) error {
+	defer r.s.lock()()
+	if _, ok := r.s.data.workouts.rows[workout.ID]; !ok {
+		return notFound("update workout")
+	}
+	r.deleteSets(workout.ID)
+
+	workout.UpdatedAt = time.Now()
+	if err := r.s.data.workouts.put(workout); err != nil {
+		return err
+	}
+	return r.insertSets(workout)
+}
+
+func (r memWorkouts) Delete(_ context.Context, userID, id uint) error {
+	defer r.s.lock()()
+	w, ok := r.s.data.workouts.rows[id]
+	if !ok || w.UserID != userID {
+		return notFound("delete workout")
+	}
+	delete(r.s.data.workouts.rows, id)
+	r.deleteSets(id)
+	return nil
+}
+
+// insertSets stores a workout's sets
+func (r memWorkouts) insertSets(workout *database.Workout) error {
+	for i := range workout.Sets {
+		workout.Sets[i].WorkoutID = workout.ID
+		if err := r.s.data.sets.insert(&workout.Sets[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteSets removes every set of a workout
+func (r memWorkouts) deleteSets(workoutID uint) {
+	sets := r.s.data.sets
+	for _, s := range sets.all(func(s *database.WorkoutSet) bool { return s.WorkoutID == workoutID }) {
+		delete(sets.rows, s.ID)
+	}
+}
+
+type memPrograms struct{ s *Memory }
+
+func (r memPrograms) List(_ context.Context) ([]database.Program, error) {
+	defer r.s.lock()()
+	return r.s.data.programs.all(nil), nil
+}
+
+func (r memPrograms) Get(_ context.Context, id uint) (*database.Program, error) {
+	defer r.s.lock()()
+	program, ok := r.s.data.programs.rows[id]
+	if !ok {
+		return nil, notFound("load program")
+	}
+	return &program, nil
+}
+
+func (r memPrograms) Create(_ context.Context, program *database.Program) error {
+	defer r.s.lock()()
+	programs := r.s.data.programs
+	if _, taken := programs.first(func(p *database.Program) bool { return p.Name == program.Name }); taken {
+		return duplicate("create program")
+	}
+	return programs.insert(program)
+}
+
+func (r memPrograms) Save(_ context.Context, program *database.Program) error {
+	defer r.s.lock()()
+	programs := r.s.data.programs
+	if _, taken := programs.first(func(p *database.Program) bool { return p.Name == program.Name && p.ID != program.ID }); taken {
+		return duplicate("save program")
+	}
+	return programs.save(program)
+}
+
+func (r memPrograms) Count(_ context.Context) (int64, error) {
+	defer r.s.lock()()
+	return int64(len(r.s.data.programs.rows)), nil
+}
+
+type memExercises struct{ s *Memory }
+
+func (r memExercises) List(_ context.Context) ([]database.Exercise, error) {
+	defer r.s.lock()()
+	return r.s.data.exercises.all(nil), nil
+}
+
+func (r memExercises) Save(_ context.Context, exercise *database.Exercise) error {
+	defer r.s.lock()()
+	exercises := r.s.data.exercises
+	if _, taken := exercises.first(func(e *database.Exercise) bool { return e.Name == exercise.Name && e.ID != exercise.ID }); taken {
+		return duplicate("save exercise")
+	}
+	return exercises.save(exercise)
+}
+
+func (r memExercises) Count(_ context.Context) (int64, error) {
+	defer r.s.lock()()
+	return int64(len(r.s.data.exercises.rows)), nil
+}
+
+type memEnrollments struct{ s *Memory }
+
+// load attaches an enrollment's program and lifts
+func (r memEnrollments) load(e database.Enrollment) database.Enrollment {
+	e.Program = r.s.data.programs.rows[e.ProgramID]
+	e.Lifts = r.s.data.lifts.all(func(l *database.EnrollmentLift) bool { return l.EnrollmentID == e.ID })
+	return e
+}
+
+// isCurrent reports whether an enrollment is active or paused
+func isCurrent(e *database.Enrollment) bool {
+	return e.Status == database.EnrollmentActive || e.Status == database.EnrollmentPaused
+}
+
+func (r memEnrollments) Current(_ context.Context, userID uint) (*database.Enrollment, error) {
+	defer r.s.lock()()
+	e, ok := r.s.data.enrollments.first(func(e *database.Enrollment) bool { return e.UserID == userID && isCurrent(e) })
+	if !ok {
+		return nil, notFound("load enrollment")
+	}
+	e = r.load(e)
+	return &e, nil
+}
+
+func (r memEnrollments) List(_ context.Context, userID uint) ([]database.Enrollment, error) {
+	defer r.s.lock()()
+	rows := r.s.data.enrollments.all(func(e *database.Enrollment) bool { return e.UserID == userID })
+	sort.SliceStable(rows, func(i, j int) bool {
+		if !rows[i].StartedAt.Equal(rows[j].StartedAt) {
+			return rows[i].StartedAt.After(rows[j].StartedAt)
+		}
+		return rows[i].ID > rows[j].ID
+	})
+	for i := range rows {
+		rows[i] = r.load(rows[i])
+	}
+	return rows, nil
+}
+
+func (r memEnrollments) Create(_ context.Context, enrollment *database.Enrollment) error {
+	defer r.s.lock()()
+	d := r.s.data
+	if err := applyDefaults(enrollment); err != nil {
+		return err
+	}
+	// Mirrors the partial unique index idx_enrollments_current
+	if isCurrent(enrollment) {
+		if _, taken := d.enrollments.first(func(e *database.Enrollment) bool {
+			return e.UserID == enrollment.UserID && isCurrent(e)
+		}); taken {
+			return duplicate("create enrollment")
+		}
+	}
+
+	if err := d.enrollments.insert(enrollment); err != nil {
+		return err
+	}
+	for i := range enrollment.Lifts {
+		enrollment.Lifts[i].EnrollmentID = enrollment.ID
+		if err := d.lifts.insert(&enrollment.Lifts[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r memEnrollments) Update(_ context.Context, enrollment *database.Enrollment) error {
+	defer r.s.lock()()
+	row, ok := r.s.data.enrollments.rows[enrollment.ID]
+	if !ok {
+		return nil
+	}
+	row.Status = enrollment.Status
+	row.PausedAt = enrollment.PausedAt
+	row.EndedAt = enrollment.EndedAt
+	row.CurrentWeek = enrollment.CurrentWeek
+	row.CurrentDay = enrollment.CurrentDay
+	row.SessionsCompleted = enrollment.SessionsCompleted
+	row.UpdatedAt = time.Now()
+	r.s.data.enrollments.rows[row.ID] = row
+	return nil
+}
+
+func (r memEnrollments) UpdateLift(_ context.Context, lift *database.EnrollmentLift) error {
+	defer r.s.lock()()
+	row, ok := r.s.data.lifts.rows[lift.ID]
+	if !ok {
+		return nil
+	}
+	row.WorkingWeight = lift.WorkingWeight
+	row.ConsecutiveFailures = lift.ConsecutiveFailures
+	row.UpdatedAt = time.Now()
+	r.s.data.lifts.rows[row.ID] = row
+	return nil
+}
+
+type memAchievements struct{ s *Memory }
+
+func (r memAchievements) List(_ context.Context) ([]database.Achievement, error) {
+	defer r.s.lock()()
+	return r.s.data.achievements.all(nil), nil
+}
+
+func (r memAchievements) Save(_ context.Context, achievement *database.Achievement) error {
+	defer r.s.lock()()
+	achievements := r.s.data.achievements
+	if _, taken := achievements.first(func(a *database.Achievement) bool { return a.Name == achievement.Name && a.ID != achievement.ID }); taken {
+		return duplicate("save achievement")
+	}
+	return achievements.save(achievement)
+}
+
+func (r memAchievements) Count(_ context.Context) (int64, error) {
+	defer r.s.lock()()
+	return int64(len(r.s.data.achievements.rows)), nil
+}
+
+func (r memAchievements) Unlocked(_ context.Context, userID uint) ([]database.UserAchievement, error) {
+	defer r.s.lock()()
+	rows := r.s.data.userAchievements.all(func(ua *database.UserAchievement) bool {
+		return ua.UserID == userID && ua.UnlockedAt != nil
+	})
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].UnlockedAt.Before(*rows[j].UnlockedAt) })
+	for i := range rows {
+		rows[i].Achievement = r.s.data.achievements.rows[rows[i].AchievementID]
+	}
+	return rows, nil
+}
+
+func (r memAchievements) Progress(_ context.Context, userID uint) ([]database.UserAchievement, error) {
+	defer r.s.lock()()
+	return r.s.data.userAchievements.all(func(ua *database.UserAchievement) bool { return ua.UserID == userID }), nil
+}
+
+func (r memAchievements) SaveProgress(_ context.Context, progress *database.UserAchievement) error {
+	defer r.s.lock()()
+	rows := r.s.data.userAchievements
+	_, taken := rows.first(func(ua *database.UserAchievement) bool {
+		return ua.UserID == progress.UserID && ua.AchievementID == progress.AchievementID && ua.ID != progress.ID
+	})
+	if taken {
+		return duplicate("save achievement progress")
+	}
+	return rows.save(progress)
+}
+
+type memBuddies struct{ s *Memory }
+
+// load attaches both users of a relationship
+func (r memBuddies) load(rel database.BuddyRelationship) database.BuddyRelationship {
+	rel.User = r.s.data.users.rows[rel.UserID]
+	rel.Buddy = r.s.data.users.rows[rel.BuddyID]
+	return rel
+}
+
+func (r memBuddies) List(_ context.Context, userID uint) ([]database.BuddyRelationship, error) {
+	defer r.s.lock()()
+	rels := r.s.data.buddies.all(func(b *database.BuddyRelationship) bool {
+		return b.UserID == userID || b.BuddyID == userID
+	})
+	for i := range rels {
+		rels[i] = r.load(rels[i])
+	}
+	return rels, nil
+}
+
+func (r memBuddies) Get(_ context.Context, userID, id uint) (*database.BuddyRelationship, error) {
+	defer r.s.lock()()
+	rel, ok := r.s.data.buddies.rows[id]
+	if !ok || (rel.UserID != userID && rel.BuddyID != userID) {
+		return nil, notFound("load buddy relationship")
+	}
+	rel = r.load(rel)
+	return &rel, nil
+}
+
+func (r memBuddies) Between(_ context.Context, a, b uint) (*database.BuddyRelationship, error) {
+	defer r.s.lock()()
+	rel, ok := r.between(a, b)
+	if !ok {
+		return nil, notFound("load buddy relationship")
+	}
+	rel = r.load(rel)
+	return &rel, nil
+}
+
+// between finds the relationship linking two users in either direction
+func (r memBuddies) between(a, b uint) (database.BuddyRelationship, bool) {
+	return r.s.data.buddies.first(func(rel *database.BuddyRelationship) bool {
+		return (rel.UserID == a && rel.BuddyID == b) || (rel.UserID == b && rel.BuddyID == a)
+	})
+}
+
+func (r memBuddies) Create(_ context.Context, rel *database.BuddyRelationship) error {
+	defer r.s.lock()()
+	if _, taken := r.between(rel.UserID, rel.BuddyID); taken {
+		return duplicate("create buddy relationship")
+	}
+	return r.s.data.buddies.insert(rel)
+}
+
+func (r memBuddies) Update(_ context.Context, rel *database.BuddyRelationship) error {
+	defer r.s.lock()()
+	stored, ok := r.s.data.buddies.rows[rel.ID]
+	if !ok {
+		return notFound("update buddy relationship")
+	}
+	stored.Status = rel.Status
+	stored.AcceptedAt = rel.AcceptedAt
+	return r.s.data.buddies.save(&stored)
+}
+
+func (r memBuddies) Delete(_ context.Context, id uint) error {
+	defer r.s.lock()()
+	if _, ok := r.s.data.buddies.rows[id]; !ok {
+		return notFound("delete buddy relationship")
+	}
+	delete(r.s.data.buddies.rows, id)
+	return nil
+}
+
+type memBuddyInvites struct{ s *Memory }
+
+func (r memBuddyInvites) Create(_ context.Context, invite *database.BuddyInvite) error {
+	defer r.s.lock()()
+	invites := r.s.data.buddyInvites
+	// Status defaults to pending, which the partial unique index covers
+	pending := invite.Status == "" || invite.Status == database.InvitePending
+	if _, taken := invites.first(func(i *database.BuddyInvite) bool {
+		return i.TokenHash == invite.TokenHash ||
+			pending && i.Status == database.InvitePending && i.InviterID == invite.InviterID && i.Email == invite.Email
+	}); taken {
+		return duplicate("store buddy invite")
+	}
+	return invites.insert(invite)
+}
+
+func (r memBuddyInvites) GetByHash(_ context.Context, hash string) (*database.BuddyInvite, error) {
+	defer r.s.lock()()
+	invite, ok := r.s.data.buddyInvites.first(func(i *database.BuddyInvite) bool { return i.TokenHash == hash })
+	if !ok {
+		return nil, notFound("look up buddy invite")
+	}
+	invite.Inviter = r.s.data.users.rows[invite.InviterID]
+	return &invite, nil
+}
+
+func (r memBuddyInvites) Pending(_ context.Context, inviterID uint, now time.Time) ([]database.BuddyInvite, error) {
+	defer r.s.lock()()
+	return r.s.data.buddyInvites.all(func(i *database.BuddyInvite) bool {
+		return i.InviterID == inviterID && i.Status == database.InvitePending && i.ExpiresAt.After(now)
+	}), nil
+}
+
+func (r memBuddyInvites) Resolve(_ context.Context, inviterID uint, email, status string, at time.Time) (int64, error) {
+	defer r.s.lock()()
+	invites := r.s.data.buddyInvites
+	var n int64
+	for _, i := range invites.all(func(i *database.BuddyInvite) bool {
+		return i.InviterID == inviterID && i.Email == email && i.Status == database.InvitePending
+	}) {
+		i.Status = status
+		i.RespondedAt = &at
+		invites.rows[i.ID] = i
+		n++
+	}
+	return n, nil
+}
+
+func (r memBuddyInvites) ResolveByID(_ context.Context, id uint, status string, at time.Time) error {
+	defer r.s.lock()()
+	invites := r.s.data.buddyInvites
+	i, ok := invites.rows[id]
+	if !ok || i.Status != database.InvitePending {
+		return notFound("resolve buddy invite")
+	}
+	i.Status = status
+	i.RespondedAt = &at
+	invites.rows[id] = i
+	return nil
+}
+
+type memCoachPermissions struct{ s *Memory }
+
+func (r memCoachPermissions) List(_ context.Context, relationshipID uint) ([]database.CoachPermission, error) {
+	defer r.s.lock()()
+	return r.s.data.coachPermissions.all(func(p *database.CoachPermission) bool {
+		return p.RelationshipID == relationshipID
+	}), nil
+}
+
+func (r memCoachPermissions) Grant(_ context.Context, p *database.CoachPermission) error {
+	defer r.s.lock()()
+	if _, ok := r.find(p.RelationshipID, p.Permission); ok {
+		return duplicate("grant coach permission")
+	}
+	return r.s.data.coachPermissions.insert(p)
+}
+
+func (r memCoachPermissions) Revoke(_ context.Context, relationshipID uint, permission string) error {
+	defer r.s.lock()()
+	p, ok := r.find(relationshipID, permission)
+	if !ok {
+		return notFound("revoke coach permission")
+	}
+	delete(r.s.data.coachPermissions.rows, p.ID)
+	return nil
+}
+
+// find returns the grant of permission in a relationship
+func (r memCoachPermissions) find(relationshipID uint, permission string) (database.CoachPermission, bool) {
+	return r.s.data.coachPermissions.first(func(p *database.CoachPermission) bool {
+		return p.RelationshipID == relationshipID && p.Permission == permission
+	})
+}
+
+type memChallenges struct{ s *Memory }
+
+// load attaches a challenge's participants and their users
+func (r memChallenges) load(c database.Challenge) database.Challenge {
+	d := r.s.data
+	c.Participants = d.participants.all(func(p *database.ChallengeParticipant) bool { return p.ChallengeID == c.ID })
+	for i := range c.Participants {
+		c.Participants[i].User = d.users.rows[c.Participants[i].UserID]
+	}
+	return c
+}
+
+func (r memChallenges) Create(_ context.Context, challenge *database.Challenge) error {
+	defer r.s.lock()()
+	if err := r.s.data.challenges.insert(challenge); err != nil {
+		return err
+	}
+	for i := range challenge.Participants {
+		challenge.Participants[i].ChallengeID = challenge.ID
+		if err := r.s.data.participants.insert(&challenge.Participants[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r memChallenges) Get(_ context.Context, id uint) (*database.Challenge, error) {
+	defer r.s.lock()()
+	c, ok := r.s.data.challenges.rows[id]
+	if !ok {
+		return nil, notFound("load challenge")
+	}
+	c = r.load(c)
+	return &c, nil
+}
+
+func (r memChallenges) List(_ context.Context, userID uint) ([]database.Challenge, error) {
+	defer r.s.lock()()
+	invited := make(map[uint]bool)
+	for _, p := range r.s.data.participants.all(func(p *database.ChallengeParticipant) bool { return p.UserID == userID }) {
+		invited[p.ChallengeID] = true
+	}
+	rows := r.s.data.challenges.all(func(c *database.Challenge) bool { return invited[c.ID] })
+	sort.SliceStable(rows, func(i, j int) bool {
+		if !rows[i].StartsAt.Equal(rows[j].StartsAt) {
+			return rows[i].StartsAt.After(rows[j].StartsAt)
+		}
+		return rows[i].ID > rows[j].ID
+	})
+	for i := range rows {
+		rows[i] = r.load(rows[i])
+	}
+	return rows, nil
+}
+
+func (r memChallenges) Due(_ context.Context, now time.Time) ([]database.Challenge, error) {
+	defer r.s.lock()()
+	rows := r.s.data.challenges.all(func(c *database.Challenge) bool {
+		return c.CompletedAt == nil && !c.EndsAt.After(now)
+	})
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].EndsAt.Before(rows[j].EndsAt) })
+	for i := range rows {
+		rows[i] = r.load(rows[i])
+	}
+	return rows, nil
+}
+
+func (r memChallenges) Complete(_ context.Context, id uint, at time.Time) (int64, error) {
+	defer r.s.lock()()
+	c, ok := r.s.data.challenges.rows[id]
+	if !ok || c.CompletedAt != nil {
+		return 0, nil
+	}
+	c.CompletedAt = &at
+	return 1, r.s.data.challenges.save(&c)
+}
+
+func (r memChallenges) UpdateParticipant(_ context.Context, p *database.ChallengeParticipant) error {
+	defer r.s.lock()()
+	stored, ok := r.s.data.participants.rows[p.ID]
+	if !ok {
+		return notFound("update challenge participant")
+	}
+	stored.Status = p.Status
+	stored.JoinedAt = p.JoinedAt
+	stored.FinalRank = p.FinalRank
+	stored.FinalScore = p.FinalScore
+	return r.s.data.participants.save(&stored)
+}
+
+func (r memChallenges) Results(_ context.Context, userID uint) ([]database.ChallengeParticipant, error) {
+	defer r.s.lock()()
+	return r.s.data.participants.all(func(p *database.ChallengeParticipant) bool {
+		return p.UserID == userID && p.FinalRank != nil
+	}), nil
+}
+
+type memMessages struct{ s *Memory }
+
+func (r memMessages) Create(_ context.Context, msg *database.WeaselMessage) error {
+	defer r.s.lock()()
+	return r.s.data.messages.insert(msg)
+}
+
+func (r memMessages) List(_ context.Context, userID uint, p Page) ([]database.WeaselMessage, error) {
+	defer r.s.lock()()
+	rows := r.s.data.messages.all(func(m *database.WeaselMessage) bool { return m.UserID == userID })
+	sort.SliceStable(rows, func(i, j int) bool {
+		if !rows[i].SentAt.Equal(rows[j].SentAt) {
+			return rows[i].SentAt.After(rows[j].SentAt)
+		}
+		return rows[i].ID > rows[j].ID
+	})
+	return page(rows, p), nil
+}
+
+func (r memMessages) Get(_ context.Context, userID, id uint) (*database.WeaselMessage, error) {
+	defer r.s.lock()()
+	msg, ok := r.s.data.messages.rows[id]
+	if !ok || msg.UserID != userID {
+		return nil, notFound("load message")
+	}
+	return &msg, nil
+}
+
+func (r memMessages) Update(_ context.Context, userID, id uint, changes map[string]any) error {
+	defer r.s.lock()()
+	msg, ok := r.s.data.messages.rows[id]
+	if !ok || msg.UserID != userID {
+		return notFound("update message")
+	}
+	if err := applyChanges(&msg, changes); err != nil {
+		return err
+	}
+	msg.UpdatedAt = time.Now()
+	r.s.data.messages.rows[id] = msg
+	return nil
+}
+
+func (r memMessages) MarkTriggered(_ context.Context, since time.Time, window time.Duration) (int64, error) {
+	defer r.s.lock()()
+	var marked int64
+	for id, msg := range r.s.data.messages.rows {
+		if msg.TriggeredWorkout || msg.SentAt.Before(since) {
+			continue
+		}
+		_, followed := r.s.data.workouts.first(func(w *database.Workout) bool {
+			return w.UserID == msg.UserID && !w.CompletedAt.Before(msg.SentAt) && !w.CompletedAt.After(msg.SentAt.Add(window))
+		})
+		if followed {
+			msg.TriggeredWorkout = true
+			r.s.data.messages.rows[id] = msg
+			marked++
+		}
+	}
+	return marked, nil
+}
+
+// matching returns the messages selected by q in insertion order
+func (r memMessages) matching(q ConversionQuery) []database.WeaselMessage {
+	return r.s.data.messages.all(func(m *database.WeaselMessage) bool {
+		return !m.SentAt.Before(q.Since) && (q.Until.IsZero() || m.SentAt.Before(q.Until)) &&
+			(q.Experiment == "" || m.Experiment == q.Experiment)
+	})
+}
+
+func (r memMessages) Conversion(_ context.Context, q ConversionQuery) ([]ConversionStats, error) {
+	defer r.s.lock()()
+	rows := r.matching(q)
+
+	byKey := map[[2]string]*ConversionStats{}
+	var out []*ConversionStats
+	for _, msg := range rows {
+		key := [2]string{msg.MessageType, msg.Intensity}
+		stats, ok := byKey[key]
+		if !ok {
+			stats = &ConversionStats{MessageType: msg.MessageType, Intensity: msg.Intensity}
+			byKey[key] = stats
+			out = append(out, stats)
+		}
+		stats.Sent++
+		if msg.ReadAt != nil {
+			stats.Read++
+		}
+		if msg.TriggeredWorkout {
+			stats.Triggered++
+		}
+	}
+
+	result := make([]ConversionStats, 0, len(out))
+	for _, stats := range out {
+		result = append(result, *stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].MessageType != result[j].MessageType {
+			return result[i].MessageType < result[j].MessageType
+		}
+		return result[i].Intensity < result[j].Intensity
+	})
+	return result, nil
+}
+
+func (r memMessages) Variants(_ context.Context, q ConversionQuery) ([]VariantStats, error) {
+	defer r.s.lock()()
+	byVariant := map[string]*VariantStats{}
+	users := map[string]map[uint]bool{}
+	for _, msg := range r.matching(q) {
+		if msg.Variant == "" {
+			continue
+		}
+		stats, ok := byVariant[msg.Variant]
+		if !ok {
+			stats = &VariantStats{Variant: msg.Variant}
+			byVariant[msg.Variant] = stats
+			users[msg.Variant] = map[uint]bool{}
+		}
+		stats.Sent++
+		if msg.TriggeredWorkout {
+			stats.Triggered++
+		}
+		users[msg.Variant][msg.UserID] = true
+	}
+
+	out := make([]VariantStats, 0, len(byVariant))
+	for variant, stats := range byVariant {
+		stats.Users = int64(len(users[variant]))
+		out = append(out, *stats)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Variant < out[j].Variant })
+	return out, nil
+}
+
+func (r memMessages) Since(_ context.Context, userID uint, since time.Time) ([]database.WeaselMessage, error) {
+	defer r.s.lock()()
+	rows := r.s.data.messages.all(func(m *database.WeaselMessage) bool {
+		return m.UserID == userID && !m.SentAt.Before(since)
+	})
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].SentAt.Before(rows[j].SentAt) })
+	return rows, nil
+}
+
+type memStreaks struct{ s *Memory }
+
+func (r memStreaks) Get(_ context.Context, userID uint, streakType string) (*database.Streak, error) {
+	defer r.s.lock()()
+	streak, ok := r.s.data.streaks.first(func(s *database.Streak) bool {
+		return s.UserID == userID && s.StreakType == streakType
+	})
+	if !ok {
+		return nil, notFound("load streak")
+	}
+	return &streak, nil
+}
+
+func (r memStreaks) List(_ context.Context, userID uint) ([]database.Streak, error) {
+	defer r.s.lock()()
+	return r.s.data.streaks.all(func(s *database.Streak) bool { return s.UserID == userID }), nil
+}
+
+func (r memStreaks) Save(_ context.Context, streak *database.Streak) error {
+	defer r.s.lock()()
+	_, taken := r.s.data.streaks.first(func(s *database.Streak) bool {
+		return s.UserID == streak.UserID && s.StreakType == streak.StreakType && s.ID != streak.ID
+	})
+	if taken {
+		return duplicate("save streak")
+	}
+	return r.s.data.streaks.save(streak)
+}
+
+type memNotifications struct{ s *Memory }
+
+func (r memNotifications) Create(_ context.Context, n *database.Notification) error {
+	defer r.s.lock()()
+	return r.s.data.notifications.insert(n)
+}
+
+func (r memNotifications) Get(_ context.Context, id uint) (*database.Notification, error) {
+	defer r.s.lock()()
+	n, ok := r.s.data.notifications.rows[id]
+	if !ok {
+		return nil, notFound("load notification")
+	}
+	return &n, nil
+}
+
+func (r memNotifications) Claim(_ context.Context, now time.Time, limit int, lease time.Duration) ([]database.Notification, error) {
+	defer r.s.lock()()
+	rows := r.s.data.notifications.all(func(n *database.Notification) bool {
+		return n.Status == database.NotificationPending && !n.NextAttemptAt.After(now)
+	})
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].NextAttemptAt.Before(rows[j].NextAttemptAt) })
+	rows = page(rows, Page{Limit: limit})
+	sort.Slice(rows, func(i, j int) bool { return rows[i].ID < rows[j].ID })
+
+	for i := range rows {
+		rows[i].NextAttemptAt = now.Add(lease)
+		r.s.data.notifications.rows[rows[i].ID] = rows[i]
+		rows[i].User = r.s.data.users.rows[rows[i].UserID]
+		rows[i].Message = r.s.data.messages.rows[rows[i].MessageID]
+	}
+	return rows, nil
+}
+
+func (r memNotifications) Update(_ context.Context, n *database.Notification) error {
+	defer r.s.lock()()
+	if _, ok := r.s.data.notifications.rows[n.ID]; !ok {
+		return notFound("update notification")
+	}
+	return r.s.data.notifications.save(n)
+}
+
+type memActivities struct{ s *Memory }
+
+func (r memActivities) List(_ context.Context) ([]database.FakeSocialActivity, error) {
+	defer r.s.lock()()
+	return r.s.data.activities.all(nil), nil
+}
+
+func (r memActivities) Recent(_ context.Context, limit int) ([]database.FakeSocialActivity, error) {
+	defer r.s.lock()()
+	rows := r.s.data.activities.all(nil)
+	sort.SliceStable(rows, func(i, j int) bool {
+		if !rows[i].Timestamp.Equal(rows[j].Timestamp) {
+			return rows[i].Timestamp.After(rows[j].Timestamp)
+		}
+		return rows[i].ID > rows[j].ID
+	})
+	return page(rows, Page{Limit: limit}), nil
+}
+
+func (r memActivities) Save(_ context.Context, activity *database.FakeSocialActivity) error {
+	defer r.s.lock()()
+	return r.s.data.activities.save(activity)
+}
+
+func (r memActivities) Count(_ context.Context) (int64, error) {
+	defer r.s.lock()()
+	return int64(len(r.s.data.activities.rows)), nil
+}
@@ -0,0 +1,157 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+)
+
+func TestMemoryTransactionRollsBack(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemory()
+	failure := errors.New("boom")
+
+	err := s.Transaction(ctx, func(tx Store) error {
+		if err := tx.Users().Create(ctx, &database.User{Email: "sam@example.com"}); err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("Expected transaction error, got %v", err)
+	}
+	if _, err := s.Users().GetByEmail(ctx, "sam@example.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected rolled back user to be missing, got %v", err)
+	}
+
+	err = s.Transaction(ctx, func(tx Store) error {
+		return tx.Users().Create(ctx, &database.User{Email: "sam@example.com"})
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := s.Users().GetByEmail(ctx, "sam@example.com"); err != nil {
+		t.Errorf("Expected committed user, got %v", err)
+	}
+}
+
+func TestMemoryDuplicates(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemory()
+
+	if err := s.Users().Create(ctx, &database.User{Email: "sam@example.com"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := s.Users().Create(ctx, &database.User{Email: "sam@example.com"}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Expected ErrDuplicate for email, got %v", err)
+	}
+
+	if err := s.Programs().Create(ctx, &database.Program{Name: "5x5"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := s.Programs().Create(ctx, &database.Program{Name: "5x5"}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Expected ErrDuplicate for program name, got %v", err)
+	}
+
+	if err := s.Achievements().SaveProgress(ctx, &database.UserAchievement{UserID: 1, AchievementID: 1}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := s.Achievements().SaveProgress(ctx, &database.UserAchievement{UserID: 1, AchievementID: 1}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Expected ErrDuplicate for achievement progress, got %v", err)
+	}
+}
+
+func TestMemoryBuddyInvites(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemory()
+	expires := time.Now().Add(time.Hour)
+	invite := func(hash string) *database.BuddyInvite {
+		return &database.BuddyInvite{InviterID: 1, Email: "lee@example.com", Role: database.InviteRolePeer, TokenHash: hash, ExpiresAt: expires}
+	}
+
+	first := invite("a")
+	if err := s.BuddyInvites().Create(ctx, first); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := s.BuddyInvites().Create(ctx, invite("b")); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Expected ErrDuplicate for a second pending invite, got %v", err)
+	}
+
+	if err := s.BuddyInvites().ResolveByID(ctx, first.ID, database.InviteCancelled, time.Now()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := s.BuddyInvites().ResolveByID(ctx, first.ID, database.InviteCancelled, time.Now()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound resolving a cancelled invite, got %v", err)
+	}
+	if err := s.BuddyInvites().Create(ctx, invite("b")); err != nil {
+		t.Errorf("Expected a new invite once the first was cancelled, got %v", err)
+	}
+}
+
+func TestMemoryMatchesGormDefaults(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemory()
+
+	user := &database.User{Email: "sam@example.com"}
+	if err := s.Users().Create(ctx, user); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if user.ID == 0 || user.CreatedAt.IsZero() {
+		t.Errorf("Expected ID and timestamps to be set, got %+v", user)
+	}
+	if user.WeaselIntensity != database.IntensityMedium {
+		t.Errorf("Expected default intensity %s, got %s", database.IntensityMedium, user.WeaselIntensity)
+	}
+
+	err := s.Users().Update(ctx, user.ID, map[string]any{"name": "Sam", "weasel_intensity": database.IntensityGentle})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	got, err := s.Users().Get(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got.Name != "Sam" || got.WeaselIntensity != database.IntensityGentle {
+		t.Errorf("Expected changes to be applied, got %+v", got)
+	}
+
+	if err := s.Users().Update(ctx, user.ID, map[string]any{"no_such_column": 1}); err == nil {
+		t.Error("Expected error for unknown column")
+	}
+}
+
+func TestMemoryReturnsCopies(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemory()
+	if err := s.Exercises().Save(ctx, &database.Exercise{Name: "Squat"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	workout := &database.Workout{
+		UserID:      1,
+		CompletedAt: time.Now(),
+		Sets:        []database.WorkoutSet{{ExerciseID: 1, Reps: 5, Completed: false}},
+	}
+	if err := s.Workouts().Create(ctx, workout); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	workout.Notes = "changed after saving"
+
+	got, err := s.Workouts().Get(ctx, 1, workout.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got.Notes != "" {
+		t.Errorf("Expected stored workout to be unaffected by caller changes, got %q", got.Notes)
+	}
+	if len(got.Sets) != 1 || got.Sets[0].Completed || got.Sets[0].Exercise.Name != "Squat" {
+		t.Errorf("Expected incomplete set with exercise loaded, got %+v", got.Sets)
+	}
+
+	if _, err := s.Workouts().Get(ctx, 2, workout.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected other users' workouts to be hidden, got %v", err)
+	}
+}
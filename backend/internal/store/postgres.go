@@ -0,0 +1,870 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// currentEnrollmentStatuses are the states in which an enrollment is the user's current one
+var currentEnrollmentStatuses = []string{database.EnrollmentActive, database.EnrollmentPaused}
+
+// Postgres implements Store on a GORM connection
+type Postgres struct {
+	db *gorm.DB
+}
+
+// NewPostgres creates a Store backed by db
+func NewPostgres(db *gorm.DB) *Postgres {
+	return &Postgres{db: db}
+}
+
+// Users returns the user repository
+func (s *Postgres) Users() UserRepository { return pgUsers{s.db} }
+
+// Sessions returns the refresh token repository
+func (s *Postgres) Sessions() SessionRepository { return pgSessions{s.db} }
+
+// ActionTokens returns the action token repository
+func (s *Postgres) ActionTokens() ActionTokenRepository { return pgActionTokens{s.db} }
+
+// Workouts returns the workout repository
+func (s *Postgres) Workouts() WorkoutRepository { return pgWorkouts{s.db} }
+
+// Programs returns the program repository
+func (s *Postgres) Programs() ProgramRepository { return pgPrograms{s.db} }
+
+// Exercises returns the exercise repository
+func (s *Postgres) Exercises() ExerciseRepository { return pgExercises{s.db} }
+
+// Enrollments returns the enrollment repository
+func (s *Postgres) Enrollments() EnrollmentRepository { return pgEnrollments{s.db} }
+
+// Achievements returns the achievement repository
+func (s *Postgres) Achievements() AchievementRepository { return pgAchievements{s.db} }
+
+// Buddies returns the buddy relationship repository
+func (s *Postgres) Buddies() BuddyRepository { return pgBuddies{s.db} }
+
+// BuddyInvites returns the buddy invitation repository
+func (s *Postgres) BuddyInvites() BuddyInviteRepository { return pgBuddyInvites{s.db} }
+
+// CoachPermissions returns the coach permission repository
+func (s *Postgres) CoachPermissions() CoachPermissionRepository { return pgCoachPermissions{s.db} }
+
+// Challenges returns the group challenge repository
+func (s *Postgres) Challenges() ChallengeRepository { return pgChallenges{s.db} }
+
+// Messages returns the Weasel Mode message repository
+func (s *Postgres) Messages() MessageRepository { return pgMessages{s.db} }
+
+// Streaks returns the streak repository
+func (s *Postgres) Streaks() StreakRepository { return pgStreaks{s.db} }
+
+// Activities returns the social activity repository
+func (s *Postgres) Activities() ActivityRepository { return pgActivities{s.db} }
+
+// Notifications returns the notification outbox repository
+func (s *Postgres) Notifications() NotificationRepository { return pgNotifications{s.db} }
+
+// Transaction runs fn in a database transaction
+func (s *Postgres) Transaction(ctx context.Context, fn func(tx Store) error) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error { //nolint:wrapcheck // errors from fn are returned unchanged
+		return fn(&Postgres{db: tx})
+	})
+}
+
+// translate maps GORM errors to the store's sentinel errors and adds context
+func translate(err error, action string) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return fmt.Errorf("failed to %s: %w", action, ErrNotFound)
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return fmt.Errorf("failed to %s: %w: %w", action, ErrDuplicate, err)
+	default:
+		return fmt.Errorf("failed to %s: %w", action, err)
+	}
+}
+
+type pgUsers struct{ db *gorm.DB }
+
+func (r pgUsers) Get(ctx context.Context, id uint) (*database.User, error) {
+	var user database.User
+	if err := r.db.WithContext(ctx).First(&user, id).Error; err != nil {
+		return nil, translate(err, "load user")
+	}
+	return &user, nil
+}
+
+func (r pgUsers) ListWeaselEnabled(ctx context.Context, afterID uint, limit int) ([]database.User, error) {
+	var users []database.User
+	err := r.db.WithContext(ctx).
+		Where("id > ? AND weasel_mode_enabled", afterID).
+		Order("id").
+		Limit(limit).
+		Find(&users).Error
+	if err != nil {
+		return nil, translate(err, "load users")
+	}
+	return users, nil
+}
+
+func (r pgUsers) GetByEmail(ctx context.Context, email string) (*database.User, error) {
+	var user database.User
+	if err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error; err != nil {
+		return nil, translate(err, "load user")
+	}
+	return &user, nil
+}
+
+func (r pgUsers) Create(ctx context.Context, user *database.User) error {
+	return translate(r.db.WithContext(ctx).Create(user).Error, "create user")
+}
+
+func (r pgUsers) Update(ctx context.Context, id uint, changes map[string]any) error {
+	err := r.db.WithContext(ctx).Model(&database.User{}).Where("id = ?", id).Updates(changes).Error
+	return translate(err, "update user")
+}
+
+func (r pgUsers) SetPassword(ctx context.Context, id uint, hash string) error {
+	err := r.db.WithContext(ctx).Model(&database.User{}).Where("id = ?", id).Updates(map[string]any{
+		"password":      hash,
+		"token_version": gorm.Expr("token_version + 1"),
+	}).Error
+	return translate(err, "update password")
+}
+
+func (r pgUsers) IncrementTokenVersion(ctx context.Context, id uint) error {
+	err := r.db.WithContext(ctx).Model(&database.User{}).
+		Where("id = ?", id).
+		Update("token_version", gorm.Expr("token_version + 1")).Error
+	return translate(err, "bump token version")
+}
+
+func (r pgUsers) MarkEmailVerified(ctx context.Context, id uint, at time.Time) error {
+	err := r.db.WithContext(ctx).Model(&database.User{}).
+		Where("id = ? AND email_verified_at IS NULL", id).
+		Update("email_verified_at", at).Error
+	return translate(err, "mark email verified")
+}
+
+type pgSessions struct{ db *gorm.DB }
+
+func (r pgSessions) Create(ctx context.Context, token *database.RefreshToken) error {
+	return translate(r.db.WithContext(ctx).Create(token).Error, "store refresh token")
+}
+
+func (r pgSessions) GetByHash(ctx context.Context, hash string) (*database.RefreshToken, error) {
+	var token database.RefreshToken
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		return nil, translate(err, "look up refresh token")
+	}
+	return &token, nil
+}
+
+func (r pgSessions) Claim(ctx context.Context, id uint, at time.Time) (bool, error) {
+	res := r.db.WithContext(ctx).Model(&database.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", id).
+		Update("used_at", at)
+	if res.Error != nil {
+		return false, translate(res.Error, "rotate refresh token")
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (r pgSessions) SetReplacedBy(ctx context.Context, id, replacedByID uint) error {
+	err := r.db.WithContext(ctx).Model(&database.RefreshToken{}).
+		Where("id = ?", id).
+		Update("replaced_by_id", replacedByID).Error
+	return translate(err, "link refresh token")
+}
+
+func (r pgSessions) RevokeFamily(ctx context.Context, familyID string, at time.Time) error {
+	err := r.db.WithContext(ctx).Model(&database.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", at).Error
+	return translate(err, "revoke token family")
+}
+
+func (r pgSessions) RevokeAll(ctx context.Context, userID uint, at time.Time) error {
+	err := r.db.WithContext(ctx).Model(&database.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", at).Error
+	return translate(err, "revoke refresh tokens")
+}
+
+type pgActionTokens struct{ db *gorm.DB }
+
+func (r pgActionTokens) Create(ctx context.Context, token *database.ActionToken) error {
+	return translate(r.db.WithContext(ctx).Create(token).Error, "store action token")
+}
+
+func (r pgActionTokens) Redeem(ctx context.Context, tokenID string, userID uint, purpose string, at time.Time) (bool, error) {
+	res := r.db.WithContext(ctx).Model(&database.ActionToken{}).
+		Where("token_id = ? AND user_id = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?",
+			tokenID, userID, purpose, at).
+		Update("used_at", at)
+	if res.Error != nil {
+		return false, translate(res.Error, "redeem token")
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (r pgActionTokens) ExpireAll(ctx context.Context, userID uint, purpose string, at time.Time) error {
+	err := r.db.WithContext(ctx).Model(&database.ActionToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", at).Error
+	return translate(err, "expire "+purpose+" tokens")
+}
+
+type pgWorkouts struct{ db *gorm.DB }
+
+// preloadSets loads sets in logging order along with their exercise
+func preloadSets(db *gorm.DB) *gorm.DB {
+	return db.
+		Preload("Sets", func(tx *gorm.DB) *gorm.DB {
+			return tx.Order("exercise_order ASC, set_index ASC")
+		}).
+		Preload("Sets.Exercise")
+}
+
+func (r pgWorkouts) Get(ctx context.Context, userID, id uint) (*database.Workout, error) {
+	var workout database.Workout
+	err := preloadSets(r.db.WithContext(ctx)).
+		Where("id = ? AND user_id = ?", id, userID).
+		First(&workout).Error
+	if err != nil {
+		return nil, translate(err, "load workout")
+	}
+	return &workout, nil
+}
+
+func (r pgWorkouts) List(ctx context.Context, userID uint, page Page) ([]database.Workout, int64, error) {
+	db := r.db.WithContext(ctx)
+
+	var total int64
+	if err := db.Model(&database.Workout{}).Where("user_id = ?", userID).Count(&total).Error; err != nil {
+		return nil, 0, translate(err, "count workouts")
+	}
+
+	var workouts []database.Workout
+	err := preloadSets(db).
+		Where("user_id = ?", userID).
+		Order("completed_at DESC, id DESC").
+		Limit(page.Limit).Offset(page.Offset).
+		Find(&workouts).Error
+	if err != nil {
+		return nil, 0, translate(err, "load workouts")
+	}
+	return workouts, total, nil
+}
+
+func (r pgWorkouts) History(ctx context.Context, q HistoryQuery) ([]database.Workout, error) {
+	db := preloadSets(r.db.WithContext(ctx)).Where("user_id = ?", q.UserID)
+	if q.ProgramID != 0 {
+		db = db.Where("program_id = ?", q.ProgramID)
+	}
+	if !q.Since.IsZero() {
+		db = db.Where("completed_at >= ?", q.Since)
+	}
+	if q.Until != nil {
+		db = db.Where("completed_at <= ?", *q.Until)
+	}
+
+	var workouts []database.Workout
+	if err := db.Order("completed_at ASC, id ASC").Find(&workouts).Error; err != nil {
+		return nil, translate(err, "load workout history")
+	}
+	return workouts, nil
+}
+
+func (r pgWorkouts) Create(ctx context.Context, workout *database.Workout) error {
+	return translate(r.db.WithContext(ctx).Create(workout).Error, "create workout")
+}
+
+func (r pgWorkouts) Replace(ctx context.Context, workout *database.Workout) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("workout_id = ?", workout.ID).Delete(&database.WorkoutSet{}).Error; err != nil {
+			return translate(err, "clear sets")
+		}
+		if err := tx.Omit("Sets").Save(workout).Error; err != nil {
+			return translate(err, "update workout")
+		}
+		if len(workout.Sets) == 0 {
+			return nil
+		}
+		for i := range workout.Sets {
+			workout.Sets[i].WorkoutID = workout.ID
+		}
+		return translate(tx.Create(&workout.Sets).Error, "store sets")
+	})
+}
+
+func (r pgWorkouts) Delete(ctx context.Context, userID, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("id = ? AND user_id = ?", id, userID).Delete(&database.Workout{})
+		if res.Error != nil {
+			return translate(res.Error, "delete workout")
+		}
+		if res.RowsAffected == 0 {
+			return translate(gorm.ErrRecordNotFound, "delete workout")
+		}
+		return translate(tx.Where("workout_id = ?", id).Delete(&database.WorkoutSet{}).Error, "delete sets")
+	})
+}
+
+type pgPrograms struct{ db *gorm.DB }
+
+func (r pgPrograms) List(ctx context.Context) ([]database.Program, error) {
+	var programs []database.Program
+	if err := r.db.WithContext(ctx).Order("id ASC").Find(&programs).Error; err != nil {
+		return nil, translate(err, "load programs")
+	}
+	return programs, nil
+}
+
+func (r pgPrograms) Get(ctx context.Context, id uint) (*database.Program, error) {
+	var program database.Program
+	if err := r.db.WithContext(ctx).First(&program, id).Error; err != nil {
+		return nil, translate(err, "load program")
+	}
+	return &program, nil
+}
+
+func (r pgPrograms) Create(ctx context.Context, program *database.Program) error {
+	return translate(r.db.WithContext(ctx).Create(program).Error, "create program")
+}
+
+func (r pgPrograms) Save(ctx context.Context, program *database.Program) error {
+	return translate(r.db.WithContext(ctx).Omit("Workouts").Save(program).Error, "save program")
+}
+
+func (r pgPrograms) Count(ctx context.Context) (int64, error) {
+	var n int64
+	err := r.db.WithContext(ctx).Model(&database.Program{}).Count(&n).Error
+	return n, translate(err, "count programs")
+}
+
+type pgExercises struct{ db *gorm.DB }
+
+func (r pgExercises) List(ctx context.Context) ([]database.Exercise, error) {
+	var exercises []database.Exercise
+	if err := r.db.WithContext(ctx).Order("id ASC").Find(&exercises).Error; err != nil {
+		return nil, translate(err, "load exercises")
+	}
+	return exercises, nil
+}
+
+func (r pgExercises) Save(ctx context.Context, exercise *database.Exercise) error {
+	return translate(r.db.WithContext(ctx).Save(exercise).Error, "save exercise")
+}
+
+func (r pgExercises) Count(ctx context.Context) (int64, error) {
+	var n int64
+	err := r.db.WithContext(ctx).Model(&database.Exercise{}).Count(&n).Error
+	return n, translate(err, "count exercises")
+}
+
+type pgEnrollments struct{ db *gorm.DB }
+
+// preloadEnrollment loads an enrollment's program and lifts
+func preloadEnrollment(db *gorm.DB) *gorm.DB {
+	return db.Preload("Program").Preload("Lifts", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id ASC")
+	})
+}
+
+func (r pgEnrollments) Current(ctx context.Context, userID uint) (*database.Enrollment, error) {
+	var e database.Enrollment
+	err := preloadEnrollment(r.db.WithContext(ctx)).
+		Where("user_id = ? AND status IN ?", userID, currentEnrollmentStatuses).
+		First(&e).Error
+	if err != nil {
+		return nil, translate(err, "load enrollment")
+	}
+	return &e, nil
+}
+
+func (r pgEnrollments) List(ctx context.Context, userID uint) ([]database.Enrollment, error) {
+	var enrollments []database.Enrollment
+	err := preloadEnrollment(r.db.WithContext(ctx)).
+		Where("user_id = ?", userID).
+		Order("started_at DESC, id DESC").
+		Find(&enrollments).Error
+	if err != nil {
+		return nil, translate(err, "load enrollments")
+	}
+	return enrollments, nil
+}
+
+func (r pgEnrollments) Create(ctx context.Context, enrollment *database.Enrollment) error {
+	return translate(r.db.WithContext(ctx).Omit("Program").Create(enrollment).Error, "create enrollment")
+}
+
+func (r pgEnrollments) Update(ctx context.Context, enrollment *database.Enrollment) error {
+	err := r.db.WithContext(ctx).Model(enrollment).
+		Select("status", "paused_at", "ended_at", "current_week", "current_day", "sessions_completed").
+		Updates(enrollment).Error
+	return translate(err, "update enrollment")
+}
+
+func (r pgEnrollments) UpdateLift(ctx context.Context, lift *database.EnrollmentLift) error {
+	err := r.db.WithContext(ctx).Model(lift).
+		Select("working_weight", "consecutive_failures").
+		Updates(lift).Error
+	return translate(err, "update lift "+lift.Exercise)
+}
+
+type pgAchievements struct{ db *gorm.DB }
+
+func (r pgAchievements) List(ctx context.Context) ([]database.Achievement, error) {
+	var achievements []database.Achievement
+	if err := r.db.WithContext(ctx).Order("id ASC").Find(&achievements).Error; err != nil {
+		return nil, translate(err, "load achievements")
+	}
+	return achievements, nil
+}
+
+func (r pgAchievements) Save(ctx context.Context, achievement *database.Achievement) error {
+	return translate(r.db.WithContext(ctx).Save(achievement).Error, "save achievement")
+}
+
+func (r pgAchievements) Count(ctx context.Context) (int64, error) {
+	var n int64
+	err := r.db.WithContext(ctx).Model(&database.Achievement{}).Count(&n).Error
+	return n, translate(err, "count achievements")
+}
+
+func (r pgAchievements) Unlocked(ctx context.Context, userID uint) ([]database.UserAchievement, error) {
+	var unlocked []database.UserAchievement
+	err := r.db.WithContext(ctx).Preload("Achievement").
+		Where("user_id = ? AND unlocked_at IS NOT NULL", userID).
+		Order("unlocked_at ASC, id ASC").
+		Find(&unlocked).Error
+	if err != nil {
+		return nil, translate(err, "load unlocked achievements")
+	}
+	return unlocked, nil
+}
+
+func (r pgAchievements) Progress(ctx context.Context, userID uint) ([]database.UserAchievement, error) {
+	var progress []database.UserAchievement
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("id ASC").Find(&progress).Error
+	if err != nil {
+		return nil, translate(err, "load achievement progress")
+	}
+	return progress, nil
+}
+
+func (r pgAchievements) SaveProgress(ctx context.Context, progress *database.UserAchievement) error {
+	err := r.db.WithContext(ctx).Omit("User", "Achievement").Save(progress).Error
+	return translate(err, "save achievement progress")
+}
+
+type pgBuddies struct{ db *gorm.DB }
+
+func (r pgBuddies) List(ctx context.Context, userID uint) ([]database.BuddyRelationship, error) {
+	var rels []database.BuddyRelationship
+	err := r.db.WithContext(ctx).
+		Preload("User").Preload("Buddy").
+		Where("user_id = ? OR buddy_id = ?", userID, userID).
+		Order("id ASC").
+		Find(&rels).Error
+	if err != nil {
+		return nil, translate(err, "load buddies")
+	}
+	return rels, nil
+}
+
+func (r pgBuddies) Get(ctx context.Context, userID, id uint) (*database.BuddyRelationship, error) {
+	var rel database.BuddyRelationship
+	err := r.db.WithContext(ctx).
+		Preload("User").Preload("Buddy").
+		Where("user_id = ? OR buddy_id = ?", userID, userID).
+		First(&rel, id).Error
+	if err != nil {
+		return nil, translate(err, "load buddy relationship")
+	}
+	return &rel, nil
+}
+
+func (r pgBuddies) Between(ctx context.Context, a, b uint) (*database.BuddyRelationship, error) {
+	var rel database.BuddyRelationship
+	err := r.db.WithContext(ctx).
+		Preload("User").Preload("Buddy").
+		Where("(user_id = ? AND buddy_id = ?) OR (user_id = ? AND buddy_id = ?)", a, b, b, a).
+		First(&rel).Error
+	if err != nil {
+		return nil, translate(err, "load buddy relationship")
+	}
+	return &rel, nil
+}
+
+func (r pgBuddies) Create(ctx context.Context, rel *database.BuddyRelationship) error {
+	return translate(r.db.WithContext(ctx).Omit("User", "Buddy", "InvitedBy").Create(rel).Error, "create buddy relationship")
+}
+
+func (r pgBuddies) Update(ctx context.Context, rel *database.BuddyRelationship) error {
+	err := r.db.WithContext(ctx).Model(rel).
+		Select("status", "accepted_at").
+		Updates(rel).Error
+	return translate(err, "update buddy relationship")
+}
+
+func (r pgBuddies) Delete(ctx context.Context, id uint) error {
+	res := r.db.WithContext(ctx).Delete(&database.BuddyRelationship{}, id)
+	if res.Error == nil && res.RowsAffected == 0 {
+		return translate(gorm.ErrRecordNotFound, "delete buddy relationship")
+	}
+	return translate(res.Error, "delete buddy relationship")
+}
+
+type pgBuddyInvites struct{ db *gorm.DB }
+
+func (r pgBuddyInvites) Create(ctx context.Context, invite *database.BuddyInvite) error {
+	return translate(r.db.WithContext(ctx).Omit("Inviter").Create(invite).Error, "store buddy invite")
+}
+
+func (r pgBuddyInvites) GetByHash(ctx context.Context, hash string) (*database.BuddyInvite, error) {
+	var invite database.BuddyInvite
+	if err := r.db.WithContext(ctx).Preload("Inviter").Where("token_hash = ?", hash).First(&invite).Error; err != nil {
+		return nil, translate(err, "look up buddy invite")
+	}
+	return &invite, nil
+}
+
+func (r pgBuddyInvites) Pending(ctx context.Context, inviterID uint, now time.Time) ([]database.BuddyInvite, error) {
+	var invites []database.BuddyInvite
+	err := r.db.WithContext(ctx).
+		Where("inviter_id = ? AND status = ? AND expires_at > ?", inviterID, database.InvitePending, now).
+		Order("id ASC").
+		Find(&invites).Error
+	if err != nil {
+		return nil, translate(err, "load buddy invites")
+	}
+	return invites, nil
+}
+
+func (r pgBuddyInvites) Resolve(ctx context.Context, inviterID uint, email, status string, at time.Time) (int64, error) {
+	res := r.db.WithContext(ctx).Model(&database.BuddyInvite{}).
+		Where("inviter_id = ? AND email = ? AND status = ?", inviterID, email, database.InvitePending).
+		Updates(map[string]any{"status": status, "responded_at": at})
+	return res.RowsAffected, translate(res.Error, "resolve buddy invites")
+}
+
+func (r pgBuddyInvites) ResolveByID(ctx context.Context, id uint, status string, at time.Time) error {
+	res := r.db.WithContext(ctx).Model(&database.BuddyInvite{}).
+		Where("id = ? AND status = ?", id, database.InvitePending).
+		Updates(map[string]any{"status": status, "responded_at": at})
+	if res.Error == nil && res.RowsAffected == 0 {
+		return translate(gorm.ErrRecordNotFound, "resolve buddy invite")
+	}
+	return translate(res.Error, "resolve buddy invite")
+}
+
+type pgCoachPermissions struct{ db *gorm.DB }
+
+func (r pgCoachPermissions) List(ctx context.Context, relationshipID uint) ([]database.CoachPermission, error) {
+	var perms []database.CoachPermission
+	err := r.db.WithContext(ctx).
+		Where("relationship_id = ?", relationshipID).
+		Order("id ASC").
+		Find(&perms).Error
+	if err != nil {
+		return nil, translate(err, "load coach permissions")
+	}
+	return perms, nil
+}
+
+func (r pgCoachPermissions) Grant(ctx context.Context, p *database.CoachPermission) error {
+	return translate(r.db.WithContext(ctx).Omit("Relationship").Create(p).Error, "grant coach permission")
+}
+
+func (r pgCoachPermissions) Revoke(ctx context.Context, relationshipID uint, permission string) error {
+	res := r.db.WithContext(ctx).
+		Where("relationship_id = ? AND permission = ?", relationshipID, permission).
+		Delete(&database.CoachPermission{})
+	if res.Error == nil && res.RowsAffected == 0 {
+		return translate(gorm.ErrRecordNotFound, "revoke coach permission")
+	}
+	return translate(res.Error, "revoke coach permission")
+}
+
+type pgChallenges struct{ db *gorm.DB }
+
+// preload loads participants in invitation order with their users
+func (r pgChallenges) preload(db *gorm.DB) *gorm.DB {
+	return db.Preload("Participants", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+		Preload("Participants.User")
+}
+
+func (r pgChallenges) Create(ctx context.Context, challenge *database.Challenge) error {
+	return translate(r.db.WithContext(ctx).Omit("Creator", "Participants.User").Create(challenge).Error, "create challenge")
+}
+
+func (r pgChallenges) Get(ctx context.Context, id uint) (*database.Challenge, error) {
+	var c database.Challenge
+	if err := r.preload(r.db.WithContext(ctx)).First(&c, id).Error; err != nil {
+		return nil, translate(err, "load challenge")
+	}
+	return &c, nil
+}
+
+func (r pgChallenges) List(ctx context.Context, userID uint) ([]database.Challenge, error) {
+	var challenges []database.Challenge
+	err := r.preload(r.db.WithContext(ctx)).
+		Where("id IN (?)", r.db.Model(&database.ChallengeParticipant{}).Select("challenge_id").Where("user_id = ?", userID)).
+		Order("starts_at DESC, id DESC").
+		Find(&challenges).Error
+	if err != nil {
+		return nil, translate(err, "load challenges")
+	}
+	return challenges, nil
+}
+
+func (r pgChallenges) Due(ctx context.Context, now time.Time) ([]database.Challenge, error) {
+	var challenges []database.Challenge
+	err := r.preload(r.db.WithContext(ctx)).
+		Where("completed_at IS NULL AND ends_at <= ?", now).
+		Order("ends_at ASC, id ASC").
+		Find(&challenges).Error
+	if err != nil {
+		return nil, translate(err, "load due challenges")
+	}
+	return challenges, nil
+}
+
+func (r pgChallenges) Complete(ctx context.Context, id uint, at time.Time) (int64, error) {
+	res := r.db.WithContext(ctx).Model(&database.Challenge{}).
+		Where("id = ? AND completed_at IS NULL", id).
+		Update("completed_at", at)
+	return res.RowsAffected, translate(res.Error, "complete challenge")
+}
+
+func (r pgChallenges) UpdateParticipant(ctx context.Context, p *database.ChallengeParticipant) error {
+	err := r.db.WithContext(ctx).Model(p).
+		Select("status", "joined_at", "final_rank", "final_score").
+		Updates(p).Error
+	return translate(err, "update challenge participant")
+}
+
+func (r pgChallenges) Results(ctx context.Context, userID uint) ([]database.ChallengeParticipant, error) {
+	var results []database.ChallengeParticipant
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND final_rank IS NOT NULL", userID).
+		Order("id ASC").
+		Find(&results).Error
+	if err != nil {
+		return nil, translate(err, "load challenge results")
+	}
+	return results, nil
+}
+
+type pgMessages struct{ db *gorm.DB }
+
+func (r pgMessages) Create(ctx context.Context, msg *database.WeaselMessage) error {
+	return translate(r.db.WithContext(ctx).Omit("User").Create(msg).Error, "store message")
+}
+
+func (r pgMessages) List(ctx context.Context, userID uint, page Page) ([]database.WeaselMessage, error) {
+	var msgs []database.WeaselMessage
+	err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("sent_at DESC, id DESC").
+		Limit(page.Limit).Offset(page.Offset).
+		Find(&msgs).Error
+	if err != nil {
+		return nil, translate(err, "load messages")
+	}
+	return msgs, nil
+}
+
+func (r pgMessages) Get(ctx context.Context, userID, id uint) (*database.WeaselMessage, error) {
+	var msg database.WeaselMessage
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&msg, id).Error; err != nil {
+		return nil, translate(err, "load message")
+	}
+	return &msg, nil
+}
+
+func (r pgMessages) Update(ctx context.Context, userID, id uint, changes map[string]any) error {
+	res := r.db.WithContext(ctx).Model(&database.WeaselMessage{}).Where("id = ? AND user_id = ?", id, userID).Updates(changes)
+	if res.Error != nil {
+		return translate(res.Error, "update message")
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("failed to update message: %w", ErrNotFound)
+	}
+	return nil
+}
+
+func (r pgMessages) MarkTriggered(ctx context.Context, since time.Time, window time.Duration) (int64, error) {
+	res := r.db.WithContext(ctx).Model(&database.WeaselMessage{}).
+		Where("NOT triggered_workout AND sent_at >= ?", since).
+		Where(`EXISTS (
+			SELECT 1 FROM workouts w
+			WHERE w.user_id = weasel_messages.user_id AND w.deleted_at IS NULL
+			AND w.completed_at >= weasel_messages.sent_at
+			AND w.completed_at <= weasel_messages.sent_at + ? * interval '1 second'
+		)`, window.Seconds()).
+		Update("triggered_workout", true)
+	if res.Error != nil {
+		return 0, translate(res.Error, "mark triggered messages")
+	}
+	return res.RowsAffected, nil
+}
+
+func (r pgMessages) Conversion(ctx context.Context, q ConversionQuery) ([]ConversionStats, error) {
+	var stats []ConversionStats
+	err := r.matching(ctx, q).
+		Select(`message_type, intensity, COUNT(*) AS sent, COUNT(read_at) AS "read",
+			COUNT(*) FILTER (WHERE triggered_workout) AS triggered`).
+		Group("message_type, intensity").
+		Order("message_type, intensity").
+		Scan(&stats).Error
+	if err != nil {
+		return nil, translate(err, "count message conversions")
+	}
+	return stats, nil
+}
+
+func (r pgMessages) Variants(ctx context.Context, q ConversionQuery) ([]VariantStats, error) {
+	var stats []VariantStats
+	err := r.matching(ctx, q).
+		Select(`variant, COUNT(*) AS sent, COUNT(DISTINCT user_id) AS users,
+			COUNT(*) FILTER (WHERE triggered_workout) AS triggered`).
+		Where("variant <> ''").
+		Group("variant").
+		Order("variant").
+		Scan(&stats).Error
+	if err != nil {
+		return nil, translate(err, "count variant conversions")
+	}
+	return stats, nil
+}
+
+// matching scopes a query to the messages selected by q
+func (r pgMessages) matching(ctx context.Context, q ConversionQuery) *gorm.DB {
+	query := r.db.WithContext(ctx).Model(&database.WeaselMessage{}).Where("sent_at >= ?", q.Since)
+	if !q.Until.IsZero() {
+		query = query.Where("sent_at < ?", q.Until)
+	}
+	if q.Experiment != "" {
+		query = query.Where("experiment = ?", q.Experiment)
+	}
+	return query
+}
+
+func (r pgMessages) Since(ctx context.Context, userID uint, since time.Time) ([]database.WeaselMessage, error) {
+	var msgs []database.WeaselMessage
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND sent_at >= ?", userID, since).
+		Order("sent_at, id").
+		Find(&msgs).Error
+	if err != nil {
+		return nil, translate(err, "load messages")
+	}
+	return msgs, nil
+}
+
+type pgStreaks struct{ db *gorm.DB }
+
+func (r pgStreaks) Get(ctx context.Context, userID uint, streakType string) (*database.Streak, error) {
+	var streak database.Streak
+	err := r.db.WithContext(ctx).Where("user_id = ? AND streak_type = ?", userID, streakType).First(&streak).Error
+	if err != nil {
+		return nil, translate(err, "load streak")
+	}
+	return &streak, nil
+}
+
+func (r pgStreaks) List(ctx context.Context, userID uint) ([]database.Streak, error) {
+	var streaks []database.Streak
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("id ASC").Find(&streaks).Error; err != nil {
+		return nil, translate(err, "load streaks")
+	}
+	return streaks, nil
+}
+
+func (r pgStreaks) Save(ctx context.Context, streak *database.Streak) error {
+	return translate(r.db.WithContext(ctx).Omit("User").Save(streak).Error, "save streak")
+}
+
+type pgNotifications struct{ db *gorm.DB }
+
+func (r pgNotifications) Create(ctx context.Context, n *database.Notification) error {
+	return translate(r.db.WithContext(ctx).Omit("User", "Message").Create(n).Error, "store notification")
+}
+
+func (r pgNotifications) Get(ctx context.Context, id uint) (*database.Notification, error) {
+	var n database.Notification
+	if err := r.db.WithContext(ctx).First(&n, id).Error; err != nil {
+		return nil, translate(err, "load notification")
+	}
+	return &n, nil
+}
+
+func (r pgNotifications) Claim(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]database.Notification, error) {
+	var claimed []database.Notification
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var ids []uint
+		err := tx.Model(&database.Notification{}).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", database.NotificationPending, now).
+			Order("next_attempt_at, id").
+			Limit(limit).
+			Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return translate(err, "claim notifications")
+		}
+		err = tx.Model(&database.Notification{}).Where("id IN ?", ids).Update("next_attempt_at", now.Add(lease)).Error
+		if err != nil {
+			return translate(err, "lease notifications")
+		}
+		return translate(tx.Preload("User").Preload("Message").Order("id").Find(&claimed, ids).Error, "load notifications")
+	})
+	if err != nil {
+		return nil, err //nolint:wrapcheck // translated inside the transaction
+	}
+	return claimed, nil
+}
+
+func (r pgNotifications) Update(ctx context.Context, n *database.Notification) error {
+	return translate(r.db.WithContext(ctx).Omit("User", "Message").Save(n).Error, "update notification")
+}
+
+type pgActivities struct{ db *gorm.DB }
+
+func (r pgActivities) List(ctx context.Context) ([]database.FakeSocialActivity, error) {
+	var activities []database.FakeSocialActivity
+	if err := r.db.WithContext(ctx).Order("id ASC").Find(&activities).Error; err != nil {
+		return nil, translate(err, "load social activity")
+	}
+	return activities, nil
+}
+
+func (r pgActivities) Recent(ctx context.Context, limit int) ([]database.FakeSocialActivity, error) {
+	var activities []database.FakeSocialActivity
+	err := r.db.WithContext(ctx).Order("timestamp DESC, id DESC").Limit(limit).Find(&activities).Error
+	if err != nil {
+		return nil, translate(err, "load social activity")
+	}
+	return activities, nil
+}
+
+func (r pgActivities) Save(ctx context.Context, activity *database.FakeSocialActivity) error {
+	return translate(r.db.WithContext(ctx).Save(activity).Error, "save social activity")
+}
+
+func (r pgActivities) Count(ctx context.Context) (int64, error) {
+	var n int64
+	err := r.db.WithContext(ctx).Model(&database.FakeSocialActivity{}).Count(&n).Error
+	return n, translate(err, "count social activity")
+}
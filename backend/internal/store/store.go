@@ -0,0 +1,319 @@
+// Package store defines the repositories the API reads and writes through.
+// Handlers depend on the Store interface instead of a database connection:
+// Postgres implements it on GORM and Memory keeps everything in process so
+// handler tests run without a database.
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+)
+
+var (
+	// ErrNotFound is returned when a requested record does not exist
+	ErrNotFound = errors.New("record not found")
+	// ErrDuplicate is returned when a write violates a uniqueness rule
+	ErrDuplicate = errors.New("duplicate record")
+)
+
+// Store gives access to every repository
+type Store interface {
+	Accounts
+	Training
+	Social
+
+	// Transaction runs fn against a Store whose writes are committed together
+	// when fn returns nil and discarded otherwise. Calling Transaction on the
+	// Store passed to fn joins the outer transaction.
+	Transaction(ctx context.Context, fn func(tx Store) error) error
+}
+
+// Accounts groups the repositories behind authentication
+type Accounts interface {
+	Users() UserRepository
+	Sessions() SessionRepository
+	ActionTokens() ActionTokenRepository
+}
+
+// Training groups the repositories behind logging and programming workouts
+type Training interface {
+	Workouts() WorkoutRepository
+	Programs() ProgramRepository
+	Exercises() ExerciseRepository
+	Enrollments() EnrollmentRepository
+}
+
+// Social groups the repositories behind gamification and Weasel Mode
+type Social interface {
+	Achievements() AchievementRepository
+	Buddies() BuddyRepository
+	BuddyInvites() BuddyInviteRepository
+	CoachPermissions() CoachPermissionRepository
+	Challenges() ChallengeRepository
+	Messages() MessageRepository
+	Streaks() StreakRepository
+	Activities() ActivityRepository
+	Notifications() NotificationRepository
+}
+
+// Page bounds a list query
+type Page struct {
+	Limit  int
+	Offset int
+}
+
+// UserRepository stores user accounts
+type UserRepository interface {
+	Get(ctx context.Context, id uint) (*database.User, error)
+	GetByEmail(ctx context.Context, email string) (*database.User, error)
+	// Create inserts user, returning ErrDuplicate when the email is taken
+	Create(ctx context.Context, user *database.User) error
+	// Update sets the given columns of a user
+	Update(ctx context.Context, id uint, changes map[string]any) error
+	// SetPassword replaces the password hash and revokes outstanding access tokens
+	SetPassword(ctx context.Context, id uint, hash string) error
+	// IncrementTokenVersion revokes every access token issued to the user
+	IncrementTokenVersion(ctx context.Context, id uint) error
+	// MarkEmailVerified stamps EmailVerifiedAt unless it is already set
+	MarkEmailVerified(ctx context.Context, id uint, at time.Time) error
+	// ListWeaselEnabled returns up to limit users with Weasel Mode on whose
+	// ID is above afterID, in ID order
+	ListWeaselEnabled(ctx context.Context, afterID uint, limit int) ([]database.User, error)
+}
+
+// SessionRepository stores rotating refresh tokens
+type SessionRepository interface {
+	Create(ctx context.Context, token *database.RefreshToken) error
+	GetByHash(ctx context.Context, hash string) (*database.RefreshToken, error)
+	// Claim marks a live token used. It reports false when the token was
+	// already used or revoked, so two concurrent rotations cannot both win.
+	Claim(ctx context.Context, id uint, at time.Time) (bool, error)
+	SetReplacedBy(ctx context.Context, id, replacedByID uint) error
+	RevokeFamily(ctx context.Context, familyID string, at time.Time) error
+	RevokeAll(ctx context.Context, userID uint, at time.Time) error
+}
+
+// ActionTokenRepository stores single-use emailed tokens
+type ActionTokenRepository interface {
+	Create(ctx context.Context, token *database.ActionToken) error
+	// Redeem marks an unexpired, unused token as used, reporting whether it was
+	Redeem(ctx context.Context, tokenID string, userID uint, purpose string, at time.Time) (bool, error)
+	// ExpireAll marks every unused token of a purpose as used
+	ExpireAll(ctx context.Context, userID uint, purpose string, at time.Time) error
+}
+
+// HistoryQuery selects the workouts a user logged, optionally against one
+// program and within a time window
+type HistoryQuery struct {
+	UserID    uint
+	ProgramID uint       // Zero means every workout, with or without a program
+	Since     time.Time  // Zero means no lower bound
+	Until     *time.Time // Nil means no upper bound
+}
+
+// WorkoutRepository stores workouts and their sets. Workouts are returned
+// with their sets in logging order and each set's exercise loaded.
+type WorkoutRepository interface {
+	Get(ctx context.Context, userID, id uint) (*database.Workout, error)
+	// List returns a page of the user's workouts, most recent first, and the total count
+	List(ctx context.Context, userID uint, page Page) ([]database.Workout, int64, error)
+	// History returns the workouts matching q, oldest first
+	History(ctx context.Context, q HistoryQuery) ([]database.Workout, error)
+	// Create inserts a workout along with its sets
+	Create(ctx context.Context, workout *database.Workout) error
+	// Replace updates a workout and replaces its sets with workout.Sets
+	Replace(ctx context.Context, workout *database.Workout) error
+	Delete(ctx context.Context, userID, id uint) error
+}
+
+// ProgramRepository stores workout programs
+type ProgramRepository interface {
+	List(ctx context.Context) ([]database.Program, error)
+	Get(ctx context.Context, id uint) (*database.Program, error)
+	// Create inserts program, returning ErrDuplicate when the name is taken
+	Create(ctx context.Context, program *database.Program) error
+	// Save inserts or updates a program, returning ErrDuplicate when the name is taken
+	Save(ctx context.Context, program *database.Program) error
+	Count(ctx context.Context) (int64, error)
+}
+
+// ExerciseRepository stores the exercise catalog
+type ExerciseRepository interface {
+	List(ctx context.Context) ([]database.Exercise, error)
+	// Save inserts or updates an exercise, returning ErrDuplicate when the name is taken
+	Save(ctx context.Context, exercise *database.Exercise) error
+	Count(ctx context.Context) (int64, error)
+}
+
+// EnrollmentRepository stores program enrollments. Enrollments are returned
+// with their program and lifts loaded.
+type EnrollmentRepository interface {
+	// Current returns the user's active or paused enrollment
+	Current(ctx context.Context, userID uint) (*database.Enrollment, error)
+	// List returns every enrollment of the user, most recent first
+	List(ctx context.Context, userID uint) ([]database.Enrollment, error)
+	// Create inserts an enrollment with its lifts, returning ErrDuplicate
+	// when the user already has a current enrollment
+	Create(ctx context.Context, enrollment *database.Enrollment) error
+	// Update writes an enrollment's status, timestamps and progress
+	Update(ctx context.Context, enrollment *database.Enrollment) error
+	// UpdateLift writes a lift's working weight and failure count
+	UpdateLift(ctx context.Context, lift *database.EnrollmentLift) error
+}
+
+// AchievementRepository stores achievements and the users who unlocked them
+type AchievementRepository interface {
+	List(ctx context.Context) ([]database.Achievement, error)
+	// Save inserts or updates an achievement, returning ErrDuplicate when the name is taken
+	Save(ctx context.Context, achievement *database.Achievement) error
+	Count(ctx context.Context) (int64, error)
+	// Unlocked returns the achievements a user has earned with the achievement loaded
+	Unlocked(ctx context.Context, userID uint) ([]database.UserAchievement, error)
+	// Progress returns every progress row of a user, earned or not
+	Progress(ctx context.Context, userID uint) ([]database.UserAchievement, error)
+	// SaveProgress inserts or updates a progress row
+	SaveProgress(ctx context.Context, progress *database.UserAchievement) error
+}
+
+// BuddyRepository stores buddy and coach relationships. Relationships are
+// returned with both users loaded.
+type BuddyRepository interface {
+	// List returns the relationships in which the user is on either side
+	List(ctx context.Context, userID uint) ([]database.BuddyRelationship, error)
+	// Get returns a relationship the user is on either side of
+	Get(ctx context.Context, userID, id uint) (*database.BuddyRelationship, error)
+	// Between returns the relationship linking two users in either direction
+	Between(ctx context.Context, a, b uint) (*database.BuddyRelationship, error)
+	// Create inserts rel, returning ErrDuplicate when the users are already linked
+	Create(ctx context.Context, rel *database.BuddyRelationship) error
+	// Update writes a relationship's status and acceptance time
+	Update(ctx context.Context, rel *database.BuddyRelationship) error
+	Delete(ctx context.Context, id uint) error
+}
+
+// BuddyInviteRepository stores emailed buddy invitations
+type BuddyInviteRepository interface {
+	// Create returns ErrDuplicate when the inviter already has an invite
+	// pending to the address, expired or not
+	Create(ctx context.Context, invite *database.BuddyInvite) error
+	// GetByHash returns the invite whose token hashes to hash with its inviter loaded
+	GetByHash(ctx context.Context, hash string) (*database.BuddyInvite, error)
+	// Pending returns the user's pending invites that are unexpired at now, oldest first
+	Pending(ctx context.Context, inviterID uint, now time.Time) ([]database.BuddyInvite, error)
+	// Resolve moves the pending invites from inviterID to email to status,
+	// returning how many it moved
+	Resolve(ctx context.Context, inviterID uint, email, status string, at time.Time) (int64, error)
+	// ResolveByID moves one pending invite to status, returning ErrNotFound
+	// when it is no longer pending
+	ResolveByID(ctx context.Context, id uint, status string, at time.Time) error
+}
+
+// CoachPermissionRepository stores the permissions clients grant their coaches
+type CoachPermissionRepository interface {
+	// List returns the permissions granted in a relationship, oldest first
+	List(ctx context.Context, relationshipID uint) ([]database.CoachPermission, error)
+	// Grant inserts p, returning ErrDuplicate when the permission is already granted
+	Grant(ctx context.Context, p *database.CoachPermission) error
+	// Revoke removes a granted permission
+	Revoke(ctx context.Context, relationshipID uint, permission string) error
+}
+
+// ChallengeRepository stores group challenges. Challenges are returned with
+// their participants in invitation order, each with the user loaded.
+type ChallengeRepository interface {
+	// Create inserts a challenge along with its participants
+	Create(ctx context.Context, challenge *database.Challenge) error
+	Get(ctx context.Context, id uint) (*database.Challenge, error)
+	// List returns the challenges the user was invited to, most recent first
+	List(ctx context.Context, userID uint) ([]database.Challenge, error)
+	// Due returns the challenges that ended at or before now and are not
+	// yet completed, oldest first
+	Due(ctx context.Context, now time.Time) ([]database.Challenge, error)
+	// Complete marks a challenge completed at at, returning how many
+	// challenges it marked: zero when it was already completed
+	Complete(ctx context.Context, id uint, at time.Time) (int64, error)
+	// UpdateParticipant writes a participant's status, join time and final standing
+	UpdateParticipant(ctx context.Context, p *database.ChallengeParticipant) error
+	// Results returns the user's final standings in completed challenges
+	Results(ctx context.Context, userID uint) ([]database.ChallengeParticipant, error)
+}
+
+// ConversionQuery selects the messages a conversion report covers
+type ConversionQuery struct {
+	Since      time.Time // Zero means no lower bound
+	Until      time.Time // Messages sent at or after Until are left out
+	Experiment string    // Non-empty keeps only messages sent under the experiment
+}
+
+// ConversionStats counts the messages of one type and intensity
+type ConversionStats struct {
+	MessageType string
+	Intensity   string
+	Sent        int64
+	Read        int64
+	Triggered   int64
+}
+
+// VariantStats counts the messages sent under one experiment variant
+type VariantStats struct {
+	Variant   string
+	Sent      int64
+	Users     int64 // Distinct users who received the messages
+	Triggered int64
+}
+
+// MessageRepository stores Weasel Mode messages
+type MessageRepository interface {
+	Create(ctx context.Context, msg *database.WeaselMessage) error
+	// Get returns one of the user's messages
+	Get(ctx context.Context, userID, id uint) (*database.WeaselMessage, error)
+	// List returns a page of the user's messages, most recent first
+	List(ctx context.Context, userID uint, page Page) ([]database.WeaselMessage, error)
+	// Since returns the user's messages sent at or after since, oldest first
+	Since(ctx context.Context, userID uint, since time.Time) ([]database.WeaselMessage, error)
+	// Update sets the given columns of one of the user's messages
+	Update(ctx context.Context, userID, id uint, changes map[string]any) error
+	// MarkTriggered flags the messages sent at or after since that the user
+	// followed with a workout within window, returning how many it flagged
+	MarkTriggered(ctx context.Context, since time.Time, window time.Duration) (int64, error)
+	// Conversion counts the messages matching q by type and intensity
+	Conversion(ctx context.Context, q ConversionQuery) ([]ConversionStats, error)
+	// Variants counts the messages matching q by experiment variant
+	Variants(ctx context.Context, q ConversionQuery) ([]VariantStats, error)
+}
+
+// NotificationRepository stores the notification outbox
+type NotificationRepository interface {
+	Create(ctx context.Context, n *database.Notification) error
+	Get(ctx context.Context, id uint) (*database.Notification, error)
+	// Claim returns up to limit pending notifications due at now with their
+	// user and message loaded, oldest first, and pushes their next attempt
+	// back by lease so concurrent workers do not deliver them twice
+	Claim(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]database.Notification, error)
+	// Update writes a notification's status, attempts and schedule
+	Update(ctx context.Context, n *database.Notification) error
+}
+
+// StreakRepository stores workout streaks, one per user and streak type
+type StreakRepository interface {
+	Get(ctx context.Context, userID uint, streakType string) (*database.Streak, error)
+	// List returns every streak of the user
+	List(ctx context.Context, userID uint) ([]database.Streak, error)
+	// Save inserts or updates a streak, returning ErrDuplicate when the user
+	// already has another streak of the type
+	Save(ctx context.Context, streak *database.Streak) error
+}
+
+// ActivityRepository stores generated social activity
+type ActivityRepository interface {
+	// List returns every activity in insertion order
+	List(ctx context.Context) ([]database.FakeSocialActivity, error)
+	// Recent returns up to limit activities, most recent first
+	Recent(ctx context.Context, limit int) ([]database.FakeSocialActivity, error)
+	// Save inserts or updates an activity
+	Save(ctx context.Context, activity *database.FakeSocialActivity) error
+	Count(ctx context.Context) (int64, error)
+}
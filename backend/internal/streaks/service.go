@@ -0,0 +1,122 @@
+package streaks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+)
+
+// Service keeps users' streaks up to date
+type Service struct {
+	store  store.Store
+	config *Config
+	now    func() time.Time
+}
+
+// NewService creates a streak service backed by s
+func NewService(s store.Store, cfg *Config) *Service {
+	return &Service{store: s, config: cfg, now: time.Now}
+}
+
+// WorkoutLogged extends every streak of the user with a workout completed
+// at. A workout logged into an earlier period than a streak's last workout,
+// or a change of the user's time zone, rebuilds the streak from history.
+func (s *Service) WorkoutLogged(ctx context.Context, userID uint, at time.Time) ([]database.Streak, error) {
+	return s.update(ctx, userID, func(streak *database.Streak, p Policy, loc *time.Location) bool {
+		if streak.Current > 0 && period(streak.StreakType, at, loc) < period(streak.StreakType, streak.LastWorkout, loc) {
+			return false
+		}
+		advance(streak, p, at, loc)
+		return true
+	})
+}
+
+// Recompute rebuilds every streak of the user from their workout history.
+// Use it after workouts are edited or deleted.
+func (s *Service) Recompute(ctx context.Context, userID uint) ([]database.Streak, error) {
+	return s.update(ctx, userID, func(*database.Streak, Policy, *time.Location) bool { return false })
+}
+
+// Refresh ends streaks whose grace period and freezes have run out without
+// a workout, and returns the user's streaks
+func (s *Service) Refresh(ctx context.Context, userID uint) ([]database.Streak, error) {
+	return s.update(ctx, userID, func(*database.Streak, Policy, *time.Location) bool { return true })
+}
+
+// update applies fn to each of the user's streaks in a transaction and
+// saves them. When fn returns false, or the streak was computed in another
+// time zone, the streak is rebuilt from history instead. Every streak is
+// then checked for expiry.
+func (s *Service) update(ctx context.Context, userID uint,
+	fn func(streak *database.Streak, p Policy, loc *time.Location) bool,
+) ([]database.Streak, error) {
+	var out []database.Streak
+	err := s.store.Transaction(ctx, func(tx store.Store) error {
+		user, err := tx.Users().Get(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("failed to load user: %w", err)
+		}
+		loc := user.Location()
+		now := s.now()
+
+		var history []time.Time // Loaded on first rebuild
+		for _, streakType := range database.StreakTypes {
+			streak, err := loadStreak(ctx, tx, userID, streakType)
+			if err != nil {
+				return err
+			}
+			p := s.config.Policies[streakType]
+
+			if streak.Timezone != user.Timezone || !fn(streak, p, loc) {
+				if history == nil {
+					if history, err = completionTimes(ctx, tx, userID); err != nil {
+						return err
+					}
+				}
+				replay(streak, p, history, loc)
+				streak.Timezone = user.Timezone
+			}
+			expire(streak, p, now, loc)
+
+			if err := tx.Streaks().Save(ctx, streak); err != nil {
+				return fmt.Errorf("failed to save streak: %w", err)
+			}
+			out = append(out, *streak)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update streaks: %w", err)
+	}
+	return out, nil
+}
+
+// loadStreak returns the user's streak of a type, or a new one
+func loadStreak(ctx context.Context, tx store.Store, userID uint, streakType string) (*database.Streak, error) {
+	streak, err := tx.Streaks().Get(ctx, userID, streakType)
+	if errors.Is(err, store.ErrNotFound) {
+		// A new streak has no time zone yet, so it is built from history
+		return &database.Streak{UserID: userID, StreakType: streakType}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load streak: %w", err)
+	}
+	return streak, nil
+}
+
+// completionTimes returns when each of the user's workouts was completed, oldest first
+func completionTimes(ctx context.Context, tx store.Store, userID uint) ([]time.Time, error) {
+	workouts, err := tx.Workouts().History(ctx, store.HistoryQuery{UserID: userID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load workouts: %w", err)
+	}
+	times := make([]time.Time, len(workouts))
+	for i, w := range workouts {
+		times[i] = w.CompletedAt
+	}
+	return times, nil
+}
@@ -0,0 +1,188 @@
+// Package streaks maintains workout streaks from workout history.
+//
+// A streak counts consecutive periods with at least one workout: calendar
+// days for the workout streak, Monday to Sunday weeks for the weekly streak
+// and calendar months for the monthly one. Periods are taken in the user's
+// time zone, so a late-night workout counts toward the day the user
+// experienced.
+//
+// Two things keep a streak alive across missed periods. The grace period
+// forgives a fixed number of missed periods between workouts, for rest days.
+// Streak freezes bridge longer gaps: each missed period beyond the grace
+// consumes one. Freezes are earned as the streak grows, so replaying the
+// history always yields the same streak and a recompute never invents or
+// loses freezes.
+package streaks
+
+import (
+	"time"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/env"
+)
+
+const (
+	daysPerWeek   = 7
+	monthsPerYear = 12
+	secondsPerDay = 24 * 60 * 60
+
+	// The Unix epoch fell on a Thursday; shifting by three days makes weeks
+	// start on Monday
+	epochToMonday = 3
+
+	// Fridays to Mondays are rest, not a broken streak
+	defaultGraceDays  = 2
+	defaultMaxFreezes = 2
+)
+
+// Policy controls how forgiving one streak type is
+type Policy struct {
+	// Grace is how many periods may be missed between workouts
+	Grace int
+	// FreezeEvery earns a freeze each time the streak grows by this many
+	// periods; zero disables earning
+	FreezeEvery int
+	// MaxFreezes caps how many freezes can be banked
+	MaxFreezes int
+}
+
+// Config holds the policy of each streak type
+type Config struct {
+	Policies map[string]Policy
+}
+
+// DefaultConfig returns the default policies
+func DefaultConfig() *Config {
+	return &Config{Policies: map[string]Policy{
+		database.StreakWorkout: {Grace: defaultGraceDays, FreezeEvery: daysPerWeek, MaxFreezes: defaultMaxFreezes},
+		database.StreakWeekly:  {Grace: 0, FreezeEvery: 4, MaxFreezes: 1},
+		database.StreakMonthly: {Grace: 0, FreezeEvery: 3, MaxFreezes: 1},
+	}}
+}
+
+// LoadConfig loads the default policies, overriding grace periods from
+// STREAK_GRACE_DAYS, STREAK_GRACE_WEEKS and STREAK_GRACE_MONTHS and the
+// freeze cap from STREAK_MAX_FREEZES
+func LoadConfig() *Config {
+	cfg := DefaultConfig()
+	grace := map[string]string{
+		database.StreakWorkout: "STREAK_GRACE_DAYS",
+		database.StreakWeekly:  "STREAK_GRACE_WEEKS",
+		database.StreakMonthly: "STREAK_GRACE_MONTHS",
+	}
+	// One cap for every streak type; -1 keeps each type's default
+	maxFreezes := env.Int("STREAK_MAX_FREEZES", -1, 0)
+	for streakType, key := range grace {
+		p := cfg.Policies[streakType]
+		p.Grace = env.Int(key, p.Grace, 0)
+		if maxFreezes >= 0 {
+			p.MaxFreezes = maxFreezes
+		}
+		cfg.Policies[streakType] = p
+	}
+	return cfg
+}
+
+// period numbers the day, week or month containing t in loc so that
+// consecutive periods differ by one
+func period(streakType string, t time.Time, loc *time.Location) int {
+	y, m, d := t.In(loc).Date()
+	switch streakType {
+	case database.StreakWeekly:
+		return floorDiv(epochDay(y, m, d)+epochToMonday, daysPerWeek)
+	case database.StreakMonthly:
+		return y*monthsPerYear + int(m) - 1
+	default:
+		return epochDay(y, m, d)
+	}
+}
+
+// epochDay returns the number of days between the Unix epoch and a date
+func epochDay(y int, m time.Month, d int) int {
+	return int(time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / secondsPerDay)
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && a < 0 {
+		q--
+	}
+	return q
+}
+
+// advance extends streak with a workout completed at, which must not fall
+// in a period before the streak's last workout
+func advance(streak *database.Streak, p Policy, at time.Time, loc *time.Location) {
+	if streak.Current == 0 {
+		restart(streak, p, at)
+		return
+	}
+
+	last := period(streak.StreakType, streak.LastWorkout, loc)
+	missed := period(streak.StreakType, at, loc) - last - 1
+	switch {
+	case missed < 0:
+		// Another workout in the same period
+		if at.After(streak.LastWorkout) {
+			streak.LastWorkout = at
+		}
+		return
+	case missed-p.Grace > streak.Freezes:
+		restart(streak, p, at)
+		return
+	}
+
+	if need := missed - p.Grace; need > 0 {
+		streak.Freezes -= need
+		streak.FreezesUsed += need
+	}
+	streak.Current++
+	streak.LastWorkout = at
+	streak.Longest = max(streak.Longest, streak.Current)
+	earnFreeze(streak, p)
+}
+
+// restart begins a new streak at a workout; banked freezes carry over
+func restart(streak *database.Streak, p Policy, at time.Time) {
+	streak.Current = 1
+	streak.StreakStart = at
+	streak.LastWorkout = at
+	streak.FreezesUsed = 0
+	streak.IsActive = true
+	streak.Longest = max(streak.Longest, 1)
+	earnFreeze(streak, p)
+}
+
+func earnFreeze(streak *database.Streak, p Policy) {
+	if p.FreezeEvery > 0 && streak.Current%p.FreezeEvery == 0 && streak.Freezes < p.MaxFreezes {
+		streak.Freezes++
+	}
+}
+
+// expire marks the streak inactive when the periods missed since its last
+// workout can no longer be bridged by the grace period and banked freezes.
+// The current period does not count as missed until it is over. Current
+// keeps the length of the lapsed run: the next workout starts a new one, and
+// a workout backfilled into the gap can still extend it, exactly as a
+// recompute would.
+func expire(streak *database.Streak, p Policy, now time.Time, loc *time.Location) {
+	if streak.Current == 0 {
+		streak.IsActive = false
+		return
+	}
+	missed := period(streak.StreakType, now, loc) - period(streak.StreakType, streak.LastWorkout, loc) - 1
+	streak.IsActive = missed-p.Grace <= streak.Freezes
+}
+
+// replay rebuilds a streak from workout completion times, oldest first
+func replay(streak *database.Streak, p Policy, completed []time.Time, loc *time.Location) {
+	streak.Current = 0
+	streak.Longest = 0
+	streak.Freezes = 0
+	streak.FreezesUsed = 0
+	streak.LastWorkout = time.Time{}
+	streak.StreakStart = time.Time{}
+	for _, at := range completed {
+		advance(streak, p, at, loc)
+	}
+}
@@ -0,0 +1,223 @@
+package streaks
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+)
+
+var monday = time.Date(2025, time.March, 3, 18, 0, 0, 0, time.UTC)
+
+// days returns evening workout times the given number of days after monday
+func days(offsets ...int) []time.Time {
+	times := make([]time.Time, len(offsets))
+	for i, d := range offsets {
+		times[i] = monday.AddDate(0, 0, d)
+	}
+	return times
+}
+
+func TestPeriod(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("Time zone data unavailable: %v", err)
+	}
+	sunday := time.Date(2025, time.March, 9, 23, 30, 0, 0, time.UTC) // Monday 00:30 in Berlin
+
+	tests := []struct {
+		name       string
+		streakType string
+		a, b       time.Time
+		loc        *time.Location
+		expected   int
+	}{
+		{"next day", database.StreakWorkout, monday, monday.AddDate(0, 0, 1), time.UTC, 1},
+		{"same day", database.StreakWorkout, monday, monday.Add(5 * time.Hour), time.UTC, 0},
+		{"past local midnight", database.StreakWorkout, monday, monday.Add(5 * time.Hour), berlin, 1},
+		{"sunday ends the week", database.StreakWeekly, monday, sunday, time.UTC, 0},
+		{"local monday starts the next", database.StreakWeekly, monday, sunday, berlin, 1},
+		{"next month", database.StreakMonthly, monday, monday.AddDate(0, 1, 0), time.UTC, 1},
+		{"across years", database.StreakMonthly, monday.AddDate(0, -3, 0), monday, time.UTC, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := period(tt.streakType, tt.b, tt.loc) - period(tt.streakType, tt.a, tt.loc); got != tt.expected {
+				t.Errorf("Expected periods %d apart, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("STREAK_GRACE_DAYS", "2")
+	t.Setenv("STREAK_MAX_FREEZES", "0")
+	cfg := LoadConfig()
+	if got := cfg.Policies[database.StreakWorkout].Grace; got != 2 {
+		t.Errorf("Expected a 2 day grace period, got %d", got)
+	}
+	for streakType, p := range cfg.Policies {
+		if p.MaxFreezes != 0 {
+			t.Errorf("Expected %s freezes to be capped at 0, got %d", streakType, p.MaxFreezes)
+		}
+	}
+
+	t.Setenv("STREAK_MAX_FREEZES", "-1")
+	defaults := DefaultConfig()
+	for streakType, p := range LoadConfig().Policies {
+		if expected := defaults.Policies[streakType].MaxFreezes; p.MaxFreezes != expected {
+			t.Errorf("Expected an invalid cap to keep %s at %d freezes, got %d", streakType, expected, p.MaxFreezes)
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+	strict := Policy{}
+	tests := []struct {
+		name            string
+		streakType      string
+		policy          Policy
+		completed       []time.Time
+		expectedCurrent int
+		expectedLongest int
+		expectedFreezes int
+	}{
+		{"consecutive days", database.StreakWorkout, strict, days(0, 1, 2), 3, 3, 0},
+		{"two workouts in a day", database.StreakWorkout, strict, days(0, 0, 1), 2, 2, 0},
+		{"missed day breaks", database.StreakWorkout, strict, days(0, 1, 3), 1, 2, 0},
+		{"grace covers rest days", database.StreakWorkout, Policy{Grace: 2}, days(0, 2, 4, 7), 4, 4, 0},
+		{"gap beyond grace", database.StreakWorkout, Policy{Grace: 2}, days(0, 2, 6), 1, 2, 0},
+		{"freeze earned and spent", database.StreakWorkout, Policy{FreezeEvery: 3, MaxFreezes: 2}, days(0, 1, 2, 4), 4, 4, 0},
+		{"freezes are capped", database.StreakWorkout, Policy{FreezeEvery: 1, MaxFreezes: 2}, days(0, 1, 2, 3), 4, 4, 2},
+		{"gap too long keeps freezes", database.StreakWorkout, Policy{FreezeEvery: 2, MaxFreezes: 1}, days(0, 1, 5), 1, 2, 1},
+		{"weekly", database.StreakWeekly, strict, days(0, 6, 7, 15), 3, 3, 0},
+		{"weekly with a missed week", database.StreakWeekly, strict, days(0, 7, 21), 1, 2, 0},
+		{"monthly", database.StreakMonthly, strict, days(0, 29, 60), 3, 3, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			streak := &database.Streak{StreakType: tt.streakType}
+			replay(streak, tt.policy, tt.completed, time.UTC)
+			if streak.Current != tt.expectedCurrent || streak.Longest != tt.expectedLongest || streak.Freezes != tt.expectedFreezes {
+				t.Errorf("Expected current %d, longest %d, freezes %d; got %d, %d, %d",
+					tt.expectedCurrent, tt.expectedLongest, tt.expectedFreezes, streak.Current, streak.Longest, streak.Freezes)
+			}
+		})
+	}
+}
+
+func TestExpire(t *testing.T) {
+	streak := &database.Streak{StreakType: database.StreakWorkout}
+	p := Policy{Grace: 1, FreezeEvery: 2, MaxFreezes: 1}
+	replay(streak, p, days(0, 1), time.UTC) // Earns one freeze
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		expected bool
+	}{
+		{"same day", monday.AddDate(0, 0, 1), true},
+		{"today not over", monday.AddDate(0, 0, 2), true},
+		{"grace day missed", monday.AddDate(0, 0, 3), true},
+		{"freeze would cover", monday.AddDate(0, 0, 4), true},
+		{"lapsed", monday.AddDate(0, 0, 5), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := *streak
+			expire(&s, p, tt.now, time.UTC)
+			if s.IsActive != tt.expected || s.Current != 2 {
+				t.Errorf("Expected active=%v, got %+v", tt.expected, s)
+			}
+		})
+	}
+}
+
+// newTestService returns a service over a memory store holding one user
+func newTestService(t *testing.T, p Policy) (*Service, store.Store, uint) {
+	t.Helper()
+	s := store.NewMemory()
+	user := &database.User{Email: "sam@example.com", Timezone: "UTC"}
+	if err := s.Users().Create(context.Background(), user); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	cfg := &Config{Policies: map[string]Policy{}}
+	for _, streakType := range database.StreakTypes {
+		cfg.Policies[streakType] = p
+	}
+	svc := NewService(s, cfg)
+	svc.now = func() time.Time { return monday.AddDate(0, 0, 9) }
+	return svc, s, user.ID
+}
+
+func logWorkouts(t *testing.T, svc *Service, s store.Store, userID uint, completed []time.Time) map[string]database.Streak {
+	t.Helper()
+	ctx := context.Background()
+	var list []database.Streak
+	for _, at := range completed {
+		if err := s.Workouts().Create(ctx, &database.Workout{UserID: userID, CompletedAt: at}); err != nil {
+			t.Fatalf("Failed to log workout: %v", err)
+		}
+		var err error
+		if list, err = svc.WorkoutLogged(ctx, userID, at); err != nil {
+			t.Fatalf("Failed to update streaks: %v", err)
+		}
+	}
+	byType := make(map[string]database.Streak, len(list))
+	for _, streak := range list {
+		byType[streak.StreakType] = streak
+	}
+	return byType
+}
+
+func TestServiceMatchesRecompute(t *testing.T) {
+	ctx := context.Background()
+	svc, s, userID := newTestService(t, Policy{Grace: 1, FreezeEvery: 3, MaxFreezes: 1})
+
+	// Logged out of order: day 5 arrives after day 6 and triggers a rebuild
+	got := logWorkouts(t, svc, s, userID, days(0, 1, 2, 6, 5, 7, 8))
+	if workout := got[database.StreakWorkout]; workout.Current != 7 || !workout.IsActive || workout.FreezesUsed != 1 {
+		t.Errorf("Expected a 7 day streak bridged by one freeze, got %+v", workout)
+	}
+
+	recomputed, err := svc.Recompute(ctx, userID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, r := range recomputed {
+		g := got[r.StreakType]
+		if g.Current != r.Current || g.Longest != r.Longest || g.Freezes != r.Freezes || g.FreezesUsed != r.FreezesUsed {
+			t.Errorf("Expected recompute to match incremental %s streak %+v, got %+v", r.StreakType, g, r)
+		}
+	}
+}
+
+func TestServiceUsesUserTimezone(t *testing.T) {
+	if _, err := time.LoadLocation("America/Los_Angeles"); err != nil {
+		t.Skipf("Time zone data unavailable: %v", err)
+	}
+	ctx := context.Background()
+	svc, s, userID := newTestService(t, Policy{})
+
+	// 18:00 and 06:00 the next morning UTC fall on the same Los Angeles day
+	completed := []time.Time{monday.AddDate(0, 0, 9), monday.AddDate(0, 0, 9).Add(12 * time.Hour)}
+	if got := logWorkouts(t, svc, s, userID, completed); got[database.StreakWorkout].Current != 2 {
+		t.Errorf("Expected two UTC days, got %+v", got[database.StreakWorkout])
+	}
+
+	if err := s.Users().Update(ctx, userID, map[string]any{"timezone": "America/Los_Angeles"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	list, err := svc.Refresh(ctx, userID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if list[0].Current != 1 || list[0].Timezone != "America/Los_Angeles" {
+		t.Errorf("Expected a time zone change to rebuild the streak, got %+v", list[0])
+	}
+}
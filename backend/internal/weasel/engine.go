@@ -0,0 +1,321 @@
+package weasel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/compare"
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/realtime"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+)
+
+var (
+	// ErrDisabled is returned for users who turned Weasel Mode off
+	ErrDisabled = errors.New("weasel mode is disabled")
+	// ErrNotAllowed is returned when the user's consent flags rule out the message type
+	ErrNotAllowed = errors.New("message type not allowed by the user's settings")
+	// ErrNoTemplate is returned when no template can be rendered for the user
+	ErrNoTemplate = errors.New("no applicable message template")
+)
+
+// Made-up statistics stay believable
+const (
+	fakePercentMin   = 60
+	fakePercentRange = 36
+	hoursPerDay      = 24
+)
+
+// Allowed reports whether the user's settings permit messages of messageType,
+// returning ErrDisabled or ErrNotAllowed when they do not
+func Allowed(user *database.User, messageType string) error {
+	switch {
+	case !user.WeaselModeEnabled:
+		return ErrDisabled
+	case messageType == database.MessageGuilt && !user.AllowGuiltTrips:
+		return ErrNotAllowed
+	case (messageType == database.MessageFOMO || messageType == database.MessageSocial) && !user.AllowSocialPressure:
+		return ErrNotAllowed
+	default:
+		return nil
+	}
+}
+
+// Outbox queues a stored message for delivery. Enqueue runs in the
+// transaction that stores the message, so a message is never kept without
+// its deliveries or delivered without being kept.
+type Outbox interface {
+	Enqueue(ctx context.Context, tx store.Store, user *database.User, msg *database.WeaselMessage) error
+}
+
+// Engine renders and stores Weasel messages
+type Engine struct {
+	store       store.Store
+	templates   []Template
+	experiments []Experiment
+	outbox      Outbox
+	events      realtime.Publisher
+	now         func() time.Time
+
+	mu  sync.Mutex // Guards rng
+	rng *rand.Rand
+}
+
+// NewEngine creates an engine that picks among templates with rng; pass a
+// seeded source for reproducible messages. Active experiments replace the
+// templates of their message type with those of the user's variant. Stored
+// messages are queued in outbox and published to events; either may be nil.
+func NewEngine(s store.Store, templates []Template, experiments []Experiment, outbox Outbox, events realtime.Publisher, rng *rand.Rand) *Engine {
+	return &Engine{store: s, templates: templates, experiments: experiments, outbox: outbox, events: events, now: time.Now, rng: rng}
+}
+
+// Request describes a message to compose
+type Request struct {
+	UserID uint
+	Type   string
+	// MaxIntensity caps the user's WeaselIntensity; empty means no cap
+	MaxIntensity string
+	// NudgeStep is recorded on the message when the nudge scheduler sends it
+	NudgeStep *int
+	// SentAt is the time the message is sent; zero means now
+	SentAt time.Time
+	// Content, when set, is sent as written instead of a rendered template
+	Content string
+	// SenderID is recorded on the message when a coach wrote Content
+	SenderID *uint
+}
+
+// Generate renders a message of messageType for the user and stores it.
+// Templates at the user's intensity are preferred, falling back to milder
+// ones when none apply.
+func (e *Engine) Generate(ctx context.Context, userID uint, messageType string) (*database.WeaselMessage, error) {
+	return e.Compose(ctx, Request{UserID: userID, Type: messageType})
+}
+
+// Compose renders and stores the requested message, like Generate. Messages
+// with Content skip the templates but are subject to the same consent
+// checks and delivery.
+func (e *Engine) Compose(ctx context.Context, req Request) (*database.WeaselMessage, error) {
+	user, err := e.store.Users().Get(ctx, req.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user: %w", err)
+	}
+	if err := Allowed(user, req.Type); err != nil {
+		return nil, err
+	}
+
+	at := req.SentAt
+	if at.IsZero() {
+		at = e.now()
+	}
+	intensity := user.WeaselIntensity
+	if req.MaxIntensity != "" && Milder(req.MaxIntensity, intensity) {
+		intensity = req.MaxIntensity
+	}
+
+	msg := &database.WeaselMessage{UserID: user.ID, MessageType: req.Type, SentAt: at, NudgeStep: req.NudgeStep, SenderID: req.SenderID}
+	if req.Content != "" {
+		msg.Content, msg.Intensity = req.Content, intensity
+	} else if err := e.render(ctx, user, msg, intensity); err != nil {
+		return nil, err
+	}
+
+	err = e.store.Transaction(ctx, func(tx store.Store) error {
+		if err := tx.Messages().Create(ctx, msg); err != nil {
+			return fmt.Errorf("failed to store message: %w", err)
+		}
+		if e.outbox == nil {
+			return nil
+		}
+		if err := e.outbox.Enqueue(ctx, tx, user, msg); err != nil {
+			return fmt.Errorf("failed to queue message: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err //nolint:wrapcheck // wrapped inside the transaction
+	}
+	e.publish(ctx, msg)
+	return msg, nil
+}
+
+// render fills in msg's content and intensity from a template of its type
+func (e *Engine) render(ctx context.Context, user *database.User, msg *database.WeaselMessage, intensity string) error {
+	vars, err := e.variables(ctx, user, msg.SentAt)
+	if err != nil {
+		return err
+	}
+
+	ok := false
+	// A variant that cannot render falls back to the regular templates
+	// without counting as an exposure
+	if x := e.experiment(msg.MessageType); x != nil {
+		variant := x.Assign(user.ID)
+		pool := e.templates
+		if len(variant.Templates) > 0 {
+			pool = variant.Templates
+		}
+		if msg.Content, msg.Intensity, ok = e.pick(pool, msg.MessageType, intensity, vars); ok {
+			msg.Experiment, msg.Variant = x.Name, variant.Name
+		}
+	}
+	if !ok {
+		if msg.Content, msg.Intensity, ok = e.pick(e.templates, msg.MessageType, intensity, vars); !ok {
+			return ErrNoTemplate
+		}
+	}
+	return nil
+}
+
+// publish streams a stored message to the user's connected clients. The
+// message is already stored, so failures are only logged.
+func (e *Engine) publish(ctx context.Context, msg *database.WeaselMessage) {
+	if e.events == nil {
+		return
+	}
+	event, err := realtime.NewEvent(msg.UserID, realtime.EventWeaselMessage, msg, msg.SentAt)
+	if err == nil {
+		err = e.events.Publish(ctx, event)
+	}
+	if err != nil {
+		slog.Warn("Failed to publish message event", "user_id", msg.UserID, "message_id", msg.ID, "error", err)
+	}
+}
+
+// experiment returns the active experiment for a message type, or nil
+func (e *Engine) experiment(messageType string) *Experiment {
+	for i := range e.experiments {
+		if x := &e.experiments[i]; x.Active && x.MessageType == messageType {
+			return x
+		}
+	}
+	return nil
+}
+
+// pick renders a random template of messageType from pool. Templates at
+// intensity are preferred, falling back to milder ones when none apply.
+func (e *Engine) pick(pool []Template, messageType, intensity string, vars map[string]any) (content, used string, ok bool) {
+	for _, level := range milderOrEqual(intensity) {
+		var candidates []string
+		for i := range pool {
+			t := &pool[i]
+			if t.Type != messageType || t.Intensity != level {
+				continue
+			}
+			// Templates needing a variable the user has no value for are skipped
+			if text, err := t.render(vars); err == nil {
+				candidates = append(candidates, text)
+			}
+		}
+		if len(candidates) > 0 {
+			return candidates[e.intN(len(candidates))], level, true
+		}
+	}
+	return "", "", false
+}
+
+// Milder reports whether intensity a is milder than b
+func Milder(a, b string) bool {
+	return slices.Index(database.WeaselIntensities, a) < slices.Index(database.WeaselIntensities, b)
+}
+
+// milderOrEqual returns the intensity followed by each milder one
+func milderOrEqual(intensity string) []string {
+	i := slices.Index(database.WeaselIntensities, intensity)
+	if i < 0 {
+		i = slices.Index(database.WeaselIntensities, database.IntensityMedium)
+	}
+	out := slices.Clone(database.WeaselIntensities[:i+1])
+	slices.Reverse(out)
+	return out
+}
+
+// variables collects the template variables the user has values for
+func (e *Engine) variables(ctx context.Context, user *database.User, at time.Time) (map[string]any, error) {
+	name := user.Name
+	if name == "" {
+		name = "friend"
+	}
+	vars := map[string]any{"Name": name}
+
+	recent, _, err := e.store.Workouts().List(ctx, user.ID, store.Page{Limit: 1})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load workouts: %w", err)
+	}
+	if len(recent) > 0 {
+		vars["DaysSinceWorkout"] = daysBetween(recent[0].CompletedAt, at, user.Location())
+	}
+
+	streak, err := e.store.Streaks().Get(ctx, user.ID, database.StreakWorkout)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+	case err != nil:
+		return nil, fmt.Errorf("failed to load streak: %w", err)
+	default:
+		if streak.IsActive && streak.Current > 0 {
+			vars["Streak"] = streak.Current
+		}
+		if streak.Longest > 0 {
+			vars["LongestStreak"] = streak.Longest
+		}
+	}
+
+	buddy, err := e.buddyName(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if buddy != "" {
+		vars["BuddyName"] = buddy
+	}
+
+	// Comparisons with buddies are only drawn for users who allow social pressure
+	if user.AllowSocialPressure {
+		comparison, err := compare.Compare(ctx, e.store, user, at)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compare with buddies: %w", err)
+		}
+		if len(comparison.Insights) > 0 {
+			vars["Insight"] = comparison.Insights[0].Text
+		}
+	}
+
+	if user.AllowFakeStats {
+		vars["FakePercent"] = fakePercentMin + e.intN(fakePercentRange)
+	}
+	return vars, nil
+}
+
+// buddyName returns the name of a random active peer buddy, or "" without one
+func (e *Engine) buddyName(ctx context.Context, userID uint) (string, error) {
+	rels, err := e.store.Buddies().List(ctx, userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to load buddies: %w", err)
+	}
+
+	peers := database.ActivePeers(rels, userID)
+	if len(peers) == 0 {
+		return "", nil
+	}
+	return peers[e.intN(len(peers))].Name, nil
+}
+
+func (e *Engine) intN(n int) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.rng.IntN(n)
+}
+
+// daysBetween counts the calendar days from a to b in loc
+func daysBetween(a, b time.Time, loc *time.Location) int {
+	ay, am, ad := a.In(loc).Date()
+	by, bm, bd := b.In(loc).Date()
+	from := time.Date(ay, am, ad, 0, 0, 0, 0, time.UTC)
+	to := time.Date(by, bm, bd, 0, 0, 0, 0, time.UTC)
+	return int(to.Sub(from).Hours() / hoursPerDay)
+}
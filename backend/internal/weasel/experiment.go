@@ -0,0 +1,174 @@
+package weasel
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed experiments.yaml
+var builtinExperiments []byte
+
+// z95 is the normal quantile for a two-sided 95% confidence interval
+const z95 = 1.959964
+
+// Experiment tests alternative copy for one message type. While an
+// experiment is active, every message of its type is sent under the
+// variant the user is bucketed into.
+type Experiment struct {
+	Name        string    `yaml:"name"`
+	MessageType string    `yaml:"message_type"`
+	Active      bool      `yaml:"active"`
+	Variants    []Variant `yaml:"variants"`
+}
+
+// Variant is one arm of an experiment. A variant without templates is a
+// control and uses the regular templates.
+type Variant struct {
+	Name      string     `yaml:"name"`
+	Weight    int        `yaml:"weight"`
+	Templates []Template `yaml:"templates"`
+}
+
+// Assign returns the variant for a user. Users are bucketed by a hash of
+// the experiment name and their ID, so they keep their variant for as long
+// as the variants and weights stay the same.
+func (x *Experiment) Assign(userID uint) *Variant {
+	total := 0
+	for _, v := range x.Variants {
+		total += v.Weight
+	}
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(x.Name + "/" + strconv.FormatUint(uint64(userID), 10))) //nolint:errcheck // hash writes never fail
+	bucket := h.Sum64() % uint64(total)                                           //nolint:gosec // weights are validated positive
+	for i := range x.Variants {
+		w := uint64(x.Variants[i].Weight) //nolint:gosec // weights are validated positive
+		if bucket < w {
+			return &x.Variants[i]
+		}
+		bucket -= w
+	}
+	return &x.Variants[len(x.Variants)-1]
+}
+
+// BuiltinExperiments returns the experiments embedded in the binary
+func BuiltinExperiments() ([]Experiment, error) {
+	return ParseExperiments(builtinExperiments)
+}
+
+// LoadExperiments reads an experiments document from path, or returns the
+// built-in experiments when path is empty
+func LoadExperiments(path string) ([]Experiment, error) {
+	if path == "" {
+		return BuiltinExperiments()
+	}
+	raw, err := os.ReadFile(path) //nolint:gosec // path comes from the operator's configuration
+	if err != nil {
+		return nil, fmt.Errorf("failed to read weasel experiments: %w", err)
+	}
+	return ParseExperiments(raw)
+}
+
+// ParseExperiments decodes, compiles and validates an experiments document.
+// Variant templates take the experiment's message type when they omit one.
+func ParseExperiments(raw []byte) ([]Experiment, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+
+	var doc struct {
+		Experiments []Experiment `yaml:"experiments"`
+	}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid weasel experiments: %w", err)
+	}
+
+	var problems []string
+	names := map[string]bool{}
+	activeTypes := map[string]string{}
+	for i := range doc.Experiments {
+		x := &doc.Experiments[i]
+		where := fmt.Sprintf("experiments[%d]", i)
+		if x.Name == "" {
+			problems = append(problems, where+".name: required")
+		}
+		if names[x.Name] {
+			problems = append(problems, fmt.Sprintf("%s.name: duplicate experiment %q", where, x.Name))
+		}
+		names[x.Name] = true
+		if !slices.Contains(database.MessageTypes, x.MessageType) {
+			problems = append(problems, fmt.Sprintf("%s.message_type: unknown type %q", where, x.MessageType))
+		}
+		if other, ok := activeTypes[x.MessageType]; ok && x.Active {
+			problems = append(problems, fmt.Sprintf("%s.active: %q already tests %s messages", where, other, x.MessageType))
+		}
+		if x.Active {
+			activeTypes[x.MessageType] = x.Name
+		}
+		problems = append(problems, x.compileVariants(where)...)
+	}
+	if len(problems) > 0 {
+		return nil, errors.New("invalid weasel experiments: " + strings.Join(problems, "; "))
+	}
+	return doc.Experiments, nil
+}
+
+// compileVariants validates the variants and compiles their templates,
+// returning the problems found
+func (x *Experiment) compileVariants(where string) []string {
+	var problems []string
+	if len(x.Variants) < 2 { //nolint:mnd // an experiment compares at least two variants
+		problems = append(problems, where+".variants: need at least two")
+	}
+
+	seen := map[string]bool{}
+	for j := range x.Variants {
+		v := &x.Variants[j]
+		at := fmt.Sprintf("%s.variants[%d]", where, j)
+		if v.Name == "" || seen[v.Name] {
+			problems = append(problems, fmt.Sprintf("%s.name: missing or duplicate name %q", at, v.Name))
+		}
+		seen[v.Name] = true
+		if v.Weight <= 0 {
+			problems = append(problems, at+".weight: must be positive")
+		}
+		for k := range v.Templates {
+			t := &v.Templates[k]
+			if t.Type == "" {
+				t.Type = x.MessageType
+			}
+			if t.Type != x.MessageType {
+				problems = append(problems, fmt.Sprintf("%s.templates[%d].type: experiment tests %s messages", at, k, x.MessageType))
+			}
+			for _, p := range t.compile(fmt.Sprintf("%s/%s/%d", x.Name, v.Name, k)) {
+				problems = append(problems, fmt.Sprintf("%s.templates[%d].%s", at, k, p))
+			}
+		}
+	}
+	return problems
+}
+
+// ConfidenceInterval returns the 95% Wilson score interval for a
+// conversion rate of successes out of trials; without trials it spans
+// every rate
+func ConfidenceInterval(successes, trials int64) (lo, hi float64) {
+	if trials <= 0 {
+		return 0, 1
+	}
+	n := float64(trials)
+	p := float64(successes) / n
+	z2 := z95 * z95
+	center := (p + z2/(2*n)) / (1 + z2/n)
+	margin := z95 / (1 + z2/n) * math.Sqrt(p*(1-p)/n+z2/(4*n*n))
+	return max(0, center-margin), min(1, center+margin)
+}
@@ -0,0 +1,91 @@
+// Package weasel generates Weasel Mode messages.
+//
+// Messages are rendered from templates chosen by message type and the
+// user's WeaselIntensity. Consent is checked before anything is rendered:
+// nothing is generated while Weasel Mode is off, guilt trips need
+// AllowGuiltTrips, FOMO and social messages need AllowSocialPressure, and
+// made-up statistics are only available with AllowFakeStats. Messages a
+// coach writes skip the templates but not the consent checks.
+package weasel
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed templates.yaml
+var builtinTemplates []byte
+
+// Template is a message template
+type Template struct {
+	Type      string `yaml:"type"`
+	Intensity string `yaml:"intensity"`
+	Text      string `yaml:"text"`
+
+	tmpl *template.Template
+}
+
+// Builtin returns the templates embedded in the binary
+func Builtin() ([]Template, error) {
+	return ParseTemplates(builtinTemplates)
+}
+
+// ParseTemplates decodes and compiles a templates document
+func ParseTemplates(raw []byte) ([]Template, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+
+	var doc struct {
+		Templates []Template `yaml:"templates"`
+	}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid weasel templates: %w", err)
+	}
+
+	var problems []string
+	for i := range doc.Templates {
+		for _, p := range doc.Templates[i].compile(fmt.Sprintf("%s/%d", doc.Templates[i].Type, i)) {
+			problems = append(problems, fmt.Sprintf("templates[%d].%s", i, p))
+		}
+	}
+	if len(problems) > 0 {
+		return nil, errors.New("invalid weasel templates: " + strings.Join(problems, "; "))
+	}
+	return doc.Templates, nil
+}
+
+// compile validates the template and parses its text, returning the
+// problems found
+func (t *Template) compile(name string) []string {
+	var problems []string
+	if !slices.Contains(database.MessageTypes, t.Type) {
+		problems = append(problems, fmt.Sprintf("type: unknown type %q", t.Type))
+	}
+	if !slices.Contains(database.WeaselIntensities, t.Intensity) {
+		problems = append(problems, fmt.Sprintf("intensity: unknown intensity %q", t.Intensity))
+	}
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(t.Text)
+	if err != nil {
+		return append(problems, fmt.Sprintf("text: %v", err))
+	}
+	t.tmpl = tmpl
+	return problems
+}
+
+// render executes the template. It fails when the template uses a variable
+// missing from vars.
+func (t *Template) render(vars map[string]any) (string, error) {
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("failed to render template: %w", err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,410 @@
+package weasel
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lucas-albers-lz4/ferrovis/internal/database"
+	"github.com/lucas-albers-lz4/ferrovis/internal/store"
+)
+
+func TestBuiltinTemplates(t *testing.T) {
+	templates, err := Builtin()
+	if err != nil {
+		t.Fatalf("Failed to load built-in templates: %v", err)
+	}
+	// Gentle is the last fallback, so every type needs a gentle template
+	for _, messageType := range database.MessageTypes {
+		found := false
+		for _, tmpl := range templates {
+			found = found || tmpl.Type == messageType && tmpl.Intensity == database.IntensityGentle
+		}
+		if !found {
+			t.Errorf("Expected a gentle %s template", messageType)
+		}
+	}
+}
+
+func TestParseTemplatesRejectsInvalid(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		expectedError string
+	}{
+		{"unknown type", "templates:\n  - {type: bribery, intensity: gentle, text: hi}\n", `unknown type "bribery"`},
+		{"unknown intensity", "templates:\n  - {type: funny, intensity: mild, text: hi}\n", `unknown intensity "mild"`},
+		{"bad syntax", "templates:\n  - {type: funny, intensity: gentle, text: '{{.Name'}\n", "templates[0].text"},
+		{"unknown field", "templates:\n  - {type: funny, intensity: gentle, txt: hi}\n", "field txt not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseTemplates([]byte(tt.body))
+			if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+				t.Errorf("Expected error containing %q, got %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	all := database.User{WeaselModeEnabled: true, AllowGuiltTrips: true, AllowSocialPressure: true}
+	noGuilt := all
+	noGuilt.AllowGuiltTrips = false
+	noPressure := all
+	noPressure.AllowSocialPressure = false
+	disabled := all
+	disabled.WeaselModeEnabled = false
+
+	tests := []struct {
+		name        string
+		user        database.User
+		messageType string
+		expected    error
+	}{
+		{"everything allowed", all, database.MessageGuilt, nil},
+		{"guilt off", noGuilt, database.MessageGuilt, ErrNotAllowed},
+		{"guilt off allows urgency", noGuilt, database.MessageUrgency, nil},
+		{"pressure off blocks social", noPressure, database.MessageSocial, ErrNotAllowed},
+		{"pressure off blocks fomo", noPressure, database.MessageFOMO, ErrNotAllowed},
+		{"pressure off allows funny", noPressure, database.MessageFunny, nil},
+		{"disabled blocks everything", disabled, database.MessageFunny, ErrDisabled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Allowed(&tt.user, tt.messageType); !errors.Is(err, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+const testTemplates = `
+templates:
+  - {type: guilt, intensity: gentle, text: "{{.Name}}, {{.DaysSinceWorkout}} days"}
+  - {type: guilt, intensity: aggressive, text: "{{.DaysSinceWorkout}} DAYS, {{.Name}}"}
+  - {type: social, intensity: medium, text: "{{.BuddyName}} is waiting"}
+  - {type: fomo, intensity: gentle, text: "{{.FakePercent}}% trained today"}
+  - {type: urgency, intensity: gentle, text: "{{.Streak}} day streak"}
+`
+
+// newTestEngine returns an engine over a memory store with the given user
+func newTestEngine(t *testing.T, user *database.User) (*Engine, store.Store) {
+	t.Helper()
+	templates, err := ParseTemplates([]byte(testTemplates))
+	if err != nil {
+		t.Fatalf("Failed to parse templates: %v", err)
+	}
+	s := store.NewMemory()
+	if err := s.Users().Create(context.Background(), user); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	e := NewEngine(s, templates, nil, nil, nil, rand.New(rand.NewPCG(1, 2)))
+	e.now = func() time.Time { return time.Date(2025, time.March, 10, 12, 0, 0, 0, time.UTC) }
+	return e, s
+}
+
+func TestGenerate(t *testing.T) {
+	ctx := context.Background()
+	user := &database.User{Email: "sam@example.com", Name: "Sam", WeaselIntensity: database.IntensityFullChaos}
+	e, s := newTestEngine(t, user)
+
+	// No workouts yet, so no template for guilt can be rendered
+	if _, err := e.Generate(ctx, user.ID, database.MessageGuilt); !errors.Is(err, ErrNoTemplate) {
+		t.Errorf("Expected ErrNoTemplate without workout history, got %v", err)
+	}
+
+	workout := &database.Workout{UserID: user.ID, CompletedAt: time.Date(2025, time.March, 7, 20, 0, 0, 0, time.UTC)}
+	if err := s.Workouts().Create(ctx, workout); err != nil {
+		t.Fatalf("Failed to log workout: %v", err)
+	}
+
+	msg, err := e.Generate(ctx, user.ID, database.MessageGuilt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Full chaos has no guilt template, so the next milder one is used
+	if msg.Content != "3 DAYS, Sam" || msg.Intensity != database.IntensityAggressive || msg.SentAt.IsZero() {
+		t.Errorf("Unexpected message %+v", msg)
+	}
+
+	stored, err := s.Messages().List(ctx, user.ID, store.Page{Limit: 10})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(stored) != 1 || stored[0].ID != msg.ID {
+		t.Errorf("Expected the message to be stored, got %+v", stored)
+	}
+}
+
+func TestGenerateRequiresVariables(t *testing.T) {
+	ctx := context.Background()
+	user := &database.User{Email: "sam@example.com", Name: "Sam", WeaselIntensity: database.IntensityMedium}
+	e, s := newTestEngine(t, user)
+
+	tests := []struct {
+		name        string
+		messageType string
+		setup       func()
+	}{
+		{"social needs a buddy", database.MessageSocial, func() {
+			buddy := &database.User{Email: "kim@example.com", Name: "Kim"}
+			if err := s.Users().Create(ctx, buddy); err != nil {
+				t.Fatalf("Failed to create buddy: %v", err)
+			}
+			rel := &database.BuddyRelationship{UserID: buddy.ID, BuddyID: user.ID, RelationshipType: database.RelationshipPeer, Status: database.BuddyActive}
+			if err := s.Buddies().Create(ctx, rel); err != nil {
+				t.Fatalf("Failed to create relationship: %v", err)
+			}
+		}},
+		{"fomo needs fake stats", database.MessageFOMO, func() {
+			if err := s.Users().Update(ctx, user.ID, map[string]any{"allow_fake_stats": true}); err != nil {
+				t.Fatalf("Failed to update user: %v", err)
+			}
+		}},
+		{"urgency needs a streak", database.MessageUrgency, func() {
+			streak := &database.Streak{UserID: user.ID, StreakType: database.StreakWorkout, Current: 4, Longest: 4, IsActive: true}
+			if err := s.Streaks().Save(ctx, streak); err != nil {
+				t.Fatalf("Failed to save streak: %v", err)
+			}
+		}},
+	}
+
+	// Consent flags default to on; fake stats are switched off for the fomo case
+	if err := s.Users().Update(ctx, user.ID, map[string]any{"allow_fake_stats": false}); err != nil {
+		t.Fatalf("Failed to update user: %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := e.Generate(ctx, user.ID, tt.messageType); !errors.Is(err, ErrNoTemplate) {
+				t.Fatalf("Expected ErrNoTemplate before setup, got %v", err)
+			}
+			tt.setup()
+			if _, err := e.Generate(ctx, user.ID, tt.messageType); err != nil {
+				t.Errorf("Expected a message after setup, got %v", err)
+			}
+		})
+	}
+}
+
+func TestSocialNamesOnlyPeers(t *testing.T) {
+	ctx := context.Background()
+	user := &database.User{Email: "sam@example.com", Name: "Sam", WeaselIntensity: database.IntensityMedium}
+	e, s := newTestEngine(t, user)
+
+	link := func(name, relationshipType string) {
+		t.Helper()
+		other := &database.User{Email: strings.ToLower(name) + "@example.com", Name: name}
+		if err := s.Users().Create(ctx, other); err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		rel := &database.BuddyRelationship{UserID: user.ID, BuddyID: other.ID, RelationshipType: relationshipType, Status: database.BuddyActive}
+		if err := s.Buddies().Create(ctx, rel); err != nil {
+			t.Fatalf("Failed to create relationship: %v", err)
+		}
+	}
+
+	link("Kim", database.RelationshipCoach)
+	if _, err := e.Generate(ctx, user.ID, database.MessageSocial); !errors.Is(err, ErrNoTemplate) {
+		t.Fatalf("Expected ErrNoTemplate with only a coach, got %v", err)
+	}
+
+	link("Alex", database.RelationshipPeer)
+	msg, err := e.Generate(ctx, user.ID, database.MessageSocial)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if msg.Content != "Alex is waiting" {
+		t.Errorf("Expected the peer to be named, got %q", msg.Content)
+	}
+}
+
+// failingOutbox rejects every message
+type failingOutbox struct{}
+
+func (failingOutbox) Enqueue(context.Context, store.Store, *database.User, *database.WeaselMessage) error {
+	return errors.New("outbox unavailable")
+}
+
+func TestComposeDiscardsMessageWhenQueueFails(t *testing.T) {
+	ctx := context.Background()
+	user := &database.User{Email: "sam@example.com", Name: "Sam", WeaselIntensity: database.IntensityMedium}
+	e, s := newTestEngine(t, user)
+	e.outbox = failingOutbox{}
+
+	streak := &database.Streak{UserID: user.ID, StreakType: database.StreakWorkout, Current: 4, Longest: 4, IsActive: true}
+	if err := s.Streaks().Save(ctx, streak); err != nil {
+		t.Fatalf("Failed to save streak: %v", err)
+	}
+	if _, err := e.Generate(ctx, user.ID, database.MessageUrgency); err == nil {
+		t.Fatal("Expected the outbox failure to be returned")
+	}
+
+	msgs, err := s.Messages().List(ctx, user.ID, store.Page{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(msgs) != 0 {
+		t.Errorf("Expected the message to be rolled back, got %d stored", len(msgs))
+	}
+}
+
+func TestInsightNeedsSocialPressure(t *testing.T) {
+	ctx := context.Background()
+	user := &database.User{Email: "sam@example.com", Name: "Sam", WeaselIntensity: database.IntensityMedium}
+	e, s := newTestEngine(t, user)
+	templates, err := ParseTemplates([]byte(`templates: [{type: funny, intensity: gentle, text: "{{.Insight}}"}]`))
+	if err != nil {
+		t.Fatalf("Failed to parse templates: %v", err)
+	}
+	e.templates = templates
+
+	alex := &database.User{Email: "alex@example.com", Name: "Alex"}
+	if err := s.Users().Create(ctx, alex); err != nil {
+		t.Fatalf("Failed to create buddy: %v", err)
+	}
+	rel := &database.BuddyRelationship{UserID: user.ID, BuddyID: alex.ID, RelationshipType: database.RelationshipPeer, Status: database.BuddyActive}
+	if err := s.Buddies().Create(ctx, rel); err != nil {
+		t.Fatalf("Failed to create relationship: %v", err)
+	}
+	if err := s.Workouts().Create(ctx, &database.Workout{UserID: alex.ID, CompletedAt: e.now().Add(-time.Hour)}); err != nil {
+		t.Fatalf("Failed to log workout: %v", err)
+	}
+
+	msg, err := e.Generate(ctx, user.ID, database.MessageFunny)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if msg.Content != "You're behind Alex by 1 workout this week." {
+		t.Errorf("Unexpected message %q", msg.Content)
+	}
+
+	// Funny messages are allowed without consent to social pressure, but
+	// comparisons with buddies are not
+	if err := s.Users().Update(ctx, user.ID, map[string]any{"allow_social_pressure": false}); err != nil {
+		t.Fatalf("Failed to update user: %v", err)
+	}
+	if _, err := e.Generate(ctx, user.ID, database.MessageFunny); !errors.Is(err, ErrNoTemplate) {
+		t.Errorf("Expected ErrNoTemplate without social pressure, got %v", err)
+	}
+}
+
+const testExperiments = `
+experiments:
+  - name: guilt-copy
+    message_type: guilt
+    active: true
+    variants:
+      - {name: control, weight: 1}
+      - name: dramatic
+        weight: 3
+        templates:
+          - {intensity: gentle, text: "{{.Name}}. {{.DaysSinceWorkout}} days. Dramatic pause."}
+`
+
+func TestParseExperimentsRejectsInvalid(t *testing.T) {
+	variants := "variants: [{name: a, weight: 1}, {name: b, weight: 1}]"
+	tests := []struct {
+		name          string
+		body          string
+		expectedError string
+	}{
+		{"unknown type", "experiments:\n  - {name: x, message_type: bribery, " + variants + "}\n", `unknown type "bribery"`},
+		{"one variant", "experiments:\n  - {name: x, message_type: funny, variants: [{name: a, weight: 1}]}\n", "need at least two"},
+		{"zero weight", "experiments:\n  - {name: x, message_type: funny, variants: [{name: a, weight: 1}, {name: b}]}\n", "must be positive"},
+		{"duplicate variant", "experiments:\n  - {name: x, message_type: funny, variants: [{name: a, weight: 1}, {name: a, weight: 1}]}\n", "duplicate name"},
+		{"two active", "experiments:\n  - {name: x, message_type: funny, active: true, " + variants + "}\n  - {name: y, message_type: funny, active: true, " + variants + "}\n", "already tests funny"},
+		{"template of another type", "experiments:\n  - name: x\n    message_type: funny\n    variants:\n      - {name: a, weight: 1}\n      - {name: b, weight: 1, templates: [{type: guilt, intensity: gentle, text: hi}]}\n", "experiment tests funny"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseExperiments([]byte(tt.body))
+			if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+				t.Errorf("Expected error containing %q, got %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestAssign(t *testing.T) {
+	experiments, err := ParseExperiments([]byte(testExperiments))
+	if err != nil {
+		t.Fatalf("Failed to parse experiments: %v", err)
+	}
+	x := &experiments[0]
+
+	counts := map[string]int{}
+	for id := uint(1); id <= 4000; id++ {
+		v := x.Assign(id)
+		if again := x.Assign(id); again != v {
+			t.Fatalf("Expected user %d to keep variant %s, got %s", id, v.Name, again.Name)
+		}
+		counts[v.Name]++
+	}
+	// Weighted 1:3, so roughly 1000 and 3000
+	if counts["control"] < 850 || counts["control"] > 1150 {
+		t.Errorf("Expected about a quarter of users in control, got %v", counts)
+	}
+}
+
+func TestComposeRecordsExposure(t *testing.T) {
+	ctx := context.Background()
+	experiments, err := ParseExperiments([]byte(testExperiments))
+	if err != nil {
+		t.Fatalf("Failed to parse experiments: %v", err)
+	}
+	user := &database.User{Email: "sam@example.com", Name: "Sam", WeaselIntensity: database.IntensityGentle}
+	e, s := newTestEngine(t, user)
+	e.experiments = experiments
+	if err := s.Workouts().Create(ctx, &database.Workout{UserID: user.ID, CompletedAt: e.now().Add(-48 * time.Hour)}); err != nil {
+		t.Fatalf("Failed to log workout: %v", err)
+	}
+
+	variant := experiments[0].Assign(user.ID)
+	msg, err := e.Generate(ctx, user.ID, database.MessageGuilt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if msg.Experiment != "guilt-copy" || msg.Variant != variant.Name {
+		t.Errorf("Expected exposure to guilt-copy/%s, got %q/%q", variant.Name, msg.Experiment, msg.Variant)
+	}
+	if (variant.Name == "dramatic") != strings.HasSuffix(msg.Content, "Dramatic pause.") {
+		t.Errorf("Expected %s copy, got %q", variant.Name, msg.Content)
+	}
+
+	// Other message types are not part of the experiment
+	msg, err = e.Generate(ctx, user.ID, database.MessageFOMO)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if msg.Experiment != "" || msg.Variant != "" {
+		t.Errorf("Expected no exposure outside the experiment, got %q/%q", msg.Experiment, msg.Variant)
+	}
+}
+
+func TestConfidenceInterval(t *testing.T) {
+	tests := []struct {
+		successes, trials int64
+		lo, hi            float64
+	}{
+		{0, 0, 0, 1},
+		{0, 10, 0, 0.2775},
+		{50, 100, 0.4038, 0.5962},
+		{10, 10, 0.7225, 1},
+	}
+
+	for _, tt := range tests {
+		lo, hi := ConfidenceInterval(tt.successes, tt.trials)
+		if math.Abs(lo-tt.lo) > 1e-4 || math.Abs(hi-tt.hi) > 1e-4 {
+			t.Errorf("Expected [%.4f, %.4f] for %d/%d, got [%.4f, %.4f]", tt.lo, tt.hi, tt.successes, tt.trials, lo, hi)
+		}
+	}
+}
@@ -77,7 +77,7 @@ export default function WorkoutScreen({ route, navigation }: WorkoutScreenProps)
                 </Text>
             </View>
 
-            <Text style={styles.weightText}>Weight: {exercise.weight}</Text>
+            <Text style={styles.weightText}>Weight: {exercise.weight} {exercise.unit}</Text>
             <Text style={styles.instructionsText}>{exercise.instructions}</Text>
         </View>
     );
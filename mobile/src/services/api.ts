@@ -42,18 +42,53 @@ export interface WorkoutExercise {
   name: string;
   sets: number;
   reps: number;
-  weight: string;
+  weight: number;
+  unit: string;
+  deload: boolean;
+  consecutive_failures: number;
   instructions: string;
 }
 
 export interface NextWorkout {
   program_name: string;
   workout_day: string;
+  session_number: number;
   exercises: WorkoutExercise[];
   estimated_duration: string;
   rest_between_sets: string;
 }
 
+// Enrollment types
+export type EnrollmentStatus = 'active' | 'paused' | 'completed' | 'abandoned';
+
+export interface EnrollmentLift {
+  exercise: string;
+  start_weight: number;
+  working_weight: number;
+  consecutive_failures: number;
+}
+
+export interface Enrollment {
+  id: number;
+  program_id: number;
+  program_name: string;
+  status: EnrollmentStatus;
+  started_at: string;
+  paused_at?: string;
+  ended_at?: string;
+  duration_weeks: number;
+  current_week: number;
+  current_day: number;
+  current_day_name?: string;
+  sessions_completed: number;
+  lifts: EnrollmentLift[];
+}
+
+export interface EnrollRequest {
+  program_id: number;
+  start_weights?: Record<string, number>;
+}
+
 // Workout types
 export interface Exercise {
   name: string;
@@ -201,6 +236,33 @@ class ApiClient {
   async getNextWorkout(programId: number): Promise<ApiResponse<{ status: string; next_workout: NextWorkout }>> {
     return this.request(`/api/programs/${programId}/next-workout`);
   }
+
+  // Enrollment endpoints
+  async enroll(data: EnrollRequest): Promise<ApiResponse<{ status: string; enrollment: Enrollment }>> {
+    return this.request('/api/enrollments', {
+      method: 'POST',
+      body: JSON.stringify(data),
+    });
+  }
+
+  async getCurrentEnrollment(): Promise<ApiResponse<{ status: string; enrollment: Enrollment }>> {
+    return this.request('/api/enrollments/current');
+  }
+
+  async switchProgram(data: EnrollRequest): Promise<ApiResponse<{ status: string; enrollment: Enrollment }>> {
+    return this.request('/api/enrollments/current/switch', {
+      method: 'POST',
+      body: JSON.stringify(data),
+    });
+  }
+
+  async pauseEnrollment(): Promise<ApiResponse<{ status: string; enrollment: Enrollment }>> {
+    return this.request('/api/enrollments/current/pause', { method: 'POST' });
+  }
+
+  async resumeEnrollment(): Promise<ApiResponse<{ status: string; enrollment: Enrollment }>> {
+    return this.request('/api/enrollments/current/resume', { method: 'POST' });
+  }
 }
 
 // Export singleton instance
@@ -81,7 +81,7 @@ async function main() {
   };
 
   // Start backend locally (not in Docker)
-  runCommand('Backend', 'go run cmd/server/main.go', 'backend', '33', backendEnv);
+  runCommand('Backend', 'go run ./cmd/server', 'backend', '33', backendEnv);
 
   // Start mobile (in background, will wait for user to choose platform)
   runCommand('Mobile', 'npm start', 'mobile', '35');
@@ -66,7 +66,7 @@ async function main() {
   console.log('\n🚀 Starting development servers...\n');
 
   // Start backend
-  runCommand('Backend', 'go run cmd/server/main.go', 'backend', '33');
+  runCommand('Backend', 'go run ./cmd/server', 'backend', '33');
 
   // Start mobile (in background, will wait for user to choose platform)
   runCommand('Mobile', 'npm start', 'mobile', '35');
@@ -87,7 +87,7 @@ echo "🎉 Development environment setup complete!"
 echo ""
 echo "📋 Next steps:"
 echo "1. Start the backend server:"
-echo "   cd backend && go run cmd/server/main.go"
+echo "   cd backend && go run ./cmd/server"
 echo ""
 echo "2. Start the mobile app:"
 echo "   cd mobile && npm start"
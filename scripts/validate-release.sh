@@ -82,7 +82,7 @@ for platform in "${PLATFORMS[@]}"; do
     CGO_ENABLED=0 GOOS=$GOOS GOARCH=$GOARCH go build \
         -ldflags="-s -w -X main.version=${VERSION} -X main.commit=${COMMIT} -X main.date=${DATE}" \
         -o bin/${BINARY_NAME} \
-        cmd/server/main.go
+        ./cmd/server
 
     if [ $? -eq 0 ]; then
         print_status "Built ${GOOS}/${GOARCH} successfully"
@@ -44,7 +44,7 @@ if curl -s http://localhost:8080/health > /dev/null 2>&1; then
     fi
 else
     echo "❌ Backend API is not responding"
-    echo "💡 Start with: cd backend && go run cmd/server/main.go"
+    echo "💡 Start with: cd backend && go run ./cmd/server"
 fi
 
 # Check if mobile development server is running
@@ -83,6 +83,6 @@ echo "  Mobile:   $(curl -s http://localhost:8081 > /dev/null 2>&1 || curl -s ht
 echo ""
 echo "📋 Quick Commands:"
 echo "  Database:    ./scripts/dev-setup.sh"
-echo "  Backend:     cd backend && go run cmd/server/main.go"
+echo "  Backend:     cd backend && go run ./cmd/server"
 echo "  Mobile:      cd mobile && npm start"
 echo "  This Check:  ./scripts/verify-setup.sh" 
\ No newline at end of file